@@ -7,7 +7,10 @@ import (
 	"text/tabwriter"
 
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/takeshy/ragujuary/internal/docutil"
 	"github.com/takeshy/ragujuary/internal/embedding"
+	"github.com/takeshy/ragujuary/internal/fileutil"
 	"github.com/takeshy/ragujuary/internal/rag"
 )
 
@@ -17,12 +20,32 @@ var (
 	embedChunkSize    int
 	embedChunkOverlap int
 	embedPDFMaxPages  int
+	embedPDFChapters  bool
+	embedPDFMode      string
 	embedTopK         int
 	embedMinScore     float64
 	embedExclude      []string
+	embedInclude      []string
+	embedNoIgnore     bool
+	embedMIMETypes    []string
+	embedDropHTMLBP   bool
+	embedEncoding     string
+	embedCSVRows      int
 	embedURL          string
 	embedAPIKey       string
 	embedDir          string
+	embedRoots        []string
+	embedIndexFormat  string
+	embedSearchDim    int
+	embedRetry        = embedding.DefaultRetryConfig()
+	embedProvider     string
+	embedAzure        embedding.AzureOptions
+	embedOllama       embedding.OllamaOptions
+	embedOpenAI       embedding.OpenAIOptions
+	embedFallbacks    []string
+	embedQueryPrefix  string
+	embedDocPrefix    string
+	embedTemplates    string
 )
 
 var embedCmd = &cobra.Command{
@@ -30,7 +53,10 @@ var embedCmd = &cobra.Command{
 	Short: "Embedding-based RAG operations (index, query, list, delete, clear)",
 	Long: `Manage a local embedding-based RAG store.
 
-Supports Gemini Embedding API (default) and OpenAI-compatible APIs (Ollama, LM Studio, etc.).
+Supports Gemini Embedding API (default), OpenAI and OpenAI-compatible APIs
+(LM Studio, vLLM, etc.), Azure OpenAI, Voyage AI, Cohere, HuggingFace Text
+Embeddings Inference and Ollama's native API (see 'embed providers'). A store
+records the provider it was built with and keeps using it.
 Unlike the managed FileSearch stores, embedding mode stores vectors locally
 and performs cosine similarity search for retrieval.
 
@@ -39,7 +65,10 @@ Examples:
   ragujuary embed index -s mystore ./docs
 
   # Use Ollama (nomic-embed-text model)
-  ragujuary embed index -s mystore --embed-url http://localhost:11434 --model nomic-embed-text ./docs`,
+  ragujuary embed index -s mystore --embed-provider ollama --model nomic-embed-text ./docs
+
+  # Use an OpenAI-compatible API
+  ragujuary embed index -s mystore --embed-url http://localhost:1234 --model text-embedding-nomic-embed-text-v1.5 ./docs`,
 }
 
 var embedIndexCmd = &cobra.Command{
@@ -47,7 +76,11 @@ var embedIndexCmd = &cobra.Command{
 	Short: "Index files using embeddings",
 	Long: `Index files from directories into a local embedding store.
 Files are chunked, embedded, and stored locally.
-Incremental: only re-indexes files whose content has changed.`,
+Incremental: only re-indexes files whose content has changed.
+
+With --dir, the index is built or updated in place in an arbitrary directory.
+Existing indexes keep their format, so a directory can be shared with other
+RAG tools that use the camelCase layout (filePath, chunkIndex, pageLabel).`,
 	Args: cobra.MinimumNArgs(1),
 	RunE: runEmbedIndex,
 }
@@ -81,25 +114,50 @@ var embedClearCmd = &cobra.Command{
 	RunE:  runEmbedClear,
 }
 
+var embedProvidersCmd = &cobra.Command{
+	Use:   "providers",
+	Short: "List the available embedding providers and their capabilities",
+	Args:  cobra.NoArgs,
+	RunE:  runEmbedProviders,
+}
+
 var embedListStores bool
 var embedDeletePattern string
 
 func init() {
 	// embed command flags
-	embedCmd.PersistentFlags().StringVar(&embedModel, "model", "gemini-embedding-2-preview", "Embedding model name")
-	embedCmd.PersistentFlags().IntVar(&embedDimension, "dimension", 768, "Embedding output dimensionality")
-	embedCmd.PersistentFlags().StringVar(&embedURL, "embed-url", "", "OpenAI-compatible embedding API URL (e.g. http://localhost:11434 for Ollama)")
-	embedCmd.PersistentFlags().StringVar(&embedAPIKey, "embed-api-key", "", "API key for OpenAI-compatible embedding APIs (or set RAGUJUARY_EMBED_API_KEY / OPENAI_API_KEY)")
+	embedCmd.PersistentFlags().StringVar(&embedModel, "model", "", "Embedding model name (default: the provider's default, e.g. gemini-embedding-2-preview)")
+	embedCmd.PersistentFlags().IntVar(&embedDimension, "dimension", 0, "Embedding output dimensionality (default: 768 for gemini, else the model's native dimension)")
+	embedCmd.PersistentFlags().StringVar(&embedURL, "embed-url", "", "Embedding API URL (e.g. http://localhost:1234 for an OpenAI-compatible server)")
+	embedCmd.PersistentFlags().StringVar(&embedAPIKey, "embed-api-key", "", "API key for non-Gemini embedding providers (or set RAGUJUARY_EMBED_API_KEY / the provider's variable, e.g. OPENAI_API_KEY)")
+	embedCmd.PersistentFlags().StringVar(&embedProvider, "embed-provider", "", "Embedding provider (see 'embed providers'; default: the store's provider, else openai with --embed-url, else gemini)")
+	addFallbackFlag(embedCmd.PersistentFlags(), &embedFallbacks)
+	addRetryFlags(embedCmd.PersistentFlags(), &embedRetry)
+	addAzureFlags(embedCmd.PersistentFlags(), &embedAzure)
+	addOllamaFlags(embedCmd.PersistentFlags(), &embedOllama)
+	addOpenAIFlags(embedCmd.PersistentFlags(), &embedOpenAI)
+	embedCmd.PersistentFlags().StringVar(&embedTemplates, "prompt-templates", "", "JSON file of per-model query/document prefixes, added to the built-in table (e.g. {\"my-e5\": {\"query\": \"query: \", \"document\": \"passage: \"}})")
 
 	// index flags
 	embedIndexCmd.Flags().StringSliceVarP(&embedExclude, "exclude", "e", nil, "Regex patterns to exclude files")
+	addDiscoverFlags(embedIndexCmd, &embedInclude, &embedNoIgnore, &embedMIMETypes)
+	embedIndexCmd.Flags().BoolVar(&embedDropHTMLBP, "html-drop-boilerplate", false, "Leave <nav>, <header>, <footer> and <aside> content out of the text extracted from HTML")
+	embedIndexCmd.Flags().StringVar(&embedEncoding, "encoding", "auto", "Character encoding of text files: auto (detect UTF-8, UTF-16, Shift_JIS, EUC-JP and ISO-2022-JP) or an encoding name such as shift_jis, euc-jp or windows-1252")
 	embedIndexCmd.Flags().IntVar(&embedChunkSize, "chunk-size", 1000, "Chunk size in characters")
 	embedIndexCmd.Flags().IntVar(&embedChunkOverlap, "chunk-overlap", 200, "Chunk overlap in characters")
+	embedIndexCmd.Flags().IntVar(&embedCSVRows, "csv-rows", docutil.DefaultCSVRows, "Max CSV rows per chunk; every chunk repeats the header row")
 	embedIndexCmd.Flags().IntVar(&embedPDFMaxPages, "pdf-pages", 6, "Max pages per PDF chunk (1-6)")
+	embedIndexCmd.Flags().BoolVar(&embedPDFChapters, "pdf-chapters", false, "Also start a new PDF chunk at every chapter of the PDF's outline (bookmarks)")
+	embedIndexCmd.Flags().StringVar(&embedPDFMode, "pdf-mode", rag.PDFModeAuto, "How PDFs are embedded: auto (page images where the backend supports them, else text), visual (page images), text (extracted text chunks) or both")
+	embedIndexCmd.Flags().StringVar(&embedDir, "dir", "", "Build or update the index in this directory instead of a named store")
+	embedIndexCmd.Flags().StringVar(&embedIndexFormat, "index-format", "auto", "Index format for new --dir indexes: auto (keep existing, else native), native or external (camelCase)")
+	embedIndexCmd.Flags().StringArrayVar(&embedRoots, "root", nil, "Record a named root NAME=DIR; files under it are stored as NAME:relative/path (can be specified multiple times)")
+	addPromptFlags(embedIndexCmd)
 
 	// query flags
 	embedQueryCmd.Flags().IntVar(&embedTopK, "top-k", 5, "Number of top results to return")
 	embedQueryCmd.Flags().Float64Var(&embedMinScore, "min-score", 0.3, "Minimum similarity score threshold")
+	embedQueryCmd.Flags().IntVar(&embedSearchDim, "search-dimension", 0, "Rank on vectors truncated to this dimension first, then rescore the best candidates at full dimension (Matryoshka models)")
 	embedQueryCmd.Flags().StringVar(&embedDir, "dir", "", "Path to external RAG index directory (overrides --store)")
 
 	// list flags
@@ -113,39 +171,185 @@ func init() {
 	embedCmd.AddCommand(embedListCmd)
 	embedCmd.AddCommand(embedDeleteCmd)
 	embedCmd.AddCommand(embedClearCmd)
+	embedCmd.AddCommand(embedProvidersCmd)
 	rootCmd.AddCommand(embedCmd)
 }
 
-func newEmbeddingClient() (embedding.Client, error) {
-	if embedURL != "" {
-		return embedding.NewOpenAIClient(embedURL, getEmbeddingAPIKey()), nil
+// newEmbeddingClient builds a client of the selected embedding provider:
+// --embed-provider, else stored (the provider the target store was built
+// with), else openai with --embed-url, else gemini. Unless given, the model
+// and dimension become the provider's defaults, and servers that know their
+// models (Ollama, TEI) are asked for one.
+func newEmbeddingClient(stored string) (embedding.Client, error) {
+	provider, err := embedding.SelectProvider(embedProvider, stored, embedURL)
+	if err != nil {
+		return nil, err
 	}
-	key, err := getAPIKey()
+	if embedTemplates != "" {
+		if err := embedding.LoadPromptTemplates(embedTemplates); err != nil {
+			return nil, err
+		}
+	}
+
+	client, err := provider.NewClient(embedding.ProviderOptions{
+		URL:    embedURL,
+		APIKey: getEmbeddingAPIKey(provider),
+		Azure:  embedAzure,
+		Ollama: embedOllama,
+		OpenAI: embedOpenAI,
+		Retry:  &embedRetry,
+	})
 	if err != nil {
 		return nil, err
 	}
-	return embedding.NewGeminiClient(key), nil
+	embedProvider = provider.Name
+
+	flags := embedCmd.PersistentFlags()
+	if !flags.Changed("dimension") {
+		embedDimension = provider.DefaultDimension
+	}
+	if !flags.Changed("model") {
+		embedModel = provider.DefaultModel
+	}
+	if resolver, ok := client.(embedding.ModelResolver); ok {
+		model, err := resolver.ResolveModel(embedModel)
+		if err != nil {
+			if flags.Changed("model") {
+				return nil, err
+			}
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		} else {
+			embedModel = model
+		}
+	}
+
+	fallbacks, err := parseFallbacks(embedFallbacks)
+	if err != nil {
+		return nil, err
+	}
+	return embedding.NewFallbackChain(client, provider.Name, fallbacks, embedding.ProviderOptions{
+		Azure:  embedAzure,
+		Ollama: embedOllama,
+		OpenAI: embedOpenAI,
+		Retry:  &embedRetry,
+	})
+}
+
+// addFallbackFlag registers --embed-fallback
+func addFallbackFlag(flags *pflag.FlagSet, specs *[]string) {
+	flags.StringArrayVar(specs, "embed-fallback", nil, "Fail over to this backend when the provider is unavailable: PROVIDER[,url=URL][,key=KEY][,model=MODEL] (repeatable, tried in order; must serve the same model)")
 }
 
-func getEmbeddingAPIKey() string {
-	if embedAPIKey != "" {
-		return embedAPIKey
+// parseFallbacks parses --embed-fallback values. A Gemini fallback without
+// a key uses --api-key.
+func parseFallbacks(values []string) ([]embedding.FallbackSpec, error) {
+	specs := make([]embedding.FallbackSpec, 0, len(values))
+	for _, value := range values {
+		spec, err := embedding.ParseFallbackSpec(value)
+		if err != nil {
+			return nil, err
+		}
+		if spec.Provider == "gemini" && spec.APIKey == "" {
+			spec.APIKey = apiKey
+		}
+		specs = append(specs, spec)
+	}
+	return specs, nil
+}
+
+// storeProvider returns the provider the target store (--dir or the named
+// store) was built with, or "" if it has none recorded
+func storeProvider(name string) string {
+	var index *rag.RagIndex
+	if embedDir != "" {
+		index, _ = rag.LoadIndexMetadataFromDir(embedDir)
+	} else {
+		index, _ = rag.LoadIndexMetadata(name)
 	}
-	if key := os.Getenv("RAGUJUARY_EMBED_API_KEY"); key != "" {
-		return key
+	if index == nil {
+		return ""
+	}
+	return index.Provider
+}
+
+// addPromptFlags registers the flags that override the prompt-template table
+func addPromptFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&embedQueryPrefix, "query-prefix", "", "Prefix for queries, stored in the index (default: from the model's prompt template)")
+	cmd.Flags().StringVar(&embedDocPrefix, "document-prefix", "", "Prefix for indexed chunks, stored in the index (default: from the model's prompt template)")
+}
+
+// promptTemplateFromFlags returns the template given by --query-prefix and
+// --document-prefix, or nil when neither was set
+func promptTemplateFromFlags(cmd *cobra.Command) *embedding.PromptTemplate {
+	if !cmd.Flags().Changed("query-prefix") && !cmd.Flags().Changed("document-prefix") {
+		return nil
 	}
-	return os.Getenv("OPENAI_API_KEY")
+	return &embedding.PromptTemplate{Query: embedQueryPrefix, Document: embedDocPrefix}
+}
+
+// addAzureFlags registers the request settings of the Azure OpenAI provider
+func addAzureFlags(flags *pflag.FlagSet, opts *embedding.AzureOptions) {
+	flags.StringVar(&opts.Deployment, "azure-deployment", "", "Azure OpenAI deployment name (default: the model name)")
+	flags.StringVar(&opts.APIVersion, "azure-api-version", embedding.DefaultAzureAPIVersion, "Azure OpenAI api-version")
+}
+
+// addOllamaFlags registers the request options of the native Ollama provider
+func addOllamaFlags(flags *pflag.FlagSet, opts *embedding.OllamaOptions) {
+	flags.StringVar(&opts.KeepAlive, "ollama-keep-alive", "", "How long Ollama keeps the model loaded after a request (e.g. 10m, -1m = forever)")
+	flags.BoolVar(&opts.NoTruncate, "ollama-no-truncate", false, "Fail instead of truncating inputs longer than the Ollama model's context")
+}
+
+// addOpenAIFlags registers the settings of the openai provider
+func addOpenAIFlags(flags *pflag.FlagSet, opts *embedding.OpenAIOptions) {
+	flags.StringVar(&opts.ImageInput, "openai-image-input", "none", "Embed images with an OpenAI-compatible CLIP-style server: none, input (data: URIs in input, e.g. Infinity) or messages (chat-style image_url parts, e.g. vLLM)")
+}
+
+// addRetryFlags registers the retry and rate-limit flags of embedding API calls
+func addRetryFlags(flags *pflag.FlagSet, cfg *embedding.RetryConfig) {
+	flags.IntVar(&cfg.MaxRetries, "max-retries", cfg.MaxRetries, "Retries for failed embedding API calls (429/5xx and network errors)")
+	flags.DurationVar(&cfg.BaseDelay, "retry-delay", cfg.BaseDelay, "Backoff before the first retry; doubles on each retry, with jitter")
+	flags.DurationVar(&cfg.MaxDelay, "max-retry-wait", cfg.MaxDelay, "Longest single wait between retries, including Retry-After")
+	flags.IntVar(&cfg.RequestsPerMinute, "requests-per-minute", 0, "Limit embedding API requests per minute (0 = unlimited)")
+	flags.IntVar(&cfg.TokensPerMinute, "tokens-per-minute", 0, "Limit estimated embedding input tokens per minute (0 = unlimited)")
+	flags.IntVar(&cfg.BreakerThreshold, "breaker-failures", cfg.BreakerThreshold, "Consecutive failures after which a fallback backend or API key is skipped")
+	flags.DurationVar(&cfg.BreakerCooldown, "breaker-cooldown", cfg.BreakerCooldown, "How long a failing fallback backend or API key is skipped")
+}
+
+// getEmbeddingAPIKey returns the API key for provider: --api-key for
+// Gemini, --embed-api-key for the others, else the provider's environment
+// variables
+func getEmbeddingAPIKey(provider embedding.Provider) string {
+	return providerAPIKey(provider, embedAPIKey)
+}
+
+func providerAPIKey(provider embedding.Provider, flagKey string) string {
+	if provider.Name == "gemini" {
+		flagKey = apiKey
+	}
+	if flagKey != "" {
+		return flagKey
+	}
+	return provider.EnvAPIKey()
 }
 
 func newEmbedConfig() rag.Config {
 	config := rag.DefaultConfig()
 	config.Model = embedModel
+	config.Provider = embedProvider
 	config.Dimension = embedDimension
 	config.ChunkSize = embedChunkSize
 	config.ChunkOverlap = embedChunkOverlap
 	config.PDFMaxPages = embedPDFMaxPages
+	config.PDFChapters = embedPDFChapters
+	config.PDFMode = embedPDFMode
 	config.TopK = embedTopK
 	config.MinScore = embedMinScore
+	config.SearchDimension = embedSearchDim
+	config.Include = embedInclude
+	config.NoIgnore = embedNoIgnore
+	config.DropHTMLBoilerplate = embedDropHTMLBP
+	config.Encoding = embedEncoding
+	config.CSVRows = embedCSVRows
 	return config
 }
 
@@ -154,18 +358,64 @@ func runEmbedIndex(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("--pdf-pages must be between 1 and 6, got %d", embedPDFMaxPages)
 	}
 
-	client, err := newEmbeddingClient()
+	// Reuse the provider, model and dimension of an existing index unless
+	// explicitly overridden; switching any of them is done with 'embed migrate'
+	var existing *rag.RagIndex
+	if embedDir != "" {
+		existing, _ = rag.LoadIndexMetadataFromDir(embedDir)
+	} else {
+		existing, _ = rag.LoadIndexMetadata(storeName)
+	}
+	stored := ""
+	if existing != nil {
+		stored = existing.Provider
+	}
+	client, err := newEmbeddingClient(stored)
 	if err != nil {
 		return err
 	}
 
 	engine := rag.NewEngine(client)
 	config := newEmbedConfig()
+	for _, spec := range embedRoots {
+		name, dir, err := rag.ParseRoot(spec)
+		if err != nil {
+			return err
+		}
+		if config.Roots == nil {
+			config.Roots = make(map[string]string)
+		}
+		config.Roots[name] = dir
+	}
+	config.IndexFormat, err = rag.ParseIndexFormat(embedIndexFormat)
+	if err != nil {
+		return err
+	}
+	config.MIMETypes, err = fileutil.ParseMIMETypes(embedMIMETypes)
+	if err != nil {
+		return err
+	}
 
-	fmt.Fprintf(os.Stderr, "Indexing files into store '%s' (model: %s, dimension: %d, chunk: %d/%d)...\n",
-		storeName, config.Model, config.Dimension, config.ChunkSize, config.ChunkOverlap)
+	if existing != nil {
+		if !cmd.Flags().Changed("model") && existing.EmbeddingModel != "" {
+			config.Model = strings.TrimPrefix(existing.EmbeddingModel, "models/")
+		}
+		if !cmd.Flags().Changed("dimension") && existing.Dimension > 0 {
+			config.Dimension = existing.Dimension
+		}
+	}
+	config.PromptTemplate = promptTemplateFromFlags(cmd)
 
-	result, err := engine.Index(args, embedExclude, storeName, config)
+	var result *rag.IndexResult
+	if embedDir != "" {
+		fmt.Fprintf(os.Stderr, "Indexing files into '%s' (provider: %s, model: %s, dimension: %d, chunk: %d/%d)...\n",
+			embedDir, config.Provider, config.Model, config.Dimension, config.ChunkSize, config.ChunkOverlap)
+		result, err = engine.IndexDir(args, embedExclude, embedDir, config)
+	} else {
+		fmt.Fprintf(os.Stderr, "Indexing files into store '%s' (provider: %s, model: %s, dimension: %d, chunk: %d/%d)...\n",
+			storeName, config.Provider, config.Model, config.Dimension, config.ChunkSize, config.ChunkOverlap)
+		result, err = engine.Index(args, embedExclude, storeName, config)
+	}
 	if err != nil {
 		return err
 	}
@@ -175,19 +425,49 @@ func runEmbedIndex(cmd *cobra.Command, args []string) error {
 	fmt.Printf("  New files:     %d\n", result.NewFiles)
 	fmt.Printf("  Updated files: %d\n", result.UpdatedFiles)
 	fmt.Printf("  Skipped files: %d\n", result.SkippedFiles)
+	if result.RenamedFiles > 0 {
+		fmt.Printf("  Renamed files: %d\n", result.RenamedFiles)
+		for _, r := range result.Renames {
+			fmt.Printf("    %s -> %s\n", r.From, r.To)
+		}
+	}
 	if result.MultimodalFiles > 0 {
 		fmt.Printf("  Multimodal:    %d\n", result.MultimodalFiles)
 	}
 	if result.SkippedMultimodal > 0 {
 		fmt.Printf("  Skipped (multimodal): %d\n", result.SkippedMultimodal)
 	}
+	if result.SkippedBinary > 0 {
+		fmt.Printf("  Skipped (binary): %d\n", result.SkippedBinary)
+	}
+	if result.FailedChunks > 0 {
+		fmt.Printf("  Failed chunks: %d (their files will be retried on the next run)\n", result.FailedChunks)
+	}
 	fmt.Printf("  Total chunks:  %d\n", result.TotalChunks)
+	printBackendStatus(client)
 
 	return nil
 }
 
+// printBackendStatus shows how many requests each backend of a fallback
+// chain served and which ones are being skipped
+func printBackendStatus(client embedding.Client) {
+	chain, ok := client.(*embedding.FallbackClient)
+	if !ok {
+		return
+	}
+	fmt.Printf("  Backends:\n")
+	for _, b := range chain.Status() {
+		state := ""
+		if b.Open {
+			state = fmt.Sprintf(" (skipped after %d failures)", b.Failures)
+		}
+		fmt.Printf("    %s: %d requests%s\n", b.Name, b.Served, state)
+	}
+}
+
 func runEmbedQuery(cmd *cobra.Command, args []string) error {
-	client, err := newEmbeddingClient()
+	client, err := newEmbeddingClient(storeProvider(storeName))
 	if err != nil {
 		return err
 	}
@@ -214,7 +494,9 @@ func runEmbedQuery(cmd *cobra.Command, args []string) error {
 
 	for i, r := range results {
 		typeLabel := ""
-		if r.ContentType != "" {
+		if r.Representation != "" {
+			typeLabel = fmt.Sprintf(" [pdf %s]", r.Representation)
+		} else if r.ContentType != "" {
 			typeLabel = fmt.Sprintf(" [%s]", r.ContentType)
 		}
 		pageInfo := ""
@@ -286,8 +568,12 @@ func runEmbedList(cmd *cobra.Command, args []string) error {
 	}
 	w.Flush()
 
+	model := index.EmbeddingModel
+	if index.Provider != "" {
+		model = index.Provider + "/" + model
+	}
 	fmt.Printf("\nTotal: %d files, %d chunks (model: %s, dimension: %d)\n",
-		len(chunkCounts), len(index.Meta), index.EmbeddingModel, index.Dimension)
+		len(chunkCounts), len(index.Meta), model, index.Dimension)
 
 	return nil
 }
@@ -297,12 +583,8 @@ func runEmbedDelete(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("--pattern is required")
 	}
 
-	client, err := newEmbeddingClient()
-	if err != nil {
-		return err
-	}
-
-	engine := rag.NewEngine(client)
+	// Deleting re-embeds nothing
+	engine := rag.NewEngine(nil)
 
 	deleted, err := engine.DeleteFiles(storeName, embedDeletePattern)
 	if err != nil {
@@ -318,6 +600,33 @@ func runEmbedDelete(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
+func runEmbedProviders(cmd *cobra.Command, args []string) error {
+	yesNo := func(b bool) string {
+		if b {
+			return "yes"
+		}
+		return "no"
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "PROVIDER\tMULTIMODAL\tTASK TYPES\tDIMENSIONS\tMAX BATCH\tDEFAULT MODEL\tDESCRIPTION")
+	for _, name := range embedding.ProviderNames() {
+		p, _ := embedding.LookupProvider(name)
+		caps := p.Capabilities
+		maxBatch := "-"
+		if caps.MaxBatch > 0 {
+			maxBatch = fmt.Sprintf("%d", caps.MaxBatch)
+		}
+		model := p.DefaultModel
+		if model == "" {
+			model = "(from server)"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			p.Name, yesNo(caps.Multimodal), yesNo(caps.TaskTypes), yesNo(caps.Dimensions), maxBatch, model, p.Description)
+	}
+	return w.Flush()
+}
+
 func runEmbedClear(cmd *cobra.Command, args []string) error {
 	name := storeName
 	if len(args) > 0 {
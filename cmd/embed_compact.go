@@ -0,0 +1,59 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/takeshy/ragujuary/internal/rag"
+)
+
+var embedCompactCmd = &cobra.Command{
+	Use:   "compact [store-name]",
+	Short: "Shrink a store's vectors to a lower dimension without re-embedding",
+	Long: `Permanently shrink the vectors of a store to a lower dimension by truncating
+and renormalizing them (Matryoshka truncation). No embedding API calls are made.
+
+Only use this with models that support Matryoshka-style truncation, such as the
+Gemini embedding models (128-3072 dimensions) or OpenAI text-embedding-3-*.
+Queries and later indexing runs use the new dimension.
+
+To try a lower dimension without changing the store, use
+'embed query --search-dimension' instead.
+
+Examples:
+  ragujuary embed compact mystore --dimension 256
+  ragujuary embed compact --dir ./rag-index --dimension 512`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runEmbedCompact,
+}
+
+func init() {
+	embedCompactCmd.Flags().StringVar(&embedDir, "dir", "", "Compact the index in this directory instead of a named store")
+	embedCmd.AddCommand(embedCompactCmd)
+}
+
+func runEmbedCompact(cmd *cobra.Command, args []string) error {
+	if !cmd.Flags().Changed("dimension") {
+		return fmt.Errorf("specify the target --dimension")
+	}
+
+	var result *rag.CompactResult
+	var err error
+	if embedDir != "" {
+		result, err = rag.CompactDir(embedDir, embedDimension)
+	} else {
+		name := storeName
+		if len(args) > 0 {
+			name = args[0]
+		}
+		result, err = rag.Compact(name, embedDimension)
+	}
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Compacted %d chunks from %d to %d dimensions (vectors are %.0f%% of their previous size).\n",
+		result.Chunks, result.FromDimension, result.ToDimension,
+		100*float64(result.ToDimension)/float64(result.FromDimension))
+	return nil
+}
@@ -0,0 +1,127 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/takeshy/ragujuary/internal/rag"
+)
+
+var (
+	embedExportOutput   string
+	embedImportRewrites []string
+	embedImportForce    bool
+)
+
+var embedExportCmd = &cobra.Command{
+	Use:   "export [store-name]",
+	Short: "Export an embedding store as a portable archive",
+	Long: `Export an embedding store (index, vectors, config and a manifest with
+model, dimension and checksums) as a zstd-compressed tar archive.
+
+Examples:
+  ragujuary embed export mystore -o mystore.tar.zst
+  ragujuary embed export mystore -o - | ssh host 'ragujuary embed import -'`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runEmbedExport,
+}
+
+var embedImportCmd = &cobra.Command{
+	Use:   "import [archive]",
+	Short: "Import an embedding store from a portable archive",
+	Long: `Import an archive created by 'embed export'. The store name defaults to
+the one recorded in the archive; use --store to install it under another name.
+
+Absolute file paths recorded at index time can be remapped to the local
+checkout with --rewrite-prefix, so incremental re-indexing keeps working:
+
+  ragujuary embed import store.tar.zst --rewrite-prefix /ci/build/repo=$HOME/src/repo`,
+	Args: cobra.ExactArgs(1),
+	RunE: runEmbedImport,
+}
+
+func init() {
+	embedExportCmd.Flags().StringVarP(&embedExportOutput, "output", "o", "", "Output archive path, or - for stdout (default: <store>.tar.zst)")
+
+	embedImportCmd.Flags().StringArrayVar(&embedImportRewrites, "rewrite-prefix", nil, "Rewrite file path prefix OLD=NEW (can be specified multiple times)")
+	embedImportCmd.Flags().BoolVarP(&embedImportForce, "force", "f", false, "Overwrite the store if it already exists")
+
+	embedCmd.AddCommand(embedExportCmd)
+	embedCmd.AddCommand(embedImportCmd)
+}
+
+func runEmbedExport(cmd *cobra.Command, args []string) error {
+	name := storeName
+	if len(args) > 0 {
+		name = args[0]
+	}
+
+	output := embedExportOutput
+	if output == "" {
+		output = name + ".tar.zst"
+	}
+
+	var w io.Writer = os.Stdout
+	if output != "-" {
+		f, err := os.Create(output)
+		if err != nil {
+			return fmt.Errorf("failed to create %s: %w", output, err)
+		}
+		defer f.Close()
+		w = f
+	}
+
+	manifest, err := rag.ExportStore(name, w)
+	if err != nil {
+		if output != "-" {
+			os.Remove(output)
+		}
+		return err
+	}
+
+	dest := output
+	if output == "-" {
+		dest = "stdout"
+	}
+	fmt.Fprintf(os.Stderr, "Exported store '%s' to %s (model: %s, dimension: %d, files: %d, chunks: %d)\n",
+		name, dest, manifest.EmbeddingModel, manifest.Dimension, manifest.Files, manifest.Chunks)
+	return nil
+}
+
+func runEmbedImport(cmd *cobra.Command, args []string) error {
+	opts := rag.ImportOptions{Overwrite: embedImportForce}
+	for _, spec := range embedImportRewrites {
+		rw, err := rag.ParsePathRewrite(spec)
+		if err != nil {
+			return err
+		}
+		opts.Rewrites = append(opts.Rewrites, rw)
+	}
+
+	var r io.Reader = os.Stdin
+	if args[0] != "-" {
+		f, err := os.Open(args[0])
+		if err != nil {
+			return fmt.Errorf("failed to open %s: %w", args[0], err)
+		}
+		defer f.Close()
+		r = f
+	}
+
+	// Only override the archive's store name when --store / RAGUJUARY_STORE was given
+	name := ""
+	if cmd.Flags().Changed("store") || os.Getenv("RAGUJUARY_STORE") != "" {
+		name = storeName
+	}
+
+	manifest, err := rag.ImportStore(r, name, opts)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Imported store '%s' (model: %s, dimension: %d, files: %d, chunks: %d)\n",
+		manifest.StoreName, manifest.EmbeddingModel, manifest.Dimension, manifest.Files, manifest.Chunks)
+	return nil
+}
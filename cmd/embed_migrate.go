@@ -0,0 +1,114 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/takeshy/ragujuary/internal/rag"
+)
+
+var embedMigrateRestart bool
+
+var embedMigrateCmd = &cobra.Command{
+	Use:   "migrate [store-name]",
+	Short: "Re-embed a store with a different provider, model, dimension or prompt template",
+	Long: `Re-embed every chunk of a store with a new embedding provider, model,
+dimension and/or prompt template (query/document prefixes).
+
+Text chunks are re-embedded from the text stored in the index; images, PDFs,
+audio and video are re-embedded from their source files. The new index is built
+next to the store, so queries keep using the old one until the migration
+finishes and the new index is swapped in.
+
+If the migration is interrupted, running the same command again resumes it.
+
+Examples:
+  ragujuary embed migrate mystore --model gemini-embedding-001 --dimension 1536
+  ragujuary embed migrate mystore --dimension 256
+  ragujuary embed migrate mystore --embed-provider ollama --model nomic-embed-text
+  ragujuary embed migrate mystore --embed-provider voyage --model voyage-3.5
+  ragujuary embed migrate mystore --query-prefix "query: " --document-prefix "passage: "`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runEmbedMigrate,
+}
+
+func init() {
+	embedMigrateCmd.Flags().BoolVar(&embedMigrateRestart, "restart", false, "Discard an interrupted migration and start over")
+	addPromptFlags(embedMigrateCmd)
+	embedCmd.AddCommand(embedMigrateCmd)
+}
+
+func runEmbedMigrate(cmd *cobra.Command, args []string) error {
+	name := storeName
+	if len(args) > 0 {
+		name = args[0]
+	}
+	template := promptTemplateFromFlags(cmd)
+	if !cmd.Flags().Changed("model") && !cmd.Flags().Changed("dimension") && !cmd.Flags().Changed("embed-provider") && template == nil {
+		return fmt.Errorf("specify the target --embed-provider, --model, --dimension and/or --query-prefix/--document-prefix")
+	}
+
+	index, _, err := rag.LoadIndex(name)
+	if err != nil {
+		return err
+	}
+	if index == nil {
+		return fmt.Errorf("store '%s' not found", name)
+	}
+
+	client, err := newEmbeddingClient(index.Provider)
+	if err != nil {
+		return err
+	}
+	engine := rag.NewEngine(client)
+
+	// Unspecified settings stay as they are in the store; a new provider
+	// starts from its own default model and dimension
+	config := newEmbedConfig()
+	sameProvider := index.Provider == "" || index.Provider == config.Provider
+	if !cmd.Flags().Changed("model") && sameProvider {
+		config.Model = strings.TrimPrefix(index.EmbeddingModel, "models/")
+	}
+	if !cmd.Flags().Changed("dimension") && sameProvider {
+		config.Dimension = index.Dimension
+	}
+	config.PromptTemplate = template
+
+	verb := "Migrating"
+	if rag.MigrationPending(name) && !embedMigrateRestart {
+		verb = "Resuming migration of"
+	}
+	fmt.Fprintf(os.Stderr, "%s store '%s' (%s/%d -> %s/%d, %d chunks)...\n",
+		verb, name, index.EmbeddingModel, index.Dimension, config.Model, config.Dimension, len(index.Meta))
+
+	result, err := engine.Migrate(name, config, rag.MigrateOptions{
+		Restart: embedMigrateRestart,
+		Progress: func(done, total int) {
+			fmt.Fprintf(os.Stderr, "  %d/%d chunks\n", done, total)
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Migration complete:\n")
+	if !sameProvider {
+		fmt.Printf("  Provider:      %s -> %s\n", index.Provider, config.Provider)
+	}
+	fmt.Printf("  Model:         %s -> %s\n", result.FromModel, result.ToModel)
+	fmt.Printf("  Dimension:     %d -> %d\n", result.FromDimension, result.ToDimension)
+	fmt.Printf("  Total chunks:  %d\n", result.TotalChunks)
+	fmt.Printf("  Embedded:      %d\n", result.EmbeddedChunks)
+	if result.ResumedChunks > 0 {
+		fmt.Printf("  Resumed:       %d\n", result.ResumedChunks)
+	}
+	if len(result.DroppedFiles) > 0 {
+		fmt.Printf("  Dropped files: %d (re-run embed index to add them again)\n", len(result.DroppedFiles))
+		for _, path := range result.DroppedFiles {
+			fmt.Printf("    %s\n", path)
+		}
+	}
+	return nil
+}
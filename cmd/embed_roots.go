@@ -0,0 +1,134 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"github.com/takeshy/ragujuary/internal/rag"
+)
+
+var embedRootsCmd = &cobra.Command{
+	Use:   "roots",
+	Short: "List the named roots of an embedding store",
+	Long: `Named roots let a store record file paths relative to a directory
+("docs:guide/intro.md") instead of absolute paths, so the store keeps
+working after the repository is moved or checked out elsewhere.
+
+Examples:
+  # Show roots
+  ragujuary embed roots -s mystore
+
+  # Migrate an absolute-path store: paths under the directory become repo:...
+  ragujuary embed roots set -s mystore repo=/home/me/src/repo
+
+  # The repository moved: point the root at the new location (no re-embedding)
+  ragujuary embed roots set -s mystore repo=/srv/checkout/repo`,
+	Args: cobra.NoArgs,
+	RunE: runEmbedRoots,
+}
+
+var embedRootsSetCmd = &cobra.Command{
+	Use:   "set NAME=DIR...",
+	Short: "Add or move named roots, converting absolute paths under them",
+	Args:  cobra.MinimumNArgs(1),
+	RunE:  runEmbedRootsSet,
+}
+
+var embedRootsRemoveCmd = &cobra.Command{
+	Use:   "remove NAME...",
+	Short: "Remove named roots, converting their paths back to absolute paths",
+	Args:  cobra.MinimumNArgs(1),
+	RunE:  runEmbedRootsRemove,
+}
+
+func init() {
+	embedRootsCmd.AddCommand(embedRootsSetCmd)
+	embedRootsCmd.AddCommand(embedRootsRemoveCmd)
+	embedCmd.AddCommand(embedRootsCmd)
+}
+
+func loadStoreForUpdate() (*rag.RagIndex, []float32, error) {
+	index, vectors, err := rag.LoadIndex(storeName)
+	if err != nil {
+		return nil, nil, err
+	}
+	if index == nil {
+		return nil, nil, fmt.Errorf("store '%s' not found", storeName)
+	}
+	return index, vectors, nil
+}
+
+func runEmbedRoots(cmd *cobra.Command, args []string) error {
+	index, _, err := loadStoreForUpdate()
+	if err != nil {
+		return err
+	}
+
+	if len(index.Roots) == 0 {
+		fmt.Printf("Store '%s' has no roots (file paths are stored as-is).\n", storeName)
+		return nil
+	}
+
+	names := make([]string, 0, len(index.Roots))
+	for name := range index.Roots {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "ROOT\tDIRECTORY\n")
+	for _, name := range names {
+		fmt.Fprintf(w, "%s\t%s\n", name, index.Roots[name])
+	}
+	w.Flush()
+	return nil
+}
+
+func runEmbedRootsSet(cmd *cobra.Command, args []string) error {
+	unlock, err := rag.LockStore(storeName, "embed roots set")
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	index, vectors, err := loadStoreForUpdate()
+	if err != nil {
+		return err
+	}
+
+	for _, spec := range args {
+		name, dir, err := rag.ParseRoot(spec)
+		if err != nil {
+			return err
+		}
+		rewritten := index.SetRoot(name, dir)
+		fmt.Printf("Root '%s' -> %s (%d entries converted to relative paths)\n", name, dir, rewritten)
+	}
+
+	return rag.SaveIndex(storeName, index, vectors)
+}
+
+func runEmbedRootsRemove(cmd *cobra.Command, args []string) error {
+	unlock, err := rag.LockStore(storeName, "embed roots remove")
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	index, vectors, err := loadStoreForUpdate()
+	if err != nil {
+		return err
+	}
+
+	for _, name := range args {
+		if err := index.RemoveRoot(name); err != nil {
+			return err
+		}
+		fmt.Printf("Removed root '%s'\n", name)
+	}
+
+	return rag.SaveIndex(storeName, index, vectors)
+}
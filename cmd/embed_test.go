@@ -1,6 +1,19 @@
 package cmd
 
-import "testing"
+import (
+	"testing"
+
+	"github.com/takeshy/ragujuary/internal/embedding"
+)
+
+func lookupProvider(t *testing.T, name string) embedding.Provider {
+	t.Helper()
+	provider, err := embedding.LookupProvider(name)
+	if err != nil {
+		t.Fatalf("LookupProvider(%q) error = %v", name, err)
+	}
+	return provider
+}
 
 func TestGetEmbeddingAPIKey(t *testing.T) {
 	original := embedAPIKey
@@ -11,17 +24,39 @@ func TestGetEmbeddingAPIKey(t *testing.T) {
 	embedAPIKey = ""
 	t.Setenv("RAGUJUARY_EMBED_API_KEY", "ragu-key")
 	t.Setenv("OPENAI_API_KEY", "openai-key")
+	openai := lookupProvider(t, "openai")
 
-	if got := getEmbeddingAPIKey(); got != "ragu-key" {
+	if got := getEmbeddingAPIKey(openai); got != "ragu-key" {
 		t.Fatalf("getEmbeddingAPIKey() = %q, want %q", got, "ragu-key")
 	}
 
 	embedAPIKey = "flag-key"
-	if got := getEmbeddingAPIKey(); got != "flag-key" {
+	if got := getEmbeddingAPIKey(openai); got != "flag-key" {
 		t.Fatalf("getEmbeddingAPIKey() with flag = %q, want %q", got, "flag-key")
 	}
 }
 
+func TestGetEmbeddingAPIKeyForGemini(t *testing.T) {
+	originalEmbed, originalRoot := embedAPIKey, apiKey
+	t.Cleanup(func() {
+		embedAPIKey, apiKey = originalEmbed, originalRoot
+	})
+
+	// --embed-api-key is for the other providers; Gemini uses --api-key
+	embedAPIKey = "flag-key"
+	apiKey = ""
+	t.Setenv("GEMINI_API_KEY", "gemini-key")
+	gemini := lookupProvider(t, "gemini")
+
+	if got := getEmbeddingAPIKey(gemini); got != "gemini-key" {
+		t.Fatalf("getEmbeddingAPIKey(gemini) = %q, want %q", got, "gemini-key")
+	}
+	apiKey = "root-key"
+	if got := getEmbeddingAPIKey(gemini); got != "root-key" {
+		t.Fatalf("getEmbeddingAPIKey(gemini) with --api-key = %q, want %q", got, "root-key")
+	}
+}
+
 func TestGetServeEmbeddingAPIKey(t *testing.T) {
 	original := serveEmbedAPIKey
 	t.Cleanup(func() {
@@ -31,13 +66,14 @@ func TestGetServeEmbeddingAPIKey(t *testing.T) {
 	serveEmbedAPIKey = ""
 	t.Setenv("RAGUJUARY_EMBED_API_KEY", "")
 	t.Setenv("OPENAI_API_KEY", "openai-key")
+	openai := lookupProvider(t, "openai")
 
-	if got := getServeEmbeddingAPIKey(); got != "openai-key" {
+	if got := getServeEmbeddingAPIKey(openai); got != "openai-key" {
 		t.Fatalf("getServeEmbeddingAPIKey() = %q, want %q", got, "openai-key")
 	}
 
 	serveEmbedAPIKey = "flag-key"
-	if got := getServeEmbeddingAPIKey(); got != "flag-key" {
+	if got := getServeEmbeddingAPIKey(openai); got != "flag-key" {
 		t.Fatalf("getServeEmbeddingAPIKey() with flag = %q, want %q", got, "flag-key")
 	}
 }
@@ -0,0 +1,164 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/takeshy/ragujuary/internal/rag"
+)
+
+var (
+	embedVerifyFix        bool
+	embedVerifySkipFiles  bool
+	embedVerifySkipSample bool
+	embedVerifyVerbose    bool
+)
+
+// maxIssuesPerKind limits how many issues of one kind are printed without --verbose
+const maxIssuesPerKind = 10
+
+var embedVerifyCmd = &cobra.Command{
+	Use:   "verify [store-name]",
+	Short: "Check an embedding store for integrity problems",
+	Long: `Check an embedding store for integrity problems:
+
+  - vector count matches chunk count × dimension
+  - NaN/Inf, all-zero and non-normalized vectors
+  - duplicate chunks
+  - checksum entries with no chunks, and chunks with no checksum
+  - recorded checksums against the files on disk
+  - model/dimension drift, by re-embedding a sample chunk
+
+With --fix, problems that can be repaired without re-embedding are fixed in place:
+bad vectors and duplicates are dropped (their files are re-embedded on the next
+'embed index'), vectors are renormalized, and orphan checksums are removed.
+
+Exits with an error if unresolved problems remain.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runEmbedVerify,
+}
+
+func init() {
+	embedVerifyCmd.Flags().BoolVar(&embedVerifyFix, "fix", false, "Repair problems that can be fixed safely")
+	embedVerifyCmd.Flags().BoolVar(&embedVerifySkipFiles, "skip-files", false, "Don't compare checksums against files on disk")
+	embedVerifyCmd.Flags().BoolVar(&embedVerifySkipSample, "skip-sample", false, "Don't re-embed a sample chunk (no API call)")
+	embedVerifyCmd.Flags().BoolVarP(&embedVerifyVerbose, "verbose", "v", false, "List every issue instead of a summary per kind")
+	embedVerifyCmd.Flags().StringVar(&embedDir, "dir", "", "Verify the index in this directory instead of a named store")
+	embedCmd.AddCommand(embedVerifyCmd)
+}
+
+func runEmbedVerify(cmd *cobra.Command, args []string) error {
+	name := storeName
+	if len(args) > 0 {
+		name = args[0]
+	}
+
+	config := rag.DefaultConfig()
+	// Only flag a model mismatch when a model was asked for explicitly
+	config.Model = ""
+	if cmd.Flags().Changed("model") {
+		config.Model = embedModel
+	}
+
+	opts := rag.VerifyOptions{
+		CheckFiles:  !embedVerifySkipFiles,
+		SampleEmbed: !embedVerifySkipSample,
+		Fix:         embedVerifyFix,
+	}
+
+	engine := rag.NewEngine(nil)
+	if opts.SampleEmbed {
+		client, err := newEmbeddingClient(storeProvider(name))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: skipping sample re-embedding: %v\n", err)
+			opts.SampleEmbed = false
+		} else {
+			engine = rag.NewEngine(client)
+		}
+	}
+
+	var report *rag.VerifyReport
+	var err error
+	target := fmt.Sprintf("store '%s'", name)
+	if embedDir != "" {
+		target = fmt.Sprintf("'%s'", embedDir)
+		report, err = engine.VerifyDir(embedDir, config, opts)
+	} else {
+		report, err = engine.Verify(name, config, opts)
+	}
+	if report == nil {
+		return err
+	}
+
+	fmt.Printf("Verifying %s (model: %s, dimension: %d, files: %d, chunks: %d)\n",
+		target, report.Model, report.Dimension, report.Files, report.Chunks)
+
+	printed := make(map[string]int)
+	counts := make(map[string]int)
+	var kinds []string
+	for _, issue := range report.Issues {
+		if counts[issue.Kind] == 0 {
+			kinds = append(kinds, issue.Kind)
+		}
+		counts[issue.Kind]++
+	}
+	for _, issue := range report.Issues {
+		if !embedVerifyVerbose && printed[issue.Kind] >= maxIssuesPerKind {
+			continue
+		}
+		printed[issue.Kind]++
+
+		status := ""
+		switch {
+		case issue.Fixed:
+			status = " [fixed]"
+		case issue.Fixable:
+			status = " [fixable]"
+		}
+		level := "ERROR"
+		if issue.Warning {
+			level = "WARN "
+		}
+		location := issue.FilePath
+		if issue.Chunk >= 0 {
+			location = fmt.Sprintf("%s (chunk %d)", issue.FilePath, issue.Chunk)
+		}
+		if location != "" {
+			location += ": "
+		}
+		fmt.Printf("  %s %-16s %s%s%s\n", level, issue.Kind, location, issue.Detail, status)
+	}
+	for _, kind := range kinds {
+		if hidden := counts[kind] - printed[kind]; hidden > 0 {
+			fmt.Printf("  ... %d more %s issue(s) (use --verbose to list all)\n", hidden, kind)
+		}
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if len(report.Issues) == 0 {
+		fmt.Println("No problems found.")
+		return nil
+	}
+	if report.Fixed > 0 {
+		fmt.Printf("Fixed %d issue(s).\n", report.Fixed)
+	}
+	if unresolved := report.Unresolved(); unresolved > 0 {
+		if !embedVerifyFix {
+			fixable := 0
+			for _, issue := range report.Issues {
+				if issue.Fixable && !issue.Warning {
+					fixable++
+				}
+			}
+			if fixable > 0 {
+				fmt.Printf("%d issue(s) can be repaired with --fix.\n", fixable)
+			}
+		}
+		return fmt.Errorf("%d unresolved problem(s) found", unresolved)
+	}
+	return nil
+}
@@ -5,6 +5,7 @@ import (
 	"os"
 
 	"github.com/spf13/cobra"
+	"github.com/takeshy/ragujuary/internal/embedding"
 )
 
 var (
@@ -40,18 +41,29 @@ func init() {
 		defaultStore = envStore
 	}
 
-	rootCmd.PersistentFlags().StringVarP(&apiKey, "api-key", "k", "", "Gemini API key (or set GEMINI_API_KEY env var)")
+	rootCmd.PersistentFlags().StringVarP(&apiKey, "api-key", "k", "", "Gemini API key (or set GEMINI_API_KEY env var); several comma-separated keys rotate for embeddings")
 	rootCmd.PersistentFlags().StringVarP(&storeName, "store", "s", defaultStore, "Store name (or set RAGUJUARY_STORE env var)")
 	rootCmd.PersistentFlags().StringVarP(&dataFile, "data-file", "d", "", "Path to data file (default: ~/.ragujuary.json)")
 	rootCmd.PersistentFlags().IntVarP(&parallelism, "parallelism", "p", 5, "Number of parallel uploads")
 }
 
+// getAPIKey returns the Gemini API key, the first one if several are given
 func getAPIKey() (string, error) {
-	if apiKey != "" {
-		return apiKey, nil
+	keys, err := getAPIKeys()
+	if err != nil {
+		return "", err
 	}
-	key := os.Getenv("GEMINI_API_KEY")
+	return embedding.SplitAPIKeys(keys)[0], nil
+}
+
+// getAPIKeys returns the Gemini API key setting as given, possibly a
+// comma-separated list of keys
+func getAPIKeys() (string, error) {
+	key := apiKey
 	if key == "" {
+		key = os.Getenv("GEMINI_API_KEY")
+	}
+	if len(embedding.SplitAPIKeys(key)) == 0 {
 		return "", fmt.Errorf("API key not provided. Use --api-key flag or set GEMINI_API_KEY environment variable")
 	}
 	return key, nil
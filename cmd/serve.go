@@ -10,6 +10,7 @@ import (
 	"time"
 
 	"github.com/spf13/cobra"
+	"github.com/takeshy/ragujuary/internal/embedding"
 	mcpserver "github.com/takeshy/ragujuary/internal/mcp"
 )
 
@@ -20,6 +21,13 @@ var (
 	serveEmbedURL    string
 	serveEmbedAPIKey string
 	serveStores      []string
+	serveRetry       = embedding.DefaultRetryConfig()
+	serveProvider    string
+	serveEmbedModel  string
+	serveAzure       embedding.AzureOptions
+	serveOllama      embedding.OllamaOptions
+	serveOpenAI      embedding.OpenAIOptions
+	serveFallbacks   []string
 )
 
 var serveCmd = &cobra.Command{
@@ -63,15 +71,31 @@ func init() {
 	serveCmd.Flags().StringVar(&serveTransport, "transport", "stdio", "Transport type: stdio, sse, or http")
 	serveCmd.Flags().IntVar(&servePort, "port", 8080, "Port for HTTP/SSE server")
 	serveCmd.Flags().StringVar(&serveAPIKey, "serve-api-key", "", "API key for HTTP authentication (or RAGUJUARY_SERVE_API_KEY env var)")
-	serveCmd.Flags().StringVar(&serveEmbedURL, "embed-url", "", "OpenAI-compatible embedding API URL (e.g. http://localhost:11434 for Ollama)")
-	serveCmd.Flags().StringVar(&serveEmbedAPIKey, "embed-api-key", "", "API key for OpenAI-compatible embedding APIs (or set RAGUJUARY_EMBED_API_KEY / OPENAI_API_KEY)")
+	serveCmd.Flags().StringVar(&serveEmbedURL, "embed-url", "", "Embedding API URL (e.g. http://localhost:1234 for an OpenAI-compatible server)")
+	serveCmd.Flags().StringVar(&serveEmbedAPIKey, "embed-api-key", "", "API key for non-Gemini embedding providers (or set RAGUJUARY_EMBED_API_KEY / the provider's variable, e.g. OPENAI_API_KEY)")
 	serveCmd.Flags().StringSliceVar(&serveStores, "stores", nil, "Restrict to specific stores (comma-separated or repeated)")
+	serveCmd.Flags().StringVar(&serveProvider, "embed-provider", "", "Embedding provider for new embedding stores (see 'embed providers'; default: openai with --embed-url, else gemini); existing stores keep theirs")
+	serveCmd.Flags().StringVar(&serveEmbedModel, "embed-model", "", "Embedding model for new embedding stores (default: the provider's; discovered for ollama and tei)")
+	addFallbackFlag(serveCmd.Flags(), &serveFallbacks)
+	addRetryFlags(serveCmd.Flags(), &serveRetry)
+	addAzureFlags(serveCmd.Flags(), &serveAzure)
+	addOllamaFlags(serveCmd.Flags(), &serveOllama)
+	addOpenAIFlags(serveCmd.Flags(), &serveOpenAI)
 	rootCmd.AddCommand(serveCmd)
 }
 
 func runServe(cmd *cobra.Command, args []string) error {
-	// Get Gemini API key
-	key, err := getAPIKey()
+	// Get Gemini API key(s)
+	key, err := getAPIKeys()
+	if err != nil {
+		return err
+	}
+
+	provider, err := embedding.SelectProvider(serveProvider, "", serveEmbedURL)
+	if err != nil {
+		return err
+	}
+	fallbacks, err := parseFallbacks(serveFallbacks)
 	if err != nil {
 		return err
 	}
@@ -80,9 +104,16 @@ func runServe(cmd *cobra.Command, args []string) error {
 	config := mcpserver.ServerConfig{
 		APIKey:         key,
 		EmbedURL:       serveEmbedURL,
-		EmbedAPIKey:    getServeEmbeddingAPIKey(),
+		EmbedProvider:  provider.Name,
+		EmbedModel:     serveEmbedModel,
+		Azure:          serveAzure,
+		Ollama:         serveOllama,
+		OpenAI:         serveOpenAI,
+		EmbedAPIKey:    getServeEmbeddingAPIKey(provider),
 		DataFile:       dataFile,
 		AllowedStores:  serveStores,
+		EmbedRetry:     &serveRetry,
+		EmbedFallbacks: fallbacks,
 	}
 
 	// Create MCP server
@@ -118,14 +149,13 @@ func runServe(cmd *cobra.Command, args []string) error {
 	}
 }
 
-func getServeEmbeddingAPIKey() string {
+// getServeEmbeddingAPIKey returns the API key for a non-Gemini provider:
+// --embed-api-key, else the provider's environment variables
+func getServeEmbeddingAPIKey(provider embedding.Provider) string {
 	if serveEmbedAPIKey != "" {
 		return serveEmbedAPIKey
 	}
-	if key := os.Getenv("RAGUJUARY_EMBED_API_KEY"); key != "" {
-		return key
-	}
-	return os.Getenv("OPENAI_API_KEY")
+	return provider.EnvAPIKey()
 }
 
 func runHTTPServerWithShutdown(handler http.Handler, transportName string, sigChan chan os.Signal) error {
@@ -124,7 +124,7 @@ func runSync(cmd *cobra.Command, args []string) error {
 	// Check for files that exist locally but have been deleted from disk
 	localFiles = storeManager.GetAllFiles(resolvedName) // Refresh after updates
 	for _, f := range localFiles {
-		if _, err := os.Stat(f.LocalPath); os.IsNotExist(err) {
+		if _, err := fileutil.Stat(f.LocalPath); os.IsNotExist(err) {
 			orphaned++
 		}
 	}
@@ -186,7 +186,7 @@ func runClean(cmd *cobra.Command, args []string) error {
 
 	// Check for files that exist in store but are missing locally
 	for _, f := range localFiles {
-		if _, err := os.Stat(f.LocalPath); os.IsNotExist(err) {
+		if _, err := fileutil.Stat(f.LocalPath); os.IsNotExist(err) {
 			toDelete = append(toDelete, f)
 		}
 	}
@@ -274,7 +274,7 @@ func runStatus(cmd *cobra.Command, args []string) error {
 
 	for _, f := range files {
 		// Check if file exists
-		if _, err := os.Stat(f.LocalPath); os.IsNotExist(err) {
+		if _, err := fileutil.Stat(f.LocalPath); os.IsNotExist(err) {
 			fmt.Printf("  ✗ [MISSING] %s\n", f.LocalPath)
 			missing++
 			continue
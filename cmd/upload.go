@@ -13,6 +13,9 @@ import (
 
 var (
 	excludePatterns []string
+	includePatterns []string
+	noIgnore        bool
+	mimeTypeSpecs   []string
 	dryRun          bool
 	createStore     bool
 )
@@ -21,7 +24,8 @@ var uploadCmd = &cobra.Command{
 	Use:   "upload [directories...]",
 	Short: "Upload files to a File Search Store",
 	Long: `Upload files from specified directories to a Gemini File Search Store.
-Files matching exclude patterns will be skipped.
+Files matching exclude patterns, or ignored by .gitignore and
+.ragujuaryignore files, will be skipped.
 Files with unchanged checksums will not be re-uploaded.
 
 The File Search Store must exist, or use --create to create it automatically.`,
@@ -31,11 +35,20 @@ The File Search Store must exist, or use --create to create it automatically.`,
 
 func init() {
 	uploadCmd.Flags().StringArrayVarP(&excludePatterns, "exclude", "e", nil, "Regex patterns to exclude files (can be specified multiple times)")
+	addDiscoverFlags(uploadCmd, &includePatterns, &noIgnore, &mimeTypeSpecs)
 	uploadCmd.Flags().BoolVar(&dryRun, "dry-run", false, "Show what would be uploaded without actually uploading")
 	uploadCmd.Flags().BoolVar(&createStore, "create", false, "Create the File Search Store if it doesn't exist")
 	rootCmd.AddCommand(uploadCmd)
 }
 
+// addDiscoverFlags registers the file selection flags shared by upload and
+// embed index
+func addDiscoverFlags(cmd *cobra.Command, include *[]string, noIgnore *bool, mimeTypes *[]string) {
+	cmd.Flags().StringArrayVar(include, "include", nil, "Only take files matching this gitignore-style glob, e.g. '*.md' or 'docs/**' (can be specified multiple times)")
+	cmd.Flags().BoolVar(noIgnore, "no-ignore", false, "Don't honour .gitignore and .ragujuaryignore files (and walk .git directories)")
+	cmd.Flags().StringArrayVar(mimeTypes, "mime-type", nil, "Treat files with an extension as a MIME type, e.g. '.mdx=text/markdown' (can be specified multiple times)")
+}
+
 func runUpload(cmd *cobra.Command, args []string) error {
 	key, err := getAPIKey()
 	if err != nil {
@@ -92,8 +105,20 @@ func runUpload(cmd *cobra.Command, args []string) error {
 	if len(excludePatterns) > 0 {
 		fmt.Printf("Excluding patterns: %s\n", strings.Join(excludePatterns, ", "))
 	}
+	if len(includePatterns) > 0 {
+		fmt.Printf("Including only: %s\n", strings.Join(includePatterns, ", "))
+	}
 
-	files, err := fileutil.DiscoverFiles(args, excludePatterns)
+	mimeTypes, err := fileutil.ParseMIMETypes(mimeTypeSpecs)
+	if err != nil {
+		return err
+	}
+	files, err := fileutil.DiscoverFiles(args, fileutil.DiscoverOptions{
+		Exclude:   excludePatterns,
+		Include:   includePatterns,
+		NoIgnore:  noIgnore,
+		MIMETypes: mimeTypes,
+	})
 	if err != nil {
 		return fmt.Errorf("failed to discover files: %w", err)
 	}
@@ -0,0 +1,193 @@
+// Package docutil extracts structured text from Office Open XML documents
+// (DOCX, PPTX and XLSX), EPUB e-books and HTML pages for text embedding.
+package docutil
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+)
+
+// OOXML MIME types
+const (
+	DOCXMime = "application/vnd.openxmlformats-officedocument.wordprocessingml.document"
+	PPTXMime = "application/vnd.openxmlformats-officedocument.presentationml.presentation"
+	XLSXMime = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+)
+
+// RowsPerSection is how many spreadsheet rows go into one labelled section
+const RowsPerSection = 40
+
+// maxPartSize caps the decompressed size of one document part
+const maxPartSize = 64 << 20
+
+// Section is a labelled part of a Document's text
+type Section struct {
+	Label string // e.g. "slide 4", "Sheet1!A1:F40" or "chapter 2: Setup"; "" for a DOCX body
+	Start int    // byte offsets of the section in Document.Text
+	End   int
+}
+
+// Document is the text extracted from a document. Headings are written as
+// Markdown headings, so the text chunks like a Markdown file.
+type Document struct {
+	Text     string
+	Sections []Section
+}
+
+// Supported returns true if Extract handles the MIME type
+func Supported(mimeType string) bool {
+	switch mimeType {
+	case DOCXMime, PPTXMime, XLSXMime, EPUBMime:
+		return true
+	default:
+		return false
+	}
+}
+
+// Extract extracts the text of a DOCX, PPTX, XLSX or EPUB document
+func Extract(data []byte, mimeType string) (*Document, error) {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open document: %w", err)
+	}
+	pkg := &ooxmlPackage{files: make(map[string]*zip.File, len(zr.File))}
+	for _, f := range zr.File {
+		pkg.files[f.Name] = f
+	}
+
+	switch mimeType {
+	case DOCXMime:
+		return extractDOCX(pkg)
+	case PPTXMime:
+		return extractPPTX(pkg)
+	case XLSXMime:
+		return extractXLSX(pkg)
+	case EPUBMime:
+		return extractEPUB(pkg)
+	default:
+		return nil, fmt.Errorf("unsupported document type %s", mimeType)
+	}
+}
+
+// builder assembles a Document section by section
+type builder struct {
+	text     strings.Builder
+	sections []Section
+}
+
+// add appends a section; empty sections are left out
+func (b *builder) add(label, text string) {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return
+	}
+	if b.text.Len() > 0 {
+		b.text.WriteString("\n\n")
+	}
+	start := b.text.Len()
+	b.text.WriteString(text)
+	b.sections = append(b.sections, Section{Label: label, Start: start, End: b.text.Len()})
+}
+
+func (b *builder) document() *Document {
+	return &Document{Text: b.text.String(), Sections: b.sections}
+}
+
+// ooxmlPackage is the zip container of an OOXML document or EPUB e-book
+type ooxmlPackage struct {
+	files map[string]*zip.File
+}
+
+// open opens a part of the package
+func (p *ooxmlPackage) open(name string) (io.ReadCloser, error) {
+	f, ok := p.files[name]
+	if !ok {
+		return nil, fmt.Errorf("document has no %s", name)
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", name, err)
+	}
+	return struct {
+		io.Reader
+		io.Closer
+	}{io.LimitReader(rc, maxPartSize), rc}, nil
+}
+
+// has reports whether the package contains a part
+func (p *ooxmlPackage) has(name string) bool {
+	_, ok := p.files[name]
+	return ok
+}
+
+// decode unmarshals an XML part
+func (p *ooxmlPackage) decode(name string, v any) error {
+	rc, err := p.open(name)
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	if err := xml.NewDecoder(rc).Decode(v); err != nil {
+		return fmt.Errorf("failed to parse %s: %w", name, err)
+	}
+	return nil
+}
+
+// relationships returns the targets of a part's relationships by ID,
+// resolved to package paths
+func (p *ooxmlPackage) relationships(part string) (map[string]string, error) {
+	dir, file := path.Split(part)
+	var rels struct {
+		Relationships []struct {
+			ID         string `xml:"Id,attr"`
+			Target     string `xml:"Target,attr"`
+			TargetMode string `xml:"TargetMode,attr"`
+		} `xml:"Relationship"`
+	}
+	if err := p.decode(dir+"_rels/"+file+".rels", &rels); err != nil {
+		return nil, err
+	}
+	targets := make(map[string]string, len(rels.Relationships))
+	for _, r := range rels.Relationships {
+		if r.TargetMode == "External" {
+			continue
+		}
+		if strings.HasPrefix(r.Target, "/") {
+			targets[r.ID] = strings.TrimPrefix(r.Target, "/")
+		} else {
+			targets[r.ID] = path.Join(dir, r.Target)
+		}
+	}
+	return targets, nil
+}
+
+// relID returns the value of an r:id attribute
+func relID(attrs []xml.Attr) string {
+	for _, a := range attrs {
+		if a.Name.Local == "id" && strings.HasSuffix(a.Name.Space, "/relationships") {
+			return a.Value
+		}
+	}
+	return ""
+}
+
+// attr returns the value of an attribute by local name
+func attr(attrs []xml.Attr, local string) string {
+	for _, a := range attrs {
+		if a.Name.Local == local {
+			return a.Value
+		}
+	}
+	return ""
+}
+
+// skipElement reports whether an element's content is left out: the
+// fallback of mc:AlternateContent repeats its mc:Choice
+func skipElement(name xml.Name) bool {
+	return name.Local == "Fallback" && strings.Contains(name.Space, "markup-compatibility")
+}
@@ -0,0 +1,191 @@
+package docutil
+
+import (
+	"archive/zip"
+	"bytes"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+const (
+	wNS = `xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main"`
+	pNS = `xmlns:p="http://schemas.openxmlformats.org/presentationml/2006/main" xmlns:a="http://schemas.openxmlformats.org/drawingml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"`
+	sNS = `xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"`
+)
+
+// buildZip packs parts into a zip archive
+func buildZip(t *testing.T, parts map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, content := range parts {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// rels builds a relationships part from ID/target pairs
+func rels(pairs ...string) string {
+	var sb strings.Builder
+	sb.WriteString(`<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">`)
+	for i := 0; i+1 < len(pairs); i += 2 {
+		sb.WriteString(`<Relationship Id="` + pairs[i] + `" Target="` + pairs[i+1] + `"/>`)
+	}
+	sb.WriteString(`</Relationships>`)
+	return sb.String()
+}
+
+func TestExtractDOCX(t *testing.T) {
+	data := buildZip(t, map[string]string{
+		"word/styles.xml": `<w:styles ` + wNS + `>
+			<w:style w:styleId="1"><w:name w:val="heading 1"/></w:style>
+			<w:style w:styleId="21"><w:name w:val="heading 2"/></w:style>
+		</w:styles>`,
+		"word/document.xml": `<w:document ` + wNS + ` xmlns:mc="http://schemas.openxmlformats.org/markup-compatibility/2006"><w:body>
+			<w:p><w:pPr><w:pStyle w:val="Title"/></w:pPr><w:r><w:t>Handbook</w:t></w:r></w:p>
+			<w:p><w:pPr><w:pStyle w:val="1"/></w:pPr><w:r><w:t>Getting </w:t></w:r><w:r><w:t>started</w:t></w:r></w:p>
+			<w:p><w:r><w:t>Install the tool.</w:t><w:tab/><w:t>Then run it.</w:t></w:r></w:p>
+			<w:p><w:pPr><w:numPr><w:ilvl w:val="0"/></w:numPr></w:pPr><w:r><w:t>first</w:t></w:r></w:p>
+			<w:p><w:pPr><w:numPr><w:ilvl w:val="0"/></w:numPr></w:pPr><w:r><w:t>second</w:t></w:r></w:p>
+			<w:p><w:pPr><w:pStyle w:val="21"/></w:pPr><w:r><w:t>Options</w:t></w:r></w:p>
+			<w:tbl>
+				<w:tr><w:tc><w:p><w:r><w:t>Flag</w:t></w:r></w:p></w:tc><w:tc><w:p><w:r><w:t>Meaning</w:t></w:r></w:p></w:tc></w:tr>
+				<w:tr><w:tc><w:p><w:r><w:t>-v</w:t></w:r></w:p></w:tc><w:tc><w:p><w:r><w:t>verbose</w:t></w:r></w:p></w:tc></w:tr>
+			</w:tbl>
+			<w:p><w:r><mc:AlternateContent><mc:Choice><w:t>shown once</w:t></mc:Choice><mc:Fallback><w:t>shown once</w:t></mc:Fallback></mc:AlternateContent></w:r></w:p>
+			<w:p><w:r><w:delText>deleted</w:delText></w:r></w:p>
+		</w:body></w:document>`,
+	})
+
+	doc, err := Extract(data, DOCXMime)
+	if err != nil {
+		t.Fatalf("Extract: %v", err)
+	}
+	want := "# Handbook\n\n" +
+		"# Getting started\n\n" +
+		"Install the tool.\tThen run it.\n\n" +
+		"- first\n- second\n\n" +
+		"## Options\n\n" +
+		"| Flag | Meaning |\n| -v | verbose |\n\n" +
+		"shown once"
+	if doc.Text != want {
+		t.Fatalf("text:\n%q\nwant:\n%q", doc.Text, want)
+	}
+	if len(doc.Sections) != 1 || doc.Sections[0] != (Section{Label: "", Start: 0, End: len(want)}) {
+		t.Fatalf("sections = %+v", doc.Sections)
+	}
+}
+
+func TestExtractPPTX(t *testing.T) {
+	slide := func(title, body string) string {
+		return `<p:sld ` + pNS + `><p:cSld><p:spTree>
+			<p:sp><p:nvSpPr><p:nvPr><p:ph type="title"/></p:nvPr></p:nvSpPr><p:txBody><a:p><a:r><a:t>` + title + `</a:t></a:r></a:p></p:txBody></p:sp>
+			<p:sp><p:nvSpPr><p:nvPr><p:ph idx="1"/></p:nvPr></p:nvSpPr><p:txBody>` + body + `</p:txBody></p:sp>
+		</p:spTree></p:cSld></p:sld>`
+	}
+	data := buildZip(t, map[string]string{
+		// Presentation order differs from the part names
+		"ppt/presentation.xml":            `<p:presentation ` + pNS + `><p:sldIdLst><p:sldId id="256" r:id="rId3"/><p:sldId id="257" r:id="rId2"/><p:sldId id="258" r:id="rId4"/></p:sldIdLst></p:presentation>`,
+		"ppt/_rels/presentation.xml.rels": rels("rId2", "slides/slide1.xml", "rId3", "slides/slide2.xml", "rId4", "slides/slide3.xml"),
+		"ppt/slides/slide1.xml":           slide("Results", `<a:p><a:r><a:t>Revenue grew</a:t></a:r></a:p>`),
+		"ppt/slides/slide2.xml":           slide("Agenda", `<a:p><a:r><a:t>Intro</a:t></a:r></a:p><a:p><a:r><a:t>Results</a:t></a:r></a:p>`),
+		"ppt/slides/slide3.xml": `<p:sld ` + pNS + `><p:cSld><p:spTree><p:graphicFrame><a:graphic><a:graphicData><a:tbl>
+			<a:tr><a:tc><a:txBody><a:p><a:r><a:t>Q1</a:t></a:r></a:p></a:txBody></a:tc><a:tc><a:txBody><a:p><a:r><a:t>10</a:t></a:r></a:p></a:txBody></a:tc></a:tr>
+		</a:tbl></a:graphicData></a:graphic></p:graphicFrame></p:spTree></p:cSld></p:sld>`,
+	})
+
+	doc, err := Extract(data, PPTXMime)
+	if err != nil {
+		t.Fatalf("Extract: %v", err)
+	}
+	want := []struct{ label, text string }{
+		{"slide 1", "## Agenda\n\nIntro\nResults"},
+		{"slide 2", "## Results\n\nRevenue grew"},
+		{"slide 3", "Q1\n10"},
+	}
+	if len(doc.Sections) != len(want) {
+		t.Fatalf("sections = %+v, text %q", doc.Sections, doc.Text)
+	}
+	for i, w := range want {
+		s := doc.Sections[i]
+		if s.Label != w.label || doc.Text[s.Start:s.End] != w.text {
+			t.Errorf("section %d = %q %q, want %q %q", i, s.Label, doc.Text[s.Start:s.End], w.label, w.text)
+		}
+	}
+}
+
+func TestExtractXLSX(t *testing.T) {
+	var rows strings.Builder
+	for r := 1; r <= RowsPerSection+2; r++ {
+		n := strings.Repeat("x", r%3+1)
+		rows.WriteString(`<row r="` + strconv.Itoa(r+1) + `"><c r="B` + strconv.Itoa(r+1) + `" t="inlineStr"><is><t>` + n + `</t></is></c><c r="D` + strconv.Itoa(r+1) + `"><v>` + strconv.Itoa(r) + `</v></c></row>`)
+	}
+	data := buildZip(t, map[string]string{
+		"xl/workbook.xml":            `<workbook ` + sNS + `><sheets><sheet name="Budget" sheetId="1" r:id="rId1"/><sheet name="Data" sheetId="2" r:id="rId2"/><sheet name="Empty" sheetId="3" r:id="rId3"/></sheets></workbook>`,
+		"xl/_rels/workbook.xml.rels": rels("rId1", "worksheets/sheet1.xml", "rId2", "/xl/worksheets/sheet2.xml", "rId3", "worksheets/sheet3.xml"),
+		"xl/sharedStrings.xml":       `<sst ` + sNS + `><si><t>Item</t></si><si><r><t>Co</t></r><r><t>st</t></r></si><si><t>東京</t><rPh><t>トウキョウ</t></rPh></si></sst>`,
+		"xl/worksheets/sheet1.xml": `<worksheet ` + sNS + `><sheetData>
+			<row r="1"><c r="A1" t="s"><v>0</v></c><c r="C1" t="s"><v>1</v></c></row>
+			<row r="2"><c r="A2" t="s"><v>2</v></c><c r="C2"><f>SUM(1,2)</f><v>3</v></c></row>
+			<row r="3"><c r="A3" t="b"><v>1</v></c></row>
+			<row r="4"><c r="A4"/></row>
+		</sheetData></worksheet>`,
+		"xl/worksheets/sheet2.xml": `<worksheet ` + sNS + `><sheetData>` + rows.String() + `</sheetData></worksheet>`,
+		"xl/worksheets/sheet3.xml": `<worksheet ` + sNS + `><sheetData/></worksheet>`,
+	})
+
+	doc, err := Extract(data, XLSXMime)
+	if err != nil {
+		t.Fatalf("Extract: %v", err)
+	}
+	if len(doc.Sections) != 3 {
+		t.Fatalf("sections = %+v, text %q", doc.Sections, doc.Text)
+	}
+	section := func(i int) (string, string) {
+		s := doc.Sections[i]
+		return s.Label, doc.Text[s.Start:s.End]
+	}
+	if label, text := section(0); label != "Budget!A1:C3" || text != "## Budget\n\nItem |  | Cost\n東京 |  | 3\nTRUE" {
+		t.Errorf("section 0 = %q %q", label, text)
+	}
+	label, text := section(1)
+	if label != "Data!B2:D41" || !strings.HasPrefix(text, "## Data\n\nxx |  | 1\n") || strings.Count(text, "\n") != RowsPerSection+1 {
+		t.Errorf("section 1 = %q %q", label, text)
+	}
+	if label, text := section(2); label != "Data!B42:D43" || text != "xxx |  | 41\nx |  | 42" {
+		t.Errorf("section 2 = %q %q", label, text)
+	}
+}
+
+func TestExtractErrors(t *testing.T) {
+	if _, err := Extract([]byte("not a zip"), DOCXMime); err == nil {
+		t.Error("expected an error for data that isn't a zip archive")
+	}
+	if _, err := Extract(buildZip(t, map[string]string{"other.xml": "<x/>"}), DOCXMime); err == nil {
+		t.Error("expected an error for a DOCX without word/document.xml")
+	}
+	if _, err := Extract(buildZip(t, nil), "text/plain"); err == nil {
+		t.Error("expected an error for an unsupported type")
+	}
+}
+
+func TestColumnNames(t *testing.T) {
+	for col, name := range map[int]string{1: "A", 26: "Z", 27: "AA", 52: "AZ", 703: "AAA"} {
+		if got := columnName(col); got != name {
+			t.Errorf("columnName(%d) = %q, want %q", col, got, name)
+		}
+		if got := columnNumber(name + "12"); got != col {
+			t.Errorf("columnNumber(%q) = %d, want %d", name+"12", got, col)
+		}
+	}
+}
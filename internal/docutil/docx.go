@@ -0,0 +1,199 @@
+package docutil
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// extractDOCX extracts the paragraphs of word/document.xml, writing headings
+// as Markdown headings, list items as "- " lines and table rows as
+// "| a | b |" lines
+func extractDOCX(pkg *ooxmlPackage) (*Document, error) {
+	levels := docxHeadingStyles(pkg)
+
+	rc, err := pkg.open("word/document.xml")
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	var (
+		blocks    []string        // paragraphs and tables of the body
+		para      strings.Builder // text of the current paragraph
+		style     string
+		outline   = -1
+		listItem  bool
+		inText    bool
+		row       []string // cells of the current table row
+		cell      []string // paragraphs of the current table cell
+		tableRows []string
+		depth     int // table nesting depth
+	)
+	dec := xml.NewDecoder(rc)
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse word/document.xml: %w", err)
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if skipElement(t.Name) {
+				if err := dec.Skip(); err != nil {
+					return nil, fmt.Errorf("failed to parse word/document.xml: %w", err)
+				}
+				continue
+			}
+			switch t.Name.Local {
+			case "p":
+				para.Reset()
+				style, outline, listItem = "", -1, false
+			case "pStyle":
+				style = attr(t.Attr, "val")
+			case "outlineLvl":
+				if n, err := strconv.Atoi(attr(t.Attr, "val")); err == nil {
+					outline = n
+				}
+			case "numPr":
+				listItem = true
+			case "t":
+				inText = true
+			case "tab":
+				para.WriteString("\t")
+			case "br", "cr":
+				para.WriteString("\n")
+			case "tbl":
+				depth++
+				if depth == 1 {
+					tableRows = nil
+				}
+			case "tr":
+				if depth == 1 {
+					row = nil
+				}
+			case "tc":
+				if depth == 1 {
+					cell = nil
+				}
+			}
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "t":
+				inText = false
+			case "p":
+				text := strings.TrimSpace(para.String())
+				if text == "" {
+					continue
+				}
+				if depth > 0 {
+					cell = append(cell, text)
+					continue
+				}
+				level := levels[style]
+				if outline >= 0 && outline < 9 && level == 0 {
+					level = outline + 1
+				}
+				switch {
+				case level > 0:
+					blocks = append(blocks, strings.Repeat("#", min(level, 6))+" "+strings.ReplaceAll(text, "\n", " "))
+				case listItem:
+					blocks = append(blocks, "- "+text)
+				default:
+					blocks = append(blocks, text)
+				}
+			case "tc":
+				if depth == 1 {
+					row = append(row, strings.ReplaceAll(strings.Join(cell, " "), "\n", " "))
+				}
+			case "tr":
+				if depth == 1 && strings.TrimSpace(strings.Join(row, "")) != "" {
+					tableRows = append(tableRows, "| "+strings.Join(row, " | ")+" |")
+				}
+			case "tbl":
+				depth--
+				if depth == 0 && len(tableRows) > 0 {
+					blocks = append(blocks, strings.Join(tableRows, "\n"))
+				}
+			}
+		case xml.CharData:
+			if inText {
+				para.Write(t)
+			}
+		}
+	}
+
+	var b builder
+	b.add("", joinBlocks(blocks))
+	return b.document(), nil
+}
+
+// joinBlocks joins paragraphs with blank lines, keeping consecutive list
+// items and table rows together
+func joinBlocks(blocks []string) string {
+	var sb strings.Builder
+	for i, block := range blocks {
+		if i > 0 {
+			if kind := tightBlock(block); kind != "" && kind == tightBlock(blocks[i-1]) {
+				sb.WriteString("\n")
+			} else {
+				sb.WriteString("\n\n")
+			}
+		}
+		sb.WriteString(block)
+	}
+	return sb.String()
+}
+
+// tightBlock returns "list" for list items and "table" for table rows,
+// which are joined to neighbours of the same kind without a blank line
+func tightBlock(block string) string {
+	switch {
+	case strings.HasPrefix(strings.TrimLeft(block, " "), "- "):
+		return "list"
+	case strings.HasPrefix(block, "| "):
+		return "table"
+	default:
+		return ""
+	}
+}
+
+// docxHeadingStyles maps paragraph style IDs to heading levels (1 for the
+// title). Style IDs are localised, so the style names of word/styles.xml
+// ("heading 1", "Title") are what identify headings.
+func docxHeadingStyles(pkg *ooxmlPackage) map[string]int {
+	levels := map[string]int{"Title": 1}
+	for n := 1; n <= 9; n++ {
+		levels["Heading"+strconv.Itoa(n)] = n
+	}
+	if !pkg.has("word/styles.xml") {
+		return levels
+	}
+	var styles struct {
+		Styles []struct {
+			ID   string `xml:"styleId,attr"`
+			Name struct {
+				Val string `xml:"val,attr"`
+			} `xml:"name"`
+		} `xml:"style"`
+	}
+	if err := pkg.decode("word/styles.xml", &styles); err != nil {
+		return levels
+	}
+	for _, s := range styles.Styles {
+		name := strings.ToLower(s.Name.Val)
+		switch {
+		case name == "title":
+			levels[s.ID] = 1
+		case strings.HasPrefix(name, "heading "):
+			if n, err := strconv.Atoi(strings.TrimPrefix(name, "heading ")); err == nil && n >= 1 && n <= 9 {
+				levels[s.ID] = n
+			}
+		}
+	}
+	return levels
+}
@@ -0,0 +1,278 @@
+package docutil
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// EPUBMime is the MIME type of EPUB e-books
+const EPUBMime = "application/epub+zip"
+
+// epubPackage is the package document (OPF) of an e-book
+type epubPackage struct {
+	Manifest []epubItem `xml:"manifest>item"`
+	Spine    struct {
+		TOC      string `xml:"toc,attr"` // manifest ID of the EPUB 2 NCX
+		ItemRefs []struct {
+			IDRef  string `xml:"idref,attr"`
+			Linear string `xml:"linear,attr"`
+		} `xml:"itemref"`
+	} `xml:"spine"`
+}
+
+type epubItem struct {
+	ID         string `xml:"id,attr"`
+	Href       string `xml:"href,attr"`
+	MediaType  string `xml:"media-type,attr"`
+	Properties string `xml:"properties,attr"`
+}
+
+// ncxPoint is an entry of an EPUB 2 table of contents
+type ncxPoint struct {
+	Label   string `xml:"navLabel>text"`
+	Content struct {
+		Src string `xml:"src,attr"`
+	} `xml:"content"`
+	Points []ncxPoint `xml:"navPoint"`
+}
+
+// extractEPUB extracts the XHTML chapters of an e-book in the reading order
+// of its spine, each as a section labelled "chapter N: Title". The title
+// comes from the table of contents, else the chapter's first heading, and
+// starts the chapter as a "# " heading. The parts of a chapter under its
+// own headings are sections labelled with the heading path, e.g.
+// "chapter 3: Setup > Linux".
+func extractEPUB(pkg *ooxmlPackage) (*Document, error) {
+	opfPath, err := epubPackagePath(pkg)
+	if err != nil {
+		return nil, err
+	}
+	var opf epubPackage
+	if err := pkg.decode(opfPath, &opf); err != nil {
+		return nil, err
+	}
+	items := make(map[string]epubItem, len(opf.Manifest))
+	for _, item := range opf.Manifest {
+		item.Href = epubHref(path.Dir(opfPath), item.Href)
+		items[item.ID] = item
+	}
+	titles := epubTitles(pkg, opf, items)
+
+	var b builder
+	chapter := 0
+	for _, ref := range opf.Spine.ItemRefs {
+		item, ok := items[ref.IDRef]
+		if !ok || ref.Linear == "no" || (item.MediaType != "application/xhtml+xml" && item.MediaType != HTMLMime) {
+			continue
+		}
+		w, err := epubChapter(pkg, item.Href)
+		if err != nil {
+			return nil, err
+		}
+		if len(w.blocks) == 0 {
+			continue
+		}
+		chapter++
+
+		title := titles[item.Href]
+		if len(w.headings) == 0 || w.headings[0].block != 0 {
+			if title != "" {
+				// The title becomes the chapter's heading
+				for i := range w.headings {
+					w.headings[i].block++
+				}
+				w.blocks = append([]string{"# " + title}, w.blocks...)
+				w.headings = append([]htmlHeading{{block: 0, level: 1, text: title}}, w.headings...)
+			}
+		} else if title == "" {
+			title = w.headings[0].text
+		}
+		label := "chapter " + strconv.Itoa(chapter)
+		if title != "" {
+			label += ": " + title
+		}
+		addChapter(&b, label, w)
+	}
+	return b.document(), nil
+}
+
+// addChapter adds the sections of a rendered chapter: its text up to its
+// second heading, then the text under each following heading
+func addChapter(b *builder, label string, w *htmlWriter) {
+	starts, labels := []int{0}, []string{label}
+	var trail []htmlHeading // enclosing headings of the current section
+	for _, h := range w.headings {
+		if h.block == 0 {
+			continue
+		}
+		for len(trail) > 0 && trail[len(trail)-1].level >= h.level {
+			trail = trail[:len(trail)-1]
+		}
+		trail = append(trail, h)
+		names := make([]string, len(trail))
+		for i, t := range trail {
+			names[i] = t.text
+		}
+		starts = append(starts, h.block)
+		labels = append(labels, label+" > "+strings.Join(names, " > "))
+	}
+	for i, start := range starts {
+		end := len(w.blocks)
+		if i+1 < len(starts) {
+			end = starts[i+1]
+		}
+		b.add(labels[i], joinBlocks(w.blocks[start:end]))
+	}
+}
+
+// epubPackagePath returns the path of the package document named by
+// META-INF/container.xml
+func epubPackagePath(pkg *ooxmlPackage) (string, error) {
+	var container struct {
+		Rootfiles []struct {
+			FullPath  string `xml:"full-path,attr"`
+			MediaType string `xml:"media-type,attr"`
+		} `xml:"rootfiles>rootfile"`
+	}
+	if err := pkg.decode("META-INF/container.xml", &container); err != nil {
+		return "", err
+	}
+	for _, rf := range container.Rootfiles {
+		if rf.MediaType == "application/oebps-package+xml" && pkg.has(rf.FullPath) {
+			return rf.FullPath, nil
+		}
+	}
+	return "", fmt.Errorf("e-book has no package document")
+}
+
+// epubChapter renders a chapter's XHTML
+func epubChapter(pkg *ooxmlPackage, name string) (*htmlWriter, error) {
+	rc, err := pkg.open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	root, err := html.Parse(rc)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", name, err)
+	}
+	w := &htmlWriter{}
+	w.render(root)
+	return w, nil
+}
+
+// epubTitles returns the title of every file the table of contents links to
+// (its first entry for the file), from the EPUB 3 navigation document or,
+// without one, the EPUB 2 NCX. A missing or broken table of contents gives
+// no titles.
+func epubTitles(pkg *ooxmlPackage, opf epubPackage, items map[string]epubItem) map[string]string {
+	titles := make(map[string]string)
+	add := func(target, title string) {
+		if title = collapseSpace(title); title != "" && titles[target] == "" {
+			titles[target] = title
+		}
+	}
+
+	for _, item := range opf.Manifest {
+		if !strings.Contains(" "+item.Properties+" ", " nav ") {
+			continue
+		}
+		nav := items[item.ID].Href
+		rc, err := pkg.open(nav)
+		if err != nil {
+			break
+		}
+		root, err := html.Parse(rc)
+		rc.Close()
+		if err != nil {
+			break
+		}
+		toc := findNav(root)
+		if toc == nil {
+			break
+		}
+		var walk func(*html.Node)
+		walk = func(n *html.Node) {
+			if n.Type == html.ElementNode && n.DataAtom == atom.A {
+				if href := attrValue(n, "href"); href != "" {
+					add(epubHref(path.Dir(nav), href), textContent(n))
+				}
+				return
+			}
+			for c := n.FirstChild; c != nil; c = c.NextSibling {
+				walk(c)
+			}
+		}
+		walk(toc)
+		return titles
+	}
+
+	ncx, ok := items[opf.Spine.TOC]
+	if !ok {
+		return titles
+	}
+	var doc struct {
+		Points []ncxPoint `xml:"navMap>navPoint"`
+	}
+	if err := pkg.decode(ncx.Href, &doc); err != nil {
+		return titles
+	}
+	var walk func([]ncxPoint)
+	walk = func(points []ncxPoint) {
+		for _, p := range points {
+			add(epubHref(path.Dir(ncx.Href), p.Content.Src), p.Label)
+			walk(p.Points)
+		}
+	}
+	walk(doc.Points)
+	return titles
+}
+
+// findNav returns the <nav epub:type="toc"> of a navigation document, or
+// its first <nav>
+func findNav(root *html.Node) *html.Node {
+	var first, toc *html.Node
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		if toc != nil {
+			return
+		}
+		if n.Type == html.ElementNode && n.DataAtom == atom.Nav {
+			if first == nil {
+				first = n
+			}
+			if strings.Contains(" "+attrValue(n, "epub:type")+" ", " toc ") {
+				toc = n
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(root)
+	if toc != nil {
+		return toc
+	}
+	return first
+}
+
+// epubHref resolves a link relative to a directory of the package to a
+// package path, without its fragment
+func epubHref(dir, href string) string {
+	if i := strings.IndexByte(href, '#'); i >= 0 {
+		href = href[:i]
+	}
+	if unescaped, err := url.PathUnescape(href); err == nil {
+		href = unescaped
+	}
+	if strings.HasPrefix(href, "/") {
+		return strings.TrimPrefix(path.Clean(href), "/")
+	}
+	return path.Join(dir, href)
+}
@@ -0,0 +1,99 @@
+package docutil
+
+import (
+	"testing"
+)
+
+const epubContainer = `<?xml version="1.0"?>
+<container version="1.0" xmlns="urn:oasis:names:tc:opendocument:xmlns:container">
+  <rootfiles><rootfile full-path="OEBPS/content.opf" media-type="application/oebps-package+xml"/></rootfiles>
+</container>`
+
+// xhtml wraps a chapter body in an XHTML page titled like the book, as
+// e-book generators do
+func xhtml(body string) string {
+	return `<?xml version="1.0" encoding="UTF-8"?>
+<html xmlns="http://www.w3.org/1999/xhtml" xmlns:epub="http://www.idpf.org/2007/ops"><head><title>Field Guide</title></head><body>` + body + `</body></html>`
+}
+
+func TestExtractEPUB(t *testing.T) {
+	data := buildZip(t, map[string]string{
+		"mimetype":               "application/epub+zip",
+		"META-INF/container.xml": epubContainer,
+		"OEBPS/content.opf": `<package xmlns="http://www.idpf.org/2007/opf" version="3.0">
+  <manifest>
+    <item id="nav" href="nav.xhtml" media-type="application/xhtml+xml" properties="nav"/>
+    <item id="cover" href="cover.xhtml" media-type="application/xhtml+xml"/>
+    <item id="c1" href="text/ch%201.xhtml" media-type="application/xhtml+xml"/>
+    <item id="c2" href="text/ch2.xhtml" media-type="application/xhtml+xml"/>
+    <item id="notes" href="text/notes.xhtml" media-type="application/xhtml+xml"/>
+    <item id="img" href="images/cover.png" media-type="image/png"/>
+  </manifest>
+  <spine>
+    <itemref idref="cover"/>
+    <itemref idref="c1"/>
+    <itemref idref="img"/>
+    <itemref idref="notes" linear="no"/>
+    <itemref idref="c2"/>
+  </spine>
+</package>`,
+		"OEBPS/nav.xhtml": xhtml(`<nav epub:type="landmarks"><ol><li><a href="text/ch2.xhtml">Wrong</a></li></ol></nav>
+<nav epub:type="toc"><ol>
+  <li><a href="text/ch%201.xhtml">Getting
+    Started</a><ol><li><a href="text/ch%201.xhtml#install">Installing</a></li></ol></li>
+  <li><a href="text/ch2.xhtml#top">Field Work</a></li>
+</ol></nav>`),
+		"OEBPS/cover.xhtml": xhtml(`<div><img src="../images/cover.png" alt=""/></div>`),
+		"OEBPS/text/ch 1.xhtml": xhtml(`<p>Welcome to the guide.</p>
+<h2 id="install">Installing</h2><p>Unpack the kit.</p>
+<h3>On Linux</h3><pre># run as root
+./setup</pre>
+<h2>Checking</h2><p>Run the tests.</p>`),
+		"OEBPS/text/ch2.xhtml":   xhtml(`<h1>Field Work</h1><p>Take notes daily.</p>`),
+		"OEBPS/text/notes.xhtml": xhtml(`<p>Footnotes.</p>`),
+	})
+
+	doc, err := Extract(data, EPUBMime)
+	if err != nil {
+		t.Fatalf("Extract: %v", err)
+	}
+	checkSections(t, doc, [][2]string{
+		{"chapter 1: Getting Started", "# Getting Started\n\nWelcome to the guide."},
+		{"chapter 1: Getting Started > Installing", "## Installing\n\nUnpack the kit."},
+		{"chapter 1: Getting Started > Installing > On Linux", "### On Linux\n\n# run as root\n./setup"},
+		{"chapter 1: Getting Started > Checking", "## Checking\n\nRun the tests."},
+		{"chapter 2: Field Work", "# Field Work\n\nTake notes daily."},
+	})
+}
+
+func TestExtractEPUB2UsesNCX(t *testing.T) {
+	data := buildZip(t, map[string]string{
+		"META-INF/container.xml": epubContainer,
+		"OEBPS/content.opf": `<package xmlns="http://www.idpf.org/2007/opf" version="2.0">
+  <manifest>
+    <item id="ncx" href="toc.ncx" media-type="application/x-dtbncx+xml"/>
+    <item id="p1" href="part1.html" media-type="application/xhtml+xml"/>
+    <item id="p2" href="part2.html" media-type="application/xhtml+xml"/>
+  </manifest>
+  <spine toc="ncx"><itemref idref="p1"/><itemref idref="p2"/></spine>
+</package>`,
+		"OEBPS/toc.ncx": `<ncx xmlns="http://www.daisy.org/z3986/2005/ncx/"><navMap>
+  <navPoint id="n1"><navLabel><text>Prologue</text></navLabel><content src="part1.html"/></navPoint>
+</navMap></ncx>`,
+		"OEBPS/part1.html": xhtml(`<p>It began at dawn.</p>`),
+		"OEBPS/part2.html": xhtml(`<h2>Second Part</h2><p>Then came noon.</p>`),
+	})
+
+	doc, err := Extract(data, EPUBMime)
+	if err != nil {
+		t.Fatalf("Extract: %v", err)
+	}
+	checkSections(t, doc, [][2]string{
+		{"chapter 1: Prologue", "# Prologue\n\nIt began at dawn."},
+		{"chapter 2: Second Part", "## Second Part\n\nThen came noon."},
+	})
+
+	if _, err := Extract(buildZip(t, map[string]string{"mimetype": EPUBMime}), EPUBMime); err == nil {
+		t.Error("expected an error for an e-book without a container")
+	}
+}
@@ -0,0 +1,279 @@
+package docutil
+
+import (
+	"bytes"
+	"fmt"
+	"mime"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// HTMLMime is the MIME type ExtractHTML handles
+const HTMLMime = "text/html"
+
+// HTMLOptions controls ExtractHTML
+type HTMLOptions struct {
+	// DropBoilerplate leaves out <nav>, <header>, <footer> and <aside>
+	// content (and elements with the matching ARIA roles)
+	DropBoilerplate bool
+}
+
+// ExtractHTML extracts the readable text of an HTML page. Scripts, styles
+// and hidden elements are left out; headings become Markdown headings (the
+// page title too when there is no <h1>), list items "- " lines, table rows
+// "| a | b |" lines, and links their text.
+func ExtractHTML(data []byte, opts HTMLOptions) (*Document, error) {
+	root, err := html.Parse(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse HTML: %w", err)
+	}
+
+	w := &htmlWriter{opts: opts}
+	if title := findElement(root, atom.Title); title != nil && findElement(root, atom.H1) == nil {
+		if text := collapseSpace(textContent(title)); text != "" {
+			w.blocks = append(w.blocks, "# "+text)
+		}
+	}
+	w.render(root)
+
+	var b builder
+	b.add("", joinBlocks(w.blocks))
+	return b.document(), nil
+}
+
+// htmlWriter renders a parsed page as blocks of text
+type htmlWriter struct {
+	opts      HTMLOptions
+	blocks    []string
+	headings  []htmlHeading
+	inline    strings.Builder // text of the current block
+	prefix    string          // list marker of the current block
+	listDepth int
+}
+
+// htmlHeading is a heading block of a rendered page
+type htmlHeading struct {
+	block int // index in htmlWriter.blocks
+	level int
+	text  string
+}
+
+// render renders the body of a parsed page
+func (w *htmlWriter) render(root *html.Node) {
+	if body := findElement(root, atom.Body); body != nil {
+		w.walk(body)
+	}
+	w.flush()
+}
+
+// flush ends the current block
+func (w *htmlWriter) flush() {
+	text := collapseSpace(w.inline.String())
+	w.inline.Reset()
+	if text != "" {
+		w.blocks = append(w.blocks, w.prefix+text)
+		w.prefix = ""
+	}
+}
+
+func (w *htmlWriter) walk(n *html.Node) {
+	switch n.Type {
+	case html.TextNode:
+		w.inline.WriteString(n.Data)
+		return
+	case html.ElementNode:
+	default:
+		w.walkChildren(n)
+		return
+	}
+	if w.skip(n) {
+		return
+	}
+
+	switch n.DataAtom {
+	case atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6:
+		w.flush()
+		if text := collapseSpace(textContent(n)); text != "" {
+			level := int(n.Data[1] - '0')
+			w.headings = append(w.headings, htmlHeading{block: len(w.blocks), level: level, text: text})
+			w.blocks = append(w.blocks, strings.Repeat("#", level)+" "+text)
+		}
+	case atom.Pre:
+		w.flush()
+		if text := strings.Trim(textContent(n), "\n"); strings.TrimSpace(text) != "" {
+			w.blocks = append(w.blocks, text)
+		}
+	case atom.Br:
+		w.flush()
+	case atom.Img:
+		if alt := attrValue(n, "alt"); alt != "" {
+			w.inline.WriteString(" " + alt + " ")
+		}
+	case atom.Ul, atom.Ol:
+		w.flush()
+		w.listDepth++
+		w.walkChildren(n)
+		w.flush()
+		w.listDepth--
+	case atom.Li:
+		w.flush()
+		w.prefix = strings.Repeat("  ", max(w.listDepth-1, 0)) + "- "
+		w.walkChildren(n)
+		w.flush()
+		w.prefix = ""
+	case atom.Tr:
+		w.flush()
+		var cells []string
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			if c.Type == html.ElementNode && (c.DataAtom == atom.Td || c.DataAtom == atom.Th) && !w.skip(c) {
+				cells = append(cells, collapseSpace(textContent(c)))
+			}
+		}
+		if strings.TrimSpace(strings.Join(cells, "")) != "" {
+			w.blocks = append(w.blocks, "| "+strings.Join(cells, " | ")+" |")
+		}
+	default:
+		if blockElements[n.DataAtom] {
+			w.flush()
+			w.walkChildren(n)
+			w.flush()
+			return
+		}
+		w.walkChildren(n)
+	}
+}
+
+func (w *htmlWriter) walkChildren(n *html.Node) {
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		w.walk(c)
+	}
+}
+
+// skip reports whether an element's content is left out
+func (w *htmlWriter) skip(n *html.Node) bool {
+	switch n.DataAtom {
+	case atom.Script, atom.Style, atom.Noscript, atom.Template, atom.Svg, atom.Iframe,
+		atom.Canvas, atom.Object, atom.Head, atom.Button, atom.Select, atom.Input, atom.Textarea:
+		return true
+	case atom.Nav, atom.Header, atom.Footer, atom.Aside:
+		if w.opts.DropBoilerplate {
+			return true
+		}
+	}
+	if hasAttr(n, "hidden") || attrValue(n, "aria-hidden") == "true" {
+		return true
+	}
+	if w.opts.DropBoilerplate {
+		switch attrValue(n, "role") {
+		case "navigation", "banner", "contentinfo", "complementary":
+			return true
+		}
+	}
+	return false
+}
+
+// blockElements start and end a block of text
+var blockElements = map[atom.Atom]bool{
+	atom.Address: true, atom.Article: true, atom.Aside: true, atom.Blockquote: true,
+	atom.Caption: true, atom.Dd: true, atom.Details: true, atom.Dialog: true,
+	atom.Div: true, atom.Dl: true, atom.Dt: true, atom.Fieldset: true,
+	atom.Figcaption: true, atom.Figure: true, atom.Footer: true, atom.Form: true,
+	atom.Header: true, atom.Hr: true, atom.Main: true, atom.Nav: true,
+	atom.P: true, atom.Section: true, atom.Summary: true, atom.Table: true,
+	atom.Tbody: true, atom.Thead: true, atom.Tfoot: true,
+}
+
+// findElement returns the first element of a kind in document order
+func findElement(n *html.Node, a atom.Atom) *html.Node {
+	if n.Type == html.ElementNode && n.DataAtom == a {
+		return n
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if found := findElement(c, a); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+// textContent returns the text under a node, without scripts and styles
+func textContent(n *html.Node) string {
+	var sb strings.Builder
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.TextNode {
+			sb.WriteString(n.Data)
+			return
+		}
+		if n.Type == html.ElementNode {
+			switch n.DataAtom {
+			case atom.Script, atom.Style, atom.Noscript, atom.Template:
+				return
+			case atom.Br:
+				sb.WriteString("\n")
+			case atom.Img:
+				sb.WriteString(" " + attrValue(n, "alt") + " ")
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(n)
+	return sb.String()
+}
+
+// collapseSpace collapses runs of whitespace into single spaces
+func collapseSpace(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+func attrValue(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+func hasAttr(n *html.Node, key string) bool {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return true
+		}
+	}
+	return false
+}
+
+// HTMLCharset returns the character encoding an HTML page declares in a
+// <meta charset> or <meta http-equiv="Content-Type"> element within its
+// first 1024 bytes, or "" when it declares none
+func HTMLCharset(data []byte) string {
+	if len(data) > 1024 {
+		data = data[:1024]
+	}
+	z := html.NewTokenizer(bytes.NewReader(data))
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			return ""
+		case html.StartTagToken, html.SelfClosingTagToken:
+			tok := z.Token()
+			if tok.DataAtom != atom.Meta {
+				continue
+			}
+			n := &html.Node{Attr: tok.Attr}
+			if cs := attrValue(n, "charset"); cs != "" {
+				return strings.TrimSpace(cs)
+			}
+			if strings.EqualFold(attrValue(n, "http-equiv"), "content-type") {
+				if _, params, err := mime.ParseMediaType(attrValue(n, "content")); err == nil && params["charset"] != "" {
+					return params["charset"]
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package docutil
+
+import (
+	"strings"
+	"testing"
+)
+
+const testPage = `<!DOCTYPE html>
+<html><head><title>Ignored title</title><style>body { color: red }</style><script>var x = "<p>not text</p>";</script></head>
+<body>
+<header><a href="/">Site name</a></header>
+<nav><ul><li><a href="/docs">Docs</a></li><li><a href="/blog">Blog</a></li></ul></nav>
+<main>
+  <h1>Install   guide</h1>
+  <p>Download the <a href="/dl">latest release</a> and
+     unpack it.<br>Then run <code>make</code>.</p>
+  <h2>Options</h2>
+  <ul>
+    <li>Fast mode
+      <ul><li>needs <b>ffmpeg</b></li></ul>
+    </li>
+    <li>Safe mode</li>
+  </ul>
+  <table><tr><th>Flag</th><th>Meaning</th></tr><tr><td>-v</td><td>verbose</td></tr></table>
+  <pre>
+line 1
+  line 2
+</pre>
+  <div hidden>secret</div>
+  <span aria-hidden="true">icon</span>
+  <img src="chart.png" alt="Sales chart">
+  <noscript>Enable JavaScript</noscript>
+</main>
+<aside>Related posts</aside>
+<footer>Copyright</footer>
+</body></html>`
+
+func TestExtractHTML(t *testing.T) {
+	doc, err := ExtractHTML([]byte(testPage), HTMLOptions{})
+	if err != nil {
+		t.Fatalf("ExtractHTML: %v", err)
+	}
+	want := "Site name\n\n" +
+		"- Docs\n- Blog\n\n" +
+		"# Install guide\n\n" +
+		"Download the latest release and unpack it.\n\n" +
+		"Then run make.\n\n" +
+		"## Options\n\n" +
+		"- Fast mode\n  - needs ffmpeg\n- Safe mode\n\n" +
+		"| Flag | Meaning |\n| -v | verbose |\n\n" +
+		"line 1\n  line 2\n\n" +
+		"Sales chart\n\n" +
+		"Related posts\n\n" +
+		"Copyright"
+	if doc.Text != want {
+		t.Fatalf("text:\n%q\nwant:\n%q", doc.Text, want)
+	}
+	for _, leaked := range []string{"<", "color", "not text", "secret", "icon", "Ignored title", "JavaScript"} {
+		if strings.Contains(doc.Text, leaked) {
+			t.Errorf("text contains %q", leaked)
+		}
+	}
+}
+
+func TestExtractHTMLDropBoilerplate(t *testing.T) {
+	doc, err := ExtractHTML([]byte(testPage), HTMLOptions{DropBoilerplate: true})
+	if err != nil {
+		t.Fatalf("ExtractHTML: %v", err)
+	}
+	if !strings.HasPrefix(doc.Text, "# Install guide\n\n") {
+		t.Fatalf("text starts %q, want the main content first", doc.Text[:min(40, len(doc.Text))])
+	}
+	for _, dropped := range []string{"Site name", "Docs", "Related posts", "Copyright"} {
+		if strings.Contains(doc.Text, dropped) {
+			t.Errorf("text contains boilerplate %q", dropped)
+		}
+	}
+
+	doc, err = ExtractHTML([]byte(`<body><div role="navigation">Menu</div><p>Body</p></body>`), HTMLOptions{DropBoilerplate: true})
+	if err != nil {
+		t.Fatalf("ExtractHTML: %v", err)
+	}
+	if doc.Text != "Body" {
+		t.Fatalf("text = %q, want the navigation role dropped", doc.Text)
+	}
+}
+
+func TestExtractHTMLUsesTitleWithoutH1(t *testing.T) {
+	doc, err := ExtractHTML([]byte(`<html><head><title> Release  notes </title></head><body><h2>v1.2</h2><p>Fixes.</p></body></html>`), HTMLOptions{})
+	if err != nil {
+		t.Fatalf("ExtractHTML: %v", err)
+	}
+	if want := "# Release notes\n\n## v1.2\n\nFixes."; doc.Text != want {
+		t.Fatalf("text = %q, want %q", doc.Text, want)
+	}
+	if len(doc.Sections) != 1 || doc.Sections[0].Label != "" {
+		t.Fatalf("sections = %+v, want one unlabelled section", doc.Sections)
+	}
+}
+
+func TestHTMLCharset(t *testing.T) {
+	tests := map[string]string{
+		`<html><head><meta charset="Shift_JIS"><title>x</title>`:                             "Shift_JIS",
+		`<meta http-equiv="Content-Type" content="text/html; charset=euc-jp">`:               "euc-jp",
+		`<html><head><meta name="viewport" content="width=device-width"></head><body>`:       "",
+		`<html><body>` + strings.Repeat("x", 1100) + `<meta charset="euc-jp"></body></html>`: "",
+	}
+	for page, want := range tests {
+		if got := HTMLCharset([]byte(page)); got != want {
+			t.Errorf("HTMLCharset(%.60q) = %q, want %q", page, got, want)
+		}
+	}
+}
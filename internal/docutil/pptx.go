@@ -0,0 +1,145 @@
+package docutil
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// extractPPTX extracts the text of each slide, in presentation order, as a
+// section labelled "slide N". Slide titles are written as "## " headings.
+func extractPPTX(pkg *ooxmlPackage) (*Document, error) {
+	slides, err := pptxSlides(pkg)
+	if err != nil {
+		return nil, err
+	}
+	var b builder
+	for i, slide := range slides {
+		text, err := pptxSlideText(pkg, slide)
+		if err != nil {
+			return nil, err
+		}
+		b.add(fmt.Sprintf("slide %d", i+1), text)
+	}
+	return b.document(), nil
+}
+
+// pptxSlides returns the slide parts in the order of ppt/presentation.xml
+func pptxSlides(pkg *ooxmlPackage) ([]string, error) {
+	const presentation = "ppt/presentation.xml"
+	rels, err := pkg.relationships(presentation)
+	if err != nil {
+		return nil, err
+	}
+	rc, err := pkg.open(presentation)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	var slides []string
+	dec := xml.NewDecoder(rc)
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", presentation, err)
+		}
+		if start, ok := tok.(xml.StartElement); ok && start.Name.Local == "sldId" {
+			if target, ok := rels[relID(start.Attr)]; ok && pkg.has(target) {
+				slides = append(slides, target)
+			}
+		}
+	}
+	return slides, nil
+}
+
+// pptxSlideText returns the paragraphs of a slide's shapes, one per line;
+// title placeholders become headings
+func pptxSlideText(pkg *ooxmlPackage, slide string) (string, error) {
+	rc, err := pkg.open(slide)
+	if err != nil {
+		return "", err
+	}
+	defer rc.Close()
+
+	var (
+		titles  []string
+		lines   []string
+		shape   []string // paragraphs of the current shape
+		para    strings.Builder
+		isTitle bool
+		inText  bool
+		depth   int // p:sp nesting (group shapes hold shapes)
+	)
+	dec := xml.NewDecoder(rc)
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", fmt.Errorf("failed to parse %s: %w", slide, err)
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if skipElement(t.Name) {
+				if err := dec.Skip(); err != nil {
+					return "", fmt.Errorf("failed to parse %s: %w", slide, err)
+				}
+				continue
+			}
+			switch t.Name.Local {
+			case "sp":
+				depth++
+				shape, isTitle = nil, false
+			case "ph":
+				typ := attr(t.Attr, "type")
+				isTitle = typ == "title" || typ == "ctrTitle"
+			case "p":
+				para.Reset()
+			case "t":
+				inText = true
+			case "br":
+				para.WriteString("\n")
+			}
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "t":
+				inText = false
+			case "p":
+				if text := strings.TrimSpace(para.String()); text != "" {
+					shape = append(shape, text)
+				}
+			case "sp":
+				depth--
+				if isTitle {
+					titles = append(titles, strings.Join(shape, " "))
+				} else {
+					lines = append(lines, shape...)
+				}
+				shape = nil
+			case "tc":
+				// Table cells live in graphic frames, not shapes
+				if depth == 0 && len(shape) > 0 {
+					lines = append(lines, strings.Join(shape, " "))
+					shape = nil
+				}
+			}
+		case xml.CharData:
+			if inText {
+				para.Write(t)
+			}
+		}
+	}
+
+	var sb strings.Builder
+	for _, title := range titles {
+		sb.WriteString("## " + strings.ReplaceAll(title, "\n", " ") + "\n\n")
+	}
+	sb.WriteString(strings.Join(lines, "\n"))
+	return sb.String(), nil
+}
@@ -0,0 +1,334 @@
+package docutil
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Structured data formats
+const (
+	FormatCSV   = "csv"
+	FormatJSON  = "json"
+	FormatJSONL = "jsonl"
+	FormatYAML  = "yaml"
+)
+
+// DefaultCSVRows is how many CSV rows go into one section by default
+const DefaultCSVRows = 50
+
+// StructuredOptions controls ExtractStructured
+type StructuredOptions struct {
+	// CSVRows caps the rows of a CSV section (0 = DefaultCSVRows)
+	CSVRows int
+	// MaxSize is the chunk size: CSV sections end before they would
+	// exceed it, and JSON values larger than it are split into their
+	// members or elements
+	MaxSize int
+}
+
+// StructuredFormat returns the structured data format of a file by its MIME
+// type and name, or "" for other files. Without a MIME type the name's
+// extension decides.
+func StructuredFormat(name, mimeType string) string {
+	switch ext := strings.ToLower(filepath.Ext(name)); {
+	case ext == ".jsonl" || ext == ".ndjson":
+		return FormatJSONL
+	case mimeType == "text/csv" || (mimeType == "" && ext == ".csv"):
+		return FormatCSV
+	case mimeType == "application/json" || (mimeType == "" && ext == ".json"):
+		return FormatJSON
+	case mimeType == "application/x-yaml" || (mimeType == "" && (ext == ".yaml" || ext == ".yml")):
+		return FormatYAML
+	default:
+		return ""
+	}
+}
+
+// ExtractStructured splits CSV, JSON, JSONL and YAML content into labelled
+// sections of whole records: CSV rows in groups, each with the header row
+// (labelled "rows 2-41"), JSON array elements and object members (labelled
+// with their JSON path, e.g. "$.servers[2]"), JSONL lines ("line 12") and
+// YAML top-level keys. It returns an error for content that doesn't parse.
+func ExtractStructured(text, format string, opts StructuredOptions) (*Document, error) {
+	switch format {
+	case FormatCSV:
+		return extractCSV(text, opts)
+	case FormatJSON:
+		return extractJSON(text, opts)
+	case FormatJSONL:
+		return extractJSONL(text, opts)
+	case FormatYAML:
+		return extractYAML(text), nil
+	default:
+		return nil, fmt.Errorf("unsupported structured format %q", format)
+	}
+}
+
+// extractCSV groups the rows after the header row into sections that start
+// with the header row. Rows keep their text from the file.
+func extractCSV(text string, opts StructuredOptions) (*Document, error) {
+	maxRows := opts.CSVRows
+	if maxRows <= 0 {
+		maxRows = DefaultCSVRows
+	}
+	r := csv.NewReader(strings.NewReader(text))
+	r.FieldsPerRecord = -1
+	r.LazyQuotes = true
+
+	// Raw text of every record, header first
+	var rows []string
+	offset := int64(0)
+	for {
+		_, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse CSV: %w", err)
+		}
+		end := r.InputOffset()
+		rows = append(rows, strings.TrimRight(text[offset:end], "\r\n"))
+		offset = end
+	}
+	if len(rows) < 2 {
+		var b builder
+		b.add("", text)
+		return b.document(), nil
+	}
+
+	header := rows[0]
+	var b builder
+	first := 1 // index of the group's first row; row numbers are 1-based with the header as row 1
+	size := len(header)
+	for i := 1; i <= len(rows); i++ {
+		if i < len(rows) {
+			grown := size + 1 + len(rows[i])
+			if i-first < maxRows && (i == first || opts.MaxSize <= 0 || grown <= opts.MaxSize) {
+				size = grown
+				continue
+			}
+		}
+		label := fmt.Sprintf("rows %d-%d", first+1, i)
+		if i == first+1 {
+			label = fmt.Sprintf("row %d", i)
+		}
+		b.add(label, header+"\n"+strings.Join(rows[first:i], "\n"))
+		first, size = i, len(header)
+		if i < len(rows) {
+			size += 1 + len(rows[i])
+		}
+	}
+	return b.document(), nil
+}
+
+// extractJSON makes a section of every element of a top-level array or
+// member of a top-level object
+func extractJSON(text string, opts StructuredOptions) (*Document, error) {
+	raw := bytes.TrimSpace([]byte(text))
+	if !json.Valid(raw) {
+		return nil, errors.New("failed to parse JSON: invalid JSON")
+	}
+	var b builder
+	if err := splitJSON(&b, "$", "", raw, opts.MaxSize, true); err != nil {
+		return nil, err
+	}
+	return b.document(), nil
+}
+
+// extractJSONL makes a section of every line
+func extractJSONL(text string, opts StructuredOptions) (*Document, error) {
+	var b builder
+	for n, line := range strings.Split(text, "\n") {
+		raw := bytes.TrimSpace([]byte(line))
+		if len(raw) == 0 {
+			continue
+		}
+		if !json.Valid(raw) {
+			return nil, fmt.Errorf("failed to parse JSON on line %d", n+1)
+		}
+		if err := splitJSON(&b, "line "+strconv.Itoa(n+1), "", raw, opts.MaxSize, false); err != nil {
+			return nil, err
+		}
+	}
+	return b.document(), nil
+}
+
+// splitJSON adds a JSON value as a section labelled with its path, or, when
+// it is the top-level value or larger than maxSize, its members or elements
+// one by one. Object members are written as `"key": value`.
+func splitJSON(b *builder, path, key string, raw []byte, maxSize int, top bool) error {
+	text := string(raw)
+	if key != "" {
+		text = key + ": " + text
+	}
+	if (raw[0] != '{' && raw[0] != '[') || (!top && (maxSize <= 0 || len(text) <= maxSize)) {
+		b.add(path, text)
+		return nil
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	if _, err := dec.Token(); err != nil { // opening delimiter
+		return fmt.Errorf("failed to parse JSON: %w", err)
+	}
+	added := false
+	for i := 0; dec.More(); i++ {
+		childPath := path + "[" + strconv.Itoa(i) + "]"
+		childKey := ""
+		if raw[0] == '{' {
+			tok, err := dec.Token()
+			if err != nil {
+				return fmt.Errorf("failed to parse JSON: %w", err)
+			}
+			name, _ := tok.(string)
+			childPath = jsonMemberPath(path, name)
+			quoted, _ := json.Marshal(name)
+			childKey = string(quoted)
+		}
+		var child json.RawMessage
+		if err := dec.Decode(&child); err != nil {
+			return fmt.Errorf("failed to parse JSON: %w", err)
+		}
+		if err := splitJSON(b, childPath, childKey, child, maxSize, false); err != nil {
+			return err
+		}
+		added = true
+	}
+	if !added { // empty object or array
+		b.add(path, text)
+	}
+	return nil
+}
+
+var jsonIdentifier = regexp.MustCompile(`^[A-Za-z_$][A-Za-z0-9_$]*$`)
+
+// jsonMemberPath appends an object member to a JSON path: $.name, or
+// $["odd name"] for names that aren't identifiers
+func jsonMemberPath(path, name string) string {
+	if jsonIdentifier.MatchString(name) {
+		return path + "." + name
+	}
+	quoted, _ := json.Marshal(name)
+	return path + "[" + string(quoted) + "]"
+}
+
+// extractYAML makes a section of every top-level key of a YAML file (every
+// item of a top-level sequence), keeping the file's text. Comments before a
+// key go with the section before it. Sections of the documents after the
+// first of a multi-document file are labelled "document 2: key".
+func extractYAML(text string) *Document {
+	type boundary struct {
+		offset int
+		label  string
+	}
+	var bounds []boundary
+	doc, items := 1, 0
+	mapping := false // the current document is a mapping
+	keyed := false   // the current document has sections
+	for offset := 0; offset < len(text); {
+		end := strings.IndexByte(text[offset:], '\n')
+		if end < 0 {
+			end = len(text)
+		} else {
+			end += offset + 1
+		}
+		line := strings.TrimRight(text[offset:end], "\r\n")
+
+		label := ""
+		switch {
+		case line == "---" || strings.HasPrefix(line, "--- ") || line == "...":
+			if keyed {
+				doc++
+			}
+			mapping, items, keyed = false, 0, false
+			bounds = append(bounds, boundary{offset, ""}) // the marker starts no section
+		case line == "-" || strings.HasPrefix(line, "- "):
+			if !mapping {
+				label = "[" + strconv.Itoa(items) + "]"
+				items++
+			}
+		case line != "" && !strings.ContainsRune(" \t#%", rune(line[0])):
+			if key, ok := yamlKey(line); ok {
+				label, mapping = key, true
+			}
+		}
+		if label != "" {
+			if doc > 1 {
+				label = "document " + strconv.Itoa(doc) + ": " + label
+			}
+			bounds = append(bounds, boundary{offset, label})
+			keyed = true
+		}
+		offset = end
+	}
+
+	var b builder
+	b.text.WriteString(text)
+	for i, bd := range bounds {
+		if bd.label == "" {
+			continue
+		}
+		start := bd.offset
+		// The first section of a document takes the comments before it
+		if i == 0 {
+			start = 0
+		} else if bounds[i-1].label == "" {
+			start = bounds[i-1].offset + lineLength(text[bounds[i-1].offset:])
+		}
+		end := len(text)
+		if i+1 < len(bounds) {
+			end = bounds[i+1].offset
+		}
+		// Trim surrounding blank lines so the section is just its content
+		for start < end && (text[start] == '\n' || text[start] == '\r') {
+			start++
+		}
+		for end > start && (text[end-1] == '\n' || text[end-1] == '\r' || text[end-1] == ' ') {
+			end--
+		}
+		if start < end {
+			b.sections = append(b.sections, Section{Label: bd.label, Start: start, End: end})
+		}
+	}
+	if len(b.sections) == 0 && strings.TrimSpace(text) != "" {
+		b.sections = append(b.sections, Section{Start: 0, End: len(text)})
+	}
+	return b.document()
+}
+
+// lineLength returns the length of the first line of s, with its newline
+func lineLength(s string) int {
+	if i := strings.IndexByte(s, '\n'); i >= 0 {
+		return i + 1
+	}
+	return len(s)
+}
+
+// yamlKey returns the key of a top-level mapping line ("key: value" or
+// "key:"), without quotes
+func yamlKey(line string) (string, bool) {
+	if q := line[0]; q == '"' || q == '\'' {
+		end := strings.IndexByte(line[1:], q)
+		if end < 0 {
+			return "", false
+		}
+		rest := line[end+2:]
+		if rest != ":" && !strings.HasPrefix(rest, ": ") && !strings.HasPrefix(rest, ":\t") {
+			return "", false
+		}
+		return line[1 : end+1], true
+	}
+	for i := 0; i < len(line); i++ {
+		if line[i] == ':' && (i+1 == len(line) || line[i+1] == ' ' || line[i+1] == '\t') {
+			return strings.TrimSpace(line[:i]), i > 0
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,167 @@
+package docutil
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// sections returns the label and text of every section
+func sections(doc *Document) [][2]string {
+	var out [][2]string
+	for _, s := range doc.Sections {
+		out = append(out, [2]string{s.Label, doc.Text[s.Start:s.End]})
+	}
+	return out
+}
+
+func checkSections(t *testing.T, doc *Document, want [][2]string) {
+	t.Helper()
+	got := sections(doc)
+	if len(got) != len(want) {
+		t.Fatalf("sections = %q, want %q", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("section %d = %q, want %q", i, got[i], want[i])
+		}
+	}
+}
+
+func TestExtractCSV(t *testing.T) {
+	var sb strings.Builder
+	sb.WriteString("id,name,notes\r\n")
+	for i := 1; i <= 5; i++ {
+		fmt.Fprintf(&sb, "%d,item %d,plain\r\n", i, i)
+	}
+	sb.WriteString("6,item 6,\"two\nlines\"\r\n")
+
+	doc, err := ExtractStructured(sb.String(), FormatCSV, StructuredOptions{CSVRows: 4})
+	if err != nil {
+		t.Fatalf("ExtractStructured: %v", err)
+	}
+	checkSections(t, doc, [][2]string{
+		{"rows 2-5", "id,name,notes\n1,item 1,plain\n2,item 2,plain\n3,item 3,plain\n4,item 4,plain"},
+		{"rows 6-7", "id,name,notes\n5,item 5,plain\n6,item 6,\"two\nlines\""},
+	})
+
+	// Groups also end at the chunk size, so the header is in every chunk
+	doc, err = ExtractStructured(sb.String(), FormatCSV, StructuredOptions{MaxSize: 45})
+	if err != nil {
+		t.Fatalf("ExtractStructured: %v", err)
+	}
+	checkSections(t, doc, [][2]string{
+		{"rows 2-3", "id,name,notes\n1,item 1,plain\n2,item 2,plain"},
+		{"rows 4-5", "id,name,notes\n3,item 3,plain\n4,item 4,plain"},
+		{"row 6", "id,name,notes\n5,item 5,plain"},
+		{"row 7", "id,name,notes\n6,item 6,\"two\nlines\""},
+	})
+}
+
+func TestExtractJSON(t *testing.T) {
+	text := `{
+  "name": "deploy",
+  "servers": [
+    {"host": "a.example.com", "role": "web"},
+    {"host": "b.example.com", "role": "db"}
+  ],
+  "odd key": true,
+  "empty": {}
+}`
+	doc, err := ExtractStructured(text, FormatJSON, StructuredOptions{MaxSize: 50})
+	if err != nil {
+		t.Fatalf("ExtractStructured: %v", err)
+	}
+	checkSections(t, doc, [][2]string{
+		{"$.name", `"name": "deploy"`},
+		{"$.servers[0]", `{"host": "a.example.com", "role": "web"}`},
+		{"$.servers[1]", `{"host": "b.example.com", "role": "db"}`},
+		{`$["odd key"]`, `"odd key": true`},
+		{"$.empty", `"empty": {}`},
+	})
+
+	doc, err = ExtractStructured(`[1, {"a": 2}]`, FormatJSON, StructuredOptions{MaxSize: 1000})
+	if err != nil {
+		t.Fatalf("ExtractStructured: %v", err)
+	}
+	checkSections(t, doc, [][2]string{{"$[0]", "1"}, {"$[1]", `{"a": 2}`}})
+
+	if _, err := ExtractStructured(`{"a": `, FormatJSON, StructuredOptions{}); err == nil {
+		t.Error("expected an error for malformed JSON")
+	}
+}
+
+func TestExtractJSONL(t *testing.T) {
+	text := "{\"id\": 1, \"msg\": \"hi\"}\n\n{\"id\": 2, \"tags\": [\"x\", \"y\"]}\n"
+	doc, err := ExtractStructured(text, FormatJSONL, StructuredOptions{MaxSize: 1000})
+	if err != nil {
+		t.Fatalf("ExtractStructured: %v", err)
+	}
+	checkSections(t, doc, [][2]string{
+		{"line 1", `{"id": 1, "msg": "hi"}`},
+		{"line 3", `{"id": 2, "tags": ["x", "y"]}`},
+	})
+
+	// A line larger than the chunk size is split into its members
+	doc, err = ExtractStructured(text, FormatJSONL, StructuredOptions{MaxSize: 25})
+	if err != nil {
+		t.Fatalf("ExtractStructured: %v", err)
+	}
+	if got := sections(doc); len(got) != 3 || got[1] != [2]string{"line 3.id", `"id": 2`} {
+		t.Errorf("sections = %q", got)
+	}
+
+	if _, err := ExtractStructured("{}\nnot json\n", FormatJSONL, StructuredOptions{}); err == nil {
+		t.Error("expected an error for a line that isn't JSON")
+	}
+}
+
+func TestExtractYAML(t *testing.T) {
+	text := `# Service settings
+name: api
+"replicas": 3
+env:
+- name: LOG_LEVEL
+  value: debug
+# Ports exposed
+ports:
+  - 8080
+---
+- first
+- second:
+    nested: true
+`
+	doc, err := ExtractStructured(text, FormatYAML, StructuredOptions{})
+	if err != nil {
+		t.Fatalf("ExtractStructured: %v", err)
+	}
+	if doc.Text != text {
+		t.Fatalf("text changed: %q", doc.Text)
+	}
+	checkSections(t, doc, [][2]string{
+		{"name", "# Service settings\nname: api"},
+		{"replicas", `"replicas": 3`},
+		{"env", "env:\n- name: LOG_LEVEL\n  value: debug\n# Ports exposed"},
+		{"ports", "ports:\n  - 8080"},
+		{"document 2: [0]", "- first"},
+		{"document 2: [1]", "- second:\n    nested: true"},
+	})
+}
+
+func TestStructuredFormat(t *testing.T) {
+	for _, tt := range []struct{ name, mime, want string }{
+		{"data.csv", "text/csv", FormatCSV},
+		{"config.json", "application/json", FormatJSON},
+		{"events.jsonl", "text/plain", FormatJSONL},
+		{"events.ndjson", "application/octet-stream", FormatJSONL},
+		{"deploy.yml", "application/x-yaml", FormatYAML},
+		{"notes.md", "text/markdown", ""},
+		{"pasted.csv", "", FormatCSV},
+		{"pasted.yaml", "", FormatYAML},
+		{"data.json", "text/plain", ""},
+	} {
+		if got := StructuredFormat(tt.name, tt.mime); got != tt.want {
+			t.Errorf("StructuredFormat(%s, %s) = %q, want %q", tt.name, tt.mime, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,277 @@
+package docutil
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// extractXLSX extracts the rows of each sheet, as "a | b | c" lines, in
+// sections of up to RowsPerSection rows labelled with their cell range
+// (e.g. "Sheet1!A1:F40"). Each sheet starts with a "## <sheet name>" heading.
+func extractXLSX(pkg *ooxmlPackage) (*Document, error) {
+	const workbook = "xl/workbook.xml"
+	rels, err := pkg.relationships(workbook)
+	if err != nil {
+		return nil, err
+	}
+	var wb struct {
+		Sheets []struct {
+			Name  string     `xml:"name,attr"`
+			Attrs []xml.Attr `xml:",any,attr"`
+		} `xml:"sheets>sheet"`
+	}
+	if err := pkg.decode(workbook, &wb); err != nil {
+		return nil, err
+	}
+	shared, err := xlsxSharedStrings(pkg)
+	if err != nil {
+		return nil, err
+	}
+
+	var b builder
+	for _, sheet := range wb.Sheets {
+		part, ok := rels[relID(sheet.Attrs)]
+		if !ok || !pkg.has(part) {
+			continue
+		}
+		rows, err := xlsxRows(pkg, part, shared)
+		if err != nil {
+			return nil, err
+		}
+		for start := 0; start < len(rows); start += RowsPerSection {
+			block := rows[start:min(start+RowsPerSection, len(rows))]
+			var sb strings.Builder
+			if start == 0 {
+				sb.WriteString("## " + sheet.Name + "\n\n")
+			}
+			minCol, maxCol := block[0].cells[0].col, 0
+			for i, row := range block {
+				if i > 0 {
+					sb.WriteString("\n")
+				}
+				sb.WriteString(row.text())
+				minCol = min(minCol, row.cells[0].col)
+				maxCol = max(maxCol, row.cells[len(row.cells)-1].col)
+			}
+			label := fmt.Sprintf("%s!%s%d:%s%d", sheet.Name,
+				columnName(minCol), block[0].num, columnName(maxCol), block[len(block)-1].num)
+			b.add(label, sb.String())
+		}
+	}
+	return b.document(), nil
+}
+
+// xlsxCell is a non-empty cell value and its 1-based column
+type xlsxCell struct {
+	col   int
+	value string
+}
+
+// xlsxRow is a row with at least one non-empty cell
+type xlsxRow struct {
+	num   int // 1-based row number
+	cells []xlsxCell
+}
+
+// text joins the row's cells with " | ", leaving empty cells between them
+// empty so columns line up
+func (r xlsxRow) text() string {
+	var parts []string
+	col := r.cells[0].col
+	for _, c := range r.cells {
+		for ; col < c.col; col++ {
+			parts = append(parts, "")
+		}
+		parts = append(parts, c.value)
+		col++
+	}
+	return strings.Join(parts, " | ")
+}
+
+// xlsxSharedStrings reads xl/sharedStrings.xml (which a workbook without
+// text cells may lack)
+func xlsxSharedStrings(pkg *ooxmlPackage) ([]string, error) {
+	const part = "xl/sharedStrings.xml"
+	if !pkg.has(part) {
+		return nil, nil
+	}
+	rc, err := pkg.open(part)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	var (
+		strs   []string
+		cur    strings.Builder
+		inText bool
+		inRPh  bool // phonetic runs repeat the reading of the text
+	)
+	dec := xml.NewDecoder(rc)
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", part, err)
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "si":
+				cur.Reset()
+			case "t":
+				inText = !inRPh
+			case "rPh":
+				inRPh = true
+			}
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "si":
+				strs = append(strs, cur.String())
+			case "t":
+				inText = false
+			case "rPh":
+				inRPh = false
+			}
+		case xml.CharData:
+			if inText {
+				cur.Write(t)
+			}
+		}
+	}
+	return strs, nil
+}
+
+// xlsxRows reads the non-empty rows of a worksheet
+func xlsxRows(pkg *ooxmlPackage, part string, shared []string) ([]xlsxRow, error) {
+	rc, err := pkg.open(part)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	var (
+		rows    []xlsxRow
+		row     xlsxRow
+		cellRef string
+		cellTyp string
+		value   strings.Builder
+		inValue bool
+		inRPh   bool
+	)
+	dec := xml.NewDecoder(rc)
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", part, err)
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "row":
+				row = xlsxRow{}
+				if n, err := strconv.Atoi(attr(t.Attr, "r")); err == nil {
+					row.num = n
+				} else if len(rows) > 0 {
+					row.num = rows[len(rows)-1].num + 1
+				} else {
+					row.num = 1
+				}
+			case "c":
+				cellRef, cellTyp = attr(t.Attr, "r"), attr(t.Attr, "t")
+				value.Reset()
+			case "v":
+				inValue = true
+			case "t":
+				inValue = !inRPh // inline string
+			case "rPh":
+				inRPh = true
+			case "f":
+				// Formulas are skipped; their cached value is in <v>
+				if err := dec.Skip(); err != nil {
+					return nil, fmt.Errorf("failed to parse %s: %w", part, err)
+				}
+			}
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "v", "t":
+				inValue = false
+			case "rPh":
+				inRPh = false
+			case "c":
+				text := strings.TrimSpace(cellValue(cellTyp, value.String(), shared))
+				if text == "" {
+					continue
+				}
+				col := len(row.cells) + 1
+				if len(row.cells) > 0 {
+					col = row.cells[len(row.cells)-1].col + 1
+				}
+				if c := columnNumber(cellRef); c > 0 {
+					col = c
+				}
+				row.cells = append(row.cells, xlsxCell{col: col, value: strings.ReplaceAll(text, "\n", " ")})
+			case "row":
+				if len(row.cells) > 0 {
+					rows = append(rows, row)
+				}
+			}
+		case xml.CharData:
+			if inValue {
+				value.Write(t)
+			}
+		}
+	}
+	return rows, nil
+}
+
+// cellValue renders a cell's raw value by its type
+func cellValue(typ, raw string, shared []string) string {
+	switch typ {
+	case "s":
+		i, err := strconv.Atoi(strings.TrimSpace(raw))
+		if err != nil || i < 0 || i >= len(shared) {
+			return ""
+		}
+		return shared[i]
+	case "b":
+		if strings.TrimSpace(raw) == "1" {
+			return "TRUE"
+		}
+		return "FALSE"
+	default:
+		return raw
+	}
+}
+
+// columnNumber returns the 1-based column of a cell reference like "AB12";
+// 0 if there is none
+func columnNumber(ref string) int {
+	n := 0
+	for _, r := range ref {
+		if r < 'A' || r > 'Z' {
+			break
+		}
+		n = n*26 + int(r-'A'+1)
+	}
+	return n
+}
+
+// columnName returns the letters of a 1-based column number
+func columnName(col int) string {
+	var name []byte
+	for col > 0 {
+		col--
+		name = append([]byte{byte('A' + col%26)}, name...)
+		col /= 26
+	}
+	return string(name)
+}
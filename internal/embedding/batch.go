@@ -0,0 +1,137 @@
+package embedding
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// ItemError reports a batch item that could not be embedded
+type ItemError struct {
+	Index int // position of the item in the batch
+	Err   error
+}
+
+// BatchError is returned by BatchEmbedContents when some items of a batch
+// could not be embedded. The returned vectors are still valid for all other
+// items; the failed ones are nil.
+type BatchError struct {
+	Items []ItemError
+}
+
+func (e *BatchError) Error() string {
+	if len(e.Items) == 1 {
+		return fmt.Sprintf("failed to embed batch item %d: %v", e.Items[0].Index, e.Items[0].Err)
+	}
+	return fmt.Sprintf("failed to embed %d batch items (first: item %d: %v)", len(e.Items), e.Items[0].Index, e.Items[0].Err)
+}
+
+// batchSender sends one embedding request for texts. It returns one slot per
+// text; slots the response did not fill are nil.
+type batchSender func(texts []string) ([][]float32, error)
+
+// embedBatch embeds texts with send, at most maxBatch per request (0 = no
+// limit), halving a batch when the API rejects it as too large and re-sending
+// items missing from a response once. Items that still fail are reported in a
+// *BatchError alongside the other vectors. Errors that affect the whole
+// request (auth, network, server) are returned as they are.
+func embedBatch(texts []string, maxBatch int, send batchSender) ([][]float32, error) {
+	result := make([][]float32, len(texts))
+	positions := make([]int, len(texts))
+	for i := range positions {
+		positions[i] = i
+	}
+	if maxBatch <= 0 {
+		maxBatch = len(texts)
+	}
+
+	var failed []ItemError
+	for start := 0; start < len(texts); start += maxBatch {
+		end := start + maxBatch
+		if end > len(texts) {
+			end = len(texts)
+		}
+		if err := embedBatchInto(texts[start:end], positions[start:end], send, result, &failed, true); err != nil {
+			return nil, err
+		}
+	}
+	if len(failed) > 0 {
+		sort.Slice(failed, func(i, j int) bool { return failed[i].Index < failed[j].Index })
+		return result, &BatchError{Items: failed}
+	}
+	return result, nil
+}
+
+func embedBatchInto(texts []string, positions []int, send batchSender, result [][]float32, failed *[]ItemError, retryMissing bool) error {
+	vecs, err := send(texts)
+	if err != nil {
+		if !isBatchTooLarge(err) {
+			return err
+		}
+		if len(texts) == 1 {
+			*failed = append(*failed, ItemError{Index: positions[0], Err: err})
+			return nil
+		}
+		mid := len(texts) / 2
+		if err := embedBatchInto(texts[:mid], positions[:mid], send, result, failed, retryMissing); err != nil {
+			return err
+		}
+		return embedBatchInto(texts[mid:], positions[mid:], send, result, failed, retryMissing)
+	}
+
+	var missingTexts []string
+	var missingPositions []int
+	for i, vec := range vecs {
+		if len(vec) > 0 {
+			result[positions[i]] = vec
+			continue
+		}
+		if !retryMissing {
+			*failed = append(*failed, ItemError{Index: positions[i], Err: errors.New("no embedding in response")})
+			continue
+		}
+		missingTexts = append(missingTexts, texts[i])
+		missingPositions = append(missingPositions, positions[i])
+	}
+	if len(missingTexts) == 0 {
+		return nil
+	}
+	return embedBatchInto(missingTexts, missingPositions, send, result, failed, false)
+}
+
+// tokenLimitMessages are fragments of the error messages embedding APIs use
+// when the input of a request is too long
+var tokenLimitMessages = []string{
+	"context length",
+	"too many tokens",
+	"token limit",
+	"input token count",
+	"too long",
+	"too large",
+	"payload size",
+	"at most 100 requests",
+}
+
+// isBatchTooLarge reports whether err means the request was rejected for its
+// size (413, or a 400 complaining about the input length), so a smaller batch
+// may succeed
+func isBatchTooLarge(err error) bool {
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) {
+		return false
+	}
+	switch statusErr.StatusCode {
+	case http.StatusRequestEntityTooLarge:
+		return true
+	case http.StatusBadRequest:
+		body := strings.ToLower(statusErr.Body)
+		for _, msg := range tokenLimitMessages {
+			if strings.Contains(body, msg) {
+				return true
+			}
+		}
+	}
+	return false
+}
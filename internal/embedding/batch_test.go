@@ -0,0 +1,162 @@
+package embedding
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// openAIStub answers /v1/embeddings requests with respond, recording the
+// inputs of each request
+type openAIStub struct {
+	mu      sync.Mutex
+	inputs  [][]string
+	inspect func(req openAIEmbedRequest) // optional; sees every request
+	respond func(w http.ResponseWriter, inputs []string, call int)
+}
+
+func (s *openAIStub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var req openAIEmbedRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var inputs []string
+	switch input := req.Input.(type) {
+	case string:
+		inputs = []string{input}
+	case []interface{}:
+		for _, v := range input {
+			inputs = append(inputs, v.(string))
+		}
+	}
+	s.mu.Lock()
+	s.inputs = append(s.inputs, inputs)
+	call := len(s.inputs)
+	if s.inspect != nil {
+		s.inspect(req)
+	}
+	s.mu.Unlock()
+	s.respond(w, inputs, call)
+}
+
+func embedAll(w http.ResponseWriter, inputs []string, skip func(i int, text string) bool) {
+	resp := openAIEmbedResponse{}
+	for i, text := range inputs {
+		if skip != nil && skip(i, text) {
+			continue
+		}
+		resp.Data = append(resp.Data, openAIEmbedData{Embedding: []float32{float32(len(text)), 1}, Index: i})
+	}
+	json.NewEncoder(w).Encode(resp)
+}
+
+func newStubbedOpenAIClient(t *testing.T, stub *openAIStub) *OpenAIClient {
+	t.Helper()
+	srv := httptest.NewServer(stub)
+	t.Cleanup(srv.Close)
+	client := NewOpenAIClient(srv.URL, "")
+	recordSleeps(&client.transport)
+	return client
+}
+
+func TestBatchEmbedResendsMissingItems(t *testing.T) {
+	stub := &openAIStub{respond: func(w http.ResponseWriter, inputs []string, call int) {
+		// The first response drops the last item
+		embedAll(w, inputs, func(i int, _ string) bool { return call == 1 && i == len(inputs)-1 })
+	}}
+	client := newStubbedOpenAIClient(t, stub)
+
+	vecs, err := client.BatchEmbedContents("m", []string{"a", "bb", "ccc"}, TaskRetrievalDocument, 0)
+	if err != nil {
+		t.Fatalf("BatchEmbedContents() error = %v", err)
+	}
+	for i, vec := range vecs {
+		if len(vec) == 0 || vec[0] != float32(i+1) {
+			t.Fatalf("vecs[%d] = %v", i, vec)
+		}
+	}
+	if len(stub.inputs) != 2 || len(stub.inputs[1]) != 1 || stub.inputs[1][0] != "ccc" {
+		t.Fatalf("requests = %v, want the missing item re-sent alone", stub.inputs)
+	}
+}
+
+func TestBatchEmbedReportsItemsStillMissing(t *testing.T) {
+	stub := &openAIStub{respond: func(w http.ResponseWriter, inputs []string, call int) {
+		// "bad" is always answered with an out-of-range index
+		resp := openAIEmbedResponse{}
+		for i, text := range inputs {
+			index := i
+			if text == "bad" {
+				index = len(inputs) + 5
+			}
+			resp.Data = append(resp.Data, openAIEmbedData{Embedding: []float32{1}, Index: index})
+		}
+		json.NewEncoder(w).Encode(resp)
+	}}
+	client := newStubbedOpenAIClient(t, stub)
+
+	vecs, err := client.BatchEmbedContents("m", []string{"a", "bad", "c"}, TaskRetrievalDocument, 0)
+	var batchErr *BatchError
+	if !errors.As(err, &batchErr) {
+		t.Fatalf("error = %v, want *BatchError", err)
+	}
+	if len(batchErr.Items) != 1 || batchErr.Items[0].Index != 1 {
+		t.Fatalf("failed items = %+v, want item 1", batchErr.Items)
+	}
+	if len(vecs) != 3 || vecs[0] == nil || vecs[1] != nil || vecs[2] == nil {
+		t.Fatalf("vecs = %v, want only item 1 nil", vecs)
+	}
+}
+
+func TestBatchEmbedSplitsOversizedBatches(t *testing.T) {
+	stub := &openAIStub{respond: func(w http.ResponseWriter, inputs []string, call int) {
+		if len(inputs) > 2 {
+			http.Error(w, "request entity too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+		for _, text := range inputs {
+			if strings.HasPrefix(text, "huge") {
+				http.Error(w, `{"error":"This model's maximum context length is 8192 tokens"}`, http.StatusBadRequest)
+				return
+			}
+		}
+		embedAll(w, inputs, nil)
+	}}
+	client := newStubbedOpenAIClient(t, stub)
+
+	texts := []string{"a", "b", "c", "huge d", "e"}
+	vecs, err := client.BatchEmbedContents("m", texts, TaskRetrievalDocument, 0)
+	var batchErr *BatchError
+	if !errors.As(err, &batchErr) {
+		t.Fatalf("error = %v, want *BatchError", err)
+	}
+	if len(batchErr.Items) != 1 || batchErr.Items[0].Index != 3 {
+		t.Fatalf("failed items = %+v, want item 3", batchErr.Items)
+	}
+	for i, vec := range vecs {
+		if (i == 3) != (vec == nil) {
+			t.Fatalf("vecs[%d] = %v", i, vec)
+		}
+	}
+}
+
+func TestBatchEmbedReturnsRequestErrors(t *testing.T) {
+	stub := &openAIStub{respond: func(w http.ResponseWriter, inputs []string, call int) {
+		http.Error(w, "invalid api key", http.StatusUnauthorized)
+	}}
+	client := newStubbedOpenAIClient(t, stub)
+
+	_, err := client.BatchEmbedContents("m", []string{"a", "b"}, TaskRetrievalDocument, 0)
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("error = %v, want StatusError 401", err)
+	}
+	if len(stub.inputs) != 1 {
+		t.Fatalf("requests = %d, want 1", len(stub.inputs))
+	}
+}
@@ -27,7 +27,49 @@ type MultimodalContent struct {
 
 // MultimodalEmbedder is an optional interface for clients that support
 // multimodal embedding (images, PDF, video, audio).
-// Clients that don't support this (e.g., OpenAI-compatible) don't implement it.
+// Clients that don't support this (e.g., Cohere) don't implement it; those
+// that do only with some servers or models also implement ContentFilter.
 type MultimodalEmbedder interface {
 	EmbedMultimodalContent(model string, content MultimodalContent, taskType TaskType, dimension int) ([]float32, error)
 }
+
+// ContentFilter is optionally implemented by MultimodalEmbedders that accept
+// only some content types, or only with some models (e.g. image-capable
+// Ollama models). Content they reject is handled as with text-only backends.
+type ContentFilter interface {
+	SupportsContent(model, mimeType string) bool
+}
+
+// SupportsMultimodal reports whether client can embed content of mimeType
+// with model
+func SupportsMultimodal(client Client, model, mimeType string) bool {
+	if _, ok := client.(MultimodalEmbedder); !ok {
+		return false
+	}
+	if filter, ok := client.(ContentFilter); ok {
+		return filter.SupportsContent(model, mimeType)
+	}
+	return true
+}
+
+// BackendReporter is implemented by clients that spread requests over
+// several backends (see FallbackClient). Its methods also return the name of
+// the backend that served the request, including the one whose *BatchError
+// lists the texts it failed to embed.
+type BackendReporter interface {
+	BatchEmbedContentsWithBackend(model string, texts []string, taskType TaskType, dimension int) ([][]float32, string, error)
+	EmbedMultimodalContentWithBackend(model string, content MultimodalContent, taskType TaskType, dimension int) ([]float32, string, error)
+}
+
+// RetryConfigurable is implemented by clients whose retry and rate-limit
+// behaviour can be tuned (see RetryConfig)
+type RetryConfigurable interface {
+	SetRetryConfig(cfg RetryConfig)
+}
+
+// ModelResolver is implemented by clients of servers that know which models
+// they serve (Ollama, TEI). ResolveModel checks model, or picks one when
+// model is empty.
+type ModelResolver interface {
+	ResolveModel(model string) (string, error)
+}
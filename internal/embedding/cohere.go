@@ -0,0 +1,116 @@
+package embedding
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+const (
+	// DefaultCohereURL is the address of the Cohere API
+	DefaultCohereURL = "https://api.cohere.com"
+	cohereMaxBatch   = 96 // texts per embed request
+)
+
+// CohereClient is an embedding client for Cohere's v2 embed API. Queries and
+// documents are told apart with input_type instead of prompt prefixes.
+type CohereClient struct {
+	transport
+	baseURL string
+	apiKey  string
+}
+
+// NewCohereClient creates a new Cohere embedding client
+func NewCohereClient(baseURL, apiKey string) *CohereClient {
+	if baseURL == "" {
+		baseURL = DefaultCohereURL
+	}
+	return &CohereClient{
+		transport: newTransport(),
+		baseURL:   strings.TrimRight(baseURL, "/"),
+		apiKey:    apiKey,
+	}
+}
+
+type cohereEmbedRequest struct {
+	Model           string   `json:"model"`
+	Texts           []string `json:"texts"`
+	InputType       string   `json:"input_type"`
+	EmbeddingTypes  []string `json:"embedding_types"`
+	OutputDimension int      `json:"output_dimension,omitempty"`
+}
+
+type cohereEmbedResponse struct {
+	Embeddings struct {
+		Float [][]float32 `json:"float"`
+	} `json:"embeddings"`
+}
+
+// cohereInputType maps a task type to Cohere's input_type, which the API
+// requires
+func cohereInputType(taskType TaskType) string {
+	switch taskType {
+	case TaskRetrievalQuery:
+		return "search_query"
+	case TaskClassification:
+		return "classification"
+	case TaskClustering:
+		return "clustering"
+	}
+	return "search_document"
+}
+
+func (c *CohereClient) embed(model string, texts []string, taskType TaskType, dimension int) ([][]float32, error) {
+	jsonBody, err := json.Marshal(cohereEmbedRequest{
+		Model:           model,
+		Texts:           texts,
+		InputType:       cohereInputType(taskType),
+		EmbeddingTypes:  []string{"float"},
+		OutputDimension: dimension,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	header := http.Header{}
+	header.Set("Authorization", "Bearer "+c.apiKey)
+	body, err := c.post("Cohere embed", c.baseURL+"/v2/embed", header, jsonBody, estimateTokens(texts...))
+	if err != nil {
+		return nil, err
+	}
+
+	var embedResp cohereEmbedResponse
+	if err := json.Unmarshal(body, &embedResp); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	// Embeddings are returned in input order; a response of the wrong
+	// length can't be matched to the texts, so all items count as missing
+	result := make([][]float32, len(texts))
+	if len(embedResp.Embeddings.Float) == len(texts) {
+		copy(result, embedResp.Embeddings.Float)
+	}
+	return result, nil
+}
+
+// EmbedContent generates an embedding for a single text
+func (c *CohereClient) EmbedContent(model, text string, taskType TaskType, dimension int) ([]float32, error) {
+	embeddings, err := c.embed(model, []string{text}, taskType, dimension)
+	if err != nil {
+		return nil, err
+	}
+	if len(embeddings[0]) == 0 {
+		return nil, fmt.Errorf("empty embedding response")
+	}
+	return embeddings[0], nil
+}
+
+// BatchEmbedContents generates embeddings for multiple texts. Batches the
+// API rejects as too large are split; items that still fail are reported in
+// a *BatchError.
+func (c *CohereClient) BatchEmbedContents(model string, texts []string, taskType TaskType, dimension int) ([][]float32, error) {
+	return embedBatch(texts, cohereMaxBatch, func(texts []string) ([][]float32, error) {
+		return c.embed(model, texts, taskType, dimension)
+	})
+}
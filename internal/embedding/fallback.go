@@ -0,0 +1,279 @@
+package embedding
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Backend is one member of a FallbackClient
+type Backend struct {
+	Name   string // shown in warnings and recorded with the chunks it embedded
+	Client Client
+	Model  string // model the backend serves ("" = whatever model is requested)
+}
+
+// BackendStatus reports the health of a FallbackClient backend
+type BackendStatus struct {
+	Name     string
+	Served   int  // requests served
+	Failures int  // consecutive failed requests
+	Open     bool // circuit open: skipped until its cooldown ends
+}
+
+type fallbackBackend struct {
+	Backend
+	served    int
+	failures  int
+	openUntil time.Time
+}
+
+// FallbackClient sends each request to the first healthy backend of an
+// ordered list and fails over to the next one when a backend is unavailable
+// (rate limited, server or network errors, rejected key). A backend that
+// fails BreakerThreshold times in a row is skipped for BreakerCooldown; when
+// every backend is skipped, the one whose cooldown ends first is tried.
+// Request errors (e.g. input too long) are returned without failing over.
+//
+// All backends must produce compatible vectors: a backend serving another
+// model than the requested one is refused.
+type FallbackClient struct {
+	mu        sync.Mutex
+	backends  []*fallbackBackend
+	threshold int
+	cooldown  time.Duration
+	now       func() time.Time
+}
+
+// NewFallbackClient creates a client that tries backends in order
+func NewFallbackClient(backends ...Backend) *FallbackClient {
+	defaults := DefaultRetryConfig()
+	c := &FallbackClient{
+		threshold: defaults.BreakerThreshold,
+		cooldown:  defaults.BreakerCooldown,
+		now:       time.Now,
+	}
+	for _, b := range backends {
+		c.backends = append(c.backends, &fallbackBackend{Backend: b})
+	}
+	return c
+}
+
+// Backends returns the backends of c, in order
+func (c *FallbackClient) Backends() []Backend {
+	backends := make([]Backend, len(c.backends))
+	for i, b := range c.backends {
+		backends[i] = b.Backend
+	}
+	return backends
+}
+
+// Status returns the health of each backend, in order
+func (c *FallbackClient) Status() []BackendStatus {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := c.now()
+	status := make([]BackendStatus, len(c.backends))
+	for i, b := range c.backends {
+		status[i] = BackendStatus{Name: b.Name, Served: b.served, Failures: b.failures, Open: now.Before(b.openUntil)}
+	}
+	return status
+}
+
+// SetRetryConfig passes cfg on to the backends and applies its circuit
+// breaker settings
+func (c *FallbackClient) SetRetryConfig(cfg RetryConfig) {
+	for _, b := range c.backends {
+		if rc, ok := b.Client.(RetryConfigurable); ok {
+			rc.SetRetryConfig(cfg)
+		}
+	}
+	if cfg.BreakerThreshold > 0 {
+		c.threshold = cfg.BreakerThreshold
+	}
+	if cfg.BreakerCooldown > 0 {
+		c.cooldown = cfg.BreakerCooldown
+	}
+}
+
+// ResolveModel resolves model with the first backend if it can (see
+// ModelResolver); the others must then serve the same model
+func (c *FallbackClient) ResolveModel(model string) (string, error) {
+	if resolver, ok := c.backends[0].Client.(ModelResolver); ok {
+		return resolver.ResolveModel(model)
+	}
+	return model, nil
+}
+
+// EmbedContent generates an embedding for a single text
+func (c *FallbackClient) EmbedContent(model, text string, taskType TaskType, dimension int) ([]float32, error) {
+	var result []float32
+	_, err := c.call(model, nil, func(client Client) error {
+		var err error
+		result, err = client.EmbedContent(model, text, taskType, dimension)
+		return err
+	})
+	return result, err
+}
+
+// BatchEmbedContents generates embeddings for multiple texts. A
+// *BatchError (some texts failed) is returned as is.
+func (c *FallbackClient) BatchEmbedContents(model string, texts []string, taskType TaskType, dimension int) ([][]float32, error) {
+	result, _, err := c.BatchEmbedContentsWithBackend(model, texts, taskType, dimension)
+	return result, err
+}
+
+// BatchEmbedContentsWithBackend is BatchEmbedContents that also returns the
+// backend that served the request
+func (c *FallbackClient) BatchEmbedContentsWithBackend(model string, texts []string, taskType TaskType, dimension int) ([][]float32, string, error) {
+	var result [][]float32
+	backend, err := c.call(model, nil, func(client Client) error {
+		var err error
+		result, err = client.BatchEmbedContents(model, texts, taskType, dimension)
+		return err
+	})
+	return result, backend, err
+}
+
+// EmbedMultimodalContent generates an embedding for binary content with the
+// backends that support it
+func (c *FallbackClient) EmbedMultimodalContent(model string, content MultimodalContent, taskType TaskType, dimension int) ([]float32, error) {
+	result, _, err := c.EmbedMultimodalContentWithBackend(model, content, taskType, dimension)
+	return result, err
+}
+
+// EmbedMultimodalContentWithBackend is EmbedMultimodalContent that also
+// returns the backend that served the request
+func (c *FallbackClient) EmbedMultimodalContentWithBackend(model string, content MultimodalContent, taskType TaskType, dimension int) ([]float32, string, error) {
+	supports := func(b Backend) bool {
+		return SupportsMultimodal(b.Client, model, content.MIMEType)
+	}
+	var result []float32
+	backend, err := c.call(model, supports, func(client Client) error {
+		var err error
+		result, err = client.(MultimodalEmbedder).EmbedMultimodalContent(model, content, taskType, dimension)
+		return err
+	})
+	return result, backend, err
+}
+
+// SupportsContent reports whether any backend can embed content of mimeType
+func (c *FallbackClient) SupportsContent(model, mimeType string) bool {
+	for _, b := range c.backends {
+		if SupportsMultimodal(b.Client, model, mimeType) {
+			return true
+		}
+	}
+	return false
+}
+
+// call runs fn with each candidate backend until one succeeds or fails with
+// an error that another backend would not avoid, and returns the name of the
+// backend that ran it last
+func (c *FallbackClient) call(model string, supports func(Backend) bool, fn func(Client) error) (string, error) {
+	candidates, err := c.candidates(model, supports)
+	if err != nil {
+		return "", err
+	}
+	var lastErr error
+	for i, b := range candidates {
+		err := fn(b.Client)
+		if err != nil && !isFailoverError(err) {
+			var batchErr *BatchError
+			if errors.As(err, &batchErr) {
+				c.record(b, nil) // served, only some texts were rejected
+			}
+			return b.Name, err
+		}
+		c.record(b, err)
+		if err == nil {
+			return b.Name, nil
+		}
+		lastErr = err
+		if i < len(candidates)-1 {
+			fmt.Fprintf(os.Stderr, "Warning: embedding backend %s failed, trying %s: %v\n", b.Name, candidates[i+1].Name, err)
+		}
+	}
+	if len(candidates) == 1 {
+		return "", lastErr
+	}
+	return "", fmt.Errorf("all embedding backends failed: %w", lastErr)
+}
+
+// candidates returns the backends to try, in order: those supporting the
+// request whose circuit is closed, else the one that reopens first
+func (c *FallbackClient) candidates(model string, supports func(Backend) bool) ([]*fallbackBackend, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, b := range c.backends {
+		if b.Model != "" && trimModelPrefix(b.Model) != trimModelPrefix(model) {
+			return nil, fmt.Errorf("embedding backend %s serves model %s, but %s was requested; every backend of a fallback chain must serve the store's model", b.Name, b.Model, model)
+		}
+	}
+
+	now := c.now()
+	var healthy []*fallbackBackend
+	var probe *fallbackBackend
+	for _, b := range c.backends {
+		if supports != nil && !supports(b.Backend) {
+			continue
+		}
+		if now.Before(b.openUntil) {
+			if probe == nil || b.openUntil.Before(probe.openUntil) {
+				probe = b
+			}
+			continue
+		}
+		healthy = append(healthy, b)
+	}
+	if len(healthy) > 0 {
+		return healthy, nil
+	}
+	if probe != nil {
+		return []*fallbackBackend{probe}, nil
+	}
+	return nil, fmt.Errorf("no embedding backend supports this content")
+}
+
+// record updates the health of b after a request that ended with err
+func (c *FallbackClient) record(b *fallbackBackend, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err == nil {
+		b.served++
+		b.failures = 0
+		b.openUntil = time.Time{}
+		return
+	}
+	b.failures++
+	if b.failures >= c.threshold && len(c.backends) > 1 {
+		b.openUntil = c.now().Add(c.cooldown)
+		fmt.Fprintf(os.Stderr, "Warning: embedding backend %s failed %d times in a row; skipping it for %s\n", b.Name, b.failures, c.cooldown)
+	}
+}
+
+// isFailoverError reports whether another backend might succeed where err
+// occurred: the backend is unavailable rather than the request invalid
+func isFailoverError(err error) bool {
+	var batchErr *BatchError
+	if errors.As(err, &batchErr) {
+		return false
+	}
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		switch statusErr.StatusCode {
+		case http.StatusUnauthorized, http.StatusForbidden, http.StatusRequestTimeout, http.StatusTooManyRequests:
+			return true
+		}
+		return statusErr.StatusCode >= 500
+	}
+	return true
+}
+
+func trimModelPrefix(model string) string {
+	return strings.TrimPrefix(model, "models/")
+}
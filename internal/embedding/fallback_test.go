@@ -0,0 +1,243 @@
+package embedding
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// flakyClient answers with err (if set) or a one-element vector of value
+type flakyClient struct {
+	err   error
+	value float32
+	calls int
+}
+
+func (c *flakyClient) EmbedContent(model, text string, taskType TaskType, dimension int) ([]float32, error) {
+	c.calls++
+	if c.err != nil {
+		return nil, c.err
+	}
+	return []float32{c.value}, nil
+}
+
+func (c *flakyClient) BatchEmbedContents(model string, texts []string, taskType TaskType, dimension int) ([][]float32, error) {
+	out := make([][]float32, len(texts))
+	for i, text := range texts {
+		vec, err := c.EmbedContent(model, text, taskType, dimension)
+		if err != nil {
+			return nil, err
+		}
+		out[i] = vec
+	}
+	return out, nil
+}
+
+func TestFallbackClientFailsOverOnUnavailableBackends(t *testing.T) {
+	primary := &flakyClient{err: &StatusError{Op: "embed", StatusCode: http.StatusTooManyRequests}}
+	secondary := &flakyClient{value: 2}
+	c := NewFallbackClient(Backend{Name: "a", Client: primary}, Backend{Name: "b", Client: secondary})
+
+	vecs, backend, err := c.BatchEmbedContentsWithBackend("m", []string{"x", "y"}, TaskRetrievalDocument, 1)
+	if err != nil {
+		t.Fatalf("BatchEmbedContents() error = %v", err)
+	}
+	if vecs[0][0] != 2 || backend != "b" {
+		t.Fatalf("served by %q with %v, want b", backend, vecs)
+	}
+
+	// A rejected request would fail everywhere: no failover
+	primary.err = &StatusError{Op: "embed", StatusCode: http.StatusBadRequest}
+	secondary.calls = 0
+	if _, err := c.EmbedContent("m", "x", TaskRetrievalQuery, 1); err == nil || secondary.calls != 0 {
+		t.Fatalf("request error: err = %v, secondary calls = %d", err, secondary.calls)
+	}
+
+	// Every backend down
+	primary.err = fmt.Errorf("connection refused")
+	secondary.err = &StatusError{Op: "embed", StatusCode: http.StatusServiceUnavailable}
+	if _, err := c.EmbedContent("m", "x", TaskRetrievalQuery, 1); err == nil || !strings.Contains(err.Error(), "all embedding backends failed") {
+		t.Fatalf("all down error = %v", err)
+	}
+}
+
+// partialClient embeds every text but the first
+type partialClient struct{ flakyClient }
+
+func (c *partialClient) BatchEmbedContents(model string, texts []string, taskType TaskType, dimension int) ([][]float32, error) {
+	out, _ := c.flakyClient.BatchEmbedContents(model, texts, taskType, dimension)
+	out[0] = nil
+	return out, &BatchError{Items: []ItemError{{Index: 0, Err: fmt.Errorf("too long")}}}
+}
+
+func TestFallbackClientReportsBackendOfPartialBatch(t *testing.T) {
+	primary := &flakyClient{err: &StatusError{Op: "embed", StatusCode: http.StatusServiceUnavailable}}
+	secondary := &partialClient{flakyClient{value: 2}}
+	c := NewFallbackClient(Backend{Name: "a", Client: primary}, Backend{Name: "b", Client: secondary})
+
+	vecs, backend, err := c.BatchEmbedContentsWithBackend("m", []string{"x", "y"}, TaskRetrievalDocument, 1)
+	var batchErr *BatchError
+	if !errors.As(err, &batchErr) {
+		t.Fatalf("error = %v, want the *BatchError", err)
+	}
+	if backend != "b" || vecs[1][0] != 2 {
+		t.Fatalf("served by %q with %v, want b", backend, vecs)
+	}
+	if status := c.Status(); status[1].Served != 1 {
+		t.Fatalf("partial batch not recorded as served: %+v", status)
+	}
+}
+
+func TestFallbackClientCircuitBreaker(t *testing.T) {
+	primary := &flakyClient{err: &StatusError{Op: "embed", StatusCode: http.StatusInternalServerError}}
+	secondary := &flakyClient{value: 2}
+	c := NewFallbackClient(Backend{Name: "a", Client: primary}, Backend{Name: "b", Client: secondary})
+	now := time.Unix(1000, 0)
+	c.now = func() time.Time { return now }
+	c.SetRetryConfig(RetryConfig{BreakerThreshold: 2, BreakerCooldown: time.Minute})
+
+	for i := 0; i < 4; i++ {
+		if _, err := c.EmbedContent("m", "x", TaskRetrievalQuery, 1); err != nil {
+			t.Fatalf("EmbedContent() error = %v", err)
+		}
+	}
+	if primary.calls != 2 {
+		t.Fatalf("open backend was called %d times, want 2 (then skipped)", primary.calls)
+	}
+	status := c.Status()
+	if !status[0].Open || status[0].Failures != 2 || status[1].Served != 4 {
+		t.Fatalf("status = %+v", status)
+	}
+
+	// After the cooldown the backend is tried again and closes on success
+	now = now.Add(2 * time.Minute)
+	primary.err = nil
+	primary.value = 1
+	if vec, _ := c.EmbedContent("m", "x", TaskRetrievalQuery, 1); vec[0] != 1 {
+		t.Fatalf("recovered backend not used: %v", vec)
+	}
+	if status := c.Status(); status[0].Open || status[0].Failures != 0 {
+		t.Fatalf("status after recovery = %+v", status[0])
+	}
+
+	// With every backend open, the one reopening first is probed
+	primary.err = fmt.Errorf("down")
+	secondary.err = fmt.Errorf("down")
+	for i := 0; i < 2; i++ {
+		c.EmbedContent("m", "x", TaskRetrievalQuery, 1)
+	}
+	primary.calls, secondary.calls = 0, 0
+	c.EmbedContent("m", "x", TaskRetrievalQuery, 1)
+	if primary.calls+secondary.calls != 1 {
+		t.Fatalf("all open: %d calls, want a single probe", primary.calls+secondary.calls)
+	}
+}
+
+func TestFallbackClientRefusesOtherModels(t *testing.T) {
+	c := NewFallbackClient(
+		Backend{Name: "a", Client: &flakyClient{value: 1}},
+		Backend{Name: "b", Client: &flakyClient{value: 2}, Model: "text-embedding-3-small"},
+	)
+	if _, err := c.EmbedContent("nomic-embed-text", "x", TaskRetrievalQuery, 1); err == nil || !strings.Contains(err.Error(), "serves model text-embedding-3-small") {
+		t.Fatalf("EmbedContent(other model) error = %v", err)
+	}
+	if _, err := c.EmbedContent("models/text-embedding-3-small", "x", TaskRetrievalQuery, 1); err != nil {
+		t.Fatalf("EmbedContent(same model) error = %v", err)
+	}
+}
+
+func TestFallbackClientMultimodalUsesCapableBackends(t *testing.T) {
+	c := NewFallbackClient(
+		Backend{Name: "text", Client: &flakyClient{value: 1}},
+		Backend{Name: "local", Client: NewLocalClient()},
+	)
+	if !SupportsMultimodal(c, "", "image/png") {
+		t.Fatal("chain with a multimodal backend should support images")
+	}
+	_, backend, err := c.EmbedMultimodalContentWithBackend("", MultimodalContent{MIMEType: "image/png", Data: []byte("png")}, TaskRetrievalDocument, 8)
+	if err != nil {
+		t.Fatalf("EmbedMultimodalContent() error = %v", err)
+	}
+	if backend != "local" {
+		t.Fatalf("served by %q, want local", backend)
+	}
+	if SupportsMultimodal(NewFallbackClient(Backend{Name: "text", Client: &flakyClient{}}), "", "image/png") {
+		t.Fatal("text-only chain should not support images")
+	}
+}
+
+func TestProviderRotatesAPIKeys(t *testing.T) {
+	rs := newRecordingServer(t, nil)
+	rs.respond = func(w http.ResponseWriter, body map[string]interface{}) {
+		rs.mu.Lock()
+		last := rs.urls[len(rs.urls)-1]
+		rs.mu.Unlock()
+		if strings.Contains(last, "key=k1") {
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.Write([]byte(`{"embedding": {"values": [0.5, 0.5]}}`))
+	}
+
+	gemini, _ := LookupProvider("gemini")
+	client, err := gemini.NewClient(ProviderOptions{URL: rs.endpoint.URL, APIKey: "k1, k2", Retry: &RetryConfig{}})
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+	chain, ok := client.(*FallbackClient)
+	if !ok {
+		t.Fatalf("client = %T, want *FallbackClient", client)
+	}
+	if _, err := chain.EmbedContent("gemini-embedding-001", "x", TaskRetrievalQuery, 2); err != nil {
+		t.Fatalf("EmbedContent() error = %v", err)
+	}
+	if status := chain.Status(); status[1].Name != "gemini#2" || status[1].Served != 1 || len(rs.urls) != 2 {
+		t.Fatalf("status %+v after %d requests, want gemini#2 served after 2", status, len(rs.urls))
+	}
+}
+
+func TestParseFallbackSpec(t *testing.T) {
+	spec, err := ParseFallbackSpec("openai,url=http://gpu2:8000,key=sk-1,model=nomic-embed-text")
+	if err != nil {
+		t.Fatalf("ParseFallbackSpec() error = %v", err)
+	}
+	if spec != (FallbackSpec{Provider: "openai", URL: "http://gpu2:8000", APIKey: "sk-1", Model: "nomic-embed-text"}) {
+		t.Fatalf("spec = %+v", spec)
+	}
+	for _, bad := range []string{"", ",url=x", "openai,url", "openai,region=eu"} {
+		if _, err := ParseFallbackSpec(bad); err == nil {
+			t.Errorf("ParseFallbackSpec(%q) error = nil", bad)
+		}
+	}
+}
+
+func TestNewFallbackChain(t *testing.T) {
+	primary := &flakyClient{value: 1}
+	if client, _ := NewFallbackChain(primary, "openai", nil, ProviderOptions{}); client != primary {
+		t.Fatal("no fallbacks should return the primary client")
+	}
+
+	client, err := NewFallbackChain(primary, "openai", []FallbackSpec{
+		{Provider: "openai", URL: "http://backup:8000", APIKey: "k", Model: "m"},
+		{Provider: "local"},
+	}, ProviderOptions{})
+	if err != nil {
+		t.Fatalf("NewFallbackChain() error = %v", err)
+	}
+	backends := client.(*FallbackClient).Backends()
+	var names, models []string
+	for _, b := range backends {
+		names = append(names, b.Name)
+		models = append(models, b.Model)
+	}
+	if strings.Join(names, " ") != "openai openai(2) local" || strings.Join(models, " ") != " m "+LocalModel {
+		t.Fatalf("backends %q models %q", names, models)
+	}
+
+	if _, err := NewFallbackChain(primary, "openai", []FallbackSpec{{Provider: "nope"}}, ProviderOptions{}); err == nil {
+		t.Fatal("unknown fallback provider should fail")
+	}
+}
@@ -1,38 +1,29 @@
 package embedding
 
 import (
-	"bytes"
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
-	"io"
-	"net/http"
-	"os"
-	"time"
 )
 
 const (
-	maxRetries   = 3
-	retryBaseWait = 5 * time.Second
-)
-
-const (
-	geminiBaseURL = "https://generativelanguage.googleapis.com/v1beta"
+	geminiBaseURL  = "https://generativelanguage.googleapis.com/v1beta"
+	geminiMaxBatch = 100 // requests per batchEmbedContents call
 )
 
 // GeminiClient is a Gemini Embedding API client
 type GeminiClient struct {
-	apiKey     string
-	httpClient *http.Client
+	transport
+	apiKey  string
+	baseURL string
 }
 
 // NewGeminiClient creates a new Gemini Embedding API client
 func NewGeminiClient(apiKey string) *GeminiClient {
 	return &GeminiClient{
-		apiKey: apiKey,
-		httpClient: &http.Client{
-			Timeout: 5 * time.Minute,
-		},
+		transport: newTransport(),
+		apiKey:    apiKey,
+		baseURL:   geminiBaseURL,
 	}
 }
 
@@ -73,61 +64,25 @@ type geminiBatchResponse struct {
 	Embeddings []geminiEmbeddingValues `json:"embeddings"`
 }
 
-// isRetryableStatus returns true for HTTP status codes that should be retried
-func isRetryableStatus(statusCode int) bool {
-	return statusCode == http.StatusServiceUnavailable || statusCode == http.StatusTooManyRequests
-}
-
 // doEmbedRequest sends an embedContent request and returns the embedding values
-func (c *GeminiClient) doEmbedRequest(model string, reqBody geminiEmbedRequest) ([]float32, error) {
-	url := fmt.Sprintf("%s/models/%s:embedContent?key=%s", geminiBaseURL, model, c.apiKey)
+func (c *GeminiClient) doEmbedRequest(model string, reqBody geminiEmbedRequest, tokens int) ([]float32, error) {
+	url := fmt.Sprintf("%s/models/%s:embedContent?key=%s", c.baseURL, model, c.apiKey)
 
 	jsonBody, err := json.Marshal(reqBody)
 	if err != nil {
 		return nil, fmt.Errorf("failed to marshal request: %w", err)
 	}
 
-	var lastErr error
-	for attempt := 0; attempt <= maxRetries; attempt++ {
-		if attempt > 0 {
-			wait := retryBaseWait * time.Duration(attempt)
-			fmt.Fprintf(os.Stderr, "Retrying embedContent (attempt %d/%d, wait %s)...\n", attempt, maxRetries, wait)
-			time.Sleep(wait)
-		}
-
-		req, err := http.NewRequest("POST", url, bytes.NewReader(jsonBody))
-		if err != nil {
-			return nil, fmt.Errorf("failed to create request: %w", err)
-		}
-		req.Header.Set("Content-Type", "application/json")
-
-		resp, err := c.httpClient.Do(req)
-		if err != nil {
-			lastErr = fmt.Errorf("failed to embed content: %w", err)
-			continue
-		}
-
-		body, err := io.ReadAll(resp.Body)
-		resp.Body.Close()
-		if err != nil {
-			return nil, fmt.Errorf("failed to read response: %w", err)
-		}
-
-		if resp.StatusCode == http.StatusOK {
-			var embedResp geminiEmbedResponse
-			if err := json.Unmarshal(body, &embedResp); err != nil {
-				return nil, fmt.Errorf("failed to parse response: %w", err)
-			}
-			return embedResp.Embedding.Values, nil
-		}
-
-		lastErr = fmt.Errorf("embed content failed with status %d: %s", resp.StatusCode, string(body))
-		if !isRetryableStatus(resp.StatusCode) {
-			return nil, lastErr
-		}
+	body, err := c.post("embed content", url, nil, jsonBody, tokens)
+	if err != nil {
+		return nil, err
 	}
 
-	return nil, lastErr
+	var embedResp geminiEmbedResponse
+	if err := json.Unmarshal(body, &embedResp); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+	return embedResp.Embedding.Values, nil
 }
 
 // EmbedContent generates an embedding for a single text
@@ -142,7 +97,7 @@ func (c *GeminiClient) EmbedContent(model, text string, taskType TaskType, dimen
 	if dimension > 0 {
 		reqBody.OutputDimensionality = dimension
 	}
-	return c.doEmbedRequest(model, reqBody)
+	return c.doEmbedRequest(model, reqBody, estimateTokens(text))
 }
 
 // EmbedMultimodalContent generates an embedding for multimodal content (image, PDF, video, audio)
@@ -162,12 +117,22 @@ func (c *GeminiClient) EmbedMultimodalContent(model string, content MultimodalCo
 	if dimension > 0 {
 		reqBody.OutputDimensionality = dimension
 	}
-	return c.doEmbedRequest(model, reqBody)
+	// Media token counts aren't known up front; only the request limit applies
+	return c.doEmbedRequest(model, reqBody, 0)
 }
 
-// BatchEmbedContents generates embeddings for multiple texts
+// BatchEmbedContents generates embeddings for multiple texts.
+// Batches the API rejects as too large are split; items that still fail are
+// reported in a *BatchError.
 func (c *GeminiClient) BatchEmbedContents(model string, texts []string, taskType TaskType, dimension int) ([][]float32, error) {
-	url := fmt.Sprintf("%s/models/%s:batchEmbedContents?key=%s", geminiBaseURL, model, c.apiKey)
+	return embedBatch(texts, geminiMaxBatch, func(texts []string) ([][]float32, error) {
+		return c.batchEmbed(model, texts, taskType, dimension)
+	})
+}
+
+// batchEmbed sends one batchEmbedContents request
+func (c *GeminiClient) batchEmbed(model string, texts []string, taskType TaskType, dimension int) ([][]float32, error) {
+	url := fmt.Sprintf("%s/models/%s:batchEmbedContents?key=%s", c.baseURL, model, c.apiKey)
 
 	requests := make([]geminiEmbedRequest, len(texts))
 	for i, text := range texts {
@@ -191,50 +156,24 @@ func (c *GeminiClient) BatchEmbedContents(model string, texts []string, taskType
 		return nil, fmt.Errorf("failed to marshal request: %w", err)
 	}
 
-	var lastErr error
-	for attempt := 0; attempt <= maxRetries; attempt++ {
-		if attempt > 0 {
-			wait := retryBaseWait * time.Duration(attempt)
-			fmt.Fprintf(os.Stderr, "Retrying batchEmbedContents (attempt %d/%d, wait %s)...\n", attempt, maxRetries, wait)
-			time.Sleep(wait)
-		}
-
-		req, err := http.NewRequest("POST", url, bytes.NewReader(jsonBody))
-		if err != nil {
-			return nil, fmt.Errorf("failed to create request: %w", err)
-		}
-		req.Header.Set("Content-Type", "application/json")
-
-		resp, err := c.httpClient.Do(req)
-		if err != nil {
-			lastErr = fmt.Errorf("failed to batch embed: %w", err)
-			continue
-		}
-
-		body, err := io.ReadAll(resp.Body)
-		resp.Body.Close()
-		if err != nil {
-			return nil, fmt.Errorf("failed to read response: %w", err)
-		}
-
-		if resp.StatusCode == http.StatusOK {
-			var batchResp geminiBatchResponse
-			if err := json.Unmarshal(body, &batchResp); err != nil {
-				return nil, fmt.Errorf("failed to parse response: %w", err)
-			}
-
-			result := make([][]float32, len(batchResp.Embeddings))
-			for i, emb := range batchResp.Embeddings {
-				result[i] = emb.Values
-			}
-			return result, nil
-		}
+	body, err := c.post("batch embed", url, nil, jsonBody, estimateTokens(texts...))
+	if err != nil {
+		return nil, err
+	}
 
-		lastErr = fmt.Errorf("batch embed failed with status %d: %s", resp.StatusCode, string(body))
-		if !isRetryableStatus(resp.StatusCode) {
-			return nil, lastErr
-		}
+	var batchResp geminiBatchResponse
+	if err := json.Unmarshal(body, &batchResp); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
 	}
 
-	return nil, lastErr
+	// Embeddings are returned in request order; a response of the wrong
+	// length can't be matched to the texts, so all items count as missing
+	result := make([][]float32, len(texts))
+	if len(batchResp.Embeddings) != len(texts) {
+		return result, nil
+	}
+	for i, emb := range batchResp.Embeddings {
+		result[i] = emb.Values
+	}
+	return result, nil
 }
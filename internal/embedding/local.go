@@ -0,0 +1,150 @@
+package embedding
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math"
+	"strings"
+	"unicode"
+)
+
+const (
+	// LocalModel is the model name of the local provider
+	LocalModel = "local-ngram-hash-v1"
+	// LocalDefaultDimension is the dimension the local provider uses when none is given
+	LocalDefaultDimension = 384
+
+	localCharNGram   = 3       // character n-gram length
+	localByteShingle = 4       // byte shingle length for binary content
+	localMaxShingles = 1 << 20 // byte shingles hashed per item at most; larger content is sampled
+)
+
+// LocalClient embeds text offline and deterministically by feature hashing:
+// words and character trigrams (so unsegmented scripts such as Japanese
+// match too) are hashed into a signed vector, which is L2-normalized.
+// Binary content is hashed the same way from byte shingles. Similarity is
+// purely lexical, which makes it a zero-cost fallback and a stand-in for
+// real models in tests; it needs no network or API key.
+type LocalClient struct{}
+
+// NewLocalClient creates a new local embedding client
+func NewLocalClient() *LocalClient {
+	return &LocalClient{}
+}
+
+// localVector accumulates hashed features into a vector of dimension dim
+type localVector []float64
+
+func (v localVector) add(feature string, weight float64) {
+	h := fnv.New64a()
+	h.Write([]byte(feature))
+	sum := h.Sum64()
+	i := int(sum % uint64(len(v)))
+	if sum>>63 == 1 {
+		weight = -weight
+	}
+	v[i] += weight
+}
+
+// normalized returns the vector scaled to unit length. A vector without
+// features becomes a fixed unit vector, so nothing is ever all zeros.
+func (v localVector) normalized() []float32 {
+	var norm float64
+	for _, x := range v {
+		norm += x * x
+	}
+	out := make([]float32, len(v))
+	if norm == 0 {
+		out[0] = 1
+		return out
+	}
+	norm = math.Sqrt(norm)
+	for i, x := range v {
+		out[i] = float32(x / norm)
+	}
+	return out
+}
+
+func localDimension(model string, dimension int) (int, error) {
+	if model != "" && model != LocalModel {
+		return 0, fmt.Errorf("unknown local embedding model %s (the local provider has only %s)", model, LocalModel)
+	}
+	if dimension <= 0 {
+		return LocalDefaultDimension, nil
+	}
+	return dimension, nil
+}
+
+// embedText hashes the words and character n-grams of text
+func embedText(text string, dim int) []float32 {
+	vec := make(localVector, dim)
+	text = strings.ToLower(text)
+
+	words := strings.FieldsFunc(text, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+	for _, w := range words {
+		vec.add("w:"+w, 1)
+	}
+
+	// Character n-grams over the words joined by single spaces
+	runes := []rune(strings.Join(words, " "))
+	for i := 0; i+localCharNGram <= len(runes); i++ {
+		vec.add("c:"+string(runes[i:i+localCharNGram]), 0.5)
+	}
+	if len(runes) > 0 && len(runes) < localCharNGram {
+		vec.add("c:"+string(runes), 0.5)
+	}
+	return vec.normalized()
+}
+
+// embedBytes hashes the byte shingles of data, sampling evenly spaced
+// shingles of large content
+func embedBytes(mimeType string, data []byte, dim int) []float32 {
+	vec := make(localVector, dim)
+	vec.add("m:"+mimeType, 1)
+	n := len(data) - localByteShingle + 1
+	step := 1
+	if n > localMaxShingles {
+		step = n/localMaxShingles + 1
+	}
+	for i := 0; i < n; i += step {
+		vec.add("b:"+string(data[i:i+localByteShingle]), 1)
+	}
+	return vec.normalized()
+}
+
+// EmbedContent generates an embedding for a single text.
+// taskType is ignored (queries and documents share one lexical space).
+func (c *LocalClient) EmbedContent(model, text string, _ TaskType, dimension int) ([]float32, error) {
+	dim, err := localDimension(model, dimension)
+	if err != nil {
+		return nil, err
+	}
+	return embedText(text, dim), nil
+}
+
+// BatchEmbedContents generates embeddings for multiple texts.
+// taskType is ignored (queries and documents share one lexical space).
+func (c *LocalClient) BatchEmbedContents(model string, texts []string, _ TaskType, dimension int) ([][]float32, error) {
+	dim, err := localDimension(model, dimension)
+	if err != nil {
+		return nil, err
+	}
+	result := make([][]float32, len(texts))
+	for i, text := range texts {
+		result[i] = embedText(text, dim)
+	}
+	return result, nil
+}
+
+// EmbedMultimodalContent generates an embedding for binary content from its
+// raw bytes. Identical files get identical vectors; there is no semantic
+// link between content and text.
+func (c *LocalClient) EmbedMultimodalContent(model string, content MultimodalContent, _ TaskType, dimension int) ([]float32, error) {
+	dim, err := localDimension(model, dimension)
+	if err != nil {
+		return nil, err
+	}
+	return embedBytes(content.MIMEType, content.Data, dim), nil
+}
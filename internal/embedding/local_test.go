@@ -0,0 +1,104 @@
+package embedding
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+func cosine(a, b []float32) float64 {
+	var dot float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+	}
+	return dot
+}
+
+func TestLocalClientIsDeterministicAndNormalized(t *testing.T) {
+	c := NewLocalClient()
+	a, err := c.EmbedContent("", "Go is a compiled language", TaskRetrievalDocument, 0)
+	if err != nil {
+		t.Fatalf("EmbedContent() error = %v", err)
+	}
+	if len(a) != LocalDefaultDimension {
+		t.Fatalf("len = %d, want %d", len(a), LocalDefaultDimension)
+	}
+	batch, err := NewLocalClient().BatchEmbedContents(LocalModel, []string{"Go is a compiled language", ""}, TaskRetrievalQuery, 0)
+	if err != nil {
+		t.Fatalf("BatchEmbedContents() error = %v", err)
+	}
+	for i := range a {
+		if a[i] != batch[0][i] {
+			t.Fatalf("vectors differ at %d: %v vs %v", i, a[i], batch[0][i])
+		}
+	}
+	for _, vec := range [][]float32{a, batch[1]} {
+		if norm := math.Sqrt(cosine(vec, vec)); math.Abs(norm-1) > 1e-5 {
+			t.Fatalf("norm = %v, want 1", norm)
+		}
+	}
+
+	if _, err := c.EmbedContent("text-embedding-3-small", "x", TaskRetrievalQuery, 0); err == nil || !strings.Contains(err.Error(), LocalModel) {
+		t.Fatalf("EmbedContent(other model) error = %v, want unknown-model error", err)
+	}
+	if v, _ := c.EmbedContent("", "x", TaskRetrievalQuery, 64); len(v) != 64 {
+		t.Fatalf("len = %d, want 64", len(v))
+	}
+}
+
+func TestLocalClientRanksLexicalOverlap(t *testing.T) {
+	c := NewLocalClient()
+	docs := []string{
+		"Python is an interpreted language with significant indentation",
+		"Go is a statically typed, compiled programming language designed at Google",
+		"東京は日本の首都です",
+	}
+	vecs, err := c.BatchEmbedContents("", docs, TaskRetrievalDocument, 0)
+	if err != nil {
+		t.Fatalf("BatchEmbedContents() error = %v", err)
+	}
+	for query, want := range map[string]int{
+		"compiled language by Google": 1,
+		"python indentation":          0,
+		"日本の首都":                       2,
+	} {
+		q, _ := c.EmbedContent("", query, TaskRetrievalQuery, 0)
+		best := 0
+		for i := range vecs {
+			if cosine(q, vecs[i]) > cosine(q, vecs[best]) {
+				best = i
+			}
+		}
+		if best != want {
+			t.Errorf("query %q matched %q, want %q", query, docs[best], docs[want])
+		}
+	}
+}
+
+func TestLocalClientEmbedsBinaryContent(t *testing.T) {
+	c := NewLocalClient()
+	png := MultimodalContent{MIMEType: "image/png", Data: []byte("\x89PNG\r\n\x1a\nsome image bytes")}
+	a, err := c.EmbedMultimodalContent("", png, TaskRetrievalDocument, 128)
+	if err != nil {
+		t.Fatalf("EmbedMultimodalContent() error = %v", err)
+	}
+	b, _ := c.EmbedMultimodalContent("", png, TaskRetrievalDocument, 128)
+	if len(a) != 128 || cosine(a, b) < 0.9999 {
+		t.Fatalf("identical content: len %d similarity %v", len(a), cosine(a, b))
+	}
+	other, _ := c.EmbedMultimodalContent("", MultimodalContent{MIMEType: "image/png", Data: []byte{1, 2}}, TaskRetrievalDocument, 128)
+	if cosine(a, other) > 0.9 {
+		t.Fatalf("different content similarity = %v", cosine(a, other))
+	}
+	if !SupportsMultimodal(c, "", "application/pdf") {
+		t.Fatal("local client should accept any binary content")
+	}
+
+	p, err := LookupProvider("local")
+	if err != nil {
+		t.Fatalf("LookupProvider(local) error = %v", err)
+	}
+	if _, err := p.NewClient(ProviderOptions{}); err != nil {
+		t.Fatalf("NewClient() needs no URL or key, got %v", err)
+	}
+}
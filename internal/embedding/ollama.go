@@ -0,0 +1,269 @@
+package embedding
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// DefaultOllamaURL is the address of a local Ollama server
+const DefaultOllamaURL = "http://localhost:11434"
+
+// OllamaOptions holds request options of Ollama's native embedding API
+type OllamaOptions struct {
+	KeepAlive  string // how long the model stays loaded after a request (e.g. "10m", "-1m" = forever); empty = server default
+	NoTruncate bool   // fail instead of truncating inputs longer than the model's context
+}
+
+// OllamaClient is an embedding client for Ollama's native /api/embed API.
+// Unlike Ollama's OpenAI-compatible endpoint, it passes keep_alive, truncate
+// and dimensions through to the server.
+type OllamaClient struct {
+	transport
+	baseURL string
+	options OllamaOptions
+
+	mu             sync.Mutex
+	capabilities   map[string][]string // per model, from /api/show
+	capabilityErrs map[string]error    // per model whose /api/show lookup failed
+}
+
+// NewOllamaClient creates a new native Ollama embedding client
+func NewOllamaClient(baseURL string, options OllamaOptions) *OllamaClient {
+	if baseURL == "" {
+		baseURL = DefaultOllamaURL
+	}
+	return &OllamaClient{
+		transport:      newTransport(),
+		baseURL:        strings.TrimRight(baseURL, "/"),
+		options:        options,
+		capabilities:   make(map[string][]string),
+		capabilityErrs: make(map[string]error),
+	}
+}
+
+type ollamaEmbedRequest struct {
+	Model      string      `json:"model"`
+	Input      interface{} `json:"input"` // string or []string
+	Truncate   *bool       `json:"truncate,omitempty"`
+	Dimensions int         `json:"dimensions,omitempty"`
+	KeepAlive  string      `json:"keep_alive,omitempty"`
+}
+
+type ollamaEmbedResponse struct {
+	Model      string      `json:"model"`
+	Embeddings [][]float32 `json:"embeddings"`
+}
+
+// OllamaModel describes a model pulled into an Ollama server
+type OllamaModel struct {
+	Name  string `json:"name"`
+	Model string `json:"model"`
+	Size  int64  `json:"size"`
+}
+
+type ollamaTagsResponse struct {
+	Models []OllamaModel `json:"models"`
+}
+
+type ollamaShowResponse struct {
+	Capabilities []string `json:"capabilities"`
+}
+
+func (c *OllamaClient) embed(reqBody ollamaEmbedRequest, tokens int) ([][]float32, error) {
+	if c.options.NoTruncate {
+		truncate := false
+		reqBody.Truncate = &truncate
+	}
+	reqBody.KeepAlive = c.options.KeepAlive
+
+	jsonBody, err := json.Marshal(reqBody)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	body, err := c.post("Ollama embed", c.baseURL+"/api/embed", nil, jsonBody, tokens)
+	if err != nil {
+		return nil, err
+	}
+
+	var embedResp ollamaEmbedResponse
+	if err := json.Unmarshal(body, &embedResp); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+	return embedResp.Embeddings, nil
+}
+
+// EmbedContent generates an embedding for a single text.
+// taskType is ignored (not supported by Ollama).
+func (c *OllamaClient) EmbedContent(model, text string, _ TaskType, dimension int) ([]float32, error) {
+	embeddings, err := c.embed(ollamaEmbedRequest{
+		Model:      model,
+		Input:      text,
+		Dimensions: dimension,
+	}, estimateTokens(text))
+	if err != nil {
+		return nil, err
+	}
+	if len(embeddings) == 0 || len(embeddings[0]) == 0 {
+		return nil, fmt.Errorf("empty embedding response")
+	}
+	return embeddings[0], nil
+}
+
+// BatchEmbedContents generates embeddings for multiple texts.
+// taskType is ignored (not supported by Ollama). Batches the server rejects as
+// too long are split; items that still fail are reported in a *BatchError.
+func (c *OllamaClient) BatchEmbedContents(model string, texts []string, _ TaskType, dimension int) ([][]float32, error) {
+	return embedBatch(texts, 0, func(texts []string) ([][]float32, error) {
+		embeddings, err := c.embed(ollamaEmbedRequest{
+			Model:      model,
+			Input:      texts,
+			Dimensions: dimension,
+		}, estimateTokens(texts...))
+		if err != nil {
+			return nil, err
+		}
+
+		// Embeddings are returned in input order; a response of the wrong
+		// length can't be matched to the texts, so all items count as missing
+		result := make([][]float32, len(texts))
+		if len(embeddings) == len(texts) {
+			copy(result, embeddings)
+		}
+		return result, nil
+	})
+}
+
+// SupportsContent reports whether model can embed content of mimeType:
+// never, as /api/embed only takes text (images are accepted by Ollama's
+// generate and chat APIs, not by its embedding API)
+func (c *OllamaClient) SupportsContent(model, mimeType string) bool {
+	return false
+}
+
+// EmbedMultimodalContent fails: /api/embed cannot embed binary content
+func (c *OllamaClient) EmbedMultimodalContent(model string, content MultimodalContent, _ TaskType, _ int) ([]float32, error) {
+	return nil, fmt.Errorf("Ollama model %s cannot embed %s content: not supported by /api/embed", model, content.MIMEType)
+}
+
+// ListModels returns the models pulled into the Ollama server (/api/tags)
+func (c *OllamaClient) ListModels() ([]OllamaModel, error) {
+	body, err := c.get("Ollama list models", c.baseURL+"/api/tags", nil)
+	if err != nil {
+		return nil, err
+	}
+	var tags ollamaTagsResponse
+	if err := json.Unmarshal(body, &tags); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+	return tags.Models, nil
+}
+
+// Capabilities returns what model can do (e.g. "embedding", "vision") as
+// reported by /api/show. Older servers report none. Results, failed
+// lookups included, are cached per model.
+func (c *OllamaClient) Capabilities(model string) ([]string, error) {
+	c.mu.Lock()
+	capabilities, ok := c.capabilities[model]
+	err, failed := c.capabilityErrs[model]
+	c.mu.Unlock()
+	if ok {
+		return capabilities, nil
+	}
+	if failed {
+		return nil, err
+	}
+
+	capabilities, err = c.showCapabilities(model)
+	c.mu.Lock()
+	if err != nil {
+		c.capabilityErrs[model] = err
+	} else {
+		c.capabilities[model] = capabilities
+	}
+	c.mu.Unlock()
+	return capabilities, err
+}
+
+func (c *OllamaClient) showCapabilities(model string) ([]string, error) {
+	jsonBody, err := json.Marshal(map[string]string{"model": model})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+	body, err := c.post("Ollama show model", c.baseURL+"/api/show", nil, jsonBody, 0)
+	if err != nil {
+		return nil, err
+	}
+	var show ollamaShowResponse
+	if err := json.Unmarshal(body, &show); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+	return show.Capabilities, nil
+}
+
+// CheckModel returns an error unless model has been pulled into the server
+func (c *OllamaClient) CheckModel(model string) error {
+	models, err := c.ListModels()
+	if err != nil {
+		return fmt.Errorf("failed to list Ollama models: %w", err)
+	}
+	for _, m := range models {
+		if ollamaModelMatches(m.Name, model) {
+			return nil
+		}
+	}
+	return fmt.Errorf("model '%s' is not available in Ollama at %s; run 'ollama pull %s'", model, c.baseURL, model)
+}
+
+// DiscoverModel picks an embedding model among the pulled models: the first
+// whose capabilities include embedding, or, for servers that don't report
+// capabilities, the first with "embed" in its name
+func (c *OllamaClient) DiscoverModel() (string, error) {
+	models, err := c.ListModels()
+	if err != nil {
+		return "", fmt.Errorf("failed to list Ollama models: %w", err)
+	}
+	fallback := ""
+	for _, m := range models {
+		capabilities, err := c.Capabilities(m.Name)
+		if err == nil && hasCapability(capabilities, "embedding") {
+			return m.Name, nil
+		}
+		if len(capabilities) == 0 && fallback == "" && strings.Contains(m.Name, "embed") {
+			fallback = m.Name
+		}
+	}
+	if fallback != "" {
+		return fallback, nil
+	}
+	return "", fmt.Errorf("no embedding model found in Ollama at %s; pull one (e.g. 'ollama pull nomic-embed-text') or pass --model", c.baseURL)
+}
+
+// ResolveModel checks that model has been pulled, or discovers an embedding
+// model when model is empty
+func (c *OllamaClient) ResolveModel(model string) (string, error) {
+	if model == "" {
+		return c.DiscoverModel()
+	}
+	if err := c.CheckModel(model); err != nil {
+		return "", err
+	}
+	return model, nil
+}
+
+// ollamaModelMatches reports whether a pulled model name refers to model;
+// a name without a tag means the latest tag
+func ollamaModelMatches(name, model string) bool {
+	return name == model || (!strings.Contains(model, ":") && name == model+":latest")
+}
+
+func hasCapability(capabilities []string, want string) bool {
+	for _, c := range capabilities {
+		if c == want {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,198 @@
+package embedding
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeOllama is an httptest stand-in for the Ollama API
+type fakeOllama struct {
+	mu           sync.Mutex
+	models       []string
+	capabilities map[string][]string
+	requests     []fakeOllamaEmbedRequest
+	shows        int // /api/show requests
+}
+
+// fakeOllamaEmbedRequest has the fields /api/embed accepts; the fake rejects
+// any other
+type fakeOllamaEmbedRequest struct {
+	Model      string                 `json:"model"`
+	Input      interface{}            `json:"input"`
+	Truncate   *bool                  `json:"truncate"`
+	Dimensions int                    `json:"dimensions"`
+	KeepAlive  string                 `json:"keep_alive"`
+	Options    map[string]interface{} `json:"options"`
+}
+
+func (f *fakeOllama) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case "/api/tags":
+		var resp ollamaTagsResponse
+		for _, name := range f.models {
+			resp.Models = append(resp.Models, OllamaModel{Name: name, Model: name})
+		}
+		json.NewEncoder(w).Encode(resp)
+	case "/api/show":
+		var req struct {
+			Model string `json:"model"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		f.mu.Lock()
+		f.shows++
+		f.mu.Unlock()
+		capabilities, ok := f.capabilities[req.Model]
+		if !ok && !f.pulled(req.Model) {
+			http.Error(w, `{"error":"model not found"}`, http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(w).Encode(ollamaShowResponse{Capabilities: capabilities})
+	case "/api/embed":
+		var req fakeOllamaEmbedRequest
+		decoder := json.NewDecoder(r.Body)
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		f.mu.Lock()
+		f.requests = append(f.requests, req)
+		f.mu.Unlock()
+
+		var inputs []string
+		switch input := req.Input.(type) {
+		case string:
+			inputs = []string{input}
+		case []interface{}:
+			for _, v := range input {
+				inputs = append(inputs, v.(string))
+			}
+		}
+		dim := req.Dimensions
+		if dim == 0 {
+			dim = 3
+		}
+		var resp ollamaEmbedResponse
+		for _, text := range inputs {
+			vec := make([]float32, dim)
+			vec[0] = float32(len(text))
+			resp.Embeddings = append(resp.Embeddings, vec)
+		}
+		json.NewEncoder(w).Encode(resp)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (f *fakeOllama) pulled(model string) bool {
+	for _, name := range f.models {
+		if name == model {
+			return true
+		}
+	}
+	return false
+}
+
+func newFakeOllamaClient(t *testing.T, fake *fakeOllama, options OllamaOptions) *OllamaClient {
+	t.Helper()
+	srv := httptest.NewServer(fake)
+	t.Cleanup(srv.Close)
+	client := NewOllamaClient(srv.URL, options)
+	recordSleeps(&client.transport)
+	return client
+}
+
+func TestOllamaClientSendsNativeOptions(t *testing.T) {
+	fake := &fakeOllama{}
+	client := newFakeOllamaClient(t, fake, OllamaOptions{KeepAlive: "10m", NoTruncate: true})
+
+	vecs, err := client.BatchEmbedContents("nomic-embed-text", []string{"a", "bb"}, TaskRetrievalDocument, 8)
+	if err != nil {
+		t.Fatalf("BatchEmbedContents() error = %v", err)
+	}
+	if len(vecs) != 2 || len(vecs[1]) != 8 || vecs[1][0] != 2 {
+		t.Fatalf("vecs = %v", vecs)
+	}
+
+	req := fake.requests[0]
+	if req.KeepAlive != "10m" || req.Truncate == nil || *req.Truncate || req.Dimensions != 8 {
+		t.Fatalf("request = %+v, want keep_alive 10m, truncate false, dimensions 8", req)
+	}
+
+	vec, err := client.EmbedContent("nomic-embed-text", "query", TaskRetrievalQuery, 0)
+	if err != nil || len(vec) != 3 {
+		t.Fatalf("EmbedContent() = %v, %v", vec, err)
+	}
+	if input, ok := fake.requests[1].Input.(string); !ok || input != "query" {
+		t.Fatalf("single input = %#v, want a string", fake.requests[1].Input)
+	}
+}
+
+func TestOllamaClientChecksAndDiscoversModels(t *testing.T) {
+	fake := &fakeOllama{
+		models: []string{"llama3:latest", "mxbai-embed-large:latest", "nomic-embed-text:latest"},
+		capabilities: map[string][]string{
+			"llama3:latest":            {"completion"},
+			"mxbai-embed-large:latest": {"embedding"},
+		},
+	}
+	client := newFakeOllamaClient(t, fake, OllamaOptions{})
+
+	if err := client.CheckModel("nomic-embed-text"); err != nil {
+		t.Fatalf("CheckModel(untagged) error = %v", err)
+	}
+	if err := client.CheckModel("bge-m3"); err == nil || !strings.Contains(err.Error(), "ollama pull bge-m3") {
+		t.Fatalf("CheckModel(missing) error = %v", err)
+	}
+
+	model, err := client.DiscoverModel()
+	if err != nil || model != "mxbai-embed-large:latest" {
+		t.Fatalf("DiscoverModel() = %q, %v", model, err)
+	}
+
+	empty := newFakeOllamaClient(t, &fakeOllama{models: []string{"llama3:latest"}}, OllamaOptions{})
+	if _, err := empty.DiscoverModel(); err == nil {
+		t.Fatal("DiscoverModel() without embedding models succeeded")
+	}
+}
+
+func TestOllamaClientDoesNotEmbedImages(t *testing.T) {
+	fake := &fakeOllama{
+		capabilities: map[string][]string{
+			"vision-embed": {"embedding", "vision"},
+		},
+	}
+	client := newFakeOllamaClient(t, fake, OllamaOptions{})
+
+	if SupportsMultimodal(client, "vision-embed", "image/png") {
+		t.Fatal("/api/embed cannot embed images, even with a vision model")
+	}
+	_, err := client.EmbedMultimodalContent("vision-embed", MultimodalContent{MIMEType: "image/png", Data: []byte{1, 2, 3}}, TaskRetrievalDocument, 0)
+	if err == nil || !strings.Contains(err.Error(), "not supported by /api/embed") {
+		t.Fatalf("EmbedMultimodalContent() error = %v", err)
+	}
+	if len(fake.requests) != 0 {
+		t.Fatalf("sent %d embed requests", len(fake.requests))
+	}
+}
+
+func TestOllamaClientCachesCapabilityLookups(t *testing.T) {
+	fake := &fakeOllama{capabilities: map[string][]string{"nomic-embed-text": {"embedding"}}}
+	client := newFakeOllamaClient(t, fake, OllamaOptions{})
+
+	for i := 0; i < 2; i++ {
+		if capabilities, err := client.Capabilities("nomic-embed-text"); err != nil || !hasCapability(capabilities, "embedding") {
+			t.Fatalf("Capabilities() = %v, %v", capabilities, err)
+		}
+		if _, err := client.Capabilities("missing"); err == nil {
+			t.Fatal("Capabilities(missing) succeeded")
+		}
+	}
+	if fake.shows != 2 {
+		t.Fatalf("%d /api/show requests, want one per model", fake.shows)
+	}
+}
@@ -1,39 +1,146 @@
 package embedding
 
 import (
-	"bytes"
+	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
 	"net/http"
+	"net/url"
 	"strings"
-	"time"
+	"sync"
 )
 
+// openAIMaxBatch is the most inputs OpenAI accepts in one embeddings request
+const openAIMaxBatch = 2048
+
 // OpenAIClient is an OpenAI-compatible embedding client.
-// Works with Ollama, LM Studio, vLLM, and any OpenAI-compatible API.
+// Works with OpenAI, Azure OpenAI, Ollama, LM Studio, vLLM, and any
+// OpenAI-compatible API.
 type OpenAIClient struct {
-	baseURL    string
-	apiKey     string
-	httpClient *http.Client
+	transport
+	endpoint   func(model string) string // embeddings URL for a model
+	header     http.Header               // authentication headers
+	imageInput string                    // how images are sent (see OpenAIOptions); "" = text only
+
+	mu           sync.Mutex
+	noDimensions map[string]bool // models that rejected the dimensions parameter
 }
 
 // NewOpenAIClient creates a new OpenAI-compatible embedding client
 func NewOpenAIClient(baseURL, apiKey string) *OpenAIClient {
 	// Normalize base URL
 	baseURL = strings.TrimRight(baseURL, "/")
+	header := http.Header{}
+	if apiKey != "" {
+		header.Set("Authorization", "Bearer "+apiKey)
+	}
 	return &OpenAIClient{
-		baseURL: baseURL,
-		apiKey:  apiKey,
-		httpClient: &http.Client{
-			Timeout: 5 * time.Minute,
+		transport:    newTransport(),
+		endpoint:     func(string) string { return baseURL + "/v1/embeddings" },
+		header:       header,
+		noDimensions: make(map[string]bool),
+	}
+}
+
+// Image input styles of OpenAI-compatible servers serving CLIP-style models
+const (
+	// ImageInputData sends images as data: URIs in input, with "modality":
+	// "image" (Infinity and similar)
+	ImageInputData = "input"
+	// ImageInputMessages sends images as image_url parts of chat-style
+	// messages (vLLM)
+	ImageInputMessages = "messages"
+)
+
+// OpenAIOptions holds the settings of the openai provider
+type OpenAIOptions struct {
+	ImageInput string // ImageInputData, ImageInputMessages, or "" / "none" for text only
+}
+
+// validate checks the image input style
+func (o OpenAIOptions) validate() error {
+	switch o.ImageInput {
+	case "", "none", ImageInputData, ImageInputMessages:
+		return nil
+	}
+	return fmt.Errorf("unknown image input style %q (must be none, %s or %s)", o.ImageInput, ImageInputData, ImageInputMessages)
+}
+
+// NewOpenAIImageClient creates an OpenAI-compatible embedding client for a
+// server that also embeds images (CLIP, SigLIP and similar models), sent in
+// the given style
+func NewOpenAIImageClient(baseURL, apiKey string, options OpenAIOptions) *OpenAIClient {
+	client := NewOpenAIClient(baseURL, apiKey)
+	if options.ImageInput != "none" {
+		client.imageInput = options.ImageInput
+	}
+	return client
+}
+
+// DefaultAzureAPIVersion is the Azure OpenAI api-version used unless another
+// is given
+const DefaultAzureAPIVersion = "2024-10-21"
+
+// AzureOptions holds the Azure OpenAI request settings
+type AzureOptions struct {
+	Deployment string // deployment name (default: the model name)
+	APIVersion string // api-version query parameter (default: DefaultAzureAPIVersion)
+}
+
+// NewAzureOpenAIClient creates an embedding client for Azure OpenAI.
+// endpoint is the resource URL (https://NAME.openai.azure.com) or a full
+// deployment URL (.../openai/deployments/NAME), which then overrides
+// options.Deployment.
+func NewAzureOpenAIClient(endpoint, apiKey string, options AzureOptions) *OpenAIClient {
+	endpoint = strings.TrimRight(endpoint, "/")
+	apiVersion := options.APIVersion
+	if apiVersion == "" {
+		apiVersion = DefaultAzureAPIVersion
+	}
+	header := http.Header{}
+	if apiKey != "" {
+		header.Set("api-key", apiKey)
+	}
+	return &OpenAIClient{
+		transport: newTransport(),
+		endpoint: func(model string) string {
+			base := endpoint
+			if !strings.Contains(endpoint, "/openai/deployments/") {
+				deployment := options.Deployment
+				if deployment == "" {
+					deployment = model
+				}
+				base = endpoint + "/openai/deployments/" + url.PathEscape(deployment)
+			}
+			return base + "/embeddings?api-version=" + url.QueryEscape(apiVersion)
 		},
+		header:       header,
+		noDimensions: make(map[string]bool),
 	}
 }
 
 type openAIEmbedRequest struct {
-	Model string      `json:"model"`
-	Input interface{} `json:"input"` // string or []string
+	Model          string          `json:"model"`
+	Input          interface{}     `json:"input,omitempty"` // string or []string
+	Messages       []openAIMessage `json:"messages,omitempty"`
+	Modality       string          `json:"modality,omitempty"`
+	EncodingFormat string          `json:"encoding_format,omitempty"`
+	Dimensions     int             `json:"dimensions,omitempty"`
+}
+
+type openAIMessage struct {
+	Role    string              `json:"role"`
+	Content []openAIContentPart `json:"content"`
+}
+
+type openAIContentPart struct {
+	Type     string          `json:"type"`
+	ImageURL *openAIImageURL `json:"image_url,omitempty"`
+}
+
+type openAIImageURL struct {
+	URL string `json:"url"`
 }
 
 type openAIEmbedResponse struct {
@@ -45,41 +152,36 @@ type openAIEmbedData struct {
 	Index     int       `json:"index"`
 }
 
-func (c *OpenAIClient) doRequest(model string, input interface{}) (*openAIEmbedResponse, error) {
-	url := c.baseURL + "/v1/embeddings"
-
-	reqBody := openAIEmbedRequest{
-		Model: model,
-		Input: input,
+// doRequest sends an embeddings request. dimension is passed as the
+// dimensions parameter (text-embedding-3 and later); models that reject it
+// are remembered and asked again without it.
+func (c *OpenAIClient) doRequest(req openAIEmbedRequest, tokens int) (*openAIEmbedResponse, error) {
+	c.mu.Lock()
+	if c.noDimensions[req.Model] {
+		req.Dimensions = 0
 	}
+	c.mu.Unlock()
 
-	jsonBody, err := json.Marshal(reqBody)
-	if err != nil {
-		return nil, fmt.Errorf("failed to marshal request: %w", err)
-	}
-
-	req, err := http.NewRequest("POST", url, bytes.NewReader(jsonBody))
-	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
-	}
-	req.Header.Set("Content-Type", "application/json")
-	if c.apiKey != "" {
-		req.Header.Set("Authorization", "Bearer "+c.apiKey)
+	resp, err := c.send(req, tokens)
+	if err != nil && req.Dimensions > 0 && isDimensionsRejected(err) {
+		c.mu.Lock()
+		c.noDimensions[req.Model] = true
+		c.mu.Unlock()
+		req.Dimensions = 0
+		return c.send(req, tokens)
 	}
+	return resp, err
+}
 
-	resp, err := c.httpClient.Do(req)
+func (c *OpenAIClient) send(req openAIEmbedRequest, tokens int) (*openAIEmbedResponse, error) {
+	jsonBody, err := json.Marshal(req)
 	if err != nil {
-		return nil, fmt.Errorf("failed to call embedding API: %w", err)
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
 	}
-	defer resp.Body.Close()
 
-	body, err := io.ReadAll(resp.Body)
+	body, err := c.post("embedding API", c.endpoint(req.Model), c.header, jsonBody, tokens)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read response: %w", err)
-	}
-
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("embedding API failed with status %d: %s", resp.StatusCode, string(body))
+		return nil, err
 	}
 
 	var embedResp openAIEmbedResponse
@@ -91,9 +193,10 @@ func (c *OpenAIClient) doRequest(model string, input interface{}) (*openAIEmbedR
 }
 
 // EmbedContent generates an embedding for a single text.
-// taskType and dimension are ignored (not supported by OpenAI-compatible APIs).
-func (c *OpenAIClient) EmbedContent(model, text string, _ TaskType, _ int) ([]float32, error) {
-	resp, err := c.doRequest(model, text)
+// taskType is ignored (OpenAI-compatible APIs have no task types; see
+// PromptTemplate for models that expect prefixes instead).
+func (c *OpenAIClient) EmbedContent(model, text string, _ TaskType, dimension int) ([]float32, error) {
+	resp, err := c.doRequest(openAIEmbedRequest{Model: model, Input: text, Dimensions: dimension}, estimateTokens(text))
 	if err != nil {
 		return nil, err
 	}
@@ -106,20 +209,69 @@ func (c *OpenAIClient) EmbedContent(model, text string, _ TaskType, _ int) ([]fl
 }
 
 // BatchEmbedContents generates embeddings for multiple texts.
-// taskType and dimension are ignored (not supported by OpenAI-compatible APIs).
-func (c *OpenAIClient) BatchEmbedContents(model string, texts []string, _ TaskType, _ int) ([][]float32, error) {
-	resp, err := c.doRequest(model, texts)
-	if err != nil {
-		return nil, err
-	}
+// taskType is ignored (OpenAI-compatible APIs have no task types).
+// Batches the server rejects as too large are split, and items missing from
+// the response are re-sent; items that still fail are reported in a *BatchError.
+func (c *OpenAIClient) BatchEmbedContents(model string, texts []string, _ TaskType, dimension int) ([][]float32, error) {
+	return embedBatch(texts, openAIMaxBatch, func(texts []string) ([][]float32, error) {
+		resp, err := c.doRequest(openAIEmbedRequest{Model: model, Input: texts, Dimensions: dimension}, estimateTokens(texts...))
+		if err != nil {
+			return nil, err
+		}
 
-	// Sort by index to maintain order
-	result := make([][]float32, len(texts))
-	for _, d := range resp.Data {
-		if d.Index < len(result) {
+		// Place each embedding by its index; out-of-range and duplicate
+		// indices are ignored and leave their slot for a retry
+		result := make([][]float32, len(texts))
+		for _, d := range resp.Data {
+			if d.Index < 0 || d.Index >= len(result) || result[d.Index] != nil {
+				continue
+			}
 			result[d.Index] = d.Embedding
 		}
+		return result, nil
+	})
+}
+
+// SupportsContent reports whether the server was declared to embed images
+// (see OpenAIOptions); other binary content is never sent
+func (c *OpenAIClient) SupportsContent(_ string, mimeType string) bool {
+	return c.imageInput != "" && strings.HasPrefix(mimeType, "image/")
+}
+
+// EmbedMultimodalContent generates an embedding for an image, sent as a
+// base64 data: URI in the configured style. Text embedded by the same
+// CLIP-style model lands in the same space, so text queries find images.
+func (c *OpenAIClient) EmbedMultimodalContent(model string, content MultimodalContent, _ TaskType, dimension int) ([]float32, error) {
+	if !c.SupportsContent(model, content.MIMEType) {
+		return nil, fmt.Errorf("embedding server was not declared to accept %s input (see --openai-image-input)", content.MIMEType)
+	}
+	uri := "data:" + content.MIMEType + ";base64," + base64.StdEncoding.EncodeToString(content.Data)
+	req := openAIEmbedRequest{Model: model, Dimensions: dimension}
+	if c.imageInput == ImageInputMessages {
+		req.Messages = []openAIMessage{{
+			Role:    "user",
+			Content: []openAIContentPart{{Type: "image_url", ImageURL: &openAIImageURL{URL: uri}}},
+		}}
+		req.EncodingFormat = "float"
+	} else {
+		req.Input = []string{uri}
+		req.Modality = "image"
+	}
+
+	resp, err := c.doRequest(req, 0)
+	if err != nil {
+		return nil, err
 	}
+	if len(resp.Data) == 0 || len(resp.Data[0].Embedding) == 0 {
+		return nil, fmt.Errorf("empty embedding response")
+	}
+	return resp.Data[0].Embedding, nil
+}
 
-	return result, nil
+// isDimensionsRejected reports whether err is a 400 caused by the dimensions
+// parameter (e.g. "This model does not support specifying dimensions.")
+func isDimensionsRejected(err error) bool {
+	var statusErr *StatusError
+	return errors.As(err, &statusErr) && statusErr.StatusCode == http.StatusBadRequest &&
+		strings.Contains(strings.ToLower(statusErr.Body), "dimensions")
 }
@@ -0,0 +1,110 @@
+package embedding
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestOpenAIClientSendsDimensions(t *testing.T) {
+	var dims []int
+	stub := &openAIStub{}
+	client := newStubbedOpenAIClient(t, stub)
+	stub.inspect = func(r openAIEmbedRequest) { dims = append(dims, r.Dimensions) }
+	stub.respond = func(w http.ResponseWriter, inputs []string, call int) {
+		embedAll(w, inputs, nil)
+	}
+
+	if _, err := client.BatchEmbedContents("text-embedding-3-small", []string{"a"}, TaskRetrievalDocument, 256); err != nil {
+		t.Fatalf("BatchEmbedContents() error = %v", err)
+	}
+	if _, err := client.EmbedContent("text-embedding-3-small", "q", TaskRetrievalQuery, 0); err != nil {
+		t.Fatalf("EmbedContent() error = %v", err)
+	}
+	if len(dims) != 2 || dims[0] != 256 || dims[1] != 0 {
+		t.Fatalf("dimensions sent = %v, want [256 0]", dims)
+	}
+}
+
+func TestOpenAIClientDropsRejectedDimensions(t *testing.T) {
+	var dims []int
+	stub := &openAIStub{}
+	client := newStubbedOpenAIClient(t, stub)
+	stub.inspect = func(r openAIEmbedRequest) { dims = append(dims, r.Dimensions) }
+	stub.respond = func(w http.ResponseWriter, inputs []string, call int) {
+		if dims[len(dims)-1] > 0 {
+			http.Error(w, `{"error":{"message":"This model does not support specifying dimensions."}}`, http.StatusBadRequest)
+			return
+		}
+		embedAll(w, inputs, nil)
+	}
+
+	for i := 0; i < 2; i++ {
+		if _, err := client.BatchEmbedContents("text-embedding-ada-002", []string{"a"}, TaskRetrievalDocument, 1536); err != nil {
+			t.Fatalf("BatchEmbedContents() error = %v", err)
+		}
+	}
+	// The first call learns that the model rejects dimensions; later calls skip it
+	if len(dims) != 3 || dims[0] != 1536 || dims[1] != 0 || dims[2] != 0 {
+		t.Fatalf("dimensions sent = %v, want [1536 0 0]", dims)
+	}
+}
+
+func TestOpenAIClientEmbedsImagesWhenDeclared(t *testing.T) {
+	png := MultimodalContent{MIMEType: "image/png", Data: []byte{0x89, 'P', 'N', 'G'}}
+	const uri = "data:image/png;base64,iVBORw=="
+
+	for _, style := range []string{ImageInputData, ImageInputMessages} {
+		t.Run(style, func(t *testing.T) {
+			var reqs []openAIEmbedRequest
+			stub := &openAIStub{}
+			client := newStubbedOpenAIClient(t, stub)
+			client.imageInput = style
+			stub.inspect = func(r openAIEmbedRequest) { reqs = append(reqs, r) }
+			stub.respond = func(w http.ResponseWriter, inputs []string, call int) {
+				embedAll(w, []string{"image"}, nil)
+			}
+
+			if !SupportsMultimodal(client, "clip", "image/png") || SupportsMultimodal(client, "clip", "application/pdf") {
+				t.Fatal("only images should be supported")
+			}
+			vec, err := client.EmbedMultimodalContent("clip", png, TaskRetrievalDocument, 0)
+			if err != nil {
+				t.Fatalf("EmbedMultimodalContent() error = %v", err)
+			}
+			if len(vec) != 2 || len(reqs) != 1 {
+				t.Fatalf("vector %v after %d requests", vec, len(reqs))
+			}
+			req := reqs[0]
+			switch style {
+			case ImageInputData:
+				inputs, _ := req.Input.([]interface{})
+				if req.Modality != "image" || len(inputs) != 1 || inputs[0] != uri {
+					t.Fatalf("request = %+v, want the data URI with modality image", req)
+				}
+			case ImageInputMessages:
+				if req.Input != nil || len(req.Messages) != 1 || req.Messages[0].Content[0].ImageURL.URL != uri {
+					t.Fatalf("request = %+v, want an image_url message", req)
+				}
+			}
+		})
+	}
+
+	// Text-only unless declared
+	client := NewOpenAIClient("http://unused", "")
+	if SupportsMultimodal(client, "text-embedding-3-small", "image/png") {
+		t.Fatal("images supported without an image input style")
+	}
+	if _, err := client.EmbedMultimodalContent("text-embedding-3-small", png, TaskRetrievalDocument, 0); err == nil {
+		t.Fatal("EmbedMultimodalContent() without an image input style succeeded")
+	}
+
+	openai, _ := LookupProvider("openai")
+	if _, err := openai.NewClient(ProviderOptions{OpenAI: OpenAIOptions{ImageInput: "base64"}}); err == nil || !strings.Contains(err.Error(), "image input") {
+		t.Fatalf("NewClient(bad image input) error = %v", err)
+	}
+	c, err := openai.NewClient(ProviderOptions{OpenAI: OpenAIOptions{ImageInput: "none"}})
+	if err != nil || SupportsMultimodal(c, "", "image/png") {
+		t.Fatalf("image input none: err = %v", err)
+	}
+}
@@ -0,0 +1,89 @@
+package embedding
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// PromptTemplate holds the prefixes some embedding models expect in front of
+// queries and documents (e.g. "query: " / "passage: " for e5). Gemini takes
+// the task type natively and needs none.
+type PromptTemplate struct {
+	Query    string `json:"query,omitempty"`
+	Document string `json:"document,omitempty"`
+}
+
+// IsZero reports whether the template adds nothing
+func (p *PromptTemplate) IsZero() bool {
+	return p == nil || (p.Query == "" && p.Document == "")
+}
+
+// Apply prefixes text for taskType. A nil template returns text unchanged.
+func (p *PromptTemplate) Apply(taskType TaskType, text string) string {
+	if p == nil {
+		return text
+	}
+	switch taskType {
+	case TaskRetrievalQuery:
+		return p.Query + text
+	case TaskRetrievalDocument:
+		return p.Document + text
+	}
+	return text
+}
+
+// bgeQueryInstruction is the retrieval instruction of the BGE family and
+// models trained the same way
+const bgeQueryInstruction = "Represent this sentence for searching relevant passages: "
+
+// promptTemplates maps model name fragments to their templates. A model uses
+// the entry with the longest fragment contained in its name.
+var promptTemplates = map[string]PromptTemplate{
+	"e5-":                    {Query: "query: ", Document: "passage: "},
+	"nomic-embed-text":       {Query: "search_query: ", Document: "search_document: "},
+	"bge-small-en":           {Query: bgeQueryInstruction},
+	"bge-base-en":            {Query: bgeQueryInstruction},
+	"bge-large-en":           {Query: bgeQueryInstruction},
+	"mxbai-embed-large":      {Query: bgeQueryInstruction},
+	"snowflake-arctic-embed": {Query: bgeQueryInstruction},
+}
+
+// LookupPromptTemplate returns the template for model from the table, or nil
+// if the model needs none
+func LookupPromptTemplate(model string) *PromptTemplate {
+	name := strings.ToLower(strings.TrimPrefix(model, "models/"))
+	best := ""
+	for fragment := range promptTemplates {
+		if strings.Contains(name, fragment) && len(fragment) > len(best) {
+			best = fragment
+		}
+	}
+	if best == "" {
+		return nil
+	}
+	tpl := promptTemplates[best]
+	if tpl.IsZero() {
+		return nil
+	}
+	return &tpl
+}
+
+// LoadPromptTemplates adds the templates of a JSON file mapping model name
+// fragments to {"query": ..., "document": ...} to the table, overriding
+// built-in entries. An entry with empty prefixes disables a built-in one.
+func LoadPromptTemplates(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read prompt templates: %w", err)
+	}
+	var templates map[string]PromptTemplate
+	if err := json.Unmarshal(data, &templates); err != nil {
+		return fmt.Errorf("failed to parse prompt templates %s: %w", path, err)
+	}
+	for fragment, tpl := range templates {
+		promptTemplates[strings.ToLower(fragment)] = tpl
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+package embedding
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLookupPromptTemplate(t *testing.T) {
+	tests := []struct {
+		model    string
+		query    string
+		document string
+	}{
+		{"nomic-embed-text:latest", "search_query: ", "search_document: "},
+		{"text-embedding-nomic-embed-text-v1.5", "search_query: ", "search_document: "},
+		{"intfloat/multilingual-e5-large", "query: ", "passage: "},
+		{"BAAI/bge-base-en-v1.5", bgeQueryInstruction, ""},
+	}
+	for _, tt := range tests {
+		tpl := LookupPromptTemplate(tt.model)
+		if tpl == nil || tpl.Query != tt.query || tpl.Document != tt.document {
+			t.Errorf("LookupPromptTemplate(%q) = %+v", tt.model, tpl)
+		}
+	}
+	for _, model := range []string{"gemini-embedding-001", "models/text-embedding-004", "text-embedding-3-small", "bge-m3"} {
+		if tpl := LookupPromptTemplate(model); tpl != nil {
+			t.Errorf("LookupPromptTemplate(%q) = %+v, want nil", model, tpl)
+		}
+	}
+}
+
+func TestPromptTemplateApply(t *testing.T) {
+	tpl := &PromptTemplate{Query: "query: ", Document: "passage: "}
+	if got := tpl.Apply(TaskRetrievalQuery, "q"); got != "query: q" {
+		t.Fatalf("query = %q", got)
+	}
+	if got := tpl.Apply(TaskRetrievalDocument, "d"); got != "passage: d" {
+		t.Fatalf("document = %q", got)
+	}
+	var none *PromptTemplate
+	if got := none.Apply(TaskRetrievalQuery, "q"); got != "q" {
+		t.Fatalf("nil template = %q", got)
+	}
+}
+
+func TestLoadPromptTemplates(t *testing.T) {
+	saved := make(map[string]PromptTemplate, len(promptTemplates))
+	for k, v := range promptTemplates {
+		saved[k] = v
+	}
+	defer func() { promptTemplates = saved }()
+
+	path := filepath.Join(t.TempDir(), "templates.json")
+	data := `{"My-Model": {"query": "Q: ", "document": "D: "}, "nomic-embed-text": {}}`
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if err := LoadPromptTemplates(path); err != nil {
+		t.Fatalf("LoadPromptTemplates() error = %v", err)
+	}
+
+	if tpl := LookupPromptTemplate("org/my-model-v2"); tpl == nil || tpl.Query != "Q: " || tpl.Document != "D: " {
+		t.Fatalf("custom template = %+v", tpl)
+	}
+	if tpl := LookupPromptTemplate("nomic-embed-text"); tpl != nil {
+		t.Fatalf("disabled built-in template = %+v, want nil", tpl)
+	}
+}
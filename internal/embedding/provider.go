@@ -0,0 +1,358 @@
+package embedding
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Capabilities describes what an embedding provider supports
+type Capabilities struct {
+	Multimodal bool // embeds images, PDF, audio or video (possibly only with some models)
+	TaskTypes  bool // tells queries and documents apart natively; otherwise prompt templates stand in
+	Dimensions bool // the output dimension can be chosen per request
+	MaxBatch   int  // most texts per request (0 = no limit)
+}
+
+// ProviderOptions holds the settings a provider builds its client from.
+// Providers ignore the fields they have no use for.
+type ProviderOptions struct {
+	URL    string // API address (empty = the provider's URL environment variable or default)
+	APIKey string
+	Azure  AzureOptions
+	Ollama OllamaOptions
+	OpenAI OpenAIOptions
+	Retry  *RetryConfig // nil = DefaultRetryConfig
+}
+
+// Provider is an entry of the embedding provider registry
+type Provider struct {
+	Name             string
+	Description      string
+	Capabilities     Capabilities
+	DefaultURL       string   // used when neither a URL nor URLEnv is set ("" = a URL is required)
+	URLEnv           string   // environment variable holding the API address
+	APIKeyEnv        []string // environment variables holding the API key, in order of preference
+	RequiresAPIKey   bool
+	Offline          bool   // runs in-process; needs no API address
+	DefaultModel     string // "" = the client resolves it (see ModelResolver) or --model is required
+	DefaultDimension int    // dimension of new stores unless one is given (0 = the model's native one)
+	New              func(url string, opts ProviderOptions) Client
+}
+
+var providers = map[string]Provider{}
+
+// RegisterProvider adds p to the registry, replacing a provider of the same name
+func RegisterProvider(p Provider) {
+	providers[p.Name] = p
+}
+
+// LookupProvider returns the registered provider called name
+func LookupProvider(name string) (Provider, error) {
+	p, ok := providers[name]
+	if !ok {
+		return Provider{}, fmt.Errorf("unknown embedding provider: %s (must be one of %s)", name, strings.Join(ProviderNames(), ", "))
+	}
+	return p, nil
+}
+
+// ProviderNames returns the names of the registered providers, sorted
+func ProviderNames() []string {
+	names := make([]string, 0, len(providers))
+	for name := range providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// SelectProvider picks the provider to use: name if given, else stored (the
+// provider a store was built with), else openai when an API URL is given,
+// else gemini
+func SelectProvider(name, stored, url string) (Provider, error) {
+	switch {
+	case name != "":
+	case stored != "":
+		name = stored
+	case url != "":
+		name = "openai"
+	default:
+		name = "gemini"
+	}
+	return LookupProvider(name)
+}
+
+// ResolveURL returns url, else the provider's URL environment variable, else
+// its default. Addresses without a scheme get http://.
+func (p Provider) ResolveURL(url string) string {
+	if url == "" && p.URLEnv != "" {
+		url = os.Getenv(p.URLEnv)
+	}
+	if url == "" {
+		return p.DefaultURL
+	}
+	if !strings.Contains(url, "://") {
+		url = "http://" + url
+	}
+	return url
+}
+
+// EnvAPIKey returns the first API key set in the provider's environment variables
+func (p Provider) EnvAPIKey() string {
+	for _, env := range p.APIKeyEnv {
+		if key := os.Getenv(env); key != "" {
+			return key
+		}
+	}
+	return ""
+}
+
+// SplitAPIKeys splits a comma-separated list of API keys
+func SplitAPIKeys(keys string) []string {
+	var result []string
+	for _, key := range strings.Split(keys, ",") {
+		if key = strings.TrimSpace(key); key != "" {
+			result = append(result, key)
+		}
+	}
+	return result
+}
+
+// NewClient builds a client of the provider from opts. Several
+// comma-separated API keys give a FallbackClient that rotates to the next
+// key when one is rate limited or rejected.
+func (p Provider) NewClient(opts ProviderOptions) (Client, error) {
+	url := p.ResolveURL(opts.URL)
+	if url == "" && !p.Offline {
+		return nil, fmt.Errorf("embedding provider %s needs an API URL (--embed-url)", p.Name)
+	}
+	if err := opts.OpenAI.validate(); err != nil {
+		return nil, err
+	}
+	keys := SplitAPIKeys(opts.APIKey)
+	if p.RequiresAPIKey && len(keys) == 0 {
+		return nil, fmt.Errorf("embedding provider %s needs an API key (--embed-api-key or %s)", p.Name, strings.Join(p.APIKeyEnv, " / "))
+	}
+
+	var client Client
+	if len(keys) > 1 {
+		backends := make([]Backend, len(keys))
+		for i, key := range keys {
+			keyOpts := opts
+			keyOpts.APIKey = key
+			backends[i] = Backend{Name: fmt.Sprintf("%s#%d", p.Name, i+1), Client: p.New(url, keyOpts)}
+		}
+		client = NewFallbackClient(backends...)
+	} else {
+		opts.APIKey = strings.TrimSpace(opts.APIKey)
+		client = p.New(url, opts)
+	}
+	if rc, ok := client.(RetryConfigurable); ok && opts.Retry != nil {
+		rc.SetRetryConfig(*opts.Retry)
+	}
+	return client, nil
+}
+
+// FallbackSpec describes a fallback embedding backend
+type FallbackSpec struct {
+	Provider string
+	URL      string // "" = the provider's URL environment variable or default
+	APIKey   string // "" = the provider's API key environment variables
+	Model    string // "" = the provider's default model
+}
+
+// ParseFallbackSpec parses PROVIDER[,url=URL][,key=KEY][,model=MODEL]
+func ParseFallbackSpec(s string) (FallbackSpec, error) {
+	parts := strings.Split(s, ",")
+	spec := FallbackSpec{Provider: strings.TrimSpace(parts[0])}
+	if spec.Provider == "" {
+		return spec, fmt.Errorf("invalid fallback %q: missing provider (want PROVIDER[,url=URL][,key=KEY][,model=MODEL])", s)
+	}
+	for _, part := range parts[1:] {
+		name, value, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			return spec, fmt.Errorf("invalid fallback %q: %q is not NAME=VALUE", s, part)
+		}
+		switch name {
+		case "url":
+			spec.URL = value
+		case "key":
+			spec.APIKey = value
+		case "model":
+			spec.Model = value
+		default:
+			return spec, fmt.Errorf("invalid fallback %q: unknown setting %q (must be url, key or model)", s, name)
+		}
+	}
+	return spec, nil
+}
+
+// NewFallbackChain returns primary followed by a backend for each spec, as a
+// FallbackClient (primary itself if there are no specs). A fallback serving
+// another model than the one it is asked for is refused when used, so every
+// spec must name the primary's model unless its provider (or, for Ollama and
+// TEI, its server) defaults to it.
+// opts supplies the settings the specs don't (Azure, Ollama, retries).
+func NewFallbackChain(primary Client, primaryName string, specs []FallbackSpec, opts ProviderOptions) (Client, error) {
+	if len(specs) == 0 {
+		return primary, nil
+	}
+	var backends []Backend
+	if chain, ok := primary.(*FallbackClient); ok {
+		backends = chain.Backends()
+	} else {
+		backends = []Backend{{Name: primaryName, Client: primary}}
+	}
+	names := make(map[string]int)
+	for _, b := range backends {
+		names[b.Name]++
+	}
+
+	for _, spec := range specs {
+		provider, err := LookupProvider(spec.Provider)
+		if err != nil {
+			return nil, err
+		}
+		specOpts := opts
+		specOpts.URL = spec.URL
+		specOpts.APIKey = spec.APIKey
+		if specOpts.APIKey == "" {
+			specOpts.APIKey = provider.EnvAPIKey()
+		}
+		client, err := provider.NewClient(specOpts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create fallback %s: %w", spec.Provider, err)
+		}
+		model := spec.Model
+		if model == "" {
+			model = provider.DefaultModel
+		}
+		if resolver, ok := client.(ModelResolver); ok {
+			// Servers that know their models confirm (or name) the model now;
+			// one that is down is still added, as it may recover
+			if resolved, err := resolver.ResolveModel(model); err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: fallback %s: %v\n", spec.Provider, err)
+			} else {
+				model = resolved
+			}
+		}
+
+		var members []Backend
+		if chain, ok := client.(*FallbackClient); ok {
+			members = chain.Backends()
+		} else {
+			members = []Backend{{Name: provider.Name, Client: client}}
+		}
+		for _, b := range members {
+			names[b.Name]++
+			if names[b.Name] > 1 {
+				b.Name = fmt.Sprintf("%s(%d)", b.Name, names[b.Name])
+			}
+			b.Model = model
+			backends = append(backends, b)
+		}
+	}
+
+	chain := NewFallbackClient(backends...)
+	if opts.Retry != nil {
+		chain.SetRetryConfig(*opts.Retry)
+	}
+	return chain, nil
+}
+
+func init() {
+	RegisterProvider(Provider{
+		Name:             "gemini",
+		Description:      "Google Gemini Embedding API",
+		Capabilities:     Capabilities{Multimodal: true, TaskTypes: true, Dimensions: true, MaxBatch: geminiMaxBatch},
+		DefaultURL:       geminiBaseURL,
+		APIKeyEnv:        []string{"GEMINI_API_KEY"},
+		RequiresAPIKey:   true,
+		DefaultModel:     "gemini-embedding-2-preview",
+		DefaultDimension: 768,
+		New: func(url string, opts ProviderOptions) Client {
+			client := NewGeminiClient(opts.APIKey)
+			client.baseURL = strings.TrimRight(url, "/")
+			return client
+		},
+	})
+	RegisterProvider(Provider{
+		Name:         "openai",
+		Description:  "OpenAI and OpenAI-compatible APIs (LM Studio, vLLM, llama.cpp, ...)",
+		Capabilities: Capabilities{Multimodal: true, Dimensions: true, MaxBatch: openAIMaxBatch},
+		DefaultURL:   "https://api.openai.com",
+		APIKeyEnv:    []string{"RAGUJUARY_EMBED_API_KEY", "OPENAI_API_KEY"},
+		DefaultModel: "text-embedding-3-small",
+		New: func(url string, opts ProviderOptions) Client {
+			return NewOpenAIImageClient(url, opts.APIKey, opts.OpenAI)
+		},
+	})
+	RegisterProvider(Provider{
+		Name:           "azure",
+		Description:    "Azure OpenAI (deployment URLs with an api-version)",
+		Capabilities:   Capabilities{Dimensions: true, MaxBatch: openAIMaxBatch},
+		URLEnv:         "AZURE_OPENAI_ENDPOINT",
+		APIKeyEnv:      []string{"RAGUJUARY_EMBED_API_KEY", "AZURE_OPENAI_API_KEY"},
+		RequiresAPIKey: true,
+		DefaultModel:   "text-embedding-3-small",
+		New: func(url string, opts ProviderOptions) Client {
+			return NewAzureOpenAIClient(url, opts.APIKey, opts.Azure)
+		},
+	})
+	RegisterProvider(Provider{
+		Name:           "voyage",
+		Description:    "Voyage AI",
+		Capabilities:   Capabilities{TaskTypes: true, Dimensions: true, MaxBatch: voyageMaxBatch},
+		DefaultURL:     DefaultVoyageURL,
+		APIKeyEnv:      []string{"RAGUJUARY_EMBED_API_KEY", "VOYAGE_API_KEY"},
+		RequiresAPIKey: true,
+		DefaultModel:   "voyage-3.5",
+		New: func(url string, opts ProviderOptions) Client {
+			return NewVoyageClient(url, opts.APIKey)
+		},
+	})
+	RegisterProvider(Provider{
+		Name:           "cohere",
+		Description:    "Cohere embed API (v2)",
+		Capabilities:   Capabilities{TaskTypes: true, Dimensions: true, MaxBatch: cohereMaxBatch},
+		DefaultURL:     DefaultCohereURL,
+		APIKeyEnv:      []string{"RAGUJUARY_EMBED_API_KEY", "COHERE_API_KEY", "CO_API_KEY"},
+		RequiresAPIKey: true,
+		DefaultModel:   "embed-v4.0",
+		New: func(url string, opts ProviderOptions) Client {
+			return NewCohereClient(url, opts.APIKey)
+		},
+	})
+	RegisterProvider(Provider{
+		Name:         "tei",
+		Description:  "HuggingFace Text Embeddings Inference (/embed)",
+		Capabilities: Capabilities{MaxBatch: teiMaxBatch},
+		URLEnv:       "TEI_URL",
+		APIKeyEnv:    []string{"RAGUJUARY_EMBED_API_KEY", "HF_TOKEN"},
+		New: func(url string, opts ProviderOptions) Client {
+			return NewTEIClient(url, opts.APIKey)
+		},
+	})
+	RegisterProvider(Provider{
+		Name:             "local",
+		Description:      "Offline feature hashing of words and character n-grams (lexical, deterministic)",
+		Capabilities:     Capabilities{Multimodal: true, Dimensions: true},
+		Offline:          true,
+		DefaultModel:     LocalModel,
+		DefaultDimension: LocalDefaultDimension,
+		New: func(string, ProviderOptions) Client {
+			return NewLocalClient()
+		},
+	})
+	RegisterProvider(Provider{
+		Name:         "ollama",
+		Description:  "Ollama native API (/api/embed)",
+		Capabilities: Capabilities{Multimodal: true, Dimensions: true},
+		DefaultURL:   DefaultOllamaURL,
+		URLEnv:       "OLLAMA_HOST",
+		New: func(url string, opts ProviderOptions) Client {
+			return NewOllamaClient(url, opts.Ollama)
+		},
+	})
+}
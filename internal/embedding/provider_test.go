@@ -0,0 +1,248 @@
+package embedding
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestSelectProvider(t *testing.T) {
+	tests := []struct {
+		name, stored, url string
+		want              string
+	}{
+		{"", "", "", "gemini"},
+		{"", "", "http://localhost:1234", "openai"},
+		{"", "voyage", "", "voyage"},
+		{"", "tei", "http://localhost:8080", "tei"},
+		{"cohere", "voyage", "", "cohere"},
+	}
+	for _, tt := range tests {
+		p, err := SelectProvider(tt.name, tt.stored, tt.url)
+		if err != nil || p.Name != tt.want {
+			t.Errorf("SelectProvider(%q, %q, %q) = %q, %v; want %q", tt.name, tt.stored, tt.url, p.Name, err, tt.want)
+		}
+	}
+	if _, err := SelectProvider("nope", "", ""); err == nil || !strings.Contains(err.Error(), "azure") {
+		t.Fatalf("unknown provider error = %v, want the list of providers", err)
+	}
+}
+
+func TestProviderResolveURLAndKey(t *testing.T) {
+	ollama, _ := LookupProvider("ollama")
+	t.Setenv("OLLAMA_HOST", "")
+	if got := ollama.ResolveURL(""); got != DefaultOllamaURL {
+		t.Fatalf("default URL = %q", got)
+	}
+	t.Setenv("OLLAMA_HOST", "gpu-box:11434")
+	if got := ollama.ResolveURL(""); got != "http://gpu-box:11434" {
+		t.Fatalf("OLLAMA_HOST URL = %q", got)
+	}
+	if got := ollama.ResolveURL("http://other:1"); got != "http://other:1" {
+		t.Fatalf("explicit URL = %q", got)
+	}
+
+	cohere, _ := LookupProvider("cohere")
+	t.Setenv("RAGUJUARY_EMBED_API_KEY", "")
+	t.Setenv("COHERE_API_KEY", "")
+	t.Setenv("CO_API_KEY", "co-key")
+	if got := cohere.EnvAPIKey(); got != "co-key" {
+		t.Fatalf("EnvAPIKey() = %q", got)
+	}
+}
+
+func TestProviderNewClientRequirements(t *testing.T) {
+	t.Setenv("TEI_URL", "")
+	tei, _ := LookupProvider("tei")
+	if _, err := tei.NewClient(ProviderOptions{}); err == nil || !strings.Contains(err.Error(), "--embed-url") {
+		t.Fatalf("tei without URL error = %v", err)
+	}
+	voyage, _ := LookupProvider("voyage")
+	if _, err := voyage.NewClient(ProviderOptions{}); err == nil || !strings.Contains(err.Error(), "VOYAGE_API_KEY") {
+		t.Fatalf("voyage without key error = %v", err)
+	}
+	retry := RetryConfig{MaxRetries: 7}
+	client, err := voyage.NewClient(ProviderOptions{APIKey: "k", Retry: &retry})
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+	if got := client.(*VoyageClient).retry.MaxRetries; got != 7 {
+		t.Fatalf("retry config not applied: MaxRetries = %d", got)
+	}
+}
+
+// recordingServer is an httptest server that records each request's URL,
+// headers and decoded JSON body and answers with respond
+type recordingServer struct {
+	mu       sync.Mutex
+	urls     []string
+	headers  []http.Header
+	bodies   []map[string]interface{}
+	respond  func(w http.ResponseWriter, body map[string]interface{})
+	endpoint *httptest.Server
+}
+
+func newRecordingServer(t *testing.T, respond func(w http.ResponseWriter, body map[string]interface{})) *recordingServer {
+	t.Helper()
+	rs := &recordingServer{respond: respond}
+	rs.endpoint = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&body)
+		rs.mu.Lock()
+		rs.urls = append(rs.urls, r.URL.String())
+		rs.headers = append(rs.headers, r.Header.Clone())
+		rs.bodies = append(rs.bodies, body)
+		rs.mu.Unlock()
+		rs.respond(w, body)
+	}))
+	t.Cleanup(rs.endpoint.Close)
+	return rs
+}
+
+func TestAzureOpenAIClientUsesDeploymentURL(t *testing.T) {
+	rs := newRecordingServer(t, func(w http.ResponseWriter, body map[string]interface{}) {
+		writeOpenAIResponse(w, len(body["input"].([]interface{})))
+	})
+
+	client := NewAzureOpenAIClient(rs.endpoint.URL, "azure-key", AzureOptions{Deployment: "embed-prod"})
+	if _, err := client.BatchEmbedContents("text-embedding-3-large", []string{"a", "b"}, TaskRetrievalDocument, 256); err != nil {
+		t.Fatalf("BatchEmbedContents() error = %v", err)
+	}
+	// Without a deployment, the model name is the deployment; a full
+	// deployment URL is used as it is
+	byModel := NewAzureOpenAIClient(rs.endpoint.URL+"/", "azure-key", AzureOptions{APIVersion: "2025-01-01"})
+	if _, err := byModel.BatchEmbedContents("text-embedding-3-small", []string{"a"}, TaskRetrievalDocument, 0); err != nil {
+		t.Fatalf("BatchEmbedContents() error = %v", err)
+	}
+	full := NewAzureOpenAIClient(rs.endpoint.URL+"/openai/deployments/mine", "azure-key", AzureOptions{Deployment: "ignored"})
+	if _, err := full.BatchEmbedContents("m", []string{"a"}, TaskRetrievalDocument, 0); err != nil {
+		t.Fatalf("BatchEmbedContents() error = %v", err)
+	}
+
+	want := []string{
+		"/openai/deployments/embed-prod/embeddings?api-version=" + DefaultAzureAPIVersion,
+		"/openai/deployments/text-embedding-3-small/embeddings?api-version=2025-01-01",
+		"/openai/deployments/mine/embeddings?api-version=" + DefaultAzureAPIVersion,
+	}
+	for i, url := range want {
+		if rs.urls[i] != url {
+			t.Errorf("request %d URL = %q, want %q", i, rs.urls[i], url)
+		}
+	}
+	if got := rs.headers[0].Get("api-key"); got != "azure-key" {
+		t.Fatalf("api-key header = %q", got)
+	}
+	if rs.headers[0].Get("Authorization") != "" {
+		t.Fatal("Azure requests must not send a bearer token")
+	}
+	if got := rs.bodies[0]["dimensions"]; got != float64(256) {
+		t.Fatalf("dimensions = %v, want 256", got)
+	}
+}
+
+func TestVoyageClientSendsInputType(t *testing.T) {
+	rs := newRecordingServer(t, func(w http.ResponseWriter, body map[string]interface{}) {
+		writeOpenAIResponse(w, len(body["input"].([]interface{})))
+	})
+	client := NewVoyageClient(rs.endpoint.URL, "voyage-key")
+
+	if _, err := client.BatchEmbedContents("voyage-3.5", []string{"a", "b"}, TaskRetrievalDocument, 512); err != nil {
+		t.Fatalf("BatchEmbedContents() error = %v", err)
+	}
+	if _, err := client.EmbedContent("voyage-3.5", "q", TaskRetrievalQuery, 0); err != nil {
+		t.Fatalf("EmbedContent() error = %v", err)
+	}
+
+	if rs.urls[0] != "/v1/embeddings" || rs.headers[0].Get("Authorization") != "Bearer voyage-key" {
+		t.Fatalf("request = %s %v", rs.urls[0], rs.headers[0])
+	}
+	if rs.bodies[0]["input_type"] != "document" || rs.bodies[0]["output_dimension"] != float64(512) {
+		t.Fatalf("document request = %v", rs.bodies[0])
+	}
+	if rs.bodies[1]["input_type"] != "query" {
+		t.Fatalf("query request = %v", rs.bodies[1])
+	}
+	if _, ok := rs.bodies[1]["output_dimension"]; ok {
+		t.Fatalf("output_dimension sent without a dimension: %v", rs.bodies[1])
+	}
+}
+
+func TestCohereClientSplitsBatchesAndSendsInputType(t *testing.T) {
+	rs := newRecordingServer(t, func(w http.ResponseWriter, body map[string]interface{}) {
+		var resp cohereEmbedResponse
+		for range body["texts"].([]interface{}) {
+			resp.Embeddings.Float = append(resp.Embeddings.Float, []float32{1, 2})
+		}
+		json.NewEncoder(w).Encode(resp)
+	})
+	client := NewCohereClient(rs.endpoint.URL, "co-key")
+
+	texts := make([]string, 2*cohereMaxBatch+1)
+	for i := range texts {
+		texts[i] = "t"
+	}
+	vecs, err := client.BatchEmbedContents("embed-v4.0", texts, TaskRetrievalDocument, 0)
+	if err != nil {
+		t.Fatalf("BatchEmbedContents() error = %v", err)
+	}
+	if len(vecs) != len(texts) || len(rs.bodies) != 3 {
+		t.Fatalf("got %d vectors in %d requests, want %d in 3", len(vecs), len(rs.bodies), len(texts))
+	}
+	if n := len(rs.bodies[0]["texts"].([]interface{})); n != cohereMaxBatch {
+		t.Fatalf("first request has %d texts, want %d", n, cohereMaxBatch)
+	}
+	if rs.urls[0] != "/v2/embed" || rs.bodies[0]["input_type"] != "search_document" {
+		t.Fatalf("request = %s %v", rs.urls[0], rs.bodies[0])
+	}
+
+	if _, err := client.EmbedContent("embed-v4.0", "q", TaskRetrievalQuery, 256); err != nil {
+		t.Fatalf("EmbedContent() error = %v", err)
+	}
+	last := rs.bodies[len(rs.bodies)-1]
+	if last["input_type"] != "search_query" || last["output_dimension"] != float64(256) {
+		t.Fatalf("query request = %v", last)
+	}
+}
+
+func TestTEIClientEmbedsAndResolvesModel(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/info":
+			json.NewEncoder(w).Encode(map[string]interface{}{"model_id": "BAAI/bge-m3", "max_client_batch_size": 32})
+		case "/embed":
+			var req teiEmbedRequest
+			json.NewDecoder(r.Body).Decode(&req)
+			if !req.Truncate || !req.Normalize {
+				t.Errorf("request = %+v, want truncate and normalize", req)
+			}
+			vecs := make([][]float32, len(req.Inputs))
+			for i, text := range req.Inputs {
+				vecs[i] = []float32{float32(len(text)), 1}
+			}
+			json.NewEncoder(w).Encode(vecs)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	client := NewTEIClient(srv.URL, "")
+	model, err := client.ResolveModel("")
+	if err != nil || model != "BAAI/bge-m3" {
+		t.Fatalf("ResolveModel() = %q, %v", model, err)
+	}
+	if model, _ := client.ResolveModel("label"); model != "label" {
+		t.Fatalf("ResolveModel(label) = %q", model)
+	}
+
+	vecs, err := client.BatchEmbedContents(model, []string{"a", "bb"}, TaskRetrievalDocument, 0)
+	if err != nil {
+		t.Fatalf("BatchEmbedContents() error = %v", err)
+	}
+	if len(vecs) != 2 || vecs[1][0] != 2 {
+		t.Fatalf("vecs = %v", vecs)
+	}
+}
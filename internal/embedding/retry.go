@@ -0,0 +1,257 @@
+package embedding
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// RetryConfig controls how embedding API calls are retried and rate limited.
+// Every client in this package applies it to its HTTP requests.
+type RetryConfig struct {
+	MaxRetries        int           // retries after the first attempt (0 = fail on the first error)
+	BaseDelay         time.Duration // backoff before the first retry; doubles on each further retry
+	MaxDelay          time.Duration // upper bound for a single wait, including Retry-After
+	RequestsPerMinute int           // client-side request limit (0 = unlimited)
+	TokensPerMinute   int           // client-side input token limit, estimated from text length (0 = unlimited)
+	BreakerThreshold  int           // consecutive failures after which a fallback backend is skipped (see FallbackClient)
+	BreakerCooldown   time.Duration // how long a failing fallback backend is skipped
+}
+
+// DefaultRetryConfig returns the retry settings clients start with
+func DefaultRetryConfig() RetryConfig {
+	return RetryConfig{
+		MaxRetries: 3,
+		BaseDelay:  5 * time.Second,
+		MaxDelay:   time.Minute,
+
+		BreakerThreshold: 3,
+		BreakerCooldown:  time.Minute,
+	}
+}
+
+// StatusError is returned when an embedding API answers with a non-success status
+type StatusError struct {
+	Op         string
+	StatusCode int
+	Body       string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("%s failed with status %d: %s", e.Op, e.StatusCode, e.Body)
+}
+
+// isRetryableStatus returns true for HTTP status codes that should be retried
+func isRetryableStatus(statusCode int) bool {
+	switch statusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// transport sends embedding API requests with retries, exponential backoff
+// and token-bucket rate limiting. Clients embed it.
+type transport struct {
+	httpClient *http.Client
+	retry      RetryConfig
+	requests   *tokenBucket
+	tokens     *tokenBucket
+	sleep      func(time.Duration)
+	now        func() time.Time
+}
+
+func newTransport() transport {
+	return transport{
+		httpClient: &http.Client{
+			Timeout: 5 * time.Minute,
+		},
+		retry: DefaultRetryConfig(),
+		sleep: time.Sleep,
+		now:   time.Now,
+	}
+}
+
+// SetRetryConfig replaces the retry and rate-limit settings of the client
+func (t *transport) SetRetryConfig(cfg RetryConfig) {
+	t.retry = cfg
+	t.requests = newTokenBucket(cfg.RequestsPerMinute, t.now())
+	t.tokens = newTokenBucket(cfg.TokensPerMinute, t.now())
+}
+
+// post sends a JSON body to url and returns the response body of a 200
+// response. tokens is the estimated input size used for rate limiting.
+func (t *transport) post(op, url string, header http.Header, body []byte, tokens int) ([]byte, error) {
+	return t.do("POST", op, url, header, body, tokens)
+}
+
+// get fetches url and returns the response body of a 200 response
+func (t *transport) get(op, url string, header http.Header) ([]byte, error) {
+	return t.do("GET", op, url, header, nil, 0)
+}
+
+func (t *transport) do(method, op, url string, header http.Header, body []byte, tokens int) ([]byte, error) {
+	var lastErr error
+	for attempt := 0; attempt <= t.retry.MaxRetries; attempt++ {
+		t.wait(tokens)
+
+		var bodyReader io.Reader
+		if body != nil {
+			bodyReader = bytes.NewReader(body)
+		}
+		req, err := http.NewRequest(method, url, bodyReader)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create request: %w", err)
+		}
+		if body != nil {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		for key, values := range header {
+			for _, v := range values {
+				req.Header.Add(key, v)
+			}
+		}
+
+		var retryAfter time.Duration
+		resp, err := t.httpClient.Do(req)
+		if err != nil {
+			lastErr = fmt.Errorf("failed to call %s: %w", op, err)
+		} else {
+			respBody, err := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			if err != nil {
+				return nil, fmt.Errorf("failed to read response: %w", err)
+			}
+			if resp.StatusCode == http.StatusOK {
+				return respBody, nil
+			}
+			lastErr = &StatusError{Op: op, StatusCode: resp.StatusCode, Body: string(respBody)}
+			if !isRetryableStatus(resp.StatusCode) {
+				return nil, lastErr
+			}
+			retryAfter = parseRetryAfter(resp.Header.Get("Retry-After"), t.now())
+		}
+
+		if attempt == t.retry.MaxRetries {
+			break
+		}
+		delay := t.backoff(attempt + 1)
+		if retryAfter > delay {
+			delay = retryAfter
+		}
+		if t.retry.MaxDelay > 0 && delay > t.retry.MaxDelay {
+			delay = t.retry.MaxDelay
+		}
+		fmt.Fprintf(os.Stderr, "Retrying %s (attempt %d/%d, wait %s)...\n", op, attempt+1, t.retry.MaxRetries, delay.Round(time.Millisecond))
+		t.sleep(delay)
+	}
+
+	return nil, lastErr
+}
+
+// wait blocks until the request and token buckets allow another request
+func (t *transport) wait(tokens int) {
+	now := t.now()
+	delay := t.requests.reserve(1, now)
+	if d := t.tokens.reserve(float64(tokens), now); d > delay {
+		delay = d
+	}
+	if delay > 0 {
+		t.sleep(delay)
+	}
+}
+
+// backoff returns the jittered exponential delay before the given retry:
+// a random duration between half and all of BaseDelay × 2^(retry-1)
+func (t *transport) backoff(retry int) time.Duration {
+	if t.retry.BaseDelay <= 0 {
+		return 0
+	}
+	delay := t.retry.BaseDelay << (retry - 1)
+	if delay <= 0 || (t.retry.MaxDelay > 0 && delay > t.retry.MaxDelay) {
+		delay = t.retry.MaxDelay
+	}
+	half := delay / 2
+	return half + time.Duration(rand.Int64N(int64(half)+1))
+}
+
+// parseRetryAfter reads a Retry-After header given in seconds or as an HTTP date
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(value); err == nil && secs > 0 {
+		return time.Duration(secs) * time.Second
+	}
+	if at, err := http.ParseTime(value); err == nil && at.After(now) {
+		return at.Sub(now)
+	}
+	return 0
+}
+
+// estimateTokens approximates the token count of texts (about 4 bytes per token)
+func estimateTokens(texts ...string) int {
+	n := 0
+	for _, text := range texts {
+		n += (len(text) + 3) / 4
+	}
+	if n == 0 {
+		n = 1
+	}
+	return n
+}
+
+// tokenBucket is a token bucket refilled continuously at perMinute tokens per
+// minute, holding at most one minute's worth. A nil bucket never limits.
+type tokenBucket struct {
+	mu       sync.Mutex
+	capacity float64
+	tokens   float64
+	perSec   float64
+	last     time.Time
+}
+
+func newTokenBucket(perMinute int, now time.Time) *tokenBucket {
+	if perMinute <= 0 {
+		return nil
+	}
+	return &tokenBucket{
+		capacity: float64(perMinute),
+		tokens:   float64(perMinute),
+		perSec:   float64(perMinute) / 60,
+		last:     now,
+	}
+}
+
+// reserve takes n tokens and returns how long the caller must wait before
+// using them. The balance may go negative, which queues later callers behind
+// earlier ones. Requests larger than the bucket wait for a full bucket.
+func (b *tokenBucket) reserve(n float64, now time.Time) time.Duration {
+	if b == nil {
+		return 0
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if elapsed := now.Sub(b.last).Seconds(); elapsed > 0 {
+		b.tokens += elapsed * b.perSec
+		if b.tokens > b.capacity {
+			b.tokens = b.capacity
+		}
+		b.last = now
+	}
+	if n > b.capacity {
+		n = b.capacity
+	}
+	b.tokens -= n
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / b.perSec * float64(time.Second))
+}
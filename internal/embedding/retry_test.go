@@ -0,0 +1,183 @@
+package embedding
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// recordSleeps replaces the transport's sleep with one that records the waits
+func recordSleeps(t *transport) *[]time.Duration {
+	var slept []time.Duration
+	t.sleep = func(d time.Duration) { slept = append(slept, d) }
+	return &slept
+}
+
+func writeOpenAIResponse(w http.ResponseWriter, n int) {
+	resp := openAIEmbedResponse{}
+	for i := 0; i < n; i++ {
+		resp.Data = append(resp.Data, openAIEmbedData{Embedding: []float32{float32(i), 1}, Index: i})
+	}
+	json.NewEncoder(w).Encode(resp)
+}
+
+func TestOpenAIClientRetriesUnavailable(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) <= 2 {
+			http.Error(w, "overloaded", http.StatusServiceUnavailable)
+			return
+		}
+		writeOpenAIResponse(w, 2)
+	}))
+	defer srv.Close()
+
+	client := NewOpenAIClient(srv.URL, "")
+	client.SetRetryConfig(RetryConfig{MaxRetries: 3, BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second})
+	slept := recordSleeps(&client.transport)
+
+	vecs, err := client.BatchEmbedContents("m", []string{"a", "b"}, TaskRetrievalDocument, 0)
+	if err != nil {
+		t.Fatalf("BatchEmbedContents() error = %v", err)
+	}
+	if len(vecs) != 2 || calls.Load() != 3 {
+		t.Fatalf("vecs=%d calls=%d, want 2/3", len(vecs), calls.Load())
+	}
+	if len(*slept) != 2 {
+		t.Fatalf("slept %v, want 2 backoffs", *slept)
+	}
+	for i, d := range *slept {
+		full := 100 * time.Millisecond << i
+		if d < full/2 || d > full {
+			t.Fatalf("backoff %d = %s, want between %s and %s", i+1, d, full/2, full)
+		}
+	}
+}
+
+func TestOpenAIClientHonoursRetryAfter(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			w.Header().Set("Retry-After", "7")
+			http.Error(w, "slow down", http.StatusTooManyRequests)
+			return
+		}
+		writeOpenAIResponse(w, 1)
+	}))
+	defer srv.Close()
+
+	client := NewOpenAIClient(srv.URL, "")
+	client.SetRetryConfig(RetryConfig{MaxRetries: 1, BaseDelay: time.Millisecond, MaxDelay: time.Minute})
+	slept := recordSleeps(&client.transport)
+
+	if _, err := client.EmbedContent("m", "a", TaskRetrievalQuery, 0); err != nil {
+		t.Fatalf("EmbedContent() error = %v", err)
+	}
+	if len(*slept) != 1 || (*slept)[0] != 7*time.Second {
+		t.Fatalf("slept %v, want [7s]", *slept)
+	}
+}
+
+func TestOpenAIClientDoesNotRetryClientErrors(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		http.Error(w, "bad model", http.StatusBadRequest)
+	}))
+	defer srv.Close()
+
+	client := NewOpenAIClient(srv.URL, "")
+	recordSleeps(&client.transport)
+
+	_, err := client.EmbedContent("m", "a", TaskRetrievalQuery, 0)
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusBadRequest {
+		t.Fatalf("error = %v, want StatusError 400", err)
+	}
+	if calls.Load() != 1 {
+		t.Fatalf("calls = %d, want 1", calls.Load())
+	}
+}
+
+func TestGeminiClientGivesUpAfterMaxRetries(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		if !strings.HasSuffix(r.URL.Path, ":batchEmbedContents") {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	client := NewGeminiClient("key")
+	client.baseURL = srv.URL
+	client.SetRetryConfig(RetryConfig{MaxRetries: 2, BaseDelay: time.Second, MaxDelay: 3 * time.Second})
+	slept := recordSleeps(&client.transport)
+
+	_, err := client.BatchEmbedContents("gemini-embedding-001", []string{"a"}, TaskRetrievalDocument, 8)
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("error = %v, want StatusError 503", err)
+	}
+	if calls.Load() != 3 || len(*slept) != 2 {
+		t.Fatalf("calls=%d sleeps=%d, want 3/2", calls.Load(), len(*slept))
+	}
+	for _, d := range *slept {
+		if d > 3*time.Second {
+			t.Fatalf("wait %s exceeds MaxDelay", d)
+		}
+	}
+}
+
+func TestRateLimitsDelayRequests(t *testing.T) {
+	now := time.Unix(0, 0)
+	tr := newTransport()
+	tr.now = func() time.Time { return now }
+	tr.SetRetryConfig(RetryConfig{RequestsPerMinute: 60, TokensPerMinute: 1000})
+	slept := recordSleeps(&tr)
+
+	// A full bucket allows a burst of one minute's worth of requests
+	for i := 0; i < 60; i++ {
+		tr.wait(1)
+	}
+	if len(*slept) != 0 {
+		t.Fatalf("burst was throttled: %v", *slept)
+	}
+	tr.wait(1)
+	if len(*slept) != 1 || (*slept)[0] != time.Second {
+		t.Fatalf("61st request waited %v, want 1s", *slept)
+	}
+
+	// After a minute the token bucket is full again; 1800 tokens leave a
+	// deficit of 800, which takes 48s to refill at 1000 tokens per minute
+	now = now.Add(time.Minute)
+	*slept = nil
+	tr.wait(900)
+	tr.wait(900)
+	if len(*slept) != 1 {
+		t.Fatalf("token limit waits = %v, want one", *slept)
+	}
+	if want := 48 * time.Second; (*slept)[0] != want {
+		t.Fatalf("token limit wait = %s, want %s", (*slept)[0], want)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	if got := parseRetryAfter("12", now); got != 12*time.Second {
+		t.Fatalf("seconds: got %s", got)
+	}
+	date := now.Add(30 * time.Second).Format(http.TimeFormat)
+	if got := parseRetryAfter(date, now); got != 30*time.Second {
+		t.Fatalf("date: got %s", got)
+	}
+	if got := parseRetryAfter("soon", now); got != 0 {
+		t.Fatalf("invalid: got %s", got)
+	}
+}
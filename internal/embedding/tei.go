@@ -0,0 +1,109 @@
+package embedding
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// teiMaxBatch is the default max_client_batch_size of a TEI server; servers
+// started with a smaller limit answer 413 and the batch is split
+const teiMaxBatch = 32
+
+// TEIClient is an embedding client for HuggingFace Text Embeddings Inference
+// (/embed). A TEI server serves a single model, so the model name is only a
+// label; ResolveModel reads it from /info.
+type TEIClient struct {
+	transport
+	baseURL string
+	header  http.Header
+}
+
+// NewTEIClient creates a new Text Embeddings Inference client. apiKey is
+// needed for hosted endpoints only.
+func NewTEIClient(baseURL, apiKey string) *TEIClient {
+	header := http.Header{}
+	if apiKey != "" {
+		header.Set("Authorization", "Bearer "+apiKey)
+	}
+	return &TEIClient{
+		transport: newTransport(),
+		baseURL:   strings.TrimRight(baseURL, "/"),
+		header:    header,
+	}
+}
+
+type teiEmbedRequest struct {
+	Inputs    []string `json:"inputs"`
+	Truncate  bool     `json:"truncate"`
+	Normalize bool     `json:"normalize"`
+}
+
+type teiInfoResponse struct {
+	ModelID string `json:"model_id"`
+}
+
+func (c *TEIClient) embed(texts []string) ([][]float32, error) {
+	jsonBody, err := json.Marshal(teiEmbedRequest{Inputs: texts, Truncate: true, Normalize: true})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	body, err := c.post("TEI embed", c.baseURL+"/embed", c.header, jsonBody, estimateTokens(texts...))
+	if err != nil {
+		return nil, err
+	}
+
+	var embeddings [][]float32
+	if err := json.Unmarshal(body, &embeddings); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	// Embeddings are returned in input order; a response of the wrong
+	// length can't be matched to the texts, so all items count as missing
+	result := make([][]float32, len(texts))
+	if len(embeddings) == len(texts) {
+		copy(result, embeddings)
+	}
+	return result, nil
+}
+
+// EmbedContent generates an embedding for a single text.
+// model, taskType and dimension are ignored (the server has one model).
+func (c *TEIClient) EmbedContent(_, text string, _ TaskType, _ int) ([]float32, error) {
+	embeddings, err := c.embed([]string{text})
+	if err != nil {
+		return nil, err
+	}
+	if len(embeddings[0]) == 0 {
+		return nil, fmt.Errorf("empty embedding response")
+	}
+	return embeddings[0], nil
+}
+
+// BatchEmbedContents generates embeddings for multiple texts.
+// model, taskType and dimension are ignored (the server has one model).
+func (c *TEIClient) BatchEmbedContents(_ string, texts []string, _ TaskType, _ int) ([][]float32, error) {
+	return embedBatch(texts, teiMaxBatch, c.embed)
+}
+
+// ResolveModel returns the model the server was started with (/info). A
+// given model name is kept as it is.
+func (c *TEIClient) ResolveModel(model string) (string, error) {
+	if model != "" {
+		return model, nil
+	}
+	body, err := c.get("TEI info", c.baseURL+"/info", c.header)
+	if err != nil {
+		return "", fmt.Errorf("failed to read TEI server info: %w", err)
+	}
+	var info teiInfoResponse
+	if err := json.Unmarshal(body, &info); err != nil {
+		return "", fmt.Errorf("failed to parse response: %w", err)
+	}
+	if info.ModelID == "" {
+		return "", fmt.Errorf("TEI server at %s did not report its model; pass --model", c.baseURL)
+	}
+	return info.ModelID, nil
+}
@@ -0,0 +1,112 @@
+package embedding
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+const (
+	// DefaultVoyageURL is the address of the Voyage AI API
+	DefaultVoyageURL = "https://api.voyageai.com"
+	voyageMaxBatch   = 1000 // texts per embeddings request
+)
+
+// VoyageClient is an embedding client for the Voyage AI API. Queries and
+// documents are told apart with input_type instead of prompt prefixes.
+type VoyageClient struct {
+	transport
+	baseURL string
+	apiKey  string
+}
+
+// NewVoyageClient creates a new Voyage AI embedding client
+func NewVoyageClient(baseURL, apiKey string) *VoyageClient {
+	if baseURL == "" {
+		baseURL = DefaultVoyageURL
+	}
+	return &VoyageClient{
+		transport: newTransport(),
+		baseURL:   strings.TrimRight(baseURL, "/"),
+		apiKey:    apiKey,
+	}
+}
+
+type voyageEmbedRequest struct {
+	Model           string   `json:"model"`
+	Input           []string `json:"input"`
+	InputType       string   `json:"input_type,omitempty"`
+	OutputDimension int      `json:"output_dimension,omitempty"`
+}
+
+type voyageEmbedResponse struct {
+	Data []openAIEmbedData `json:"data"` // same shape as OpenAI's
+}
+
+// voyageInputType maps a task type to Voyage's input_type
+func voyageInputType(taskType TaskType) string {
+	switch taskType {
+	case TaskRetrievalQuery:
+		return "query"
+	case TaskRetrievalDocument:
+		return "document"
+	}
+	return ""
+}
+
+func (c *VoyageClient) embed(model string, texts []string, taskType TaskType, dimension int) ([][]float32, error) {
+	jsonBody, err := json.Marshal(voyageEmbedRequest{
+		Model:           model,
+		Input:           texts,
+		InputType:       voyageInputType(taskType),
+		OutputDimension: dimension,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	header := http.Header{}
+	header.Set("Authorization", "Bearer "+c.apiKey)
+	body, err := c.post("Voyage embed", c.baseURL+"/v1/embeddings", header, jsonBody, estimateTokens(texts...))
+	if err != nil {
+		return nil, err
+	}
+
+	var embedResp voyageEmbedResponse
+	if err := json.Unmarshal(body, &embedResp); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	// Place each embedding by its index; out-of-range and duplicate indices
+	// are ignored and leave their slot for a retry
+	result := make([][]float32, len(texts))
+	for _, d := range embedResp.Data {
+		if d.Index < 0 || d.Index >= len(result) || result[d.Index] != nil {
+			continue
+		}
+		result[d.Index] = d.Embedding
+	}
+	return result, nil
+}
+
+// EmbedContent generates an embedding for a single text
+func (c *VoyageClient) EmbedContent(model, text string, taskType TaskType, dimension int) ([]float32, error) {
+	embeddings, err := c.embed(model, []string{text}, taskType, dimension)
+	if err != nil {
+		return nil, err
+	}
+	if len(embeddings[0]) == 0 {
+		return nil, fmt.Errorf("empty embedding response")
+	}
+	return embeddings[0], nil
+}
+
+// BatchEmbedContents generates embeddings for multiple texts. Batches the
+// API rejects as too large are split; items that still fail are reported in
+// a *BatchError.
+func (c *VoyageClient) BatchEmbedContents(model string, texts []string, taskType TaskType, dimension int) ([][]float32, error) {
+	return embedBatch(texts, voyageMaxBatch, func(texts []string) ([][]float32, error) {
+		return c.embed(model, texts, taskType, dimension)
+	})
+}
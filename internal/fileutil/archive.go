@@ -0,0 +1,387 @@
+package fileutil
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ArchiveSeparator separates the path of an archive from the path of an
+// entry in it: "docs/bundle.zip!/guide/intro.md"
+const ArchiveSeparator = "!/"
+
+// archiveExtensions are the archive formats whose entries are discovered
+var archiveExtensions = []string{".zip", ".tar", ".tar.gz", ".tgz"}
+
+// IsArchive returns true if the file is a zip or (gzipped) tar archive
+func IsArchive(path string) bool {
+	name := strings.ToLower(path)
+	for _, ext := range archiveExtensions {
+		if strings.HasSuffix(name, ext) {
+			return true
+		}
+	}
+	return false
+}
+
+// SplitArchivePath splits the path of an archive entry into the archive's
+// path and the entry's; ok is false for other paths
+func SplitArchivePath(path string) (archive, entry string, ok bool) {
+	for i := 0; ; {
+		j := strings.Index(path[i:], ArchiveSeparator)
+		if j < 0 {
+			return "", "", false
+		}
+		j += i
+		if IsArchive(path[:j]) {
+			return path[:j], path[j+len(ArchiveSeparator):], true
+		}
+		i = j + 1
+	}
+}
+
+// Open opens a file, or an archive entry (see SplitArchivePath), for reading
+func Open(path string) (io.ReadCloser, error) {
+	if archive, entry, ok := SplitArchivePath(path); ok {
+		return openEntry(archive, entry)
+	}
+	return os.Open(path)
+}
+
+// ReadFile reads a file or an archive entry
+func ReadFile(path string) ([]byte, error) {
+	if _, _, ok := SplitArchivePath(path); !ok {
+		return os.ReadFile(path)
+	}
+	rc, err := Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	return io.ReadAll(rc)
+}
+
+// Stat returns the FileInfo of a file or an archive entry. A missing entry
+// is reported like a missing file (os.IsNotExist).
+func Stat(path string) (fs.FileInfo, error) {
+	archive, entry, ok := SplitArchivePath(path)
+	if !ok {
+		return os.Stat(path)
+	}
+	if _, err := os.Stat(archive); err != nil {
+		return nil, err
+	}
+	var found fs.FileInfo
+	err := walkArchive(archive, func(name string, info fs.FileInfo, _ io.Reader) error {
+		if name == entry {
+			found = info
+			return errStopWalk
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if found == nil {
+		return nil, &fs.PathError{Op: "stat", Path: path, Err: fs.ErrNotExist}
+	}
+	return found, nil
+}
+
+// LocalPath returns a path on disk with the content of path, for tools that
+// only read files (ffmpeg): path itself for a file, or a temporary copy of
+// an archive entry. cleanup removes the copy.
+func LocalPath(path string) (local string, cleanup func(), err error) {
+	if _, _, ok := SplitArchivePath(path); !ok {
+		return path, func() {}, nil
+	}
+	rc, err := Open(path)
+	if err != nil {
+		return "", nil, err
+	}
+	defer rc.Close()
+	f, err := os.CreateTemp("", "ragujuary-entry-*"+filepath.Ext(path))
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	cleanup = func() { os.Remove(f.Name()) }
+	if _, err := io.Copy(f, rc); err != nil {
+		f.Close()
+		cleanup()
+		return "", nil, fmt.Errorf("failed to extract %s: %w", path, err)
+	}
+	if err := f.Close(); err != nil {
+		cleanup()
+		return "", nil, fmt.Errorf("failed to extract %s: %w", path, err)
+	}
+	return f.Name(), cleanup, nil
+}
+
+// discoverArchive returns the regular files of an archive, with their
+// checksums: computing them here reads a tar archive once instead of once
+// per entry
+func discoverArchive(archive, root string, include includeMatcher, exclude func(string) bool, mimeTypes map[string]string) ([]FileInfo, error) {
+	var files []FileInfo
+	err := walkArchive(archive, func(name string, info fs.FileInfo, r io.Reader) error {
+		entryPath := archive + ArchiveSeparator + name
+		// Include globs match the entry's path in the archive too
+		if !include.match(root, entryPath) && !include.match(archive+"!", entryPath) {
+			return nil
+		}
+		if exclude(entryPath) {
+			return nil
+		}
+		h := sha256.New()
+		head := make([]byte, sniffLen)
+		n, err := io.ReadFull(io.TeeReader(r, h), head)
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return fmt.Errorf("failed to read %s: %w", entryPath, err)
+		}
+		if _, err := io.Copy(h, r); err != nil {
+			return fmt.Errorf("failed to read %s: %w", entryPath, err)
+		}
+		mimeType, binary := detectContent(entryPath, head[:n], mimeTypes)
+		files = append(files, FileInfo{
+			Path:     entryPath,
+			Size:     info.Size(),
+			Checksum: hex.EncodeToString(h.Sum(nil)),
+			MimeType: mimeType,
+			Binary:   binary,
+		})
+		return nil
+	})
+	return files, err
+}
+
+// errStopWalk ends walkArchive early without an error
+var errStopWalk = errors.New("stop walking the archive")
+
+// walkArchive calls fn for every regular file of an archive, in archive
+// order, with its entry path and content
+func walkArchive(archive string, fn func(name string, info fs.FileInfo, r io.Reader) error) error {
+	if strings.HasSuffix(strings.ToLower(archive), ".zip") {
+		zr, err := zip.OpenReader(archive)
+		if err != nil {
+			return fmt.Errorf("failed to open archive %s: %w", archive, err)
+		}
+		defer zr.Close()
+		for _, f := range zr.File {
+			name := entryName(f.Name)
+			if name == "" || !f.Mode().IsRegular() {
+				continue
+			}
+			rc, err := f.Open()
+			if err != nil {
+				return fmt.Errorf("failed to open %s in %s: %w", name, archive, err)
+			}
+			err = fn(name, f.FileInfo(), rc)
+			rc.Close()
+			if err == errStopWalk {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if entries, ok := cachedTar(archive); ok {
+		for _, e := range entries {
+			if err := fn(e.name, e.info, bytes.NewReader(e.data)); err == errStopWalk {
+				return nil
+			} else if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	tr, closer, err := openTar(archive)
+	if err != nil {
+		return err
+	}
+	defer closer.Close()
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read archive %s: %w", archive, err)
+		}
+		name := entryName(hdr.Name)
+		if name == "" || hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		if err := fn(name, hdr.FileInfo(), tr); err == errStopWalk {
+			return nil
+		} else if err != nil {
+			return err
+		}
+	}
+}
+
+// openEntry opens an archive entry for reading
+func openEntry(archive, entry string) (io.ReadCloser, error) {
+	if strings.HasSuffix(strings.ToLower(archive), ".zip") {
+		zr, err := zip.OpenReader(archive)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open archive %s: %w", archive, err)
+		}
+		for _, f := range zr.File {
+			if entryName(f.Name) == entry && f.Mode().IsRegular() {
+				rc, err := f.Open()
+				if err != nil {
+					zr.Close()
+					return nil, fmt.Errorf("failed to open %s in %s: %w", entry, archive, err)
+				}
+				return readCloser{rc, func() error { rc.Close(); return zr.Close() }}, nil
+			}
+		}
+		zr.Close()
+		return nil, &fs.PathError{Op: "open", Path: archive + ArchiveSeparator + entry, Err: fs.ErrNotExist}
+	}
+
+	if entries, ok := cachedTar(archive); ok {
+		for _, e := range entries {
+			if e.name == entry {
+				return io.NopCloser(bytes.NewReader(e.data)), nil
+			}
+		}
+		return nil, &fs.PathError{Op: "open", Path: archive + ArchiveSeparator + entry, Err: fs.ErrNotExist}
+	}
+	tr, closer, err := openTar(archive)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			closer.Close()
+			return nil, &fs.PathError{Op: "open", Path: archive + ArchiveSeparator + entry, Err: fs.ErrNotExist}
+		}
+		if err != nil {
+			closer.Close()
+			return nil, fmt.Errorf("failed to read archive %s: %w", archive, err)
+		}
+		if hdr.Typeflag == tar.TypeReg && entryName(hdr.Name) == entry {
+			return readCloser{tr, closer.Close}, nil
+		}
+	}
+}
+
+// readCloser pairs a reader with the function that releases it
+type readCloser struct {
+	io.Reader
+	close func() error
+}
+
+func (r readCloser) Close() error { return r.close() }
+
+// openTar opens a tar archive, gunzipping .tar.gz and .tgz
+func openTar(archive string) (*tar.Reader, io.Closer, error) {
+	f, err := os.Open(archive)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open archive %s: %w", archive, err)
+	}
+	name := strings.ToLower(archive)
+	if !strings.HasSuffix(name, ".gz") && !strings.HasSuffix(name, ".tgz") {
+		return tar.NewReader(f), f, nil
+	}
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		f.Close()
+		return nil, nil, fmt.Errorf("failed to open archive %s: %w", archive, err)
+	}
+	return tar.NewReader(gz), readCloser{nil, func() error { gz.Close(); return f.Close() }}, nil
+}
+
+// entryName returns the path an archive entry is discovered under, or ""
+// for entries outside the archive's root
+func entryName(name string) string {
+	name = path.Clean("/" + strings.ReplaceAll(name, "\\", "/"))
+	if name == "/" {
+		return ""
+	}
+	return name[1:]
+}
+
+// maxTarCache caps the size of a tar archive whose entries are cached
+const maxTarCache = 64 << 20
+
+// tarEntry is a cached regular file of a tar archive
+type tarEntry struct {
+	name string
+	info fs.FileInfo
+	data []byte
+}
+
+// tarCache holds the entries of the last small tar archive read, so that
+// reading its entries one after another doesn't decompress it each time
+var tarCache struct {
+	sync.Mutex
+	archive string
+	size    int64
+	modTime time.Time
+	entries []tarEntry
+	large   bool // the content exceeds maxTarCache
+}
+
+// cachedTar returns the entries of a tar archive whose content fits in
+// maxTarCache; ok is false for larger archives, which are streamed
+func cachedTar(archive string) ([]tarEntry, bool) {
+	st, err := os.Stat(archive)
+	if err != nil || st.Size() > maxTarCache {
+		return nil, false
+	}
+	tarCache.Lock()
+	defer tarCache.Unlock()
+	if tarCache.archive == archive && tarCache.size == st.Size() && tarCache.modTime.Equal(st.ModTime()) {
+		return tarCache.entries, !tarCache.large
+	}
+
+	tr, closer, err := openTar(archive)
+	if err != nil {
+		return nil, false
+	}
+	defer closer.Close()
+	var entries []tarEntry
+	var total int64
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, false
+		}
+		name := entryName(hdr.Name)
+		if name == "" || hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		if total += hdr.Size; total > maxTarCache {
+			tarCache.archive, tarCache.size, tarCache.modTime, tarCache.entries, tarCache.large = archive, st.Size(), st.ModTime(), nil, true
+			return nil, false
+		}
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, false
+		}
+		entries = append(entries, tarEntry{name: name, info: hdr.FileInfo(), data: data})
+	}
+	tarCache.archive, tarCache.size, tarCache.modTime, tarCache.entries, tarCache.large = archive, st.Size(), st.ModTime(), entries, false
+	return entries, true
+}
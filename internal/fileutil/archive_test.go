@@ -0,0 +1,164 @@
+package fileutil
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// entries are written in this order, directories first
+var archiveEntries = []struct{ name, content string }{
+	{"docs/", ""},
+	{"docs/guide.md", "# Guide\n"},
+	{"./docs/api/ref.txt", "reference\n"},
+	{"notes.txt", "notes\n"},
+}
+
+func writeZip(t *testing.T, path string) {
+	t.Helper()
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zw := zip.NewWriter(f)
+	for _, e := range archiveEntries {
+		w, err := zw.Create(e.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(e.content))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+}
+
+func writeTarGz(t *testing.T, path string) {
+	t.Helper()
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gz := gzip.NewWriter(f)
+	tw := tar.NewWriter(gz)
+	for _, e := range archiveEntries {
+		hdr := &tar.Header{Name: e.name, Mode: 0o644, Size: int64(len(e.content)), Typeflag: tar.TypeReg}
+		if e.content == "" {
+			hdr.Typeflag, hdr.Mode = tar.TypeDir, 0o755
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		tw.Write([]byte(e.content))
+	}
+	tw.Close()
+	gz.Close()
+	f.Close()
+}
+
+func TestDiscoverFilesDescendsIntoArchives(t *testing.T) {
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{"readme.md": "# Readme\n"})
+	writeZip(t, filepath.Join(root, "bundle.zip"))
+	writeTarGz(t, filepath.Join(root, "drop.tar.gz"))
+
+	got := discovered(t, root, []string{root}, DiscoverOptions{})
+	want := []string{
+		"bundle.zip!/docs/api/ref.txt", "bundle.zip!/docs/guide.md", "bundle.zip!/notes.txt",
+		"drop.tar.gz!/docs/api/ref.txt", "drop.tar.gz!/docs/guide.md", "drop.tar.gz!/notes.txt",
+		"readme.md",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("discovered %v, want %v", got, want)
+	}
+
+	// Include globs match paths inside the archive; excludes the full path
+	got = discovered(t, root, []string{root}, DiscoverOptions{Include: []string{"docs/**"}, Exclude: []string{`drop\.tar`}})
+	if want := []string{"bundle.zip!/docs/api/ref.txt", "bundle.zip!/docs/guide.md"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("discovered %v, want %v", got, want)
+	}
+
+	// An archive named explicitly
+	got = discovered(t, root, []string{filepath.Join(root, "bundle.zip")}, DiscoverOptions{Include: []string{"*.md"}})
+	if want := []string{"bundle.zip!/docs/guide.md"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("discovered %v, want %v", got, want)
+	}
+}
+
+func TestArchiveEntriesReadLikeFiles(t *testing.T) {
+	root := t.TempDir()
+	writeZip(t, filepath.Join(root, "bundle.zip"))
+	writeTarGz(t, filepath.Join(root, "drop.tgz"))
+
+	files, err := DiscoverFiles([]string{root}, DiscoverOptions{})
+	if err != nil {
+		t.Fatalf("DiscoverFiles: %v", err)
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].Path < files[j].Path })
+	if len(files) != 6 {
+		t.Fatalf("discovered %d files, want 6", len(files))
+	}
+	for _, f := range files {
+		data, err := ReadFile(f.Path)
+		if err != nil {
+			t.Fatalf("ReadFile(%s): %v", f.Path, err)
+		}
+		checksum, err := CalculateChecksum(f.Path)
+		if err != nil || checksum != f.Checksum {
+			t.Errorf("CalculateChecksum(%s) = %s, %v; discovered %s", f.Path, checksum, err, f.Checksum)
+		}
+		info, err := Stat(f.Path)
+		if err != nil || info.Size() != int64(len(data)) || f.Size != int64(len(data)) {
+			t.Errorf("Stat(%s) = %v, %v for %d bytes", f.Path, info, err, len(data))
+		}
+		if f.MimeType == "" || f.Binary {
+			t.Errorf("%s: type %q, binary %v", f.Path, f.MimeType, f.Binary)
+		}
+	}
+	if data, _ := ReadFile(filepath.Join(root, "drop.tgz") + "!/docs/guide.md"); string(data) != "# Guide\n" {
+		t.Errorf("ReadFile(guide.md) = %q", data)
+	}
+
+	local, cleanup, err := LocalPath(filepath.Join(root, "bundle.zip") + "!/notes.txt")
+	if err != nil {
+		t.Fatalf("LocalPath: %v", err)
+	}
+	if data, err := os.ReadFile(local); err != nil || string(data) != "notes\n" || filepath.Ext(local) != ".txt" {
+		t.Errorf("LocalPath copy %s = %q, %v", local, data, err)
+	}
+	cleanup()
+	if _, err := os.Stat(local); !os.IsNotExist(err) {
+		t.Errorf("LocalPath copy left behind: %v", err)
+	}
+
+	for _, missing := range []string{
+		filepath.Join(root, "bundle.zip") + "!/gone.md",
+		filepath.Join(root, "drop.tgz") + "!/gone.md",
+		filepath.Join(root, "nothere.zip") + "!/notes.txt",
+	} {
+		if _, err := Stat(missing); !os.IsNotExist(err) {
+			t.Errorf("Stat(%s) = %v, want a not-exist error", missing, err)
+		}
+	}
+}
+
+func TestSplitArchivePath(t *testing.T) {
+	tests := []struct{ path, archive, entry string }{
+		{"/d/bundle.zip!/docs/a.md", "/d/bundle.zip", "docs/a.md"},
+		{"/d/wow!/x.TAR.GZ!/a.md", "/d/wow!/x.TAR.GZ", "a.md"},
+		{"/d/wow!/a.md", "", ""},
+		{"/d/plain.md", "", ""},
+	}
+	for _, tt := range tests {
+		archive, entry, ok := SplitArchivePath(tt.path)
+		if archive != tt.archive || entry != tt.entry || ok != (tt.archive != "") {
+			t.Errorf("SplitArchivePath(%q) = %q, %q, %v", tt.path, archive, entry, ok)
+		}
+	}
+}
@@ -0,0 +1,195 @@
+package fileutil
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/htmlindex"
+	"golang.org/x/text/encoding/japanese"
+	"golang.org/x/text/encoding/unicode"
+)
+
+// Encoding names DecodeText reports
+const (
+	EncodingUTF16LE  = "utf-16le"
+	EncodingUTF16BE  = "utf-16be"
+	EncodingShiftJIS = "shift_jis"
+	EncodingEUCJP    = "euc-jp"
+	EncodingISO2022  = "iso-2022-jp"
+)
+
+// encodingAliases are names htmlindex doesn't know
+var encodingAliases = map[string]string{
+	"cp932":  "shift_jis",
+	"ms932":  "shift_jis",
+	"eucjp":  "euc-jp",
+	"utf16":  "utf-16le",
+	"utf-16": "utf-16le",
+}
+
+// LookupEncoding resolves an encoding name (a WHATWG label such as
+// "shift_jis", "sjis", "euc-jp", "utf-16le" or "windows-1252") to its
+// canonical name. "" and "auto" mean detection and resolve to "".
+func LookupEncoding(name string) (string, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if name == "" || name == "auto" {
+		return "", nil
+	}
+	if alias, ok := encodingAliases[name]; ok {
+		name = alias
+	}
+	enc, err := htmlindex.Get(name)
+	if err != nil {
+		return "", fmt.Errorf("unknown encoding %q", name)
+	}
+	canonical, err := htmlindex.Name(enc)
+	if err != nil {
+		return "", fmt.Errorf("unknown encoding %q", name)
+	}
+	return canonical, nil
+}
+
+// DecodeText transcodes text to UTF-8 and returns it with the name of the
+// encoding it was decoded from ("" for UTF-8). With an encoding name the
+// content is decoded from it; with "" or "auto" the encoding is detected: a
+// byte order mark, UTF-16 without one, valid UTF-8, ISO-2022-JP escapes,
+// then whichever of Shift_JIS and EUC-JP decodes the content into Japanese
+// text more cleanly. Content that none of these fit is returned as is.
+func DecodeText(data []byte, encodingName string) (string, string, error) {
+	name, err := LookupEncoding(encodingName)
+	if err != nil {
+		return "", "", err
+	}
+	if name == "" {
+		name = detectEncoding(data)
+	}
+	switch name {
+	case "", "utf-8":
+		return string(bytes.TrimPrefix(data, utf8BOM)), "", nil
+	case EncodingUTF16LE, EncodingUTF16BE:
+		// The decoder drops a byte order mark, and follows it over the order
+		order := unicode.LittleEndian
+		if name == EncodingUTF16BE {
+			order = unicode.BigEndian
+		}
+		return decodeWith(unicode.UTF16(order, unicode.UseBOM), data, name)
+	}
+	enc, err := htmlindex.Get(name)
+	if err != nil {
+		return "", "", fmt.Errorf("unknown encoding %q", name)
+	}
+	return decodeWith(enc, data, name)
+}
+
+func decodeWith(enc encoding.Encoding, data []byte, name string) (string, string, error) {
+	text, err := enc.NewDecoder().Bytes(data)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to decode %s text: %w", name, err)
+	}
+	return string(text), name, nil
+}
+
+var utf8BOM = []byte{0xEF, 0xBB, 0xBF}
+
+// detectEncoding guesses the encoding of text; "" for UTF-8 and for
+// content no candidate fits
+func detectEncoding(data []byte) string {
+	switch {
+	case bytes.HasPrefix(data, utf8BOM):
+		return ""
+	case bytes.HasPrefix(data, []byte{0xFF, 0xFE}):
+		return EncodingUTF16LE
+	case bytes.HasPrefix(data, []byte{0xFE, 0xFF}):
+		return EncodingUTF16BE
+	}
+	if order := utf16Order(data); order != "" {
+		return order
+	}
+	if utf8.Valid(data) {
+		// ISO-2022-JP is 7-bit: valid UTF-8 with kanji escape sequences
+		if bytes.Contains(data, []byte("\x1b$B")) || bytes.Contains(data, []byte("\x1b$@")) {
+			return EncodingISO2022
+		}
+		return ""
+	}
+
+	best, bestScore := "", 0
+	for _, candidate := range []struct {
+		name string
+		enc  encoding.Encoding
+	}{
+		{EncodingShiftJIS, japanese.ShiftJIS},
+		{EncodingEUCJP, japanese.EUCJP},
+	} {
+		text, err := candidate.enc.NewDecoder().Bytes(data)
+		if err != nil {
+			continue
+		}
+		if score := japaneseScore(text); score > bestScore {
+			best, bestScore = candidate.name, score
+		}
+	}
+	return best
+}
+
+// japaneseScore rates how much decoded text looks like Japanese: kana,
+// kanji and full-width punctuation count for it; replacement characters
+// (invalid sequences) and half-width katakana, which is what EUC-JP read as
+// Shift_JIS turns into, count against it
+func japaneseScore(text []byte) int {
+	score := 0
+	for _, r := range string(text) {
+		switch {
+		case r == utf8.RuneError:
+			score -= 8
+		case r >= 0xFF61 && r <= 0xFF9F: // half-width katakana
+			score -= 2
+		case r >= 0x3000 && r <= 0x30FF, // punctuation, hiragana, katakana
+			r >= 0x4E00 && r <= 0x9FFF, // kanji
+			r >= 0xFF01 && r <= 0xFF5E: // full-width forms
+			score += 2
+		}
+	}
+	return score
+}
+
+// utf16Order recognises UTF-16 without a byte order mark by its ASCII
+// characters, a printable byte paired with a NUL: "utf-16le" when the NULs
+// are the odd bytes, "utf-16be" when they are the even ones, "" otherwise
+func utf16Order(data []byte) string {
+	n := min(len(data), sniffLen) &^ 1
+	if n < 4 {
+		return ""
+	}
+	var le, be, nulPairs int
+	for i := 0; i < n; i += 2 {
+		switch {
+		case data[i] == 0 && data[i+1] == 0:
+			nulPairs++
+		case data[i+1] == 0 && asciiText(data[i]):
+			le++
+		case data[i] == 0 && asciiText(data[i+1]):
+			be++
+		}
+	}
+	pairs := n / 2
+	switch {
+	case nulPairs*20 >= pairs:
+		return ""
+	case le*5 > pairs*2 && be*20 < pairs:
+		return EncodingUTF16LE
+	case be*5 > pairs*2 && le*20 < pairs:
+		return EncodingUTF16BE
+	default:
+		return ""
+	}
+}
+
+// asciiText reports whether a byte is a printable ASCII character or
+// whitespace
+func asciiText(b byte) bool {
+	return (b >= 0x20 && b < 0x7F) || b == '\t' || b == '\n' || b == '\r'
+}
@@ -0,0 +1,95 @@
+package fileutil
+
+import (
+	"testing"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/japanese"
+	"golang.org/x/text/encoding/unicode"
+)
+
+const japaneseSample = "# 議事録\n\n来週の会議は東京本社で行います。資料を準備してください。\n"
+
+func encode(t *testing.T, enc encoding.Encoding, s string) []byte {
+	t.Helper()
+	data, err := enc.NewEncoder().Bytes([]byte(s))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestDecodeTextDetects(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     []byte
+		encoding string
+	}{
+		{"utf-8", []byte(japaneseSample), ""},
+		{"utf-8 with BOM", append([]byte("\xef\xbb\xbf"), japaneseSample...), ""},
+		{"shift_jis", encode(t, japanese.ShiftJIS, japaneseSample), EncodingShiftJIS},
+		{"euc-jp", encode(t, japanese.EUCJP, japaneseSample), EncodingEUCJP},
+		{"iso-2022-jp", encode(t, japanese.ISO2022JP, japaneseSample), EncodingISO2022},
+		{"utf-16le with BOM", encode(t, unicode.UTF16(unicode.LittleEndian, unicode.UseBOM), japaneseSample), EncodingUTF16LE},
+		{"utf-16be with BOM", encode(t, unicode.UTF16(unicode.BigEndian, unicode.UseBOM), japaneseSample), EncodingUTF16BE},
+	}
+	for _, tt := range tests {
+		text, enc, err := DecodeText(tt.data, "auto")
+		if err != nil {
+			t.Fatalf("%s: DecodeText: %v", tt.name, err)
+		}
+		if enc != tt.encoding || text != japaneseSample {
+			t.Errorf("%s: DecodeText = %q, %q; want %q, %q", tt.name, text, enc, japaneseSample, tt.encoding)
+		}
+	}
+
+	// ASCII-heavy UTF-16 without a byte order mark
+	english := "Meeting notes: see 東京 office\n"
+	for order, name := range map[unicode.Endianness]string{unicode.LittleEndian: EncodingUTF16LE, unicode.BigEndian: EncodingUTF16BE} {
+		data := encode(t, unicode.UTF16(order, unicode.IgnoreBOM), english)
+		if text, enc, err := DecodeText(data, ""); err != nil || text != english || enc != name {
+			t.Errorf("BOM-less %s: DecodeText = %q, %q, %v", name, text, enc, err)
+		}
+	}
+}
+
+func TestDecodeTextLeavesUnknownBytes(t *testing.T) {
+	// Latin-1 isn't detected: the content is kept as it is
+	data := []byte("caf\xe9 cr\xe8me\n")
+	text, enc, err := DecodeText(data, "")
+	if err != nil || text != string(data) || enc != "" {
+		t.Fatalf("DecodeText = %q, %q, %v", text, enc, err)
+	}
+}
+
+func TestDecodeTextOverride(t *testing.T) {
+	text, enc, err := DecodeText([]byte("caf\xe9\n"), "latin1")
+	if err != nil || text != "café\n" || enc != "windows-1252" {
+		t.Fatalf("DecodeText(latin1) = %q, %q, %v", text, enc, err)
+	}
+	text, enc, err = DecodeText(encode(t, japanese.ShiftJIS, japaneseSample), "CP932")
+	if err != nil || text != japaneseSample || enc != EncodingShiftJIS {
+		t.Fatalf("DecodeText(CP932) = %q, %q, %v", text, enc, err)
+	}
+	if _, _, err := DecodeText([]byte("x"), "klingon"); err == nil {
+		t.Fatal("expected an error for an unknown encoding")
+	}
+}
+
+func TestLookupEncoding(t *testing.T) {
+	for name, want := range map[string]string{
+		"":          "",
+		"auto":      "",
+		"sjis":      EncodingShiftJIS,
+		"Shift_JIS": EncodingShiftJIS,
+		"ms932":     EncodingShiftJIS,
+		"EUC-JP":    EncodingEUCJP,
+		"utf-16":    EncodingUTF16LE,
+		"UTF-16BE":  EncodingUTF16BE,
+		"utf8":      "utf-8",
+	} {
+		if got, err := LookupEncoding(name); err != nil || got != want {
+			t.Errorf("LookupEncoding(%q) = %q, %v; want %q", name, got, err, want)
+		}
+	}
+}
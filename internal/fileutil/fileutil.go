@@ -13,15 +13,16 @@ import (
 
 // FileInfo represents information about a file
 type FileInfo struct {
-	Path     string
+	Path     string // absolute; "archive.zip!/entry" for a file in an archive
 	Size     int64
-	Checksum string
+	Checksum string // SHA256 of the content; only set for archive entries
 	MimeType string
+	Binary   bool // the content is binary, not text in some encoding
 }
 
-// CalculateChecksum calculates SHA256 checksum of a file
+// CalculateChecksum calculates SHA256 checksum of a file or an archive entry
 func CalculateChecksum(path string) (string, error) {
-	f, err := os.Open(path)
+	f, err := Open(path)
 	if err != nil {
 		return "", fmt.Errorf("failed to open file: %w", err)
 	}
@@ -35,18 +36,50 @@ func CalculateChecksum(path string) (string, error) {
 	return hex.EncodeToString(h.Sum(nil)), nil
 }
 
-// DiscoverFiles discovers files in the given directories
-// excluding files matching any of the exclude patterns
-func DiscoverFiles(dirs []string, excludePatterns []string) ([]FileInfo, error) {
+// DiscoverOptions controls which files DiscoverFiles returns
+type DiscoverOptions struct {
+	Exclude  []string // regexes; files and directories whose absolute path matches one are skipped
+	Include  []string // gitignore-style globs; when given, only files matching one are returned
+	NoIgnore bool     // don't honour .gitignore / .ragujuaryignore files, and walk .git directories
+
+	// MIMETypes maps file extensions (".mdx" or "mdx") to the MIME type to
+	// use for them instead of the detected one
+	MIMETypes map[string]string
+}
+
+// DiscoverFiles discovers files in the given directories. Unless
+// opts.NoIgnore is set, files ignored by .gitignore and .ragujuaryignore files
+// (of the directories walked and of their parents up to the enclosing git
+// repository's root) are skipped, as are .git directories. The files of zip
+// and tar archives are returned in place of the archives, under paths like
+// "bundle.zip!/docs/guide.md" (see Open).
+func DiscoverFiles(dirs []string, opts DiscoverOptions) ([]FileInfo, error) {
 	// Compile exclude patterns
-	excludeRegexps := make([]*regexp.Regexp, 0, len(excludePatterns))
-	for _, pattern := range excludePatterns {
+	excludeRegexps := make([]*regexp.Regexp, 0, len(opts.Exclude))
+	for _, pattern := range opts.Exclude {
 		re, err := regexp.Compile(pattern)
 		if err != nil {
 			return nil, fmt.Errorf("invalid exclude pattern %q: %w", pattern, err)
 		}
 		excludeRegexps = append(excludeRegexps, re)
 	}
+	excluded := func(path string) bool {
+		for _, re := range excludeRegexps {
+			if re.MatchString(path) {
+				return true
+			}
+		}
+		return false
+	}
+	include, err := newIncludeMatcher(opts.Include)
+	if err != nil {
+		return nil, fmt.Errorf("invalid include pattern: %w", err)
+	}
+	mimeTypes, err := normalizeMIMETypes(opts.MIMETypes)
+	if err != nil {
+		return nil, err
+	}
+	ignores := newIgnoreMatcher()
 
 	var files []FileInfo
 
@@ -55,34 +88,57 @@ func DiscoverFiles(dirs []string, excludePatterns []string) ([]FileInfo, error)
 		if err != nil {
 			return nil, fmt.Errorf("failed to get absolute path for %q: %w", dir, err)
 		}
+		top := ignoreTop(absDir)
 
 		err = filepath.Walk(absDir, func(path string, info os.FileInfo, err error) error {
 			if err != nil {
 				return err
 			}
+			ignored := !opts.NoIgnore && path != absDir && ignores.ignored(path, info.IsDir(), top)
 
 			// Skip directories
 			if info.IsDir() {
+				if ignored || (!opts.NoIgnore && info.Name() == ".git") {
+					return filepath.SkipDir
+				}
 				// Check if directory should be excluded
-				for _, re := range excludeRegexps {
-					if re.MatchString(path) {
-						return filepath.SkipDir
-					}
+				if excluded(path) {
+					return filepath.SkipDir
 				}
 				return nil
 			}
+			// A file named explicitly is always taken. Include globs apply
+			// to the entries of archives, not the archives.
+			archive := IsArchive(path)
+			if path != absDir && (ignored || (!archive && !include.match(absDir, path))) {
+				return nil
+			}
 
 			// Check if file should be excluded
-			for _, re := range excludeRegexps {
-				if re.MatchString(path) {
+			if excluded(path) {
+				return nil
+			}
+
+			// Archives are discovered as directories of their entries
+			if archive {
+				entries, err := discoverArchive(path, absDir, include, excluded, mimeTypes)
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "Warning: skipping %s: %v\n", path, err)
 					return nil
 				}
+				files = append(files, entries...)
+				return nil
 			}
 
+			mimeType, binary, err := DetectFile(path, mimeTypes)
+			if err != nil {
+				return fmt.Errorf("failed to detect type of %s: %w", path, err)
+			}
 			files = append(files, FileInfo{
 				Path:     path,
 				Size:     info.Size(),
-				MimeType: detectMimeType(path),
+				MimeType: mimeType,
+				Binary:   binary,
 			})
 
 			return nil
@@ -96,58 +152,6 @@ func DiscoverFiles(dirs []string, excludePatterns []string) ([]FileInfo, error)
 	return files, nil
 }
 
-// detectMimeType detects MIME type based on file extension
-func detectMimeType(path string) string {
-	ext := strings.ToLower(filepath.Ext(path))
-	mimeTypes := map[string]string{
-		".txt":  "text/plain",
-		".md":   "text/markdown",
-		".html": "text/html",
-		".htm":  "text/html",
-		".css":  "text/css",
-		".js":   "text/javascript",
-		".ts":   "text/typescript",
-		".json": "application/json",
-		".xml":  "application/xml",
-		".yaml": "application/x-yaml",
-		".yml":  "application/x-yaml",
-		".go":   "text/x-go",
-		".py":   "text/x-python",
-		".java": "text/x-java",
-		".c":    "text/x-c",
-		".cpp":  "text/x-c++",
-		".h":    "text/x-c",
-		".hpp":  "text/x-c++",
-		".rs":   "text/x-rust",
-		".rb":   "text/x-ruby",
-		".php":  "text/x-php",
-		".sh":   "text/x-shellscript",
-		".pdf":  "application/pdf",
-		".doc":  "application/msword",
-		".docx": "application/vnd.openxmlformats-officedocument.wordprocessingml.document",
-		".csv":  "text/csv",
-		// Image types
-		".png":  "image/png",
-		".jpg":  "image/jpeg",
-		".jpeg": "image/jpeg",
-		".gif":  "image/gif",
-		".webp": "image/webp",
-		// Video types
-		".mp4":  "video/mp4",
-		".mpeg": "video/mpeg",
-		".mpg":  "video/mpeg",
-		// Audio types
-		".mp3": "audio/mp3",
-		".wav": "audio/wav",
-		".ogg": "audio/ogg",
-	}
-
-	if mime, ok := mimeTypes[ext]; ok {
-		return mime
-	}
-	return "application/octet-stream"
-}
-
 // ClassifyContent returns the content type category for a MIME type.
 // Returns "text", "image", "pdf", "video", or "audio".
 func ClassifyContent(mimeType string) string {
@@ -0,0 +1,249 @@
+package fileutil
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// IgnoreFileNames are the ignore files honoured in every directory, in the
+// order they apply: rules of later files override earlier ones
+var IgnoreFileNames = []string{".gitignore", ".ragujuaryignore"}
+
+// ignoreRule is one pattern line of an ignore file
+type ignoreRule struct {
+	re       *regexp.Regexp
+	negate   bool // "!pattern" re-includes what earlier rules excluded
+	dirOnly  bool // "pattern/" matches directories only
+	anchored bool // the pattern contains a slash: matched against the path relative to the ignore file
+}
+
+// ignoreList holds the rules of the ignore files of one directory
+type ignoreList struct {
+	dir   string
+	rules []ignoreRule
+}
+
+// parseIgnore parses gitignore-format lines
+func parseIgnore(dir string, lines []string) *ignoreList {
+	list := &ignoreList{dir: dir}
+	for _, line := range lines {
+		line = strings.TrimRight(line, "\r")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		// Trailing spaces are ignored unless escaped
+		for strings.HasSuffix(line, " ") && !strings.HasSuffix(line, "\\ ") {
+			line = line[:len(line)-1]
+		}
+		rule := ignoreRule{}
+		if strings.HasPrefix(line, "!") {
+			rule.negate = true
+			line = line[1:]
+		} else if strings.HasPrefix(line, "\\!") || strings.HasPrefix(line, "\\#") {
+			line = line[1:]
+		}
+		if strings.HasSuffix(line, "/") {
+			rule.dirOnly = true
+			line = strings.TrimRight(line, "/")
+		}
+		if line == "" {
+			continue
+		}
+		if strings.Contains(line, "/") {
+			rule.anchored = true
+			line = strings.TrimPrefix(line, "/")
+		}
+		re, err := globRegexp(line)
+		if err != nil {
+			continue // like git, skip patterns that can't be parsed
+		}
+		rule.re = re
+		list.rules = append(list.rules, rule)
+	}
+	return list
+}
+
+// loadIgnoreDir reads the ignore files of dir; nil if it has none
+func loadIgnoreDir(dir string) *ignoreList {
+	var lines []string
+	for _, name := range IgnoreFileNames {
+		f, err := os.Open(filepath.Join(dir, name))
+		if err != nil {
+			continue
+		}
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			lines = append(lines, scanner.Text())
+		}
+		f.Close()
+	}
+	if len(lines) == 0 {
+		return nil
+	}
+	return parseIgnore(dir, lines)
+}
+
+// match reports whether the rules decide about path (absolute), and if so
+// whether it is ignored. The last matching rule wins.
+func (l *ignoreList) match(path string, isDir bool) (ignored, matched bool) {
+	rel, err := filepath.Rel(l.dir, path)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return false, false
+	}
+	rel = filepath.ToSlash(rel)
+	base := rel[strings.LastIndex(rel, "/")+1:]
+	for _, rule := range l.rules {
+		if rule.dirOnly && !isDir {
+			continue
+		}
+		subject := base
+		if rule.anchored {
+			subject = rel
+		}
+		if rule.re.MatchString(subject) {
+			ignored, matched = !rule.negate, true
+		}
+	}
+	return ignored, matched
+}
+
+// ignoreMatcher applies the ignore files of a directory tree, loading each
+// directory's files once
+type ignoreMatcher struct {
+	lists map[string]*ignoreList // by directory; nil entries have no ignore files
+}
+
+func newIgnoreMatcher() *ignoreMatcher {
+	return &ignoreMatcher{lists: make(map[string]*ignoreList)}
+}
+
+func (m *ignoreMatcher) list(dir string) *ignoreList {
+	list, ok := m.lists[dir]
+	if !ok {
+		list = loadIgnoreDir(dir)
+		m.lists[dir] = list
+	}
+	return list
+}
+
+// ignored reports whether path is ignored by the ignore files of its
+// ancestors up to top (inclusive). Deeper files override shallower ones.
+func (m *ignoreMatcher) ignored(path string, isDir bool, top string) bool {
+	var dirs []string
+	for dir := filepath.Dir(path); ; dir = filepath.Dir(dir) {
+		dirs = append(dirs, dir)
+		if dir == top || dir == filepath.Dir(dir) {
+			break
+		}
+	}
+	result := false
+	for i := len(dirs) - 1; i >= 0; i-- {
+		if list := m.list(dirs[i]); list != nil {
+			if ignored, matched := list.match(path, isDir); matched {
+				result = ignored
+			}
+		}
+	}
+	return result
+}
+
+// ignoreTop returns the directory whose ignore files are the outermost ones
+// applying to dir: the root of the enclosing git repository, else dir
+func ignoreTop(dir string) string {
+	for d := dir; ; d = filepath.Dir(d) {
+		if _, err := os.Stat(filepath.Join(d, ".git")); err == nil {
+			return d
+		}
+		if d == filepath.Dir(d) {
+			return dir
+		}
+	}
+}
+
+// globRegexp converts a gitignore glob to an anchored regexp: * and ? don't
+// match "/", "**" spans directories, and [...] is a character class
+func globRegexp(glob string) (*regexp.Regexp, error) {
+	var b strings.Builder
+	b.WriteString("^")
+	for i := 0; i < len(glob); i++ {
+		c := glob[i]
+		switch {
+		case strings.HasPrefix(glob[i:], "**/"):
+			b.WriteString("(?:.*/)?")
+			i += 2
+		case strings.HasPrefix(glob[i:], "/**") && i+3 == len(glob):
+			b.WriteString("/.*")
+			i += 2
+		case strings.HasPrefix(glob[i:], "**"):
+			b.WriteString(".*")
+			i++
+		case c == '*':
+			b.WriteString("[^/]*")
+		case c == '?':
+			b.WriteString("[^/]")
+		case c == '[':
+			end := strings.IndexByte(glob[i+1:], ']')
+			if end < 0 {
+				b.WriteString(`\[`)
+				continue
+			}
+			class := glob[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			b.WriteString("[" + strings.ReplaceAll(class, `\`, `\\`) + "]")
+			i += end + 1
+		case c == '\\' && i+1 < len(glob):
+			i++
+			b.WriteString(regexp.QuoteMeta(string(glob[i])))
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	b.WriteString("$")
+	return regexp.Compile(b.String())
+}
+
+// includeMatcher keeps the files matching any of a set of globs: globs
+// without a slash match the file name, others the path relative to the
+// directory being discovered
+type includeMatcher []ignoreRule
+
+func newIncludeMatcher(globs []string) (includeMatcher, error) {
+	var m includeMatcher
+	for _, glob := range globs {
+		rule := ignoreRule{anchored: strings.Contains(glob, "/")}
+		re, err := globRegexp(strings.TrimPrefix(glob, "/"))
+		if err != nil {
+			return nil, err
+		}
+		rule.re = re
+		m = append(m, rule)
+	}
+	return m, nil
+}
+
+func (m includeMatcher) match(root, path string) bool {
+	if len(m) == 0 {
+		return true
+	}
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		return false
+	}
+	rel = filepath.ToSlash(rel)
+	base := rel[strings.LastIndex(rel, "/")+1:]
+	for _, rule := range m {
+		subject := base
+		if rule.anchored {
+			subject = rel
+		}
+		if rule.re.MatchString(subject) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,190 @@
+package fileutil
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// writeTree creates files (with parent directories) under root
+func writeTree(t *testing.T, root string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// discovered returns the discovered files relative to root, sorted
+func discovered(t *testing.T, root string, dirs []string, opts DiscoverOptions) []string {
+	t.Helper()
+	files, err := DiscoverFiles(dirs, opts)
+	if err != nil {
+		t.Fatalf("DiscoverFiles: %v", err)
+	}
+	var rel []string
+	for _, f := range files {
+		r, err := filepath.Rel(root, f.Path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rel = append(rel, filepath.ToSlash(r))
+	}
+	sort.Strings(rel)
+	return rel
+}
+
+func TestDiscoverFilesHonoursIgnoreFiles(t *testing.T) {
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{
+		".git/HEAD":           "ref: refs/heads/main\n",
+		".gitignore":          "# build output\n*.log\nbuild/\n/top.txt\n!keep.log\n",
+		".ragujuaryignore":    "secrets/**\n",
+		"a.md":                "a",
+		"top.txt":             "anchored to the root",
+		"debug.log":           "ignored",
+		"keep.log":            "re-included",
+		"build/out.md":        "ignored directory",
+		"secrets/key.md":      "ignored by .ragujuaryignore",
+		"docs/top.txt":        "anchored pattern doesn't match here",
+		"docs/b.md":           "b",
+		"docs/.gitignore":     "*.tmp\n!important.log\ndrafts/\n",
+		"docs/x.tmp":          "ignored by the nested file",
+		"docs/important.log":  "re-included by the nested file",
+		"docs/drafts/d.md":    "ignored directory",
+		"docs/sub/build":      "a file, not a directory: build/ doesn't match",
+		"docs/sub/deep/c.log": "ignored",
+	})
+
+	got := discovered(t, root, []string{root}, DiscoverOptions{})
+	want := []string{
+		".gitignore",
+		".ragujuaryignore",
+		"a.md",
+		"docs/.gitignore",
+		"docs/b.md",
+		"docs/important.log",
+		"docs/sub/build",
+		"docs/top.txt",
+		"keep.log",
+	}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Fatalf("discovered files:\n got %v\nwant %v", got, want)
+	}
+}
+
+func TestDiscoverFilesUsesIgnoreFilesAboveTheWalkedDirectory(t *testing.T) {
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{
+		".git/HEAD":        "ref: refs/heads/main\n",
+		".gitignore":       "*.log\ndocs/private/\n",
+		"docs/a.md":        "a",
+		"docs/a.log":       "ignored by the repository root's .gitignore",
+		"docs/private/p":   "ignored",
+		"docs/public/p.md": "p",
+	})
+
+	got := discovered(t, root, []string{filepath.Join(root, "docs")}, DiscoverOptions{})
+	want := []string{"docs/a.md", "docs/public/p.md"}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Fatalf("discovered files:\n got %v\nwant %v", got, want)
+	}
+}
+
+func TestDiscoverFilesNoIgnore(t *testing.T) {
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{
+		".git/HEAD":  "ref: refs/heads/main\n",
+		".gitignore": "*.log\n",
+		"a.log":      "a",
+	})
+
+	got := discovered(t, root, []string{root}, DiscoverOptions{NoIgnore: true})
+	want := []string{".git/HEAD", ".gitignore", "a.log"}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Fatalf("discovered files:\n got %v\nwant %v", got, want)
+	}
+}
+
+func TestDiscoverFilesInclude(t *testing.T) {
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{
+		"README.md":         "r",
+		"main.go":           "m",
+		"docs/guide.md":     "g",
+		"docs/img/logo.png": "l",
+		"docs/api/ref.txt":  "t",
+		"notes/todo.txt":    "t",
+		"ignored.md":        "i",
+		".ragujuaryignore":  "ignored.md\n",
+	})
+
+	got := discovered(t, root, []string{root}, DiscoverOptions{Include: []string{"*.md", "docs/**/*.txt"}})
+	want := []string{"README.md", "docs/api/ref.txt", "docs/guide.md"}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Fatalf("discovered files:\n got %v\nwant %v", got, want)
+	}
+
+	// Exclude patterns still apply on top of include globs
+	got = discovered(t, root, []string{root}, DiscoverOptions{Include: []string{"*.md"}, Exclude: []string{"README"}})
+	want = []string{"docs/guide.md"}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Fatalf("discovered files with exclude:\n got %v\nwant %v", got, want)
+	}
+
+	if _, err := DiscoverFiles([]string{root}, DiscoverOptions{Include: []string{"[z-a].md"}}); err == nil {
+		t.Fatal("expected an error for an invalid include glob")
+	}
+}
+
+func TestDiscoverFilesTakesExplicitFiles(t *testing.T) {
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{
+		".gitignore": "*.log\n",
+		"a.log":      "named explicitly",
+	})
+
+	got := discovered(t, root, []string{filepath.Join(root, "a.log")}, DiscoverOptions{Include: []string{"*.md"}})
+	want := []string{"a.log"}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Fatalf("discovered files:\n got %v\nwant %v", got, want)
+	}
+}
+
+func TestGlobRegexp(t *testing.T) {
+	tests := []struct {
+		glob  string
+		path  string
+		match bool
+	}{
+		{"*.md", "a.md", true},
+		{"*.md", "dir/a.md", false},
+		{"a?c", "abc", true},
+		{"a?c", "a/c", false},
+		{"docs/**", "docs/a/b.md", true},
+		{"**/build", "x/y/build", true},
+		{"**/build", "build", true},
+		{"a/**/b", "a/b", true},
+		{"a/**/b", "a/x/y/b", true},
+		{"[abc].txt", "b.txt", true},
+		{"[!abc].txt", "b.txt", false},
+		{"\\*.txt", "*.txt", true},
+		{"\\*.txt", "a.txt", false},
+	}
+	for _, tt := range tests {
+		re, err := globRegexp(tt.glob)
+		if err != nil {
+			t.Fatalf("globRegexp(%q): %v", tt.glob, err)
+		}
+		if got := re.MatchString(tt.path); got != tt.match {
+			t.Errorf("glob %q on %q = %v, want %v", tt.glob, tt.path, got, tt.match)
+		}
+	}
+}
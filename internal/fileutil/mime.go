@@ -0,0 +1,226 @@
+package fileutil
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"path/filepath"
+	"strings"
+)
+
+// sniffLen is how much of a file is read to recognise its content
+const sniffLen = 8192
+
+// extensionMIMETypes maps lowercase file extensions to MIME types
+var extensionMIMETypes = map[string]string{
+	".txt":  "text/plain",
+	".md":   "text/markdown",
+	".html": "text/html",
+	".htm":  "text/html",
+	".css":  "text/css",
+	".js":   "text/javascript",
+	".ts":   "text/typescript",
+	".json": "application/json",
+	".xml":  "application/xml",
+	".yaml": "application/x-yaml",
+	".yml":  "application/x-yaml",
+	".go":   "text/x-go",
+	".py":   "text/x-python",
+	".java": "text/x-java",
+	".c":    "text/x-c",
+	".cpp":  "text/x-c++",
+	".h":    "text/x-c",
+	".hpp":  "text/x-c++",
+	".rs":   "text/x-rust",
+	".rb":   "text/x-ruby",
+	".php":  "text/x-php",
+	".sh":   "text/x-shellscript",
+	".pdf":  "application/pdf",
+	".doc":  "application/msword",
+	".docx": "application/vnd.openxmlformats-officedocument.wordprocessingml.document",
+	".pptx": "application/vnd.openxmlformats-officedocument.presentationml.presentation",
+	".xlsx": "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+	".csv":  "text/csv",
+	".epub": "application/epub+zip",
+	// Image types
+	".png":  "image/png",
+	".jpg":  "image/jpeg",
+	".jpeg": "image/jpeg",
+	".gif":  "image/gif",
+	".webp": "image/webp",
+	// Video types
+	".mp4":  "video/mp4",
+	".mpeg": "video/mpeg",
+	".mpg":  "video/mpeg",
+	// Audio types
+	".mp3": "audio/mp3",
+	".wav": "audio/wav",
+	".ogg": "audio/ogg",
+}
+
+// sniffedMIMETypes renames types reported by http.DetectContentType to the
+// names used in extensionMIMETypes
+var sniffedMIMETypes = map[string]string{
+	"audio/wave":      "audio/wav",
+	"audio/mpeg":      "audio/mp3",
+	"application/ogg": "audio/ogg",
+}
+
+// detectMimeType detects MIME type based on file extension
+func detectMimeType(path string) string {
+	if mime, ok := extensionMIMETypes[strings.ToLower(filepath.Ext(path))]; ok {
+		return mime
+	}
+	return "application/octet-stream"
+}
+
+// MIMETypeByExtension returns the MIME type of a file name's extension, or
+// application/octet-stream for extensions it doesn't know
+func MIMETypeByExtension(name string) string {
+	return detectMimeType(name)
+}
+
+// DetectFile determines a file's MIME type and whether its content is
+// binary. A type given for the file's extension in overrides wins; then a
+// recognised PDF, image, audio or video signature in the file's first bytes
+// (so a misnamed or extensionless media file is still embedded as such);
+// then the extension. Files of unknown type are text/plain, or
+// application/octet-stream when their content is binary.
+func DetectFile(path string, overrides map[string]string) (mimeType string, binary bool, err error) {
+	f, err := Open(path)
+	if err != nil {
+		return "", false, fmt.Errorf("failed to open file: %w", err)
+	}
+	defer f.Close()
+	head := make([]byte, sniffLen)
+	n, err := io.ReadFull(f, head)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return "", false, fmt.Errorf("failed to read file: %w", err)
+	}
+	mimeType, binary = detectContent(path, head[:n], overrides)
+	return mimeType, binary, nil
+}
+
+// detectContent is DetectFile on the first bytes of a file
+func detectContent(path string, head []byte, overrides map[string]string) (mimeType string, binary bool) {
+	binary = looksBinary(head)
+	if mime, ok := overrides[strings.ToLower(filepath.Ext(path))]; ok {
+		return mime, binary
+	}
+	byExt := detectMimeType(path)
+	known := byExt != "application/octet-stream"
+	if sniffed := sniffMedia(head); sniffed != "" && (!known || mediaClass(byExt) != mediaClass(sniffed)) {
+		return sniffed, binary
+	}
+	if known {
+		return byExt, binary
+	}
+	if binary {
+		return "application/octet-stream", true
+	}
+	return "text/plain", false
+}
+
+// sniffMedia returns the MIME type of PDF, image, audio and video content
+// recognised from its first bytes, or "" when it isn't one of those
+func sniffMedia(head []byte) string {
+	switch {
+	case len(head) >= 12 && bytes.Equal(head[4:8], []byte("ftyp")):
+		// ISO base media: the brand tells audio-only files from video
+		if brand := string(head[8:12]); brand == "M4A " || brand == "M4B " {
+			return "audio/mp4"
+		}
+		return "video/mp4"
+	case bytes.HasPrefix(head, []byte("ID3")):
+		return "audio/mp3"
+	case len(head) >= 2 && head[0] == 0xFF && (head[1] == 0xFB || head[1] == 0xF3 || head[1] == 0xF2):
+		// MPEG-1/2 layer III frame sync without an ID3 tag
+		return "audio/mp3"
+	}
+	mime := http.DetectContentType(head)
+	if i := strings.IndexByte(mime, ';'); i >= 0 {
+		mime = mime[:i]
+	}
+	if renamed, ok := sniffedMIMETypes[mime]; ok {
+		mime = renamed
+	}
+	if mediaClass(mime) == "" {
+		return ""
+	}
+	return mime
+}
+
+// mediaClass returns "pdf", "image", "audio" or "video" for media MIME
+// types, "" for anything else
+func mediaClass(mimeType string) string {
+	if ct := ClassifyContent(mimeType); IsMultimodal(ct) {
+		return ct
+	}
+	return ""
+}
+
+// looksBinary reports whether content is binary rather than text in some
+// encoding: it has a NUL byte, or many control characters, and is neither
+// marked nor recognised as UTF-16 (which is full of NULs)
+func looksBinary(head []byte) bool {
+	for _, bom := range [][]byte{{0xEF, 0xBB, 0xBF}, {0xFE, 0xFF}, {0xFF, 0xFE}} {
+		if bytes.HasPrefix(head, bom) {
+			return false
+		}
+	}
+	if utf16Order(head) != "" {
+		return false
+	}
+	if bytes.IndexByte(head, 0) >= 0 {
+		return true
+	}
+	control := 0
+	for _, b := range head {
+		if b < 0x20 && b != '\t' && b != '\n' && b != '\r' && b != '\f' && b != '\v' && b != 0x1b {
+			control++
+		}
+	}
+	return control*10 > len(head)
+}
+
+// ParseMIMETypes parses "EXT=TYPE" mappings (e.g. ".mdx=text/markdown" or
+// "log=text/plain") into an extension → MIME type map for DiscoverOptions
+func ParseMIMETypes(specs []string) (map[string]string, error) {
+	if len(specs) == 0 {
+		return nil, nil
+	}
+	types := make(map[string]string, len(specs))
+	for _, spec := range specs {
+		ext, mime, ok := strings.Cut(spec, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid MIME type mapping %q: expected EXT=TYPE", spec)
+		}
+		types[ext] = mime
+	}
+	return normalizeMIMETypes(types)
+}
+
+// normalizeMIMETypes lowercases extensions and gives them a leading dot, and
+// checks that every MIME type has the type/subtype form
+func normalizeMIMETypes(types map[string]string) (map[string]string, error) {
+	if len(types) == 0 {
+		return nil, nil
+	}
+	normalized := make(map[string]string, len(types))
+	for ext, mime := range types {
+		ext = strings.ToLower(strings.TrimSpace(ext))
+		mime = strings.ToLower(strings.TrimSpace(mime))
+		if ext == "" || ext == "." {
+			return nil, fmt.Errorf("invalid MIME type mapping %q=%q: empty extension", ext, mime)
+		}
+		if !strings.HasPrefix(ext, ".") {
+			ext = "." + ext
+		}
+		if typ, sub, ok := strings.Cut(mime, "/"); !ok || typ == "" || sub == "" || strings.Contains(sub, "/") {
+			return nil, fmt.Errorf("invalid MIME type %q for %s: expected type/subtype", mime, ext)
+		}
+		normalized[ext] = mime
+	}
+	return normalized, nil
+}
@@ -0,0 +1,82 @@
+package fileutil
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDetectFile(t *testing.T) {
+	dir := t.TempDir()
+	files := []struct {
+		name    string
+		content []byte
+		want    string
+		binary  bool
+	}{
+		{"notes.md", []byte("# Notes\n"), "text/markdown", false},
+		{"README", []byte("plain text without an extension\n"), "text/plain", false},
+		{"empty", nil, "text/plain", false},
+		{"program", []byte{0x7f, 'E', 'L', 'F', 2, 1, 1, 0}, "application/octet-stream", true},
+		{"data.bin", []byte{1, 2, 3, 4, 5, 6, 7, 8}, "application/octet-stream", true},
+		{"report", []byte("%PDF-1.7\n%\xe2\xe3\xcf\xd3\n"), "application/pdf", false},
+		{"image.txt", []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR"), "image/png", true},
+		{"photo.jpg", []byte("\xff\xd8\xff\xe0\x00\x10JFIF\x00"), "image/jpeg", true},
+		{"clip", []byte("\x00\x00\x00\x18ftypmp42\x00\x00\x00\x00"), "video/mp4", true},
+		{"voice", []byte("\x00\x00\x00\x18ftypM4A \x00\x00\x00\x00"), "audio/mp4", true},
+		{"take.dat", []byte("RIFF\x24\x00\x00\x00WAVEfmt "), "audio/wav", true},
+		{"song", []byte("ID3\x04\x00\x00\x00\x00\x00\x00"), "audio/mp3", true},
+		{"song.mp3", []byte("\xff\xfb\x90\x64"), "audio/mp3", false},
+		{"utf16.txt", []byte("\xff\xfeh\x00i\x00"), "text/plain", false},
+		{"utf16-nobom.txt", []byte("h\x00e\x00l\x00l\x00o\x00"), "text/plain", false},
+		{"old.doc", []byte("\xd0\xcf\x11\xe0\xa1\xb1\x1a\xe1\x00\x00"), "application/msword", true},
+	}
+	for _, f := range files {
+		path := filepath.Join(dir, f.name)
+		if err := os.WriteFile(path, f.content, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		mime, binary, err := DetectFile(path, nil)
+		if err != nil {
+			t.Fatalf("DetectFile(%s): %v", f.name, err)
+		}
+		if mime != f.want || binary != f.binary {
+			t.Errorf("DetectFile(%s) = %q, binary %v; want %q, binary %v", f.name, mime, binary, f.want, f.binary)
+		}
+	}
+
+	if _, _, err := DetectFile(filepath.Join(dir, "missing"), nil); err == nil {
+		t.Fatal("expected an error for a missing file")
+	}
+}
+
+func TestDetectFileOverrides(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "page.MDX")
+	if err := os.WriteFile(path, []byte("# Page\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	overrides, err := ParseMIMETypes([]string{"mdx=text/markdown", ".log=text/plain"})
+	if err != nil {
+		t.Fatalf("ParseMIMETypes: %v", err)
+	}
+	if mime, _, _ := DetectFile(path, overrides); mime != "text/markdown" {
+		t.Fatalf("DetectFile with override = %q, want text/markdown", mime)
+	}
+
+	files, err := DiscoverFiles([]string{dir}, DiscoverOptions{MIMETypes: map[string]string{".MDX": "Text/X-MDX"}})
+	if err != nil {
+		t.Fatalf("DiscoverFiles: %v", err)
+	}
+	if len(files) != 1 || files[0].MimeType != "text/x-mdx" {
+		t.Fatalf("DiscoverFiles = %+v, want page.MDX as text/x-mdx", files)
+	}
+}
+
+func TestParseMIMETypesRejectsInvalidMappings(t *testing.T) {
+	for _, spec := range []string{"mdx", "=text/plain", ".mdx=markdown", ".mdx=text/", ".mdx=a/b/c"} {
+		if _, err := ParseMIMETypes([]string{spec}); err == nil {
+			t.Errorf("ParseMIMETypes(%q) succeeded, want an error", spec)
+		}
+	}
+}
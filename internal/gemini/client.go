@@ -6,10 +6,11 @@ import (
 	"fmt"
 	"io"
 	"net/http"
-	"os"
 	"path/filepath"
 	"strings"
 	"time"
+
+	"github.com/takeshy/ragujuary/internal/fileutil"
 )
 
 const (
@@ -80,6 +81,7 @@ type UploadConfig struct {
 	DisplayName    string            `json:"displayName,omitempty"`
 	CustomMetadata []CustomMetadata  `json:"customMetadata,omitempty"`
 	ChunkingConfig *ChunkingConfig   `json:"chunkingConfig,omitempty"`
+	MimeType       string            `json:"-"` // detected content type; empty = by file extension
 }
 
 // CustomMetadata represents custom metadata for a document
@@ -316,13 +318,16 @@ func (c *Client) UploadToFileSearchStore(storeName string, filePath string, conf
 	}
 
 	// Get file info
-	fileInfo, err := os.Stat(filePath)
+	fileInfo, err := fileutil.Stat(filePath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to stat file: %w", err)
 	}
 
 	// Detect MIME type
 	mimeType := detectMimeType(filePath)
+	if config != nil && config.MimeType != "" {
+		mimeType = config.MimeType
+	}
 
 	// Step 1: Initiate resumable upload
 	initURL := fmt.Sprintf("%s/%s:uploadToFileSearchStore?key=%s", uploadBaseURL, storeName, c.apiKey)
@@ -373,7 +378,7 @@ func (c *Client) UploadToFileSearchStore(storeName string, filePath string, conf
 	}
 
 	// Step 2: Upload file content
-	file, err := os.Open(filePath)
+	file, err := fileutil.Open(filePath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open file: %w", err)
 	}
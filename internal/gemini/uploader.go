@@ -84,13 +84,24 @@ func (u *Uploader) uploadFile(file fileutil.FileInfo) UploadResult {
 		FileInfo: file,
 	}
 
-	// Calculate checksum
-	checksum, err := fileutil.CalculateChecksum(file.Path)
-	if err != nil {
-		result.Error = fmt.Errorf("failed to calculate checksum: %w", err)
+	// Binary content of no known type isn't a document File Search can index
+	if file.Binary && file.MimeType == "application/octet-stream" {
+		result.Skipped = true
+		result.Reason = "binary content of unknown type"
 		return result
 	}
-	file.Checksum = checksum
+
+	// Calculate checksum (archive entries come with theirs)
+	checksum := file.Checksum
+	if checksum == "" {
+		var err error
+		checksum, err = fileutil.CalculateChecksum(file.Path)
+		if err != nil {
+			result.Error = fmt.Errorf("failed to calculate checksum: %w", err)
+			return result
+		}
+		file.Checksum = checksum
+	}
 
 	// Check if file already exists with same checksum
 	existing, found := u.storeManager.GetFileByPath(u.storeName, file.Path)
@@ -112,6 +123,7 @@ func (u *Uploader) uploadFile(file fileutil.FileInfo) UploadResult {
 	// Create upload config with display name and checksum metadata
 	config := &UploadConfig{
 		DisplayName: file.Path,
+		MimeType:    file.MimeType,
 		CustomMetadata: []CustomMetadata{
 			{Key: "checksum", StringValue: &checksum},
 		},
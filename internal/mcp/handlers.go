@@ -11,9 +11,9 @@ import (
 	"time"
 
 	"github.com/modelcontextprotocol/go-sdk/mcp"
+	"github.com/takeshy/ragujuary/internal/docutil"
 	"github.com/takeshy/ragujuary/internal/fileutil"
 	"github.com/takeshy/ragujuary/internal/gemini"
-	"github.com/takeshy/ragujuary/internal/pdfutil"
 	"github.com/takeshy/ragujuary/internal/rag"
 	"github.com/takeshy/ragujuary/internal/store"
 )
@@ -134,7 +134,10 @@ func (s *Server) handleUpload(ctx context.Context, req *mcp.CallToolRequest, inp
 func (s *Server) handleUploadEmbed(ctx context.Context, storeName string, input UploadInput) (*mcp.CallToolResult, UploadOutput, error) {
 	output := UploadOutput{FileName: input.FileName}
 
-	config := rag.DefaultConfig()
+	engine, config, err := s.embedEngine(storeName)
+	if err != nil {
+		return nil, output, err
+	}
 	if input.ChunkSize > 0 {
 		config.ChunkSize = input.ChunkSize
 	}
@@ -150,6 +153,35 @@ func (s *Server) handleUploadEmbed(ctx context.Context, storeName string, input
 		}
 		config.PDFMaxPages = input.PDFMaxPages
 	}
+	config.PDFChapters = input.PDFChapters
+	config.DropHTMLBoilerplate = input.DropHTMLBoilerplate
+
+	// Document path: DOCX, PPTX, XLSX and EPUB are indexed as their text
+	if mimeType := input.MIMEType; input.IsBase64 {
+		if mimeType == "" {
+			mimeType = fileutil.MIMETypeByExtension(input.FileName)
+		}
+		if docutil.Supported(mimeType) {
+			data, err := base64.StdEncoding.DecodeString(input.FileContent)
+			if err != nil {
+				return nil, output, fmt.Errorf("failed to decode base64 content: %w", err)
+			}
+			if err := engine.IndexDocumentContent(storeName, input.FileName, data, mimeType, config); err != nil {
+				output.Error = err.Error()
+				return &mcp.CallToolResult{
+					Content: []mcp.Content{
+						&mcp.TextContent{Text: fmt.Sprintf("Indexing failed: %v", err)},
+					},
+				}, output, nil
+			}
+			output.Success = true
+			return &mcp.CallToolResult{
+				Content: []mcp.Content{
+					&mcp.TextContent{Text: fmt.Sprintf("Successfully indexed '%s' (%d chunks)", input.FileName, storedChunks(storeName, input.FileName))},
+				},
+			}, output, nil
+		}
+	}
 
 	// Multimodal path
 	if input.MIMEType != "" && input.IsBase64 {
@@ -163,7 +195,7 @@ func (s *Server) handleUploadEmbed(ctx context.Context, storeName string, input
 			return nil, output, fmt.Errorf("failed to decode base64 content: %w", err)
 		}
 
-		if err := s.ragEngine.IndexMultimodalContent(storeName, input.FileName, data, input.MIMEType, config); err != nil {
+		if err := engine.IndexMultimodalContent(storeName, input.FileName, data, input.MIMEType, config); err != nil {
 			output.Error = err.Error()
 			return &mcp.CallToolResult{
 				Content: []mcp.Content{
@@ -173,20 +205,8 @@ func (s *Server) handleUploadEmbed(ctx context.Context, storeName string, input
 		}
 
 		chunks := 1
-		if input.MIMEType == "application/pdf" {
-			if pageCount, err := pdfutil.PageCount(data); err == nil {
-				chunks = (pageCount + config.PDFMaxPages - 1) / config.PDFMaxPages
-			}
-		} else if idx, _, loadErr := rag.LoadIndex(storeName); loadErr == nil && idx != nil {
-			count := 0
-			for _, m := range idx.Meta {
-				if m.FilePath == input.FileName {
-					count++
-				}
-			}
-			if count > 0 {
-				chunks = count
-			}
+		if count := storedChunks(storeName, input.FileName); count > 0 {
+			chunks = count
 		}
 
 		output.Success = true
@@ -198,7 +218,7 @@ func (s *Server) handleUploadEmbed(ctx context.Context, storeName string, input
 	}
 
 	// Text path
-	if err := s.ragEngine.IndexContent(storeName, input.FileName, input.FileContent, config); err != nil {
+	if err := engine.IndexContent(storeName, input.FileName, input.FileContent, config); err != nil {
 		output.Error = err.Error()
 		return &mcp.CallToolResult{
 			Content: []mcp.Content{
@@ -217,6 +237,21 @@ func (s *Server) handleUploadEmbed(ctx context.Context, storeName string, input
 	}, output, nil
 }
 
+// storedChunks counts the chunks an embedding store holds for a file
+func storedChunks(storeName, fileName string) int {
+	idx, _, err := rag.LoadIndex(storeName)
+	if err != nil || idx == nil {
+		return 0
+	}
+	count := 0
+	for _, m := range idx.Meta {
+		if m.FilePath == fileName {
+			count++
+		}
+	}
+	return count
+}
+
 // handleQuery handles the query tool (auto-detects Embedding or FileSearch store)
 func (s *Server) handleQuery(ctx context.Context, req *mcp.CallToolRequest, input QueryInput) (*mcp.CallToolResult, QueryOutput, error) {
 	output := QueryOutput{}
@@ -312,7 +347,10 @@ func (s *Server) handleQuery(ctx context.Context, req *mcp.CallToolRequest, inpu
 func (s *Server) handleQueryEmbed(ctx context.Context, storeName string, input QueryInput) (*mcp.CallToolResult, QueryOutput, error) {
 	output := QueryOutput{}
 
-	config := rag.DefaultConfig()
+	engine, config, err := s.embedEngine(storeName)
+	if err != nil {
+		return nil, output, err
+	}
 	if input.TopK > 0 {
 		config.TopK = input.TopK
 	}
@@ -320,7 +358,7 @@ func (s *Server) handleQueryEmbed(ctx context.Context, storeName string, input Q
 		config.MinScore = input.MinScore
 	}
 
-	results, err := s.ragEngine.Query(input.Question, storeName, config)
+	results, err := engine.Query(input.Question, storeName, config)
 	if err != nil {
 		return nil, output, fmt.Errorf("query failed: %w", err)
 	}
@@ -340,7 +378,11 @@ func (s *Server) handleQueryEmbed(ctx context.Context, storeName string, input Q
 		if len(text) > 300 {
 			text = text[:300] + "..."
 		}
-		textBuilder.WriteString(fmt.Sprintf("[%.4f] %s: %s\n\n", r.Score, r.FilePath, text))
+		source := r.FilePath
+		if r.Representation != "" {
+			source += " (" + r.Representation + ")"
+		}
+		textBuilder.WriteString(fmt.Sprintf("[%.4f] %s: %s\n\n", r.Score, source, text))
 	}
 	output.Answer = textBuilder.String()
 
@@ -750,7 +792,12 @@ func (s *Server) handleUploadDirectory(ctx context.Context, req *mcp.CallToolReq
 
 	storeManager.GetOrCreateStore(localStoreName)
 
-	files, err := fileutil.DiscoverFiles(input.Directories, input.ExcludePatterns)
+	files, err := fileutil.DiscoverFiles(input.Directories, fileutil.DiscoverOptions{
+		Exclude:   input.ExcludePatterns,
+		Include:   input.IncludePatterns,
+		NoIgnore:  input.NoIgnore,
+		MIMETypes: input.MIMETypes,
+	})
 	if err != nil {
 		output.Error = err.Error()
 		return &mcp.CallToolResult{
@@ -821,7 +868,10 @@ func (s *Server) handleUploadDirectory(ctx context.Context, req *mcp.CallToolReq
 func (s *Server) handleUploadDirectoryEmbed(ctx context.Context, storeName string, input UploadDirectoryInput) (*mcp.CallToolResult, UploadDirectoryOutput, error) {
 	output := UploadDirectoryOutput{}
 
-	config := rag.DefaultConfig()
+	engine, config, err := s.embedEngine(storeName)
+	if err != nil {
+		return nil, output, err
+	}
 	if input.ChunkSize > 0 {
 		config.ChunkSize = input.ChunkSize
 	}
@@ -837,8 +887,16 @@ func (s *Server) handleUploadDirectoryEmbed(ctx context.Context, storeName strin
 		}
 		config.PDFMaxPages = input.PDFMaxPages
 	}
+	config.PDFChapters = input.PDFChapters
+	config.PDFMode = input.PDFMode
+	config.DropHTMLBoilerplate = input.DropHTMLBoilerplate
+	config.Include = input.IncludePatterns
+	config.NoIgnore = input.NoIgnore
+	config.MIMETypes = input.MIMETypes
+	config.Encoding = input.Encoding
+	config.CSVRows = input.CSVRows
 
-	result, err := s.ragEngine.Index(input.Directories, input.ExcludePatterns, storeName, config)
+	result, err := engine.Index(input.Directories, input.ExcludePatterns, storeName, config)
 	if err != nil {
 		output.Error = err.Error()
 		return &mcp.CallToolResult{
@@ -852,10 +910,18 @@ func (s *Server) handleUploadDirectoryEmbed(ctx context.Context, storeName strin
 	output.Uploaded = result.IndexedFiles
 	output.Skipped = result.SkippedFiles
 
+	text := fmt.Sprintf("Indexed %d files (%d chunks). New: %d, Updated: %d, Renamed: %d, Skipped: %d, Multimodal: %d",
+		result.IndexedFiles, result.TotalChunks, result.NewFiles, result.UpdatedFiles, result.RenamedFiles, result.SkippedFiles, result.MultimodalFiles)
+	if result.SkippedBinary > 0 {
+		text += fmt.Sprintf(", Skipped (binary): %d", result.SkippedBinary)
+	}
+	if result.FailedChunks > 0 {
+		text += fmt.Sprintf(", Failed chunks: %d", result.FailedChunks)
+	}
+
 	return &mcp.CallToolResult{
 		Content: []mcp.Content{
-			&mcp.TextContent{Text: fmt.Sprintf("Indexed %d files (%d chunks). New: %d, Updated: %d, Skipped: %d, Multimodal: %d",
-				result.IndexedFiles, result.TotalChunks, result.NewFiles, result.UpdatedFiles, result.SkippedFiles, result.MultimodalFiles)},
+			&mcp.TextContent{Text: text},
 		},
 	}, output, nil
 }
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+	"strings"
 	"sync"
 
 	"github.com/modelcontextprotocol/go-sdk/mcp"
@@ -16,10 +17,18 @@ import (
 // ServerConfig holds configuration for the MCP server
 type ServerConfig struct {
 	APIKey        string
-	EmbedURL      string   // Optional: OpenAI-compatible embedding URL (e.g. http://localhost:11434 for Ollama)
-	EmbedAPIKey   string   // Optional: API key for OpenAI-compatible embedding APIs
-	DataFile      string   // Optional: path to store data file (default: ~/.ragujuary.json)
-	AllowedStores []string // Optional: restrict to specific stores
+	EmbedURL      string                  // Optional: OpenAI-compatible embedding URL (e.g. http://localhost:11434 for Ollama)
+	EmbedAPIKey   string                  // Optional: API key for OpenAI-compatible embedding APIs
+	EmbedProvider string                  // Optional: embedding provider for new embedding stores (default: openai if EmbedURL is set, else gemini)
+	EmbedModel    string                  // Optional: embedding model for new embedding stores (default: the provider's; discovered for ollama and tei)
+	Azure         embedding.AzureOptions  // Optional: request settings of the azure provider
+	Ollama        embedding.OllamaOptions // Optional: request options of the ollama provider
+	OpenAI        embedding.OpenAIOptions // Optional: settings of the openai provider (image input)
+	DataFile      string                  // Optional: path to store data file (default: ~/.ragujuary.json)
+	AllowedStores []string                // Optional: restrict to specific stores
+	EmbedRetry    *embedding.RetryConfig  // Optional: retry/rate-limit settings for embedding API calls (nil = defaults)
+	// Optional: backends the configured embedding provider fails over to, in order
+	EmbedFallbacks []embedding.FallbackSpec
 }
 
 // Server wraps the MCP server with ragujuary-specific functionality
@@ -30,20 +39,66 @@ type Server struct {
 	storeManager *store.Manager
 	storeMu      sync.Mutex
 	config       ServerConfig
+
+	// Engines for embedding stores built with another provider than the
+	// configured one, by provider name
+	enginesMu sync.Mutex
+	engines   map[string]*rag.Engine
 }
 
 // NewServer creates a new MCP server for ragujuary
 func NewServer(config ServerConfig, version string) (*Server, error) {
-	// Initialize gemini client
-	geminiClient := gemini.NewClient(config.APIKey)
+	// Initialize gemini client (several comma-separated keys rotate for
+	// embeddings only)
+	geminiKey := ""
+	if keys := embedding.SplitAPIKeys(config.APIKey); len(keys) > 0 {
+		geminiKey = keys[0]
+	}
+	geminiClient := gemini.NewClient(geminiKey)
 
 	// Initialize embedding client and RAG engine
-	var embeddingClient embedding.Client
-	if config.EmbedURL != "" {
-		embeddingClient = embedding.NewOpenAIClient(config.EmbedURL, config.EmbedAPIKey)
-	} else {
-		embeddingClient = embedding.NewGeminiClient(config.APIKey)
+	provider, err := embedding.SelectProvider(config.EmbedProvider, "", config.EmbedURL)
+	if err != nil {
+		return nil, err
+	}
+	apiKey := config.EmbedAPIKey
+	if provider.Name == "gemini" {
+		apiKey = config.APIKey
+	}
+	if apiKey == "" {
+		apiKey = provider.EnvAPIKey()
 	}
+	embeddingClient, err := provider.NewClient(embedding.ProviderOptions{
+		URL:    config.EmbedURL,
+		APIKey: apiKey,
+		Azure:  config.Azure,
+		Ollama: config.Ollama,
+		OpenAI: config.OpenAI,
+		Retry:  config.EmbedRetry,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if config.EmbedModel == "" {
+		config.EmbedModel = provider.DefaultModel
+	}
+	if resolver, ok := embeddingClient.(embedding.ModelResolver); ok {
+		model, err := resolver.ResolveModel(config.EmbedModel)
+		if err != nil {
+			return nil, err
+		}
+		config.EmbedModel = model
+	}
+	embeddingClient, err = embedding.NewFallbackChain(embeddingClient, provider.Name, config.EmbedFallbacks, embedding.ProviderOptions{
+		Azure:  config.Azure,
+		Ollama: config.Ollama,
+		OpenAI: config.OpenAI,
+		Retry:  config.EmbedRetry,
+	})
+	if err != nil {
+		return nil, err
+	}
+	config.EmbedProvider = provider.Name
 	ragEngine := rag.NewEngine(embeddingClient)
 
 	// Create MCP server
@@ -65,11 +120,87 @@ func NewServer(config ServerConfig, version string) (*Server, error) {
 	return s, nil
 }
 
+// embedConfig returns the RAG settings new embedding stores start from
+func (s *Server) embedConfig() rag.Config {
+	config := rag.DefaultConfig()
+	if s.config.EmbedModel != "" {
+		config.Model = s.config.EmbedModel
+	}
+	if s.config.EmbedProvider != "" {
+		config.Provider = s.config.EmbedProvider
+		if provider, err := embedding.LookupProvider(s.config.EmbedProvider); err == nil {
+			config.Dimension = provider.DefaultDimension
+		}
+	}
+	return config
+}
+
+// embedEngine returns the engine and settings for an embedding store: those
+// of the provider, model and dimension an existing store was built with,
+// else the server's defaults
+func (s *Server) embedEngine(storeName string) (*rag.Engine, rag.Config, error) {
+	config := s.embedConfig()
+	index, _ := rag.LoadIndexMetadata(storeName)
+	if index == nil || len(index.Meta) == 0 {
+		return s.ragEngine, config, nil
+	}
+	if index.EmbeddingModel != "" {
+		config.Model = strings.TrimPrefix(index.EmbeddingModel, "models/")
+	}
+	if index.Dimension > 0 {
+		config.Dimension = index.Dimension
+	}
+	if index.Provider == "" || index.Provider == s.config.EmbedProvider {
+		return s.ragEngine, config, nil
+	}
+	config.Provider = index.Provider
+	engine, err := s.providerEngine(index.Provider)
+	if err != nil {
+		return nil, config, fmt.Errorf("store '%s' was built with embedding provider %s: %w", storeName, index.Provider, err)
+	}
+	return engine, config, nil
+}
+
+// providerEngine returns an engine for a provider other than the configured
+// one, using its default URL and the API key from its environment variables
+func (s *Server) providerEngine(name string) (*rag.Engine, error) {
+	s.enginesMu.Lock()
+	defer s.enginesMu.Unlock()
+	if engine, ok := s.engines[name]; ok {
+		return engine, nil
+	}
+
+	provider, err := embedding.LookupProvider(name)
+	if err != nil {
+		return nil, err
+	}
+	apiKey := provider.EnvAPIKey()
+	if name == "gemini" {
+		apiKey = s.config.APIKey
+	}
+	client, err := provider.NewClient(embedding.ProviderOptions{
+		APIKey: apiKey,
+		Azure:  s.config.Azure,
+		Ollama: s.config.Ollama,
+		OpenAI: s.config.OpenAI,
+		Retry:  s.config.EmbedRetry,
+	})
+	if err != nil {
+		return nil, err
+	}
+	engine := rag.NewEngine(client)
+	if s.engines == nil {
+		s.engines = make(map[string]*rag.Engine)
+	}
+	s.engines[name] = engine
+	return engine, nil
+}
+
 // registerTools registers all MCP tools
 func (s *Server) registerTools() {
 	mcp.AddTool(s.mcpServer, &mcp.Tool{
 		Name:        "upload",
-		Description: "Upload/index a file to a store. Auto-detects store type: embedding stores index content locally, FileSearch stores upload to Gemini cloud. For multimodal content (image/PDF/video/audio), set mime_type and is_base64=true.",
+		Description: "Upload/index a file to a store. Auto-detects store type: embedding stores index content locally, FileSearch stores upload to Gemini cloud. For multimodal content (image/PDF/video/audio), set mime_type and is_base64=true. Documents (DOCX/PPTX/XLSX/EPUB) sent with is_base64=true are indexed as their extracted text in embedding stores.",
 	}, s.handleUpload)
 
 	mcp.AddTool(s.mcpServer, &mcp.Tool{
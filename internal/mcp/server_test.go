@@ -1,6 +1,7 @@
 package mcp
 
 import (
+	"archive/zip"
 	"bytes"
 	"context"
 	"encoding/base64"
@@ -296,3 +297,120 @@ func TestHandleUploadEmbedReportsPDFChunkCountUsingConfiguredPageLimit(t *testin
 		t.Fatalf("handleUploadEmbed() message = %q, want configured chunk count", text.Text)
 	}
 }
+
+func TestEmbedEngineReusesStoreProvider(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("OLLAMA_HOST", "")
+
+	index := &rag.RagIndex{
+		Meta:           []rag.ChunkMeta{{FilePath: "a.txt", Text: "alpha"}},
+		Dimension:      2,
+		FileChecksums:  map[string]string{"a.txt": "x"},
+		EmbeddingModel: "nomic-embed-text",
+		Provider:       "ollama",
+	}
+	if err := rag.SaveIndex("ollama-store", index, []float32{1, 0}); err != nil {
+		t.Fatalf("SaveIndex() error = %v", err)
+	}
+
+	s := &Server{
+		config:    ServerConfig{APIKey: "test-key", EmbedProvider: "gemini", EmbedModel: "gemini-embedding-2-preview"},
+		ragEngine: rag.NewEngine(testEmbeddingClient{}),
+	}
+
+	engine, config, err := s.embedEngine("ollama-store")
+	if err != nil {
+		t.Fatalf("embedEngine() error = %v", err)
+	}
+	if engine == s.ragEngine {
+		t.Fatal("store built with ollama got the gemini engine")
+	}
+	if config.Provider != "ollama" || config.Model != "nomic-embed-text" || config.Dimension != 2 {
+		t.Fatalf("config = %+v, want the store's provider, model and dimension", config)
+	}
+	if again, _, _ := s.embedEngine("ollama-store"); again != engine {
+		t.Fatal("provider engine was not reused")
+	}
+
+	// New stores use the configured provider and its defaults
+	engine, config, err = s.embedEngine("new-store")
+	if err != nil {
+		t.Fatalf("embedEngine() error = %v", err)
+	}
+	if engine != s.ragEngine || config.Provider != "gemini" || config.Dimension != 768 {
+		t.Fatalf("new store: provider %q dimension %d", config.Provider, config.Dimension)
+	}
+}
+
+func TestEmbedToolsWithLocalProvider(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	s, err := NewServer(ServerConfig{EmbedProvider: "local"}, "test")
+	if err != nil {
+		t.Fatalf("NewServer() error = %v", err)
+	}
+	ctx := context.Background()
+
+	if _, out, err := s.handleCreateStore(ctx, nil, CreateStoreInput{StoreName: "local-store", Type: "embed"}); err != nil || !out.Success {
+		t.Fatalf("handleCreateStore() = %+v, %v", out, err)
+	}
+	for name, text := range map[string]string{
+		"go.md":     "Go is a statically typed, compiled programming language designed at Google.",
+		"python.md": "Python is an interpreted language with significant indentation.",
+	} {
+		if _, out, err := s.handleUpload(ctx, nil, UploadInput{StoreName: "local-store", FileName: name, FileContent: text}); err != nil || !out.Success {
+			t.Fatalf("handleUpload(%s) = %+v, %v", name, out, err)
+		}
+	}
+
+	_, out, err := s.handleQuery(ctx, nil, QueryInput{Question: "python indentation", StoreName: "local-store", TopK: 1, MinScore: 0.01})
+	if err != nil {
+		t.Fatalf("handleQuery() error = %v", err)
+	}
+	if !strings.Contains(out.Answer, "python.md") {
+		t.Fatalf("handleQuery() answer = %q, want python.md", out.Answer)
+	}
+
+	index, err := rag.LoadIndexMetadata("local-store")
+	if err != nil {
+		t.Fatalf("LoadIndexMetadata() error = %v", err)
+	}
+	if index.Provider != "local" || index.EmbeddingModel != embedding.LocalModel || index.Dimension != embedding.LocalDefaultDimension {
+		t.Fatalf("store provider %q model %q dimension %d", index.Provider, index.EmbeddingModel, index.Dimension)
+	}
+
+	// An e-book is indexed as its chapters, by its extension
+	var epub bytes.Buffer
+	zw := zip.NewWriter(&epub)
+	for name, content := range map[string]string{
+		"META-INF/container.xml": `<container><rootfiles><rootfile full-path="book.opf" media-type="application/oebps-package+xml"/></rootfiles></container>`,
+		"book.opf":               `<package><manifest><item id="c1" href="c1.xhtml" media-type="application/xhtml+xml"/></manifest><spine><itemref idref="c1"/></spine></package>`,
+		"c1.xhtml":               `<html><body><h1>Rust</h1><p>Rust guarantees memory safety without a garbage collector.</p></body></html>`,
+	} {
+		w, _ := zw.Create(name)
+		w.Write([]byte(content))
+	}
+	zw.Close()
+	upload := UploadInput{StoreName: "local-store", FileName: "rust.epub", FileContent: base64.StdEncoding.EncodeToString(epub.Bytes()), IsBase64: true}
+	if _, out, err := s.handleUpload(ctx, nil, upload); err != nil || !out.Success {
+		t.Fatalf("handleUpload(rust.epub) = %+v, %v", out, err)
+	}
+	stored, _, err := rag.LoadIndex("local-store")
+	if err != nil {
+		t.Fatalf("LoadIndex() error = %v", err)
+	}
+	found := false
+	for _, meta := range stored.Meta {
+		if meta.FilePath == "rust.epub" {
+			found = true
+			if meta.PageLabel != "chapter 1: Rust" || !strings.HasPrefix(meta.Text, "# Rust\n\nRust guarantees") {
+				t.Errorf("rust.epub chunk = %q (%q)", meta.Text, meta.PageLabel)
+			}
+		}
+	}
+	if !found {
+		t.Fatal("rust.epub has no chunks")
+	}
+}
@@ -2,15 +2,17 @@ package mcp
 
 // UploadInput represents input for the upload tool (works for both FileSearch and Embedding stores)
 type UploadInput struct {
-	StoreName    string `json:"store_name" jsonschema:"name of the store"`
-	FileName     string `json:"file_name" jsonschema:"file name or path for the uploaded file"`
-	FileContent  string `json:"file_content" jsonschema:"file content (base64 encoded for binary files, plain text for text files)"`
-	IsBase64     bool   `json:"is_base64,omitempty" jsonschema:"set to true if file_content is base64 encoded"`
-	MIMEType     string `json:"mime_type,omitempty" jsonschema:"MIME type for binary content (e.g. image/png, application/pdf) - embedding stores only"`
-	ChunkSize    int    `json:"chunk_size,omitempty" jsonschema:"chunk size in characters (default: 1000) - embedding stores only"`
-	ChunkOverlap int    `json:"chunk_overlap,omitempty" jsonschema:"chunk overlap in characters (default: 200) - embedding stores only"`
-	Dimension    int    `json:"dimension,omitempty" jsonschema:"embedding dimensionality (default: 768) - embedding stores only"`
-	PDFMaxPages  int    `json:"pdf_max_pages,omitempty" jsonschema:"max pages per PDF chunk (1-6, default: 6) - embedding stores only"`
+	StoreName           string `json:"store_name" jsonschema:"name of the store"`
+	FileName            string `json:"file_name" jsonschema:"file name or path for the uploaded file"`
+	FileContent         string `json:"file_content" jsonschema:"file content (base64 encoded for binary files, plain text for text files)"`
+	IsBase64            bool   `json:"is_base64,omitempty" jsonschema:"set to true if file_content is base64 encoded"`
+	MIMEType            string `json:"mime_type,omitempty" jsonschema:"MIME type for binary content (e.g. image/png, application/pdf, application/epub+zip; documents default to the type of the file name's extension) - embedding stores only"`
+	ChunkSize           int    `json:"chunk_size,omitempty" jsonschema:"chunk size in characters (default: 1000) - embedding stores only"`
+	ChunkOverlap        int    `json:"chunk_overlap,omitempty" jsonschema:"chunk overlap in characters (default: 200) - embedding stores only"`
+	Dimension           int    `json:"dimension,omitempty" jsonschema:"embedding dimensionality (default: 768) - embedding stores only"`
+	PDFMaxPages         int    `json:"pdf_max_pages,omitempty" jsonschema:"max pages per PDF chunk (1-6, default: 6) - embedding stores only"`
+	PDFChapters         bool   `json:"pdf_chapters,omitempty" jsonschema:"also start a new PDF chunk at every chapter of the PDF's outline - embedding stores only"`
+	DropHTMLBoilerplate bool   `json:"drop_html_boilerplate,omitempty" jsonschema:"leave nav, header, footer and aside content out of HTML files - embedding stores only"`
 }
 
 // UploadOutput represents output from the upload tool
@@ -123,14 +125,22 @@ type StoreInfo struct {
 
 // UploadDirectoryInput represents input for the upload_directory tool
 type UploadDirectoryInput struct {
-	StoreName       string   `json:"store_name" jsonschema:"name of the store"`
-	Directories     []string `json:"directories" jsonschema:"list of directory paths to upload/index"`
-	ExcludePatterns []string `json:"exclude_patterns,omitempty" jsonschema:"regex patterns to exclude files"`
-	Parallelism     int      `json:"parallelism,omitempty" jsonschema:"number of parallel uploads (default: 5) - FileSearch only"`
-	ChunkSize       int      `json:"chunk_size,omitempty" jsonschema:"chunk size in characters (default: 1000) - embedding stores only"`
-	ChunkOverlap    int      `json:"chunk_overlap,omitempty" jsonschema:"chunk overlap in characters (default: 200) - embedding stores only"`
-	Dimension       int      `json:"dimension,omitempty" jsonschema:"embedding dimensionality (default: 768) - embedding stores only"`
-	PDFMaxPages     int      `json:"pdf_max_pages,omitempty" jsonschema:"max pages per PDF chunk (1-6, default: 6) - embedding stores only"`
+	StoreName           string            `json:"store_name" jsonschema:"name of the store"`
+	Directories         []string          `json:"directories" jsonschema:"list of directory paths to upload/index"`
+	ExcludePatterns     []string          `json:"exclude_patterns,omitempty" jsonschema:"regex patterns to exclude files"`
+	IncludePatterns     []string          `json:"include_patterns,omitempty" jsonschema:"gitignore-style globs of the files to take (e.g. *.md, docs/**); default: all files"`
+	NoIgnore            bool              `json:"no_ignore,omitempty" jsonschema:"also take files ignored by .gitignore and .ragujuaryignore"`
+	DropHTMLBoilerplate bool              `json:"drop_html_boilerplate,omitempty" jsonschema:"leave nav, header, footer and aside content out of HTML files - embedding stores only"`
+	MIMETypes           map[string]string `json:"mime_types,omitempty" jsonschema:"MIME types to use for file extensions instead of the detected ones, e.g. {\".mdx\": \"text/markdown\"}"`
+	Encoding            string            `json:"encoding,omitempty" jsonschema:"character encoding of text files, e.g. shift_jis or euc-jp (default: auto - detected) - embedding stores only"`
+	Parallelism         int               `json:"parallelism,omitempty" jsonschema:"number of parallel uploads (default: 5) - FileSearch only"`
+	ChunkSize           int               `json:"chunk_size,omitempty" jsonschema:"chunk size in characters (default: 1000) - embedding stores only"`
+	ChunkOverlap        int               `json:"chunk_overlap,omitempty" jsonschema:"chunk overlap in characters (default: 200) - embedding stores only"`
+	CSVRows             int               `json:"csv_rows,omitempty" jsonschema:"max CSV rows per chunk, each repeating the header row (default: 50) - embedding stores only"`
+	Dimension           int               `json:"dimension,omitempty" jsonschema:"embedding dimensionality (default: 768) - embedding stores only"`
+	PDFMaxPages         int               `json:"pdf_max_pages,omitempty" jsonschema:"max pages per PDF chunk (1-6, default: 6) - embedding stores only"`
+	PDFChapters         bool              `json:"pdf_chapters,omitempty" jsonschema:"also start a new PDF chunk at every chapter of the PDF's outline - embedding stores only"`
+	PDFMode             string            `json:"pdf_mode,omitempty" jsonschema:"how PDFs are embedded: auto (default), visual (page images), text (extracted text chunks) or both - embedding stores only"`
 }
 
 // UploadDirectoryOutput represents output from the upload_directory tool
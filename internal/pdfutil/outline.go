@@ -0,0 +1,112 @@
+package pdfutil
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+)
+
+// OutlineEntry is a bookmark of a PDF's outline
+type OutlineEntry struct {
+	Path []string // titles of the entry's ancestors and the entry, outermost first
+	Page int      // 1-based page the entry points to
+}
+
+// Metadata is the document information and outline of a PDF
+type Metadata struct {
+	Title   string         // from the document information dictionary
+	Outline []OutlineEntry // bookmarks in document order
+}
+
+// ReadMetadata reads a PDF's title and outline (bookmarks). A PDF without
+// them gives empty Metadata.
+func ReadMetadata(data []byte) (*Metadata, error) {
+	conf := model.NewDefaultConfiguration()
+	conf.ValidationMode = model.ValidationRelaxed
+	conf.Cmd = model.LISTBOOKMARKS
+	ctx, err := api.ReadValidateAndOptimize(bytes.NewReader(data), conf)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read PDF: %w", err)
+	}
+	bookmarks, err := pdfcpu.Bookmarks(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read PDF outline: %w", err)
+	}
+
+	meta := &Metadata{Title: strings.TrimSpace(ctx.Title)}
+	var walk func([]pdfcpu.Bookmark, []string)
+	walk = func(bookmarks []pdfcpu.Bookmark, parents []string) {
+		for _, bm := range bookmarks {
+			title := strings.Join(strings.Fields(bm.Title), " ")
+			if title == "" {
+				continue
+			}
+			path := append(append([]string(nil), parents...), title)
+			if bm.PageFrom > 0 {
+				meta.Outline = append(meta.Outline, OutlineEntry{Path: path, Page: bm.PageFrom})
+			}
+			walk(bm.Kids, path)
+		}
+	}
+	walk(bookmarks, nil)
+	return meta, nil
+}
+
+// Sections returns the outline sections a page range lies in: the section
+// its first page is in, then those starting on its later pages. Each is
+// the titles of the section's path joined by " > ", e.g.
+// "2 Installation > 2.1 Linux".
+func (m *Metadata) Sections(startPage, endPage int) []string {
+	if m == nil {
+		return nil
+	}
+	// Entries by page; a later entry on the same page (a subsection) wins
+	entries := append([]OutlineEntry(nil), m.Outline...)
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].Page < entries[j].Page })
+
+	var sections []string
+	add := func(e OutlineEntry) {
+		s := strings.Join(e.Path, " > ")
+		if len(sections) == 0 || sections[len(sections)-1] != s {
+			sections = append(sections, s)
+		}
+	}
+	enclosing := -1
+	for i, e := range entries {
+		if e.Page <= startPage {
+			enclosing = i
+		}
+	}
+	if enclosing >= 0 {
+		add(entries[enclosing])
+	}
+	for _, e := range entries {
+		if e.Page > startPage && e.Page <= endPage {
+			add(e)
+		}
+	}
+	return sections
+}
+
+// ChapterStarts returns the pages after the first that top-level outline
+// entries point to, in order
+func (m *Metadata) ChapterStarts() []int {
+	if m == nil {
+		return nil
+	}
+	seen := make(map[int]bool)
+	var pages []int
+	for _, e := range m.Outline {
+		if len(e.Path) == 1 && e.Page > 1 && !seen[e.Page] {
+			seen[e.Page] = true
+			pages = append(pages, e.Page)
+		}
+	}
+	sort.Ints(pages)
+	return pages
+}
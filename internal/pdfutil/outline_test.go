@@ -0,0 +1,71 @@
+package pdfutil
+
+import (
+	"reflect"
+	"testing"
+)
+
+// testOutline is a nested outline over 25 pages; the last two top-level
+// entries are out of page order
+var testOutline = &Metadata{Outline: []OutlineEntry{
+	{Path: []string{"Introduction"}, Page: 1},
+	{Path: []string{"Setup"}, Page: 3},
+	{Path: []string{"Setup", "Linux"}, Page: 4},
+	{Path: []string{"Setup", "Linux", "Debian"}, Page: 4},
+	{Path: []string{"Usage"}, Page: 6},
+	{Path: []string{"Appendix"}, Page: 20},
+	{Path: []string{"Index"}, Page: 20},
+	{Path: []string{"Glossary"}, Page: 15},
+}}
+
+func TestSections(t *testing.T) {
+	tests := []struct {
+		name       string
+		meta       *Metadata
+		start, end int
+		want       []string
+	}{
+		{"first chapter", testOutline, 1, 2, []string{"Introduction"}},
+		{"into nested sections", testOutline, 2, 4, []string{"Introduction", "Setup", "Setup > Linux", "Setup > Linux > Debian"}},
+		{"deepest section of the first page", testOutline, 4, 5, []string{"Setup > Linux > Debian"}},
+		{"across chapters", testOutline, 5, 7, []string{"Setup > Linux > Debian", "Usage"}},
+		{"outline out of page order", testOutline, 14, 16, []string{"Usage", "Glossary"}},
+		{"same page entries", testOutline, 20, 20, []string{"Index"}},
+		{"past the last page", testOutline, 30, 40, []string{"Index"}},
+		{"before the first page", testOutline, 0, 0, nil},
+		{"starting before the first page", testOutline, -3, 2, []string{"Introduction"}},
+		{"outline starting late", &Metadata{Outline: []OutlineEntry{{Path: []string{"Body"}, Page: 3}}}, 1, 2, nil},
+		{"no outline", &Metadata{Title: "Manual"}, 1, 5, nil},
+		{"no metadata", nil, 1, 5, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.meta.Sections(tt.start, tt.end); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Sections(%d, %d) = %q, want %q", tt.start, tt.end, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestChapterStarts(t *testing.T) {
+	tests := []struct {
+		name string
+		meta *Metadata
+		want []int
+	}{
+		{"top-level entries after the first page", testOutline, []int{3, 6, 15, 20}},
+		{"only nested entries", &Metadata{Outline: []OutlineEntry{
+			{Path: []string{"Guide"}, Page: 1},
+			{Path: []string{"Guide", "Part"}, Page: 5},
+		}}, nil},
+		{"no outline", &Metadata{}, nil},
+		{"no metadata", nil, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.meta.ChapterStarts(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ChapterStarts() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -32,6 +32,13 @@ func PageCount(data []byte) (int, error) {
 // If maxPages <= 0, DefaultMaxPages (6) is used.
 // If the PDF has <= maxPages pages, returns a single chunk with the original data.
 func SplitPages(data []byte, maxPages int) ([]PDFChunk, error) {
+	return SplitPagesAt(data, maxPages, nil)
+}
+
+// SplitPagesAt is SplitPages that also starts a new chunk at each of the
+// given pages, in ascending order (e.g. Metadata.ChapterStarts), so no
+// chunk spans one of them
+func SplitPagesAt(data []byte, maxPages int, breaks []int) ([]PDFChunk, error) {
 	if maxPages <= 0 {
 		maxPages = DefaultMaxPages
 	}
@@ -41,43 +48,52 @@ func SplitPages(data []byte, maxPages int) ([]PDFChunk, error) {
 		return nil, fmt.Errorf("failed to get page count: %w", err)
 	}
 
-	if totalPages <= maxPages {
-		return []PDFChunk{{
-			Data:       data,
-			StartPage:  1,
-			EndPage:    totalPages,
-			TotalPages: totalPages,
-		}}, nil
-	}
-
 	var chunks []PDFChunk
-	for start := 1; start <= totalPages; start += maxPages {
+	for start := 1; start <= totalPages; {
 		end := start + maxPages - 1
 		if end > totalPages {
 			end = totalPages
 		}
-
-		var buf bytes.Buffer
-		rs := bytes.NewReader(data)
-		conf := model.NewDefaultConfiguration()
-		conf.ValidationMode = model.ValidationRelaxed
-		pageSelection := fmt.Sprintf("%d-%d", start, end)
-
-		if err := api.Collect(rs, &buf, []string{pageSelection}, conf); err != nil {
-			return nil, fmt.Errorf("failed to extract pages %s: %w", pageSelection, err)
+		for _, b := range breaks {
+			if b > start && b <= end {
+				end = b - 1
+				break
+			}
 		}
 
+		chunkData := data
+		if start != 1 || end != totalPages {
+			if chunkData, err = ExtractPages(data, start, end); err != nil {
+				return nil, err
+			}
+		}
 		chunks = append(chunks, PDFChunk{
-			Data:       buf.Bytes(),
+			Data:       chunkData,
 			StartPage:  start,
 			EndPage:    end,
 			TotalPages: totalPages,
 		})
+		start = end + 1
 	}
 
 	return chunks, nil
 }
 
+// ExtractPages returns a PDF of the pages startPage to endPage (1-based,
+// inclusive) of a PDF
+func ExtractPages(data []byte, startPage, endPage int) ([]byte, error) {
+	var buf bytes.Buffer
+	rs := bytes.NewReader(data)
+	conf := model.NewDefaultConfiguration()
+	conf.ValidationMode = model.ValidationRelaxed
+	pageSelection := fmt.Sprintf("%d-%d", startPage, endPage)
+
+	if err := api.Collect(rs, &buf, []string{pageSelection}, conf); err != nil {
+		return nil, fmt.Errorf("failed to extract pages %s: %w", pageSelection, err)
+	}
+	return buf.Bytes(), nil
+}
+
 // ExtractText extracts plain text from a PDF, returning text per page.
 func ExtractText(data []byte) ([]string, error) {
 	r, err := pdf.NewReader(bytes.NewReader(data), int64(len(data)))
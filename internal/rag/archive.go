@@ -0,0 +1,362 @@
+package rag
+
+import (
+	"archive/tar"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+const (
+	manifestFileName     = "manifest.json"
+	configFileName       = "config.json"
+	archiveFormatVersion = 1
+	maxArchiveEntrySize  = 4 << 30 // 4 GiB
+)
+
+// ArchiveManifest describes the contents of an exported store archive
+type ArchiveManifest struct {
+	ArchiveVersion int               `json:"archive_version"`
+	StoreName      string            `json:"store_name"`
+	EmbeddingModel string            `json:"embedding_model"`
+	Dimension      int               `json:"dimension"`
+	Chunks         int               `json:"chunks"`
+	Files          int               `json:"files"`
+	CreatedAt      string            `json:"created_at"`
+	Checksums      map[string]string `json:"checksums"` // archive entry name -> sha256
+}
+
+// ArchiveConfig holds the indexing parameters the store was built with,
+// so that the importing side can reproduce them on incremental re-index.
+// Import rejects an archive whose config.json disagrees with its index.
+type ArchiveConfig struct {
+	EmbeddingModel string `json:"embedding_model"`
+	Dimension      int    `json:"dimension"`
+	ChunkSize      int    `json:"chunk_size"`
+	ChunkOverlap   int    `json:"chunk_overlap"`
+	PDFMaxPages    int    `json:"pdf_max_pages"`
+	PDFChapters    bool   `json:"pdf_chapters,omitempty"`
+}
+
+// PathRewrite replaces a leading path prefix in FilePath entries on import
+type PathRewrite struct {
+	From string
+	To   string
+}
+
+// ImportOptions controls how an archive is imported
+type ImportOptions struct {
+	Rewrites  []PathRewrite
+	Overwrite bool
+}
+
+// ParsePathRewrite parses an "old=new" prefix rewrite specification
+func ParsePathRewrite(spec string) (PathRewrite, error) {
+	from, to, ok := strings.Cut(spec, "=")
+	if !ok || from == "" || to == "" {
+		return PathRewrite{}, fmt.Errorf("invalid path rewrite %q (expected OLD=NEW)", spec)
+	}
+	return PathRewrite{From: from, To: to}, nil
+}
+
+// apply rewrites path if it starts with the From prefix on a path boundary
+func (r PathRewrite) apply(path string) (string, bool) {
+	from := strings.TrimRight(r.From, "/\\")
+	if path == from {
+		return strings.TrimRight(r.To, "/\\"), true
+	}
+	if !strings.HasPrefix(path, from) {
+		return path, false
+	}
+	rest := path[len(from):]
+	if rest[0] != '/' && rest[0] != '\\' {
+		return path, false
+	}
+	return strings.TrimRight(r.To, "/\\") + rest, true
+}
+
+// rewritePath applies the first matching rewrite to path
+func rewritePath(path string, rewrites []PathRewrite) string {
+	for _, r := range rewrites {
+		if rewritten, ok := r.apply(path); ok {
+			return rewritten
+		}
+	}
+	return path
+}
+
+// ExportStore writes a zstd-compressed tar archive of an embedding store to w
+func ExportStore(storeName string, w io.Writer) (*ArchiveManifest, error) {
+	dir, err := storeDir(storeName)
+	if err != nil {
+		return nil, err
+	}
+
+	index, _, err := LoadIndexFromDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load store: %w", err)
+	}
+	if index == nil {
+		return nil, fmt.Errorf("store '%s' not found", storeName)
+	}
+
+	indexData, err := os.ReadFile(filepath.Join(dir, indexFileName))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read index: %w", err)
+	}
+	vectorData, err := os.ReadFile(filepath.Join(dir, vectorsFileName))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read vectors: %w", err)
+	}
+	configData, err := json.MarshalIndent(archiveConfigOf(index), "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal config: %w", err)
+	}
+
+	entries := []struct {
+		name string
+		data []byte
+	}{
+		{indexFileName, indexData},
+		{vectorsFileName, vectorData},
+		{configFileName, configData},
+	}
+
+	manifest := &ArchiveManifest{
+		ArchiveVersion: archiveFormatVersion,
+		StoreName:      storeName,
+		EmbeddingModel: index.EmbeddingModel,
+		Dimension:      index.Dimension,
+		Chunks:         len(index.Meta),
+		Files:          len(index.FileChecksums),
+		CreatedAt:      time.Now().UTC().Format(time.RFC3339),
+		Checksums:      make(map[string]string, len(entries)),
+	}
+	for _, e := range entries {
+		manifest.Checksums[e.name] = sha256Hex(e.data)
+	}
+	manifestData, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal manifest: %w", err)
+	}
+
+	zw, err := zstd.NewWriter(w)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create zstd writer: %w", err)
+	}
+	tw := tar.NewWriter(zw)
+
+	// Manifest goes first so readers can inspect it without unpacking everything
+	if err := writeTarEntry(tw, manifestFileName, manifestData); err != nil {
+		return nil, err
+	}
+	for _, e := range entries {
+		if err := writeTarEntry(tw, e.name, e.data); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return nil, fmt.Errorf("failed to finalize archive: %w", err)
+	}
+	if err := zw.Close(); err != nil {
+		return nil, fmt.Errorf("failed to finalize compression: %w", err)
+	}
+
+	return manifest, nil
+}
+
+// ImportStore reads an archive produced by ExportStore and installs it as storeName.
+// If storeName is empty, the store name recorded in the manifest is used.
+func ImportStore(r io.Reader, storeName string, opts ImportOptions) (*ArchiveManifest, error) {
+	zr, err := zstd.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open zstd stream: %w", err)
+	}
+	defer zr.Close()
+
+	files := make(map[string][]byte)
+	tr := tar.NewReader(zr)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read archive: %w", err)
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		switch hdr.Name {
+		case manifestFileName, indexFileName, vectorsFileName, configFileName:
+		default:
+			return nil, fmt.Errorf("unexpected archive entry %q", hdr.Name)
+		}
+		if hdr.Size > maxArchiveEntrySize {
+			return nil, fmt.Errorf("archive entry %q is too large (%d bytes)", hdr.Name, hdr.Size)
+		}
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read archive entry %q: %w", hdr.Name, err)
+		}
+		files[hdr.Name] = data
+	}
+
+	manifestData, ok := files[manifestFileName]
+	if !ok {
+		return nil, fmt.Errorf("archive is missing %s", manifestFileName)
+	}
+	var manifest ArchiveManifest
+	if err := json.Unmarshal(manifestData, &manifest); err != nil {
+		return nil, fmt.Errorf("failed to parse manifest: %w", err)
+	}
+	if manifest.ArchiveVersion > archiveFormatVersion {
+		return nil, fmt.Errorf("unsupported archive version %d (max supported: %d)", manifest.ArchiveVersion, archiveFormatVersion)
+	}
+
+	for name, want := range manifest.Checksums {
+		data, ok := files[name]
+		if !ok {
+			return nil, fmt.Errorf("archive is missing %s", name)
+		}
+		if got := sha256Hex(data); got != want {
+			return nil, fmt.Errorf("checksum mismatch for %s: got %s, want %s", name, got, want)
+		}
+	}
+	for _, name := range []string{indexFileName, vectorsFileName} {
+		if _, ok := manifest.Checksums[name]; !ok {
+			return nil, fmt.Errorf("manifest has no checksum for %s", name)
+		}
+	}
+
+	index, err := unmarshalIndex(files[indexFileName])
+	if err != nil {
+		return nil, err
+	}
+	if index.FormatVersion > formatVersion {
+		return nil, fmt.Errorf("incompatible index format version %d (max supported: %d)", index.FormatVersion, formatVersion)
+	}
+	vectors, err := decodeVectors(files[vectorsFileName])
+	if err != nil {
+		return nil, err
+	}
+	if expected := len(index.Meta) * index.Dimension; len(vectors) != expected {
+		return nil, fmt.Errorf("vectors/index mismatch: got %d floats, expected %d (%d chunks × %d dimensions)",
+			len(vectors), expected, len(index.Meta), index.Dimension)
+	}
+	if manifest.EmbeddingModel != index.EmbeddingModel || manifest.Dimension != index.Dimension {
+		return nil, fmt.Errorf("manifest (model %s, dimension %d) does not match index (model %s, dimension %d)",
+			manifest.EmbeddingModel, manifest.Dimension, index.EmbeddingModel, index.Dimension)
+	}
+
+	if data, ok := files[configFileName]; ok {
+		var config ArchiveConfig
+		if err := json.Unmarshal(data, &config); err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", configFileName, err)
+		}
+		if recorded := archiveConfigOf(index); config != recorded {
+			return nil, fmt.Errorf("%s (%s) does not match index (%s)", configFileName, config, recorded)
+		}
+	}
+
+	if len(opts.Rewrites) > 0 {
+		for i := range index.Meta {
+			index.Meta[i].FilePath = rewritePath(index.Meta[i].FilePath, opts.Rewrites)
+		}
+		checksums := make(map[string]string, len(index.FileChecksums))
+		for path, checksum := range index.FileChecksums {
+			checksums[rewritePath(path, opts.Rewrites)] = checksum
+		}
+		index.FileChecksums = checksums
+		for name, dir := range index.Roots {
+			index.Roots[name] = rewritePath(dir, opts.Rewrites)
+		}
+	}
+
+	if storeName == "" {
+		storeName = manifest.StoreName
+	}
+	if storeName == "" {
+		return nil, fmt.Errorf("store name is required (archive manifest has none)")
+	}
+
+	dir, err := storeDir(storeName)
+	if err != nil {
+		return nil, err
+	}
+	lock, err := lockStore(dir, "embed import")
+	if err != nil {
+		return nil, err
+	}
+	defer lock.unlock()
+	if _, err := os.Stat(filepath.Join(dir, indexFileName)); err == nil {
+		if !opts.Overwrite {
+			return nil, fmt.Errorf("store '%s' already exists (use overwrite to replace it)", storeName)
+		}
+	} else if entries, _ := os.ReadDir(dir); len(entries) > 0 {
+		return nil, fmt.Errorf("refusing to replace %s: it is not an embedding store", dir)
+	}
+
+	// Stage into a sibling directory and swap so a failed import leaves the old store intact
+	stagingDir := filepath.Clean(dir) + ".importing"
+	if err := os.RemoveAll(stagingDir); err != nil {
+		return nil, fmt.Errorf("failed to clean staging directory: %w", err)
+	}
+	if err := saveIndexToDir(stagingDir, index, vectors); err != nil {
+		os.RemoveAll(stagingDir)
+		return nil, err
+	}
+	if err := swapStoreDir(dir, stagingDir); err != nil {
+		return nil, err
+	}
+
+	manifest.StoreName = storeName
+	return &manifest, nil
+}
+
+// archiveConfigOf returns the indexing parameters of index as exported
+func archiveConfigOf(index *RagIndex) ArchiveConfig {
+	return ArchiveConfig{
+		EmbeddingModel: index.EmbeddingModel,
+		Dimension:      index.Dimension,
+		ChunkSize:      index.EffectiveChunkSize(),
+		ChunkOverlap:   index.EffectiveChunkOverlap(),
+		PDFMaxPages:    index.EffectivePDFMaxPages(),
+		PDFChapters:    index.PDFChapters,
+	}
+}
+
+func (c ArchiveConfig) String() string {
+	return fmt.Sprintf("model %s, dimension %d, chunk size %d, overlap %d, PDF max pages %d, PDF chapters %t",
+		c.EmbeddingModel, c.Dimension, c.ChunkSize, c.ChunkOverlap, c.PDFMaxPages, c.PDFChapters)
+}
+
+func writeTarEntry(tw *tar.Writer, name string, data []byte) error {
+	hdr := &tar.Header{
+		Name:    name,
+		Mode:    0644,
+		Size:    int64(len(data)),
+		ModTime: time.Now(),
+	}
+	if err := tw.WriteHeader(hdr); err != nil {
+		return fmt.Errorf("failed to write archive header for %s: %w", name, err)
+	}
+	if _, err := tw.Write(data); err != nil {
+		return fmt.Errorf("failed to write archive entry %s: %w", name, err)
+	}
+	return nil
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
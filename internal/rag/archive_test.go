@@ -0,0 +1,292 @@
+package rag
+
+import (
+	"archive/tar"
+	"bytes"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+func TestExportImportRoundTripWithPathRewrite(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	index := &RagIndex{
+		Meta: []ChunkMeta{
+			{FilePath: "/ci/build/repo/docs/a.md", Text: "alpha"},
+			{FilePath: "/ci/build/repo-other/b.md", Text: "beta"},
+		},
+		Dimension: 2,
+		FileChecksums: map[string]string{
+			"/ci/build/repo/docs/a.md":  "sum-a",
+			"/ci/build/repo-other/b.md": "sum-b",
+		},
+		EmbeddingModel: "test-model",
+		ChunkSize:      500,
+		ChunkOverlap:   50,
+	}
+	if err := SaveIndex("src", index, []float32{1, 0, 0, 1}); err != nil {
+		t.Fatalf("SaveIndex() error = %v", err)
+	}
+
+	var buf bytes.Buffer
+	manifest, err := ExportStore("src", &buf)
+	if err != nil {
+		t.Fatalf("ExportStore() error = %v", err)
+	}
+	if manifest.Chunks != 2 || manifest.Dimension != 2 || manifest.EmbeddingModel != "test-model" {
+		t.Fatalf("unexpected manifest: %+v", manifest)
+	}
+
+	rw, err := ParsePathRewrite("/ci/build/repo=/home/dev/repo")
+	if err != nil {
+		t.Fatalf("ParsePathRewrite() error = %v", err)
+	}
+	archive := buf.Bytes()
+	imported, err := ImportStore(bytes.NewReader(archive), "dst", ImportOptions{Rewrites: []PathRewrite{rw}})
+	if err != nil {
+		t.Fatalf("ImportStore() error = %v", err)
+	}
+	if imported.StoreName != "dst" {
+		t.Fatalf("imported store name = %q, want dst", imported.StoreName)
+	}
+
+	got, vectors, err := LoadIndex("dst")
+	if err != nil {
+		t.Fatalf("LoadIndex() error = %v", err)
+	}
+	if got.Meta[0].FilePath != "/home/dev/repo/docs/a.md" {
+		t.Fatalf("rewritten path = %q", got.Meta[0].FilePath)
+	}
+	// Prefix must match on a path boundary only
+	if got.Meta[1].FilePath != "/ci/build/repo-other/b.md" {
+		t.Fatalf("non-matching path was rewritten: %q", got.Meta[1].FilePath)
+	}
+	if _, ok := got.FileChecksums["/home/dev/repo/docs/a.md"]; !ok {
+		t.Fatalf("checksum key not rewritten: %v", got.FileChecksums)
+	}
+	if got.ChunkSize != 500 || got.ChunkOverlap != 50 {
+		t.Fatalf("chunk config = %d/%d, want 500/50", got.ChunkSize, got.ChunkOverlap)
+	}
+	if len(vectors) != 4 || vectors[3] != 1 {
+		t.Fatalf("vectors = %v", vectors)
+	}
+
+	// Importing again without overwrite must fail
+	if _, err := ImportStore(bytes.NewReader(archive), "dst", ImportOptions{}); err == nil {
+		t.Fatal("ImportStore() into existing store error = nil, want error")
+	}
+	if _, err := ImportStore(bytes.NewReader(archive), "dst", ImportOptions{Overwrite: true}); err != nil {
+		t.Fatalf("ImportStore() with overwrite error = %v", err)
+	}
+
+	// Store name defaults to the manifest
+	if err := DeleteIndex("src"); err != nil {
+		t.Fatalf("DeleteIndex() error = %v", err)
+	}
+	if m, err := ImportStore(bytes.NewReader(archive), "", ImportOptions{}); err != nil || m.StoreName != "src" {
+		t.Fatalf("ImportStore() default name = %v, %v", m, err)
+	}
+}
+
+func TestImportRejectsCorruptArchive(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	if _, err := ImportStore(strings.NewReader("not an archive"), "x", ImportOptions{}); err == nil {
+		t.Fatal("ImportStore() error = nil, want error")
+	}
+}
+
+// editArchive rewrites or removes the entries of an archive, keeping their order
+func editArchive(t *testing.T, archive []byte, edit func(files map[string][]byte)) []byte {
+	t.Helper()
+	zr, err := zstd.NewReader(bytes.NewReader(archive))
+	if err != nil {
+		t.Fatalf("zstd.NewReader() error = %v", err)
+	}
+	defer zr.Close()
+
+	var names []string
+	files := make(map[string][]byte)
+	tr := tar.NewReader(zr)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("tar.Next() error = %v", err)
+		}
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			t.Fatalf("read %s: %v", hdr.Name, err)
+		}
+		names = append(names, hdr.Name)
+		files[hdr.Name] = data
+	}
+	edit(files)
+
+	var out bytes.Buffer
+	zw, err := zstd.NewWriter(&out)
+	if err != nil {
+		t.Fatalf("zstd.NewWriter() error = %v", err)
+	}
+	tw := tar.NewWriter(zw)
+	for _, name := range names {
+		data, ok := files[name]
+		if !ok {
+			continue // removed
+		}
+		if err := writeTarEntry(tw, name, data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatalf("tar close: %v", err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatalf("zstd close: %v", err)
+	}
+	return out.Bytes()
+}
+
+// withManifest rewrites the manifest of an archive
+func withManifest(t *testing.T, archive []byte, edit func(*ArchiveManifest)) []byte {
+	t.Helper()
+	return editArchive(t, archive, func(files map[string][]byte) {
+		var m ArchiveManifest
+		if err := json.Unmarshal(files[manifestFileName], &m); err != nil {
+			t.Fatalf("parse manifest: %v", err)
+		}
+		edit(&m)
+		data, err := json.Marshal(m)
+		if err != nil {
+			t.Fatalf("marshal manifest: %v", err)
+		}
+		files[manifestFileName] = data
+	})
+}
+
+// withConfig rewrites the config.json of an archive and its checksum
+func withConfig(t *testing.T, archive []byte, edit func(*ArchiveConfig)) []byte {
+	t.Helper()
+	var data []byte
+	archive = editArchive(t, archive, func(files map[string][]byte) {
+		var c ArchiveConfig
+		if err := json.Unmarshal(files[configFileName], &c); err != nil {
+			t.Fatalf("parse config: %v", err)
+		}
+		edit(&c)
+		var err error
+		if data, err = json.Marshal(c); err != nil {
+			t.Fatalf("marshal config: %v", err)
+		}
+		files[configFileName] = data
+	})
+	return withManifest(t, archive, func(m *ArchiveManifest) { m.Checksums[configFileName] = sha256Hex(data) })
+}
+
+func TestImportChecksConfigAgainstIndex(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	index := &RagIndex{
+		Meta:           []ChunkMeta{{FilePath: "/docs/a.md", Text: "alpha"}},
+		Dimension:      2,
+		FileChecksums:  map[string]string{"/docs/a.md": "sum-a"},
+		EmbeddingModel: "test-model",
+		ChunkSize:      500,
+		PDFChapters:    true,
+	}
+	if err := SaveIndex("src", index, []float32{1, 0}); err != nil {
+		t.Fatalf("SaveIndex() error = %v", err)
+	}
+	var buf bytes.Buffer
+	if _, err := ExportStore("src", &buf); err != nil {
+		t.Fatalf("ExportStore() error = %v", err)
+	}
+
+	for name, edit := range map[string]func(*ArchiveConfig){
+		"chunk size":    func(c *ArchiveConfig) { c.ChunkSize = 1000 },
+		"chunk overlap": func(c *ArchiveConfig) { c.ChunkOverlap = 50 },
+		"pdf max pages": func(c *ArchiveConfig) { c.PDFMaxPages = 2 },
+		"pdf chapters":  func(c *ArchiveConfig) { c.PDFChapters = false },
+	} {
+		archive := withConfig(t, buf.Bytes(), edit)
+		if _, err := ImportStore(bytes.NewReader(archive), "dst", ImportOptions{}); err == nil || !strings.Contains(err.Error(), "does not match index") {
+			t.Errorf("ImportStore() with another %s error = %v", name, err)
+		}
+	}
+	if index, _ := LoadIndexMetadata("dst"); index != nil {
+		t.Fatal("a rejected archive was imported")
+	}
+
+	// Archives without config.json are still accepted
+	archive := editArchive(t, buf.Bytes(), func(files map[string][]byte) { delete(files, configFileName) })
+	archive = withManifest(t, archive, func(m *ArchiveManifest) { delete(m.Checksums, configFileName) })
+	if _, err := ImportStore(bytes.NewReader(archive), "dst", ImportOptions{}); err != nil {
+		t.Fatalf("ImportStore() without config error = %v", err)
+	}
+}
+
+func TestImportRejectsUnsafeStoreNames(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	index := &RagIndex{
+		Meta:           []ChunkMeta{{FilePath: "/docs/a.md", Text: "alpha"}},
+		Dimension:      2,
+		FileChecksums:  map[string]string{"/docs/a.md": "sum-a"},
+		EmbeddingModel: "test-model",
+	}
+	if err := SaveIndex("src", index, []float32{1, 0}); err != nil {
+		t.Fatalf("SaveIndex() error = %v", err)
+	}
+	var buf bytes.Buffer
+	if _, err := ExportStore("src", &buf); err != nil {
+		t.Fatalf("ExportStore() error = %v", err)
+	}
+
+	victim := filepath.Join(home, "victim")
+	os.MkdirAll(victim, 0755)
+	os.WriteFile(filepath.Join(victim, "keep.txt"), []byte("keep"), 0644)
+
+	for _, name := range []string{"../victim", "..", ".", victim, "a/b", `a\b`} {
+		archive := withManifest(t, buf.Bytes(), func(m *ArchiveManifest) { m.StoreName = name })
+		if _, err := ImportStore(bytes.NewReader(archive), "", ImportOptions{Overwrite: true}); err == nil {
+			t.Errorf("ImportStore() with store name %q error = nil, want error", name)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(victim, "keep.txt")); err != nil {
+		t.Fatalf("victim directory was touched: %v", err)
+	}
+
+	// A directory that isn't a store is never replaced
+	base, err := storeBaseDir()
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.MkdirAll(filepath.Join(base, "notes"), 0755)
+	os.WriteFile(filepath.Join(base, "notes", "todo.txt"), []byte("todo"), 0644)
+	if _, err := ImportStore(bytes.NewReader(buf.Bytes()), "notes", ImportOptions{Overwrite: true}); err == nil {
+		t.Error("ImportStore() over a non-store directory error = nil, want error")
+	}
+	if _, err := os.Stat(filepath.Join(base, "notes", "todo.txt")); err != nil {
+		t.Fatalf("non-store directory was replaced: %v", err)
+	}
+}
+
+func TestParsePathRewriteInvalid(t *testing.T) {
+	for _, spec := range []string{"", "/a", "=/b", "/a="} {
+		if _, err := ParsePathRewrite(spec); err == nil {
+			t.Fatalf("ParsePathRewrite(%q) error = nil, want error", spec)
+		}
+	}
+}
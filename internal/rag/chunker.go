@@ -1,6 +1,9 @@
 package rag
 
-import "regexp"
+import (
+	"regexp"
+	"unicode/utf8"
+)
 
 // Chunk represents a text chunk with its position in the source document
 type Chunk struct {
@@ -40,6 +43,10 @@ func ChunkText(text string, chunkSize, chunkOverlap int) []Chunk {
 					}
 				}
 			}
+			// Never cut a multi-byte character in two
+			if e := runeStart(text, end); e > start {
+				end = e
+			}
 		} else {
 			end = len(text)
 		}
@@ -52,8 +59,8 @@ func ChunkText(text string, chunkSize, chunkOverlap int) []Chunk {
 			})
 		}
 
-		nextStart := end - chunkOverlap
-		if len(chunks) > 0 && nextStart <= chunks[len(chunks)-1].StartOffset {
+		nextStart := runeStart(text, end-chunkOverlap)
+		if nextStart <= start || (len(chunks) > 0 && nextStart <= chunks[len(chunks)-1].StartOffset) {
 			nextStart = end // Prevent infinite loop
 		}
 		start = nextStart
@@ -78,6 +85,15 @@ func FindNearestHeading(text string, offset int) string {
 	return lastHeading
 }
 
+// runeStart moves a byte offset back to the start of the UTF-8 character it
+// falls in
+func runeStart(text string, i int) int {
+	for i > 0 && i < len(text) && !utf8.RuneStart(text[i]) {
+		i--
+	}
+	return i
+}
+
 // lastIndex returns the last occurrence of substr in s, or -1 if not found.
 func lastIndex(s, substr string) int {
 	result := -1
@@ -0,0 +1,20 @@
+package rag
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestChunkTextKeepsCharactersWhole(t *testing.T) {
+	text := strings.Repeat("東京本社で会議を行います", 20)
+	chunks := ChunkText(text, 100, 20)
+	if len(chunks) < 2 {
+		t.Fatalf("got %d chunks, want several", len(chunks))
+	}
+	for _, c := range chunks {
+		if !utf8.ValidString(c.Text) || !strings.HasPrefix(text[c.StartOffset:], c.Text) {
+			t.Errorf("chunk at %d = %q", c.StartOffset, c.Text)
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package rag
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// compactingSuffix marks the staging directory a compacted store is written to
+const compactingSuffix = ".compacting"
+
+// CompactResult holds the result of shrinking a store's vectors
+type CompactResult struct {
+	FromDimension int
+	ToDimension   int
+	Chunks        int
+}
+
+// Compact permanently shrinks the vectors of a store to dimension
+func Compact(storeName string, dimension int) (*CompactResult, error) {
+	dir, err := storeDir(storeName)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(filepath.Join(dir, indexFileName)); os.IsNotExist(err) {
+		return nil, fmt.Errorf("store '%s' not found", storeName)
+	}
+	return CompactDir(dir, dimension)
+}
+
+// CompactDir permanently shrinks the vectors of the RAG index in dir to
+// dimension by truncating and renormalizing them. This is only meaningful for
+// models trained with Matryoshka representation learning (e.g. the Gemini
+// embedding models), whose leading components carry most of the meaning.
+// The compacted index is written next to dir and swapped in when complete.
+func CompactDir(dir string, dimension int) (*CompactResult, error) {
+	lock, err := lockStore(dir, "embed compact")
+	if err != nil {
+		return nil, err
+	}
+	defer lock.unlock()
+
+	index, vectors, err := LoadIndexFromDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load index: %w", err)
+	}
+	if index == nil {
+		return nil, fmt.Errorf("no index found in '%s'", dir)
+	}
+	if dimension <= 0 || dimension >= index.Dimension {
+		return nil, fmt.Errorf("dimension must be between 1 and %d (the current dimension), got %d", index.Dimension-1, dimension)
+	}
+
+	result := &CompactResult{
+		FromDimension: index.Dimension,
+		ToDimension:   dimension,
+		Chunks:        len(index.Meta),
+	}
+
+	compacted := make([]float32, 0, len(index.Meta)*dimension)
+	for i := range index.Meta {
+		vec := vectors[i*index.Dimension : (i+1)*index.Dimension]
+		compacted = append(compacted, truncateVector(vec, dimension)...)
+	}
+	if index.EmbeddedDimension == 0 {
+		index.EmbeddedDimension = index.Dimension
+	}
+	index.Dimension = dimension
+
+	// Cleaned so "idx/" stages to "idx.compacting", not into the index itself
+	stagingDir := filepath.Clean(dir) + compactingSuffix
+	if err := os.RemoveAll(stagingDir); err != nil {
+		return nil, fmt.Errorf("failed to clean staging directory: %w", err)
+	}
+	if err := saveIndexToDir(stagingDir, index, compacted); err != nil {
+		os.RemoveAll(stagingDir)
+		return nil, err
+	}
+	if err := swapStoreDir(dir, stagingDir); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// truncateVector returns the first dimension components of vec, renormalized
+// to unit length (Matryoshka truncation)
+func truncateVector(vec []float32, dimension int) []float32 {
+	if len(vec) <= dimension {
+		return vec
+	}
+	return normalized(vec[:dimension])
+}
+
+// fitToStore truncates freshly embedded vectors to a compacted store's
+// dimension, for backends that return more dimensions than were requested.
+// Vectors of stores that were never compacted are left alone.
+func fitToStore(vecs [][]float32, index *RagIndex) {
+	if index == nil || index.EmbeddedDimension == 0 {
+		return
+	}
+	for i, vec := range vecs {
+		vecs[i] = truncateVector(vec, index.Dimension)
+	}
+}
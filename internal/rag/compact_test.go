@@ -0,0 +1,145 @@
+package rag
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/takeshy/ragujuary/internal/embedding"
+)
+
+func TestCompactTruncatesAndRenormalizes(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	docsDir := filepath.Join(home, "docs")
+	if err := os.MkdirAll(docsDir, 0755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(docsDir, "a.md"), []byte("alpha"), 0644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	engine := NewEngine(fakeEmbeddingClient{})
+	config := DefaultConfig()
+	config.Dimension = 8
+	if _, err := engine.Index([]string{docsDir}, nil, "compact-store", config); err != nil {
+		t.Fatalf("index: %v", err)
+	}
+
+	if _, err := Compact("compact-store", 8); err == nil {
+		t.Fatal("Compact() accepted a dimension that is not smaller")
+	}
+	result, err := Compact("compact-store", 3)
+	if err != nil {
+		t.Fatalf("Compact() error = %v", err)
+	}
+	if result.FromDimension != 8 || result.ToDimension != 3 || result.Chunks != 1 {
+		t.Fatalf("result = %+v", result)
+	}
+
+	index, vectors, err := LoadIndex("compact-store")
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if index.Dimension != 3 || index.EmbeddedDimension != 8 || len(vectors) != 3 {
+		t.Fatalf("dimension=%d embedded=%d vectors=%d", index.Dimension, index.EmbeddedDimension, len(vectors))
+	}
+	var sumSq float64
+	for _, v := range vectors {
+		sumSq += float64(v) * float64(v)
+	}
+	if math.Abs(sumSq-1) > 1e-5 {
+		t.Fatalf("compacted vector norm² = %f, want 1", sumSq)
+	}
+
+	// The fake backend ignores the requested dimension; new vectors are truncated to fit
+	config.Dimension = 3
+	if err := os.WriteFile(filepath.Join(docsDir, "b.md"), []byte("beta"), 0644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	wide := NewEngine(wideEmbeddingClient{})
+	if _, err := wide.Index([]string{docsDir}, nil, "compact-store", config); err != nil {
+		t.Fatalf("index into compacted store: %v", err)
+	}
+	results, err := wide.Query("alpha", "compact-store", config)
+	if err != nil {
+		t.Fatalf("query: %v", err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("results = %+v", results)
+	}
+}
+
+func TestCompactDirWithTrailingSlash(t *testing.T) {
+	home := t.TempDir()
+	docsDir := filepath.Join(home, "docs")
+	indexDir := filepath.Join(home, "idx")
+	if err := os.MkdirAll(docsDir, 0755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(docsDir, "a.md"), []byte("alpha"), 0644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	config := DefaultConfig()
+	config.Dimension = 8
+	if _, err := NewEngine(fakeEmbeddingClient{}).IndexDir([]string{docsDir}, nil, indexDir, config); err != nil {
+		t.Fatalf("index: %v", err)
+	}
+
+	if _, err := CompactDir(indexDir+string(filepath.Separator), 3); err != nil {
+		t.Fatalf("CompactDir() error = %v", err)
+	}
+	index, _, err := LoadIndexFromDir(indexDir)
+	if err != nil || index == nil || index.Dimension != 3 {
+		t.Fatalf("compacted index = %+v, %v", index, err)
+	}
+	entries, err := os.ReadDir(home)
+	if err != nil {
+		t.Fatalf("read dir: %v", err)
+	}
+	if len(entries) != 2 {
+		t.Errorf("entries next to the index = %v, want only docs and idx", entries)
+	}
+	if _, err := os.Stat(filepath.Join(indexDir, compactingSuffix)); !os.IsNotExist(err) {
+		t.Errorf("staging directory left inside the index: %v", err)
+	}
+}
+
+func TestSearchTruncatedRescoresAtFullDimension(t *testing.T) {
+	index := &RagIndex{
+		Dimension: 4,
+		Meta:      []ChunkMeta{{FilePath: "a"}, {FilePath: "b"}, {FilePath: "c"}},
+	}
+	vectors := []float32{
+		1, 0, 0, 0,
+		0.9, 0.1, 0.4, 0,
+		0, 1, 0, 0,
+	}
+	query := []float32{1, 0, 0.5, 0}
+
+	full := Search(query, index, vectors, 2, 0)
+	coarse := SearchTruncated(query, index, vectors, 2, 0, 2)
+	if len(coarse) != len(full) {
+		t.Fatalf("got %d results, want %d", len(coarse), len(full))
+	}
+	for i := range full {
+		if coarse[i].FilePath != full[i].FilePath || math.Abs(coarse[i].Score-full[i].Score) > 1e-9 {
+			t.Fatalf("result %d = %+v, want %+v", i, coarse[i], full[i])
+		}
+	}
+}
+
+// wideEmbeddingClient returns 8-dimensional vectors whatever dimension is asked for
+type wideEmbeddingClient struct {
+	fakeEmbeddingClient
+}
+
+func (w wideEmbeddingClient) EmbedContent(model, text string, taskType embedding.TaskType, dimension int) ([]float32, error) {
+	return fakeVector(text, 8), nil
+}
+
+func (w wideEmbeddingClient) BatchEmbedContents(model string, texts []string, taskType embedding.TaskType, dimension int) ([][]float32, error) {
+	return w.fakeEmbeddingClient.BatchEmbedContents(model, texts, taskType, 8)
+}
@@ -1,11 +1,14 @@
 package rag
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 
+	"github.com/takeshy/ragujuary/internal/docutil"
 	"github.com/takeshy/ragujuary/internal/embedding"
 	"github.com/takeshy/ragujuary/internal/fileutil"
 	"github.com/takeshy/ragujuary/internal/mediautil"
@@ -16,13 +19,37 @@ const defaultBatchSize = 32
 
 // Config holds configuration for the RAG engine
 type Config struct {
-	Model        string
-	Dimension    int
-	ChunkSize    int
-	ChunkOverlap int
-	TopK         int
-	MinScore     float64
-	PDFMaxPages  int
+	Model           string
+	Provider        string // embedding provider recorded in new stores (see embedding.LookupProvider)
+	Dimension       int
+	ChunkSize       int
+	ChunkOverlap    int
+	TopK            int
+	MinScore        float64
+	PDFMaxPages     int
+	Roots           map[string]string // Named roots to record in the store (name -> absolute directory)
+	IndexFormat     IndexFormat       // On-disk format for newly created indexes (existing indexes keep theirs)
+	SearchDimension int               // Coarse-pass dimension for truncated (Matryoshka) search; 0 = search at full dimension
+	Include         []string          // Globs of the files to index (empty = all; see fileutil.DiscoverOptions)
+	NoIgnore        bool              // Index files ignored by .gitignore / .ragujuaryignore too
+	MIMETypes       map[string]string // Extension -> MIME type overrides for file discovery
+	// DropHTMLBoilerplate leaves <nav>, <header>, <footer> and <aside>
+	// content out of the text extracted from HTML
+	DropHTMLBoilerplate bool
+	// Encoding is the character encoding of text files ("" or "auto" =
+	// detect; see fileutil.DecodeText)
+	Encoding string
+	// CSVRows caps the rows of a CSV chunk (0 = docutil.DefaultCSVRows)
+	CSVRows int
+	// PDFChapters starts a new PDF page chunk at every top-level outline
+	// entry (chapter) instead of only every PDFMaxPages pages
+	PDFChapters bool
+	// PDFMode chooses how PDFs are embedded: PDFModeAuto (""), PDFModeVisual,
+	// PDFModeText or PDFModeBoth
+	PDFMode string
+	// PromptTemplate overrides the prompt-template table for new stores
+	// (nil = look up Model; an empty template disables prefixes)
+	PromptTemplate *embedding.PromptTemplate
 }
 
 // DefaultConfig returns a Config with sensible defaults
@@ -47,6 +74,16 @@ type IndexResult struct {
 	UpdatedFiles      int
 	MultimodalFiles   int
 	SkippedMultimodal int
+	SkippedBinary     int // files of binary content that isn't a supported media type
+	RenamedFiles      int
+	Renames           []Rename
+	FailedChunks      int // text chunks the backend could not embed; their files are retried next run
+}
+
+// Rename records a file whose chunks were re-pointed to a new path without re-embedding
+type Rename struct {
+	From string
+	To   string
 }
 
 // Engine orchestrates the RAG indexing and query pipeline
@@ -61,11 +98,50 @@ func NewEngine(embeddingClient embedding.Client) *Engine {
 	}
 }
 
-func shouldReindexForPDFPageLimit(index *RagIndex, config Config, fi fileutil.FileInfo, supportsMultimodal bool) bool {
-	if !supportsMultimodal || fi.MimeType != "application/pdf" {
+// PDF modes: the representations a PDF is embedded as
+const (
+	PDFModeAuto   = "auto"   // page images where the backend embeds PDFs, else extracted text
+	PDFModeVisual = "visual" // page images only
+	PDFModeText   = "text"   // extracted text only
+	PDFModeBoth   = "both"   // extracted text and page images
+)
+
+// Representations of a PDF, reported with its search results
+const (
+	RepresentationText   = "text"   // a chunk of the extracted text
+	RepresentationVisual = "visual" // a page-range (multimodal) embedding
+)
+
+// checkPDFMode reports an unknown PDF mode ("" means auto)
+func checkPDFMode(mode string) error {
+	switch mode {
+	case "", PDFModeAuto, PDFModeVisual, PDFModeText, PDFModeBoth:
+		return nil
+	}
+	return fmt.Errorf("unknown PDF mode %q (want %s, %s, %s or %s)", mode, PDFModeAuto, PDFModeVisual, PDFModeText, PDFModeBoth)
+}
+
+// pdfRepresentations returns whether PDFs are embedded as chunks of their
+// extracted text and as page images under mode, on a backend that can (or
+// can't) embed PDFs. Both mode on a text-only backend embeds the text only.
+func pdfRepresentations(mode string, multimodal bool) (text, visual bool) {
+	switch mode {
+	case PDFModeText:
+		return true, false
+	case PDFModeVisual:
+		return false, true
+	case PDFModeBoth:
+		return true, multimodal
+	default:
+		return !multimodal, multimodal
+	}
+}
+
+func shouldReindexForPDFSplit(index *RagIndex, config Config, fi fileutil.FileInfo, supportsMultimodal bool) bool {
+	if _, visual := pdfRepresentations(config.PDFMode, supportsMultimodal); !supportsMultimodal || !visual || fi.MimeType != "application/pdf" {
 		return false
 	}
-	return index != nil && index.EffectivePDFMaxPages() != config.PDFMaxPages
+	return index != nil && (index.EffectivePDFMaxPages() != config.PDFMaxPages || index.PDFChapters != config.PDFChapters)
 }
 
 func shouldReindexForTextChunkConfig(index *RagIndex, config Config, fi fileutil.FileInfo, supportsMultimodal bool) bool {
@@ -80,13 +156,42 @@ func shouldReindexForTextChunkConfig(index *RagIndex, config Config, fi fileutil
 	if !fileutil.IsMultimodal(ct) {
 		return true
 	}
-	return ct == "pdf" && !supportsMultimodal
+	text, _ := pdfRepresentations(config.PDFMode, supportsMultimodal)
+	return ct == "pdf" && text
 }
 
 // Index indexes files from directories into the local embedding store
 func (e *Engine) Index(dirs []string, excludePatterns []string, storeName string, config Config) (*IndexResult, error) {
+	dir, err := storeDir(storeName)
+	if err != nil {
+		return nil, err
+	}
+	return e.IndexDir(dirs, excludePatterns, dir, config)
+}
+
+// IndexDir indexes files from directories into a RAG index in an arbitrary directory.
+// An existing index keeps its on-disk format (ragujuary snake_case or external camelCase),
+// so the directory can be shared with other RAG tools.
+func (e *Engine) IndexDir(dirs []string, excludePatterns []string, indexDir string, config Config) (*IndexResult, error) {
+	if _, err := fileutil.LookupEncoding(config.Encoding); err != nil {
+		return nil, err
+	}
+	if err := checkPDFMode(config.PDFMode); err != nil {
+		return nil, err
+	}
+	lock, err := lockStore(indexDir, "embed index")
+	if err != nil {
+		return nil, err
+	}
+	defer lock.unlock()
+
 	// Discover files
-	files, err := fileutil.DiscoverFiles(dirs, excludePatterns)
+	files, err := fileutil.DiscoverFiles(dirs, fileutil.DiscoverOptions{
+		Exclude:   excludePatterns,
+		Include:   config.Include,
+		NoIgnore:  config.NoIgnore,
+		MIMETypes: config.MIMETypes,
+	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to discover files: %w", err)
 	}
@@ -95,65 +200,190 @@ func (e *Engine) Index(dirs []string, excludePatterns []string, storeName string
 		return &IndexResult{}, nil
 	}
 
-	// Check multimodal support
-	_, supportsMultimodal := e.embeddingClient.(embedding.MultimodalEmbedder)
+	// Check multimodal support (per content type: some backends only embed images)
+	supportsMultimodal := func(mimeType string) bool {
+		return embedding.SupportsMultimodal(e.embeddingClient, config.Model, mimeType)
+	}
+
+	// Load existing index and apply roots, so that paths under a root are keyed
+	// as "name:rel/path" both in the existing entries and the freshly scanned ones
+	existingIndex, existingVectors, _ := LoadIndexFromDir(indexDir)
+	format := config.IndexFormat
+	modelName := config.Model
+	roots := &RagIndex{}
+	if existingIndex != nil {
+		roots.Roots = existingIndex.Roots
+	}
+	for name, dir := range config.Roots {
+		if existingIndex != nil {
+			existingIndex.SetRoot(name, dir)
+			roots.Roots = existingIndex.Roots
+		} else {
+			roots.SetRoot(name, dir)
+		}
+	}
 
-	// Compute checksums, classify files
+	// Compute checksums, classify files. Maps are keyed by the stored path
+	// (root-relative where possible); FileInfo.Path stays absolute for reading.
 	newChecksums := make(map[string]string)
-	fileContents := make(map[string]string) // text files only
+	fileContents := make(map[string]string)            // text files only
+	fileSections := make(map[string][]docutil.Section) // labelled parts of extracted documents
+	fileEncodings := make(map[string]string)           // encodings text files were decoded from
+	pdfOutlines := make(map[string]*pdfutil.Metadata)  // outlines of PDFs chunked as text
 	fileInfoMap := make(map[string]fileutil.FileInfo)
+	pathKeys := make(map[string]string) // absolute path -> stored path
+	binaryFiles := make(map[string]bool)
 	for _, f := range files {
-		checksum, err := fileutil.CalculateChecksum(f.Path)
-		if err != nil {
-			return nil, fmt.Errorf("failed to calculate checksum for %s: %w", f.Path, err)
+		// Binary content without a media type would be embedded as garbage text
+		if f.Binary && !fileutil.IsMultimodal(fileutil.ClassifyContent(f.MimeType)) && !docutil.Supported(f.MimeType) {
+			fmt.Fprintf(os.Stderr, "Warning: skipping %s (binary content, %s)\n", f.Path, f.MimeType)
+			binaryFiles[roots.RelativePath(f.Path)] = true
+			continue
+		}
+		checksum := f.Checksum // archive entries come with theirs
+		if checksum == "" {
+			checksum, err = fileutil.CalculateChecksum(f.Path)
+			if err != nil {
+				return nil, fmt.Errorf("failed to calculate checksum for %s: %w", f.Path, err)
+			}
 		}
-		newChecksums[f.Path] = checksum
-		fileInfoMap[f.Path] = f
+		key := roots.RelativePath(f.Path)
+		pathKeys[f.Path] = key
+		newChecksums[key] = checksum
+		fileInfoMap[key] = f
 
 		ct := fileutil.ClassifyContent(f.MimeType)
 		if !fileutil.IsMultimodal(ct) {
-			content, err := os.ReadFile(f.Path)
+			data, err := fileutil.ReadFile(f.Path)
 			if err != nil {
 				return nil, fmt.Errorf("failed to read file %s: %w", f.Path, err)
 			}
-			fileContents[f.Path] = string(content)
-		} else if ct == "pdf" && !supportsMultimodal {
-			// Extract text from PDF for text-only backends
-			data, err := os.ReadFile(f.Path)
+			doc, encoding, err := extractText(f.Path, data, f.MimeType, config)
+			if err != nil {
+				// Treated as not scanned: a new file is retried next run, an
+				// indexed one keeps its checksum and chunks
+				fmt.Fprintf(os.Stderr, "Warning: failed to extract text from %s: %v\n", f.Path, err)
+				delete(newChecksums, key)
+				delete(fileInfoMap, key)
+				delete(pathKeys, f.Path)
+				continue
+			}
+			fileContents[key] = doc.Text
+			fileSections[key] = doc.Sections
+			fileEncodings[key] = encoding
+		} else if text, _ := pdfRepresentations(config.PDFMode, supportsMultimodal(f.MimeType)); ct == "pdf" && text {
+			// Extract the PDF's text for chunking (text-only backends, text and both modes)
+			data, err := fileutil.ReadFile(f.Path)
 			if err != nil {
 				fmt.Fprintf(os.Stderr, "Warning: failed to read %s: %v\n", f.Path, err)
 				continue
 			}
-			text, err := pdfutil.ExtractAllText(data)
+			doc, err := pdfTextDocument(data)
 			if err != nil {
 				fmt.Fprintf(os.Stderr, "Warning: failed to extract text from %s: %v\n", f.Path, err)
 				continue
 			}
-			if text != "" {
-				fileContents[f.Path] = text
+			if doc.Text != "" {
+				fileContents[key] = doc.Text
+				fileSections[key] = doc.Sections
+				if meta, err := pdfutil.ReadMetadata(data); err == nil {
+					pdfOutlines[key] = meta
+				}
 			}
 		}
 	}
 
-	// Load existing index
-	existingIndex, existingVectors, _ := LoadIndex(storeName)
 	oldChecksums := make(map[string]string)
 	finalChecksums := make(map[string]string)
 	if existingIndex != nil {
 		oldChecksums = existingIndex.FileChecksums
 		for path, checksum := range existingIndex.FileChecksums {
-			finalChecksums[path] = checksum
+			if !binaryFiles[path] {
+				finalChecksums[path] = checksum
+			}
+		}
+
+		format = existingIndex.Format
+
+		// Switching models goes through Migrate; mixing vector spaces would corrupt search
+		if err := checkStoreModel(existingIndex, config.Model); err != nil {
+			return nil, err
+		}
+		if err := checkStoreProvider(existingIndex, config.Provider); err != nil {
+			return nil, err
 		}
+		if err := checkStorePromptTemplate(existingIndex, config); err != nil {
+			return nil, err
+		}
+		if existingIndex.EmbeddingModel != "" && sameModel(existingIndex.EmbeddingModel, config.Model) {
+			// Keep the model name spelled the way the index has it (e.g. "models/..." from other tools)
+			modelName = existingIndex.EmbeddingModel
+		}
+	}
+
+	// Re-point chunks of moved/renamed files to their new path instead of re-embedding
+	var renames []Rename
+	renamedTo := make(map[string]bool)
+	if existingIndex != nil {
+		renames = detectRenames(oldChecksums, newChecksums, roots)
+		for _, r := range renames {
+			applyRename(existingIndex, r)
+			delete(finalChecksums, r.From)
+			finalChecksums[r.To] = oldChecksums[r.To]
+			renamedTo[r.To] = true
+		}
+	}
 
-		// Check model compatibility
-		if existingIndex.EmbeddingModel != "" && existingIndex.EmbeddingModel != config.Model {
-			// Model changed, must re-index everything
-			existingIndex = nil
-			existingVectors = nil
-			oldChecksums = make(map[string]string)
-			finalChecksums = make(map[string]string)
+	// Files decoded from another encoding than their chunks were (a new
+	// --encoding, or detection that got better) are re-chunked like changed files
+	recordedEncodings := make(map[string]string)
+	if existingIndex != nil {
+		for _, meta := range existingIndex.Meta {
+			if meta.ContentType == "" {
+				recordedEncodings[meta.FilePath] = meta.Encoding
+			}
+		}
+	}
+	encodingChanged := func(path string) bool {
+		encoding, decoded := fileEncodings[path]
+		recorded, indexed := recordedEncodings[path]
+		return decoded && indexed && encoding != recorded
+	}
+
+	// PDFs embedded as other representations than the PDF mode asks for (a
+	// new --pdf-mode) are re-embedded like changed files. A PDF no text
+	// could be extracted from is embedded as page images in every mode where
+	// the backend can.
+	type pdfRepresentation struct{ text, visual bool }
+	recordedPDFs := make(map[string]pdfRepresentation)
+	if existingIndex != nil {
+		for _, meta := range existingIndex.Meta {
+			rep := recordedPDFs[meta.FilePath]
+			switch meta.ContentType {
+			case "":
+				rep.text = true
+			case "pdf":
+				rep.visual = true
+			}
+			recordedPDFs[meta.FilePath] = rep
 		}
 	}
+	pdfRoute := func(path string) pdfRepresentation {
+		fi := fileInfoMap[path]
+		_, extracted := fileContents[path]
+		hasText := len(fileSections[path]) > 0
+		// On text-only backends a PDF whose pages have no text still counts as text
+		text := extracted && (hasText || !supportsMultimodal(fi.MimeType))
+		_, visual := pdfRepresentations(config.PDFMode, supportsMultimodal(fi.MimeType))
+		return pdfRepresentation{text: text, visual: visual || !text}
+	}
+	pdfModeChanged := func(path string) bool {
+		recorded, indexed := recordedPDFs[path]
+		if !indexed || fileutil.ClassifyContent(fileInfoMap[path].MimeType) != "pdf" {
+			return false
+		}
+		return recorded != pdfRoute(path)
+	}
 
 	// Separate changed and unchanged files
 	var changedFiles []string
@@ -169,8 +399,13 @@ func (e *Engine) Index(dirs []string, excludePatterns []string, storeName string
 			pdfConfigChanged := false
 			textChunkConfigChanged := false
 			if scanned && haveInfo {
-				pdfConfigChanged = shouldReindexForPDFPageLimit(existingIndex, config, fi, supportsMultimodal)
-				textChunkConfigChanged = shouldReindexForTextChunkConfig(existingIndex, config, fi, supportsMultimodal)
+				pdfConfigChanged = shouldReindexForPDFSplit(existingIndex, config, fi, supportsMultimodal(fi.MimeType)) ||
+					pdfModeChanged(meta.FilePath)
+				textChunkConfigChanged = shouldReindexForTextChunkConfig(existingIndex, config, fi, supportsMultimodal(fi.MimeType)) ||
+					encodingChanged(meta.FilePath)
+			}
+			if binaryFiles[meta.FilePath] {
+				continue // indexed before it turned binary
 			}
 			if !scanned || (checksum == oldChecksums[meta.FilePath] && !pdfConfigChanged && !textChunkConfigChanged) {
 				unchangedMeta = append(unchangedMeta, meta)
@@ -182,14 +417,22 @@ func (e *Engine) Index(dirs []string, excludePatterns []string, storeName string
 	}
 
 	// Find changed/new files
-	result := &IndexResult{}
+	result := &IndexResult{
+		RenamedFiles:  len(renames),
+		Renames:       renames,
+		SkippedBinary: len(binaryFiles),
+	}
 	for filePath, checksum := range newChecksums {
 		fi := fileInfoMap[filePath]
-		pdfConfigChanged := shouldReindexForPDFPageLimit(existingIndex, config, fi, supportsMultimodal)
-		textChunkConfigChanged := shouldReindexForTextChunkConfig(existingIndex, config, fi, supportsMultimodal)
+		pdfConfigChanged := shouldReindexForPDFSplit(existingIndex, config, fi, supportsMultimodal(fi.MimeType)) ||
+			pdfModeChanged(filePath)
+		textChunkConfigChanged := shouldReindexForTextChunkConfig(existingIndex, config, fi, supportsMultimodal(fi.MimeType)) ||
+			encodingChanged(filePath)
 		if oldChecksum, exists := oldChecksums[filePath]; exists {
 			if checksum == oldChecksum && !pdfConfigChanged && !textChunkConfigChanged {
-				result.SkippedFiles++
+				if !renamedTo[filePath] {
+					result.SkippedFiles++
+				}
 			} else {
 				changedFiles = append(changedFiles, filePath)
 				result.UpdatedFiles++
@@ -203,24 +446,34 @@ func (e *Engine) Index(dirs []string, excludePatterns []string, storeName string
 	// Split changed files into text and multimodal
 	var textFiles []string
 	var multimodalFileInfos []fileutil.FileInfo
+	bothPDFs := make(map[string]bool) // PDFs embedded as text and page images
 	for _, filePath := range changedFiles {
 		fi := fileInfoMap[filePath]
 		ct := fileutil.ClassifyContent(fi.MimeType)
-		if fileutil.IsMultimodal(ct) {
-			// PDFs with extracted text go to text pipeline
-			if _, hasText := fileContents[filePath]; hasText {
+		if ct == "pdf" {
+			// PDFs with extracted text go to the text pipeline, and to the
+			// multimodal one too for their page images in both mode
+			route := pdfRoute(filePath)
+			if route.text {
 				textFiles = append(textFiles, filePath)
-			} else {
+			}
+			if route.visual {
 				multimodalFileInfos = append(multimodalFileInfos, fi)
 			}
+			bothPDFs[filePath] = route.text && route.visual
+		} else if fileutil.IsMultimodal(ct) {
+			multimodalFileInfos = append(multimodalFileInfos, fi)
 		} else {
 			textFiles = append(textFiles, filePath)
 		}
 	}
 
+	config.PromptTemplate = promptTemplateFor(existingIndex, config)
+
 	// Chunk and embed text files
 	newMeta := make([]ChunkMeta, 0)
 	newVecs := make([][]float32, 0)
+	incompleteFiles := make(map[string]bool)
 
 	if len(textFiles) > 0 {
 		var allTexts []string
@@ -228,42 +481,38 @@ func (e *Engine) Index(dirs []string, excludePatterns []string, storeName string
 
 		for _, filePath := range textFiles {
 			content := fileContents[filePath]
-			chunks := ChunkText(content, config.ChunkSize, config.ChunkOverlap)
+			chunks, labels := chunkSections(content, fileSections[filePath], config.ChunkSize, config.ChunkOverlap)
 
-			for _, chunk := range chunks {
-				heading := FindNearestHeading(content, chunk.StartOffset)
-				var embeddingText string
-				if heading != "" {
-					embeddingText = fmt.Sprintf("[%s > %s]\n%s", filePath, heading, chunk.Text)
-				} else {
-					embeddingText = fmt.Sprintf("[%s]\n%s", filePath, chunk.Text)
-				}
-				allTexts = append(allTexts, embeddingText)
+			for i, chunk := range chunks {
+				allTexts = append(allTexts, buildEmbeddingText(filePath, content, chunk))
 				allMetas = append(allMetas, ChunkMeta{
 					FilePath:    filePath,
 					StartOffset: chunk.StartOffset,
 					Text:        chunk.Text,
+					PageLabel:   labels[i],
+					Sections:    pageSections(pdfOutlines[filePath], labels[i]),
+					Encoding:    fileEncodings[filePath],
 				})
 			}
 		}
 
-		// Batch embed
-		for i := 0; i < len(allTexts); i += defaultBatchSize {
-			end := i + defaultBatchSize
-			if end > len(allTexts) {
-				end = len(allTexts)
-			}
-			batch := allTexts[i:end]
+		embeddings, failed, err := e.embedTexts(allTexts, allMetas, config)
+		if err != nil {
+			return nil, err
+		}
 
-			embeddings, err := e.embeddingClient.BatchEmbedContents(config.Model, batch, embedding.TaskRetrievalDocument, config.Dimension)
-			if err != nil {
-				return nil, fmt.Errorf("failed to embed batch: %w", err)
+		// Chunks that failed individually are left out; their files keep no
+		// checksum so the next run embeds them again
+		for i, vec := range embeddings {
+			if itemErr, ok := failed[i]; ok {
+				fmt.Fprintf(os.Stderr, "Warning: failed to embed chunk of %s at offset %d: %v\n", allMetas[i].FilePath, allMetas[i].StartOffset, itemErr)
+				incompleteFiles[allMetas[i].FilePath] = true
+				result.FailedChunks++
+				continue
 			}
-
-			newVecs = append(newVecs, embeddings...)
+			newMeta = append(newMeta, allMetas[i])
+			newVecs = append(newVecs, vec)
 		}
-
-		newMeta = append(newMeta, allMetas...)
 	}
 
 	// Check ffmpeg availability if there are audio/video files
@@ -282,25 +531,25 @@ func (e *Engine) Index(dirs []string, excludePatterns []string, storeName string
 	}
 
 	// Embed multimodal files (split PDFs by pages, audio/video by duration)
-	mmClient, _ := e.embeddingClient.(embedding.MultimodalEmbedder)
 	for _, fi := range multimodalFileInfos {
-		if !supportsMultimodal {
+		key := pathKeys[fi.Path]
+		if !supportsMultimodal(fi.MimeType) {
 			fmt.Fprintf(os.Stderr, "Warning: skipping %s (backend does not support multimodal embedding)\n", fi.Path)
-			delete(finalChecksums, fi.Path)
+			delete(finalChecksums, key)
 			result.SkippedMultimodal++
 			continue
 		}
 		if !fileutil.SupportedEmbeddingMIME(fi.MimeType) {
 			fmt.Fprintf(os.Stderr, "Warning: skipping %s (unsupported MIME type %s)\n", fi.Path, fi.MimeType)
-			delete(finalChecksums, fi.Path)
+			delete(finalChecksums, key)
 			result.SkippedMultimodal++
 			continue
 		}
 
-		data, err := os.ReadFile(fi.Path)
+		data, err := fileutil.ReadFile(fi.Path)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "Warning: failed to read %s: %v\n", fi.Path, err)
-			delete(finalChecksums, fi.Path)
+			delete(finalChecksums, key)
 			result.SkippedMultimodal++
 			continue
 		}
@@ -309,10 +558,10 @@ func (e *Engine) Index(dirs []string, excludePatterns []string, storeName string
 
 		// PDF: split into page chunks (configurable, max 6 pages per chunk)
 		if fi.MimeType == "application/pdf" {
-			chunks, err := pdfutil.SplitPages(data, config.PDFMaxPages)
+			chunks, pdfMeta, err := splitPDF(fi.Path, data, config)
 			if err != nil {
 				fmt.Fprintf(os.Stderr, "Warning: failed to split PDF %s: %v\n", fi.Path, err)
-				delete(finalChecksums, fi.Path)
+				delete(finalChecksums, key)
 				result.SkippedMultimodal++
 				continue
 			}
@@ -325,38 +574,28 @@ func (e *Engine) Index(dirs []string, excludePatterns []string, storeName string
 
 			embedded := 0
 			for _, chunk := range chunks {
-				vec, err := mmClient.EmbedMultimodalContent(config.Model, embedding.MultimodalContent{
-					MIMEType: fi.MimeType,
-					Data:     chunk.Data,
-				}, embedding.TaskRetrievalDocument, config.Dimension)
+				vec, backend, err := e.embedContent(fi.MimeType, chunk.Data, config)
 				if err != nil {
 					fmt.Fprintf(os.Stderr, "Warning: failed to embed %s (pages %d-%d): %v\n", fi.Path, chunk.StartPage, chunk.EndPage, err)
 					continue
 				}
 
 				pageLabel := fmt.Sprintf("pages %d-%d of %d", chunk.StartPage, chunk.EndPage, chunk.TotalPages)
-
-				// Build text from extracted pages for this chunk range
-				chunkText := fmt.Sprintf("[%s: %s (%s)]", ct, filepath.Base(fi.Path), pageLabel)
-				if pageTexts != nil {
-					var parts []string
-					for p := chunk.StartPage; p <= chunk.EndPage && p <= len(pageTexts); p++ {
-						if t := pageTexts[p-1]; t != "" {
-							parts = append(parts, t)
-						}
-					}
-					if len(parts) > 0 {
-						chunkText = chunkText + "\n\n" + strings.Join(parts, "\n\n")
-					}
+				var title string
+				var sections []string
+				if pdfMeta != nil {
+					title, sections = pdfMeta.Title, pdfMeta.Sections(chunk.StartPage, chunk.EndPage)
 				}
 
 				newMeta = append(newMeta, ChunkMeta{
-					FilePath:    fi.Path,
+					FilePath:    key,
 					StartOffset: 0,
-					Text:        chunkText,
+					Text:        pdfChunkText(ct, filepath.Base(fi.Path), pageLabel, chunk, pageTexts, title, sections),
 					ContentType: ct,
 					MIMEType:    fi.MimeType,
 					PageLabel:   pageLabel,
+					Sections:    sections,
+					Backend:     backend,
 				})
 				newVecs = append(newVecs, vec)
 				embedded++
@@ -364,9 +603,9 @@ func (e *Engine) Index(dirs []string, excludePatterns []string, storeName string
 
 			if embedded > 0 {
 				result.MultimodalFiles++
-				finalChecksums[fi.Path] = newChecksums[fi.Path]
+				finalChecksums[key] = newChecksums[key]
 			} else {
-				delete(finalChecksums, fi.Path)
+				delete(finalChecksums, key)
 				result.SkippedMultimodal++
 			}
 			continue
@@ -374,72 +613,79 @@ func (e *Engine) Index(dirs []string, excludePatterns []string, storeName string
 
 		// Audio/Video: split by duration if exceeding Gemini limits
 		if ct == "audio" || ct == "video" {
-			needsSplit, _, _, maxDur, probeErr := mediautil.NeedsSplit(fi.Path, fi.MimeType)
+			// ffmpeg reads files: archive entries are probed and split from a copy
+			mediaPath, cleanup, err := fileutil.LocalPath(fi.Path)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: failed to read %s: %v\n", fi.Path, err)
+				delete(finalChecksums, key)
+				result.SkippedMultimodal++
+				continue
+			}
+			needsSplit, _, _, maxDur, probeErr := mediautil.NeedsSplit(mediaPath, fi.MimeType)
+			var segments []mediautil.MediaChunk
+			var splitErr error
+			if probeErr == nil && needsSplit {
+				segments, splitErr = mediautil.SplitMedia(mediaPath, fi.MimeType, maxDur)
+			}
+			cleanup()
+
 			if probeErr != nil {
 				// Can't probe — try single embedding with the raw data
-				vec, err := mmClient.EmbedMultimodalContent(config.Model, embedding.MultimodalContent{
-					MIMEType: fi.MimeType,
-					Data:     data,
-				}, embedding.TaskRetrievalDocument, config.Dimension)
+				vec, backend, err := e.embedContent(fi.MimeType, data, config)
 				if err != nil {
 					fmt.Fprintf(os.Stderr, "Warning: failed to embed %s: %v\n", fi.Path, err)
-					delete(finalChecksums, fi.Path)
+					delete(finalChecksums, key)
 					result.SkippedMultimodal++
 					continue
 				}
 				newMeta = append(newMeta, ChunkMeta{
-					FilePath:    fi.Path,
+					FilePath:    key,
 					StartOffset: 0,
 					Text:        fmt.Sprintf("[%s: %s]", ct, filepath.Base(fi.Path)),
 					ContentType: ct,
 					MIMEType:    fi.MimeType,
+					Backend:     backend,
 				})
 				newVecs = append(newVecs, vec)
 				result.MultimodalFiles++
-				finalChecksums[fi.Path] = newChecksums[fi.Path]
+				finalChecksums[key] = newChecksums[key]
 				continue
 			}
 
 			if !needsSplit {
 				// Short enough — embed directly
-				vec, err := mmClient.EmbedMultimodalContent(config.Model, embedding.MultimodalContent{
-					MIMEType: fi.MimeType,
-					Data:     data,
-				}, embedding.TaskRetrievalDocument, config.Dimension)
+				vec, backend, err := e.embedContent(fi.MimeType, data, config)
 				if err != nil {
 					fmt.Fprintf(os.Stderr, "Warning: failed to embed %s: %v\n", fi.Path, err)
-					delete(finalChecksums, fi.Path)
+					delete(finalChecksums, key)
 					result.SkippedMultimodal++
 					continue
 				}
 				newMeta = append(newMeta, ChunkMeta{
-					FilePath:    fi.Path,
+					FilePath:    key,
 					StartOffset: 0,
 					Text:        fmt.Sprintf("[%s: %s]", ct, filepath.Base(fi.Path)),
 					ContentType: ct,
 					MIMEType:    fi.MimeType,
+					Backend:     backend,
 				})
 				newVecs = append(newVecs, vec)
 				result.MultimodalFiles++
-				finalChecksums[fi.Path] = newChecksums[fi.Path]
+				finalChecksums[key] = newChecksums[key]
 				continue
 			}
 
-			// Split and embed each segment
-			segments, err := mediautil.SplitMedia(fi.Path, fi.MimeType, maxDur)
-			if err != nil {
-				fmt.Fprintf(os.Stderr, "Warning: failed to split %s: %v\n", fi.Path, err)
-				delete(finalChecksums, fi.Path)
+			// Embed each segment
+			if splitErr != nil {
+				fmt.Fprintf(os.Stderr, "Warning: failed to split %s: %v\n", fi.Path, splitErr)
+				delete(finalChecksums, key)
 				result.SkippedMultimodal++
 				continue
 			}
 
 			embedded := 0
 			for _, seg := range segments {
-				vec, err := mmClient.EmbedMultimodalContent(config.Model, embedding.MultimodalContent{
-					MIMEType: fi.MimeType,
-					Data:     seg.Data,
-				}, embedding.TaskRetrievalDocument, config.Dimension)
+				vec, backend, err := e.embedContent(fi.MimeType, seg.Data, config)
 				if err != nil {
 					timeLabel := mediautil.FormatTimeLabel(seg.StartSec, seg.EndSec, seg.TotalSec)
 					fmt.Fprintf(os.Stderr, "Warning: failed to embed %s (%s): %v\n", fi.Path, timeLabel, err)
@@ -448,12 +694,13 @@ func (e *Engine) Index(dirs []string, excludePatterns []string, storeName string
 
 				timeLabel := mediautil.FormatTimeLabel(seg.StartSec, seg.EndSec, seg.TotalSec)
 				newMeta = append(newMeta, ChunkMeta{
-					FilePath:    fi.Path,
+					FilePath:    key,
 					StartOffset: 0,
 					Text:        fmt.Sprintf("[%s: %s (%s)]", ct, filepath.Base(fi.Path), timeLabel),
 					ContentType: ct,
 					MIMEType:    fi.MimeType,
 					PageLabel:   timeLabel,
+					Backend:     backend,
 				})
 				newVecs = append(newVecs, vec)
 				embedded++
@@ -461,39 +708,42 @@ func (e *Engine) Index(dirs []string, excludePatterns []string, storeName string
 
 			if embedded > 0 {
 				result.MultimodalFiles++
-				finalChecksums[fi.Path] = newChecksums[fi.Path]
+				finalChecksums[key] = newChecksums[key]
 			} else {
-				delete(finalChecksums, fi.Path)
+				delete(finalChecksums, key)
 				result.SkippedMultimodal++
 			}
 			continue
 		}
 
 		// Image and other multimodal: single embedding
-		vec, err := mmClient.EmbedMultimodalContent(config.Model, embedding.MultimodalContent{
-			MIMEType: fi.MimeType,
-			Data:     data,
-		}, embedding.TaskRetrievalDocument, config.Dimension)
+		vec, backend, err := e.embedContent(fi.MimeType, data, config)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "Warning: failed to embed %s: %v\n", fi.Path, err)
-			delete(finalChecksums, fi.Path)
+			delete(finalChecksums, key)
 			result.SkippedMultimodal++
 			continue
 		}
 
 		newMeta = append(newMeta, ChunkMeta{
-			FilePath:    fi.Path,
+			FilePath:    key,
 			StartOffset: 0,
 			Text:        fmt.Sprintf("[%s: %s]", ct, filepath.Base(fi.Path)),
 			ContentType: ct,
 			MIMEType:    fi.MimeType,
+			Backend:     backend,
 		})
 		newVecs = append(newVecs, vec)
 		result.MultimodalFiles++
-		finalChecksums[fi.Path] = newChecksums[fi.Path]
+		finalChecksums[key] = newChecksums[key]
 	}
 
 	for _, filePath := range textFiles {
+		// A PDF whose page images failed is retried whole next run
+		if incompleteFiles[filePath] || (bothPDFs[filePath] && finalChecksums[filePath] == "") {
+			delete(finalChecksums, filePath)
+			continue
+		}
 		finalChecksums[filePath] = newChecksums[filePath]
 	}
 
@@ -502,9 +752,10 @@ func (e *Engine) Index(dirs []string, excludePatterns []string, storeName string
 	allVecArrays := append(unchangedVecs, newVecs...)
 
 	// Determine dimension
-	dimension := config.Dimension
-	if len(allVecArrays) > 0 {
-		dimension = len(allVecArrays[0])
+	fitToStore(allVecArrays, existingIndex)
+	dimension, err := uniformDimension(allVecArrays, config.Dimension)
+	if err != nil {
+		return nil, err
 	}
 
 	// Build flat vector array
@@ -518,13 +769,21 @@ func (e *Engine) Index(dirs []string, excludePatterns []string, storeName string
 		Meta:           allMeta,
 		Dimension:      dimension,
 		FileChecksums:  finalChecksums,
-		EmbeddingModel: config.Model,
+		EmbeddingModel: modelName,
 		ChunkSize:      config.ChunkSize,
 		ChunkOverlap:   config.ChunkOverlap,
 		PDFMaxPages:    config.PDFMaxPages,
+		PDFChapters:    config.PDFChapters,
+		Roots:          roots.Roots,
+		Format:         format,
+		PromptTemplate: storedPromptTemplate(config.PromptTemplate),
+		Provider:       providerFor(existingIndex, config),
+	}
+	if existingIndex != nil {
+		index.EmbeddedDimension = existingIndex.EmbeddedDimension
 	}
 
-	if err := SaveIndex(storeName, index, flatVectors); err != nil {
+	if err := saveIndexToDir(indexDir, index, flatVectors); err != nil {
 		return nil, fmt.Errorf("failed to save index: %w", err)
 	}
 
@@ -534,6 +793,305 @@ func (e *Engine) Index(dirs []string, excludePatterns []string, storeName string
 	return result, nil
 }
 
+// detectRenames pairs paths that vanished from disk with newly discovered paths
+// that have the same content checksum. Files that merely fall outside the
+// current scan still exist on disk and are not considered vanished.
+func detectRenames(oldChecksums, newChecksums map[string]string, roots *RagIndex) []Rename {
+	vanishedByChecksum := make(map[string][]string)
+	for path, checksum := range oldChecksums {
+		if _, scanned := newChecksums[path]; scanned {
+			continue
+		}
+		if _, err := fileutil.Stat(roots.ResolvePath(path)); !os.IsNotExist(err) {
+			continue
+		}
+		vanishedByChecksum[checksum] = append(vanishedByChecksum[checksum], path)
+	}
+	if len(vanishedByChecksum) == 0 {
+		return nil
+	}
+
+	var added []string
+	for path := range newChecksums {
+		if _, exists := oldChecksums[path]; !exists {
+			added = append(added, path)
+		}
+	}
+	// Deterministic pairing when several copies share the same content
+	sort.Strings(added)
+	for _, paths := range vanishedByChecksum {
+		sort.Strings(paths)
+	}
+
+	var renames []Rename
+	for _, to := range added {
+		candidates := vanishedByChecksum[newChecksums[to]]
+		if len(candidates) == 0 {
+			continue
+		}
+		renames = append(renames, Rename{From: candidates[0], To: to})
+		vanishedByChecksum[newChecksums[to]] = candidates[1:]
+	}
+	return renames
+}
+
+// applyRename moves all chunks and the checksum entry of r.From to r.To
+func applyRename(index *RagIndex, r Rename) {
+	oldBase := filepath.Base(filepath.FromSlash(r.From))
+	newBase := filepath.Base(filepath.FromSlash(r.To))
+	for i, m := range index.Meta {
+		if m.FilePath != r.From {
+			continue
+		}
+		index.Meta[i].FilePath = r.To
+		// Multimodal chunk text carries the file name in its "[type: name ...]" header
+		if m.ContentType != "" {
+			header := "[" + m.ContentType + ": " + oldBase
+			if strings.HasPrefix(m.Text, header) {
+				index.Meta[i].Text = "[" + m.ContentType + ": " + newBase + m.Text[len(header):]
+			}
+		}
+	}
+	index.FileChecksums[r.To] = index.FileChecksums[r.From]
+	delete(index.FileChecksums, r.From)
+}
+
+// extractText returns the text to chunk for a file that isn't multimodal:
+// the text extracted from Office documents and HTML pages, else the content
+// itself, transcoded to UTF-8 and, for CSV, JSON and YAML, split into
+// records. It also returns the encoding the text was decoded from ("" for
+// UTF-8 and Office documents).
+func extractText(name string, data []byte, mimeType string, config Config) (*docutil.Document, string, error) {
+	if docutil.Supported(mimeType) {
+		doc, err := docutil.Extract(data, mimeType)
+		return doc, "", err
+	}
+	encoding := config.Encoding
+	if mimeType == docutil.HTMLMime {
+		// A page's own charset declaration beats detection, not --encoding.
+		// A <meta> can't declare UTF-16: the page would have to be ASCII to
+		// be read that far.
+		if name, _ := fileutil.LookupEncoding(encoding); name == "" {
+			declared, err := fileutil.LookupEncoding(docutil.HTMLCharset(data))
+			if err == nil && declared != fileutil.EncodingUTF16LE && declared != fileutil.EncodingUTF16BE {
+				encoding = declared
+			}
+		}
+	}
+	text, encoding, err := fileutil.DecodeText(data, encoding)
+	if err != nil {
+		return nil, "", err
+	}
+	if mimeType == docutil.HTMLMime {
+		doc, err := docutil.ExtractHTML([]byte(text), docutil.HTMLOptions{DropBoilerplate: config.DropHTMLBoilerplate})
+		return doc, encoding, err
+	}
+	if format := docutil.StructuredFormat(name, mimeType); format != "" {
+		opts := docutil.StructuredOptions{CSVRows: config.CSVRows, MaxSize: config.ChunkSize}
+		if doc, err := docutil.ExtractStructured(text, format, opts); err == nil {
+			return doc, encoding, nil
+		}
+		// Malformed data is chunked as plain text
+	}
+	return &docutil.Document{Text: text}, encoding, nil
+}
+
+// chunkSections chunks each section of an extracted document on its own, so
+// no chunk spans two slides or sheet ranges, and returns the section label
+// of every chunk. Offsets stay relative to the whole text. Without sections
+// it is ChunkText.
+func chunkSections(text string, sections []docutil.Section, chunkSize, chunkOverlap int) ([]Chunk, []string) {
+	if len(sections) == 0 {
+		chunks := ChunkText(text, chunkSize, chunkOverlap)
+		return chunks, make([]string, len(chunks))
+	}
+	var chunks []Chunk
+	var labels []string
+	for _, s := range sections {
+		for _, chunk := range ChunkText(text[s.Start:s.End], chunkSize, chunkOverlap) {
+			chunk.StartOffset += s.Start
+			chunks = append(chunks, chunk)
+			labels = append(labels, s.Label)
+		}
+	}
+	return chunks, labels
+}
+
+// buildEmbeddingText prefixes a text chunk with its file path and nearest
+// Markdown heading, which is what gets embedded for the chunk
+func buildEmbeddingText(filePath, content string, chunk Chunk) string {
+	heading := FindNearestHeading(content, chunk.StartOffset)
+	if heading != "" {
+		return fmt.Sprintf("[%s > %s]\n%s", filePath, heading, chunk.Text)
+	}
+	return fmt.Sprintf("[%s]\n%s", filePath, chunk.Text)
+}
+
+// sameModel reports whether two model names refer to the same model,
+// ignoring the "models/" prefix some tools record
+func sameModel(a, b string) bool {
+	return strings.TrimPrefix(a, "models/") == strings.TrimPrefix(b, "models/")
+}
+
+// checkStoreModel returns an error when an index that already holds chunks
+// was built with a different embedding model
+func checkStoreModel(index *RagIndex, model string) error {
+	if index == nil || len(index.Meta) == 0 || index.EmbeddingModel == "" || sameModel(index.EmbeddingModel, model) {
+		return nil
+	}
+	return fmt.Errorf("store was built with embedding model %s, not %s (use 'embed migrate' to re-embed it with another model)",
+		index.EmbeddingModel, model)
+}
+
+// checkStoreProvider returns an error when an index that already holds
+// chunks was recorded with a different embedding provider
+func checkStoreProvider(index *RagIndex, provider string) error {
+	if index == nil || len(index.Meta) == 0 || index.Provider == "" || provider == "" || index.Provider == provider {
+		return nil
+	}
+	return fmt.Errorf("store was built with embedding provider %s, not %s (use 'embed migrate' to re-embed it with another provider)",
+		index.Provider, provider)
+}
+
+// providerFor returns the provider to record in an index: the store's own
+// once it holds chunks and has one, else config's
+func providerFor(index *RagIndex, config Config) string {
+	if index != nil && len(index.Meta) > 0 && index.Provider != "" {
+		return index.Provider
+	}
+	return config.Provider
+}
+
+// checkStorePromptTemplate returns an error when config explicitly asks for a
+// prompt template other than the one an index holding chunks was built with
+func checkStorePromptTemplate(index *RagIndex, config Config) error {
+	if index == nil || len(index.Meta) == 0 || config.PromptTemplate == nil || samePromptTemplate(index.PromptTemplate, config.PromptTemplate) {
+		return nil
+	}
+	return fmt.Errorf("store was built with a different prompt template (use 'embed migrate' to re-embed it with another template)")
+}
+
+// promptTemplateFor returns the prompt template texts are embedded with: the
+// store's own once it holds chunks, else config's, else the table entry of
+// config.Model
+func promptTemplateFor(index *RagIndex, config Config) *embedding.PromptTemplate {
+	if index != nil && len(index.Meta) > 0 {
+		return index.PromptTemplate
+	}
+	if config.PromptTemplate != nil {
+		return storedPromptTemplate(config.PromptTemplate)
+	}
+	return embedding.LookupPromptTemplate(config.Model)
+}
+
+// storedPromptTemplate returns tpl, or nil if it adds no prefixes
+func storedPromptTemplate(tpl *embedding.PromptTemplate) *embedding.PromptTemplate {
+	if tpl.IsZero() {
+		return nil
+	}
+	return tpl
+}
+
+func samePromptTemplate(a, b *embedding.PromptTemplate) bool {
+	if a.IsZero() || b.IsZero() {
+		return a.IsZero() && b.IsZero()
+	}
+	return *a == *b
+}
+
+// uniformDimension returns the length shared by all vectors, or fallback if
+// there are none. Vectors of different sizes can't live in one store.
+func uniformDimension(vecs [][]float32, fallback int) (int, error) {
+	if len(vecs) == 0 {
+		return fallback, nil
+	}
+	dim := len(vecs[0])
+	for _, vec := range vecs[1:] {
+		if len(vec) != dim {
+			return 0, fmt.Errorf("embedding returned %d dimensions but the store has %d (use 'embed migrate' to change the dimension)",
+				len(vec), dim)
+		}
+	}
+	return dim, nil
+}
+
+// embedTexts embeds texts in batches of defaultBatchSize, with the document
+// prefix of config.PromptTemplate (already resolved), and records in metas
+// (parallel to texts) the backend that served each batch. Chunks the client
+// reports as failed (embedding.BatchError) are returned in failed by position
+// and have nil vectors; any other error aborts.
+func (e *Engine) embedTexts(texts []string, metas []ChunkMeta, config Config) ([][]float32, map[int]error, error) {
+	vecs := make([][]float32, 0, len(texts))
+	failed := make(map[int]error)
+	for i := 0; i < len(texts); i += defaultBatchSize {
+		end := i + defaultBatchSize
+		if end > len(texts) {
+			end = len(texts)
+		}
+
+		batch := make([]string, 0, end-i)
+		for _, text := range texts[i:end] {
+			batch = append(batch, config.PromptTemplate.Apply(embedding.TaskRetrievalDocument, text))
+		}
+
+		embeddings, backend, err := e.embedBatch(batch, config)
+		var batchErr *embedding.BatchError
+		if errors.As(err, &batchErr) {
+			for _, item := range batchErr.Items {
+				failed[i+item.Index] = item.Err
+			}
+		} else if err != nil {
+			return nil, nil, fmt.Errorf("failed to embed batch: %w", err)
+		}
+		if len(embeddings) != end-i {
+			return nil, nil, fmt.Errorf("failed to embed batch: got %d embeddings for %d texts", len(embeddings), end-i)
+		}
+		for j, vec := range embeddings {
+			if _, ok := failed[i+j]; !ok && len(vec) == 0 {
+				return nil, nil, fmt.Errorf("failed to embed batch: empty embedding for text %d", i+j)
+			}
+		}
+		for j := i; j < end; j++ {
+			metas[j].Backend = backend
+		}
+		vecs = append(vecs, embeddings...)
+	}
+	return vecs, failed, nil
+}
+
+// embedBatch embeds document texts and returns the backend that served them
+// when the client spreads requests over several (see
+// embedding.FallbackClient), else ""
+func (e *Engine) embedBatch(texts []string, config Config) ([][]float32, string, error) {
+	if reporter, ok := e.embeddingClient.(embedding.BackendReporter); ok {
+		return reporter.BatchEmbedContentsWithBackend(config.Model, texts, embedding.TaskRetrievalDocument, config.Dimension)
+	}
+	vecs, err := e.embeddingClient.BatchEmbedContents(config.Model, texts, embedding.TaskRetrievalDocument, config.Dimension)
+	return vecs, "", err
+}
+
+// embedContent embeds the binary content of a document like embedBatch. The
+// client must support it (see embedding.SupportsMultimodal).
+func (e *Engine) embedContent(mimeType string, data []byte, config Config) ([]float32, string, error) {
+	content := embedding.MultimodalContent{MIMEType: mimeType, Data: data}
+	if reporter, ok := e.embeddingClient.(embedding.BackendReporter); ok {
+		return reporter.EmbedMultimodalContentWithBackend(config.Model, content, embedding.TaskRetrievalDocument, config.Dimension)
+	}
+	vec, err := e.embeddingClient.(embedding.MultimodalEmbedder).EmbedMultimodalContent(config.Model, content, embedding.TaskRetrievalDocument, config.Dimension)
+	return vec, "", err
+}
+
+// firstItemError returns the error of the lowest failed position
+func firstItemError(failed map[int]error) error {
+	first := -1
+	for i := range failed {
+		if first < 0 || i < first {
+			first = i
+		}
+	}
+	return failed[first]
+}
+
 func allMetaFilePaths(meta []ChunkMeta) map[string]struct{} {
 	files := make(map[string]struct{}, len(meta))
 	for _, m := range meta {
@@ -544,6 +1102,50 @@ func allMetaFilePaths(meta []ChunkMeta) map[string]struct{} {
 
 // IndexContent indexes a single piece of content (for MCP use)
 func (e *Engine) IndexContent(storeName, fileName, content string, config Config) error {
+	doc := &docutil.Document{Text: content}
+
+	// HTML is indexed as its extracted text
+	if ext := strings.ToLower(filepath.Ext(fileName)); ext == ".html" || ext == ".htm" {
+		extracted, err := docutil.ExtractHTML([]byte(content), docutil.HTMLOptions{DropBoilerplate: config.DropHTMLBoilerplate})
+		if err != nil {
+			return fmt.Errorf("failed to extract text from %s: %w", fileName, err)
+		}
+		doc = extracted
+	}
+
+	// CSV, JSON and YAML are chunked by record, like their files
+	if format := docutil.StructuredFormat(fileName, ""); format != "" {
+		opts := docutil.StructuredOptions{CSVRows: config.CSVRows, MaxSize: config.ChunkSize}
+		if extracted, err := docutil.ExtractStructured(content, format, opts); err == nil {
+			doc = extracted
+		}
+	}
+	return e.indexDocument(storeName, fileName, doc, config)
+}
+
+// IndexDocumentContent indexes the text of a single DOCX, PPTX, XLSX or EPUB
+// document (for MCP use)
+func (e *Engine) IndexDocumentContent(storeName, fileName string, data []byte, mimeType string, config Config) error {
+	doc, err := docutil.Extract(data, mimeType)
+	if err != nil {
+		return fmt.Errorf("failed to extract text from %s: %w", fileName, err)
+	}
+	return e.indexDocument(storeName, fileName, doc, config)
+}
+
+// indexDocument chunks and embeds extracted text as the only chunks of
+// fileName in a store
+func (e *Engine) indexDocument(storeName, fileName string, doc *docutil.Document, config Config) error {
+	dir, err := storeDir(storeName)
+	if err != nil {
+		return err
+	}
+	lock, err := lockStore(dir, "upload of "+fileName)
+	if err != nil {
+		return err
+	}
+	defer lock.unlock()
+
 	// Load existing index
 	existingIndex, existingVectors, _ := LoadIndex(storeName)
 
@@ -552,13 +1154,16 @@ func (e *Engine) IndexContent(storeName, fileName, content string, config Config
 	dimension := config.Dimension
 
 	// Keep existing chunks from other files
-	if existingIndex != nil && existingVectors != nil {
-		// Check model compatibility
-		if existingIndex.EmbeddingModel != "" && existingIndex.EmbeddingModel != config.Model {
-			existingIndex = nil
-			existingVectors = nil
-		}
+	if err := checkStoreModel(existingIndex, config.Model); err != nil {
+		return err
+	}
+	if err := checkStoreProvider(existingIndex, config.Provider); err != nil {
+		return err
 	}
+	if err := checkStorePromptTemplate(existingIndex, config); err != nil {
+		return err
+	}
+	config.PromptTemplate = promptTemplateFor(existingIndex, config)
 
 	if existingIndex != nil && existingVectors != nil {
 		dim := existingIndex.Dimension
@@ -574,47 +1179,37 @@ func (e *Engine) IndexContent(storeName, fileName, content string, config Config
 	}
 
 	// Chunk new content
-	chunks := ChunkText(content, config.ChunkSize, config.ChunkOverlap)
+	chunks, labels := chunkSections(doc.Text, doc.Sections, config.ChunkSize, config.ChunkOverlap)
 
 	var texts []string
 	var metas []ChunkMeta
-	for _, chunk := range chunks {
-		heading := FindNearestHeading(content, chunk.StartOffset)
-		var embeddingText string
-		if heading != "" {
-			embeddingText = fmt.Sprintf("[%s > %s]\n%s", fileName, heading, chunk.Text)
-		} else {
-			embeddingText = fmt.Sprintf("[%s]\n%s", fileName, chunk.Text)
-		}
-		texts = append(texts, embeddingText)
+	for i, chunk := range chunks {
+		texts = append(texts, buildEmbeddingText(fileName, doc.Text, chunk))
 		metas = append(metas, ChunkMeta{
 			FilePath:    fileName,
 			StartOffset: chunk.StartOffset,
 			Text:        chunk.Text,
+			PageLabel:   labels[i],
 		})
 	}
 
-	// Batch embed
-	for i := 0; i < len(texts); i += defaultBatchSize {
-		end := i + defaultBatchSize
-		if end > len(texts) {
-			end = len(texts)
-		}
-		batch := texts[i:end]
-
-		embeddings, err := e.embeddingClient.BatchEmbedContents(config.Model, batch, embedding.TaskRetrievalDocument, config.Dimension)
-		if err != nil {
-			return fmt.Errorf("failed to embed batch: %w", err)
-		}
-
-		allVecs = append(allVecs, embeddings...)
+	embeddings, failed, err := e.embedTexts(texts, metas, config)
+	if err != nil {
+		return err
+	}
+	if len(failed) > 0 {
+		// The content can't be indexed partially: it has no source file to retry from
+		return fmt.Errorf("failed to embed %d of %d chunks of %s: %w", len(failed), len(texts), fileName, firstItemError(failed))
 	}
+	allVecs = append(allVecs, embeddings...)
 
 	allMeta = append(allMeta, metas...)
 
 	// Update dimension
-	if len(allVecs) > 0 {
-		dimension = len(allVecs[0])
+	fitToStore(allVecs, existingIndex)
+	dimension, err = uniformDimension(allVecs, dimension)
+	if err != nil {
+		return err
 	}
 
 	// Build flat vectors
@@ -642,6 +1237,17 @@ func (e *Engine) IndexContent(storeName, fileName, content string, config Config
 		ChunkSize:      config.ChunkSize,
 		ChunkOverlap:   config.ChunkOverlap,
 		PDFMaxPages:    config.PDFMaxPages,
+		PDFChapters:    config.PDFChapters,
+		PromptTemplate: storedPromptTemplate(config.PromptTemplate),
+		Provider:       providerFor(existingIndex, config),
+	}
+	if existingIndex != nil {
+		index.Roots = existingIndex.Roots
+		index.Format = existingIndex.Format
+		index.EmbeddedDimension = existingIndex.EmbeddedDimension
+		// No PDF was split: keep the settings the stored PDFs were split with
+		index.PDFMaxPages = existingIndex.PDFMaxPages
+		index.PDFChapters = existingIndex.PDFChapters
 	}
 
 	return SaveIndex(storeName, index, flatVectors)
@@ -649,10 +1255,18 @@ func (e *Engine) IndexContent(storeName, fileName, content string, config Config
 
 // IndexMultimodalContent indexes a single multimodal file (for MCP use)
 func (e *Engine) IndexMultimodalContent(storeName, fileName string, data []byte, mimeType string, config Config) error {
-	mmClient, ok := e.embeddingClient.(embedding.MultimodalEmbedder)
-	if !ok {
-		return fmt.Errorf("current embedding backend does not support multimodal content")
+	if !embedding.SupportsMultimodal(e.embeddingClient, config.Model, mimeType) {
+		return fmt.Errorf("current embedding backend does not support %s content", mimeType)
+	}
+	dir, err := storeDir(storeName)
+	if err != nil {
+		return err
+	}
+	lock, err := lockStore(dir, "upload of "+fileName)
+	if err != nil {
+		return err
 	}
+	defer lock.unlock()
 
 	// Load existing index
 	existingIndex, existingVectors, _ := LoadIndex(storeName)
@@ -661,11 +1275,11 @@ func (e *Engine) IndexMultimodalContent(storeName, fileName string, data []byte,
 	var allVecs [][]float32
 	dimension := config.Dimension
 
-	if existingIndex != nil && existingVectors != nil {
-		if existingIndex.EmbeddingModel != "" && existingIndex.EmbeddingModel != config.Model {
-			existingIndex = nil
-			existingVectors = nil
-		}
+	if err := checkStoreModel(existingIndex, config.Model); err != nil {
+		return err
+	}
+	if err := checkStoreProvider(existingIndex, config.Provider); err != nil {
+		return err
 	}
 
 	if existingIndex != nil && existingVectors != nil {
@@ -685,7 +1299,7 @@ func (e *Engine) IndexMultimodalContent(storeName, fileName string, data []byte,
 
 	// PDF: split into page chunks (configurable, max 6 pages per chunk)
 	if mimeType == "application/pdf" {
-		chunks, err := pdfutil.SplitPages(data, config.PDFMaxPages)
+		chunks, pdfMeta, err := splitPDF(fileName, data, config)
 		if err != nil {
 			return fmt.Errorf("failed to split PDF: %w", err)
 		}
@@ -697,37 +1311,27 @@ func (e *Engine) IndexMultimodalContent(storeName, fileName string, data []byte,
 		}
 
 		for _, chunk := range chunks {
-			vec, err := mmClient.EmbedMultimodalContent(config.Model, embedding.MultimodalContent{
-				MIMEType: mimeType,
-				Data:     chunk.Data,
-			}, embedding.TaskRetrievalDocument, config.Dimension)
+			vec, backend, err := e.embedContent(mimeType, chunk.Data, config)
 			if err != nil {
 				return fmt.Errorf("failed to embed PDF pages %d-%d: %w", chunk.StartPage, chunk.EndPage, err)
 			}
 
 			pageLabel := fmt.Sprintf("pages %d-%d of %d", chunk.StartPage, chunk.EndPage, chunk.TotalPages)
-
-			// Build text from extracted pages for this chunk range
-			chunkText := fmt.Sprintf("[%s: %s (%s)]", ct, fileName, pageLabel)
-			if pageTexts != nil {
-				var parts []string
-				for p := chunk.StartPage; p <= chunk.EndPage && p <= len(pageTexts); p++ {
-					if t := pageTexts[p-1]; t != "" {
-						parts = append(parts, t)
-					}
-				}
-				if len(parts) > 0 {
-					chunkText = chunkText + "\n\n" + strings.Join(parts, "\n\n")
-				}
+			var title string
+			var sections []string
+			if pdfMeta != nil {
+				title, sections = pdfMeta.Title, pdfMeta.Sections(chunk.StartPage, chunk.EndPage)
 			}
 
 			allMeta = append(allMeta, ChunkMeta{
 				FilePath:    fileName,
 				StartOffset: 0,
-				Text:        chunkText,
+				Text:        pdfChunkText(ct, fileName, pageLabel, chunk, pageTexts, title, sections),
 				ContentType: ct,
 				MIMEType:    mimeType,
 				PageLabel:   pageLabel,
+				Sections:    sections,
+				Backend:     backend,
 			})
 			allVecs = append(allVecs, vec)
 		}
@@ -753,10 +1357,7 @@ func (e *Engine) IndexMultimodalContent(storeName, fileName string, data []byte,
 		needsSplit, _, _, maxDur, probeErr := mediautil.NeedsSplit(tmpPath, mimeType)
 		if probeErr != nil || !needsSplit {
 			// Can't probe or short enough — embed directly
-			vec, err := mmClient.EmbedMultimodalContent(config.Model, embedding.MultimodalContent{
-				MIMEType: mimeType,
-				Data:     data,
-			}, embedding.TaskRetrievalDocument, config.Dimension)
+			vec, backend, err := e.embedContent(mimeType, data, config)
 			if err != nil {
 				return fmt.Errorf("failed to embed multimodal content: %w", err)
 			}
@@ -766,6 +1367,7 @@ func (e *Engine) IndexMultimodalContent(storeName, fileName string, data []byte,
 				Text:        fmt.Sprintf("[%s: %s]", ct, fileName),
 				ContentType: ct,
 				MIMEType:    mimeType,
+				Backend:     backend,
 			})
 			allVecs = append(allVecs, vec)
 		} else {
@@ -774,10 +1376,7 @@ func (e *Engine) IndexMultimodalContent(storeName, fileName string, data []byte,
 				return fmt.Errorf("failed to split media: %w", err)
 			}
 			for _, seg := range segments {
-				vec, err := mmClient.EmbedMultimodalContent(config.Model, embedding.MultimodalContent{
-					MIMEType: mimeType,
-					Data:     seg.Data,
-				}, embedding.TaskRetrievalDocument, config.Dimension)
+				vec, backend, err := e.embedContent(mimeType, seg.Data, config)
 				if err != nil {
 					return fmt.Errorf("failed to embed media segment %s: %w",
 						mediautil.FormatTimeLabel(seg.StartSec, seg.EndSec, seg.TotalSec), err)
@@ -790,16 +1389,14 @@ func (e *Engine) IndexMultimodalContent(storeName, fileName string, data []byte,
 					ContentType: ct,
 					MIMEType:    mimeType,
 					PageLabel:   timeLabel,
+					Backend:     backend,
 				})
 				allVecs = append(allVecs, vec)
 			}
 		}
 	} else {
 		// Image and other multimodal: single embedding
-		vec, err := mmClient.EmbedMultimodalContent(config.Model, embedding.MultimodalContent{
-			MIMEType: mimeType,
-			Data:     data,
-		}, embedding.TaskRetrievalDocument, config.Dimension)
+		vec, backend, err := e.embedContent(mimeType, data, config)
 		if err != nil {
 			return fmt.Errorf("failed to embed multimodal content: %w", err)
 		}
@@ -810,12 +1407,15 @@ func (e *Engine) IndexMultimodalContent(storeName, fileName string, data []byte,
 			Text:        fmt.Sprintf("[%s: %s]", ct, fileName),
 			ContentType: ct,
 			MIMEType:    mimeType,
+			Backend:     backend,
 		})
 		allVecs = append(allVecs, vec)
 	}
 
-	if len(allVecs) > 0 {
-		dimension = len(allVecs[0])
+	fitToStore(allVecs, existingIndex)
+	dimension, err = uniformDimension(allVecs, dimension)
+	if err != nil {
+		return err
 	}
 
 	flatVectors := make([]float32, len(allMeta)*dimension)
@@ -841,6 +1441,19 @@ func (e *Engine) IndexMultimodalContent(storeName, fileName string, data []byte,
 		ChunkSize:      config.ChunkSize,
 		ChunkOverlap:   config.ChunkOverlap,
 		PDFMaxPages:    config.PDFMaxPages,
+		PDFChapters:    config.PDFChapters,
+		PromptTemplate: storedPromptTemplate(promptTemplateFor(existingIndex, config)),
+		Provider:       providerFor(existingIndex, config),
+	}
+	if existingIndex != nil {
+		index.Roots = existingIndex.Roots
+		index.Format = existingIndex.Format
+		index.EmbeddedDimension = existingIndex.EmbeddedDimension
+		if mimeType != "application/pdf" {
+			// No PDF was split: keep the settings the stored PDFs were split with
+			index.PDFMaxPages = existingIndex.PDFMaxPages
+			index.PDFChapters = existingIndex.PDFChapters
+		}
 	}
 
 	return SaveIndex(storeName, index, flatVectors)
@@ -880,13 +1493,24 @@ func (e *Engine) queryIndex(question string, index *RagIndex, vectors []float32,
 		model = strings.TrimPrefix(index.EmbeddingModel, "models/")
 	}
 
-	// Embed query
-	queryVec, err := e.embeddingClient.EmbedContent(model, question, embedding.TaskRetrievalQuery, index.Dimension)
+	// Embed query, prefixed the way the store's documents were
+	queryText := index.PromptTemplate.Apply(embedding.TaskRetrievalQuery, question)
+	queryVec, err := e.embeddingClient.EmbedContent(model, queryText, embedding.TaskRetrievalQuery, index.Dimension)
 	if err != nil {
 		return nil, fmt.Errorf("failed to embed query: %w", err)
 	}
 
+	// Backends that ignore the requested dimension return full-size vectors
+	if len(queryVec) > index.Dimension && index.Dimension > 0 {
+		queryVec = truncateVector(queryVec, index.Dimension)
+	} else if len(queryVec) < index.Dimension {
+		return nil, fmt.Errorf("query embedding has %d dimensions but the store has %d", len(queryVec), index.Dimension)
+	}
+
 	// Search
+	if config.SearchDimension > 0 && config.SearchDimension < index.Dimension {
+		return SearchTruncated(queryVec, index, vectors, config.TopK, config.MinScore, config.SearchDimension), nil
+	}
 	results := Search(queryVec, index, vectors, config.TopK, config.MinScore)
 
 	return results, nil
@@ -894,6 +1518,12 @@ func (e *Engine) queryIndex(question string, index *RagIndex, vectors []float32,
 
 // DeleteFiles removes files matching a pattern from the index
 func (e *Engine) DeleteFiles(storeName, pattern string) (int, error) {
+	unlock, err := LockStore(storeName, "delete")
+	if err != nil {
+		return 0, err
+	}
+	defer unlock()
+
 	index, vectors, err := LoadIndex(storeName)
 	if err != nil {
 		return 0, fmt.Errorf("failed to load index: %w", err)
@@ -902,10 +1532,12 @@ func (e *Engine) DeleteFiles(storeName, pattern string) (int, error) {
 		return 0, fmt.Errorf("store '%s' not found", storeName)
 	}
 
-	// Find matching files
+	// Find matching files; root-anchored paths ("name:rel/path") also match
+	// by the absolute path they resolve to
 	matchedFiles := make(map[string]bool)
 	for filePath := range index.FileChecksums {
-		matched, _ := fileutil.FilterFilesByPattern([]fileutil.FileInfo{{Path: filePath}}, pattern)
+		candidates := []fileutil.FileInfo{{Path: filePath}, {Path: index.ResolvePath(filePath)}}
+		matched, _ := fileutil.FilterFilesByPattern(candidates, pattern)
 		if len(matched) > 0 {
 			matchedFiles[filePath] = true
 		}
@@ -943,10 +1575,19 @@ func (e *Engine) DeleteFiles(storeName, pattern string) (int, error) {
 	}
 
 	newIndex := &RagIndex{
-		Meta:           newMeta,
-		Dimension:      dim,
-		FileChecksums:  newChecksums,
-		EmbeddingModel: index.EmbeddingModel,
+		Meta:              newMeta,
+		Dimension:         dim,
+		FileChecksums:     newChecksums,
+		EmbeddingModel:    index.EmbeddingModel,
+		ChunkSize:         index.ChunkSize,
+		ChunkOverlap:      index.ChunkOverlap,
+		PDFMaxPages:       index.PDFMaxPages,
+		PDFChapters:       index.PDFChapters,
+		Roots:             index.Roots,
+		Format:            index.Format,
+		EmbeddedDimension: index.EmbeddedDimension,
+		PromptTemplate:    index.PromptTemplate,
+		Provider:          index.Provider,
 	}
 
 	if err := SaveIndex(storeName, newIndex, flatVectors); err != nil {
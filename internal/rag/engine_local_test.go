@@ -0,0 +1,455 @@
+package rag_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/text/encoding/japanese"
+
+	"github.com/takeshy/ragujuary/internal/embedding"
+	"github.com/takeshy/ragujuary/internal/rag"
+)
+
+// The local provider needs no API key, so these run the whole pipeline
+// (indexing, storage in both formats, search and verification) everywhere.
+
+func localEngine(t *testing.T) (*rag.Engine, rag.Config) {
+	t.Helper()
+	provider, err := embedding.LookupProvider("local")
+	if err != nil {
+		t.Fatalf("LookupProvider(local) error = %v", err)
+	}
+	client, err := provider.NewClient(embedding.ProviderOptions{})
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+	config := rag.DefaultConfig()
+	config.Provider = provider.Name
+	config.Model = provider.DefaultModel
+	config.Dimension = provider.DefaultDimension
+	config.ChunkSize = 500
+	config.ChunkOverlap = 50
+	config.MinScore = 0
+	return rag.NewEngine(client), config
+}
+
+func TestLocal_IndexQueryAndVerify(t *testing.T) {
+	for _, format := range []rag.IndexFormat{rag.FormatNative, rag.FormatExternal} {
+		t.Run(string(format), func(t *testing.T) {
+			home := t.TempDir()
+			t.Setenv("HOME", home)
+
+			docsDir := filepath.Join(home, "docs")
+			os.MkdirAll(docsDir, 0755)
+			os.WriteFile(filepath.Join(docsDir, "go.md"), []byte("# Go\nGo is a statically typed, compiled programming language designed at Google."), 0644)
+			os.WriteFile(filepath.Join(docsDir, "python.md"), []byte("# Python\nPython is an interpreted language with significant indentation."), 0644)
+
+			img := image.NewRGBA(image.Rect(0, 0, 4, 4))
+			img.Set(0, 0, color.RGBA{R: 255, A: 255})
+			var buf bytes.Buffer
+			png.Encode(&buf, img)
+			os.WriteFile(filepath.Join(docsDir, "red.png"), buf.Bytes(), 0644)
+
+			engine, config := localEngine(t)
+			config.IndexFormat = format
+
+			result, err := engine.Index([]string{docsDir}, nil, "local", config)
+			if err != nil {
+				t.Fatalf("Index() error = %v", err)
+			}
+			if result.IndexedFiles != 3 || result.MultimodalFiles != 1 {
+				t.Fatalf("indexed %d files (%d multimodal), want 3 (1)", result.IndexedFiles, result.MultimodalFiles)
+			}
+
+			index, vectors, err := rag.LoadIndex("local")
+			if err != nil {
+				t.Fatalf("LoadIndex() error = %v", err)
+			}
+			if (index.Format == rag.FormatExternal) != (format == rag.FormatExternal) || index.Provider != "local" || index.EmbeddingModel != embedding.LocalModel {
+				t.Fatalf("index format %q provider %q model %q", index.Format, index.Provider, index.EmbeddingModel)
+			}
+			if len(vectors) != len(index.Meta)*embedding.LocalDefaultDimension {
+				t.Fatalf("%d vector values for %d chunks", len(vectors), len(index.Meta))
+			}
+
+			results, err := engine.Query("compiled language from Google", "local", config)
+			if err != nil {
+				t.Fatalf("Query() error = %v", err)
+			}
+			if len(results) == 0 || !strings.HasSuffix(results[0].FilePath, "go.md") {
+				t.Fatalf("top result = %+v, want go.md", results)
+			}
+
+			// Unchanged files are skipped and the stored vectors re-embed identically
+			again, err := engine.Index([]string{docsDir}, nil, "local", config)
+			if err != nil {
+				t.Fatalf("re-Index() error = %v", err)
+			}
+			if again.SkippedFiles != 3 {
+				t.Fatalf("re-index skipped %d files, want 3", again.SkippedFiles)
+			}
+			report, err := engine.Verify("local", config, rag.VerifyOptions{CheckFiles: true, SampleEmbed: true})
+			if err != nil {
+				t.Fatalf("Verify() error = %v", err)
+			}
+			if report.Unresolved() != 0 {
+				t.Fatalf("Verify() issues = %+v", report.Issues)
+			}
+		})
+	}
+}
+
+func TestLocal_SkipsBinaryFiles(t *testing.T) {
+	docsDir := t.TempDir()
+	indexDir := t.TempDir()
+	os.WriteFile(filepath.Join(docsDir, "notes.md"), []byte("# Notes\nPlain text notes about the project."), 0644)
+	os.WriteFile(filepath.Join(docsDir, "blob.bin"), []byte{0x7f, 'E', 'L', 'F', 2, 1, 1, 0, 0, 0, 0, 0}, 0644)
+
+	// A PNG without an image extension is recognised by its signature
+	img := image.NewRGBA(image.Rect(0, 0, 4, 4))
+	var buf bytes.Buffer
+	png.Encode(&buf, img)
+	os.WriteFile(filepath.Join(docsDir, "picture.dat"), buf.Bytes(), 0644)
+
+	engine, config := localEngine(t)
+	result, err := engine.IndexDir([]string{docsDir}, nil, indexDir, config)
+	if err != nil {
+		t.Fatalf("IndexDir() error = %v", err)
+	}
+	if result.SkippedBinary != 1 || result.MultimodalFiles != 1 || result.IndexedFiles != 2 {
+		t.Fatalf("result = %+v, want 1 binary skipped, 1 multimodal and 2 indexed files", result)
+	}
+
+	// A file that turns binary loses the chunks it had
+	os.WriteFile(filepath.Join(docsDir, "notes.md"), []byte("\x00\x01\x02\x03 not text any more"), 0644)
+	result, err = engine.IndexDir([]string{docsDir}, nil, indexDir, config)
+	if err != nil {
+		t.Fatalf("IndexDir() error = %v", err)
+	}
+	if result.SkippedBinary != 2 {
+		t.Fatalf("SkippedBinary = %d, want 2", result.SkippedBinary)
+	}
+	index, _, err := rag.LoadIndexFromDir(indexDir)
+	if err != nil {
+		t.Fatalf("LoadIndexFromDir() error = %v", err)
+	}
+	for _, meta := range index.Meta {
+		if strings.HasSuffix(meta.FilePath, "notes.md") {
+			t.Fatalf("chunk of notes.md kept after it turned binary: %+v", meta)
+		}
+	}
+	if _, ok := index.FileChecksums["notes.md"]; ok {
+		t.Fatal("checksum of notes.md kept after it turned binary")
+	}
+}
+
+func TestLocal_IndexesArchiveEntries(t *testing.T) {
+	docsDir := t.TempDir()
+	indexDir := t.TempDir()
+	writeBundle := func(guide string) {
+		var buf bytes.Buffer
+		zw := zip.NewWriter(&buf)
+		for _, e := range [][2]string{
+			{"docs/guide.md", guide},
+			{"docs/faq.md", "# FAQ\n\nAsk the platform team."},
+			{"logo.bin", "\x00\x01\x02\x03"},
+		} {
+			w, _ := zw.Create(e[0])
+			w.Write([]byte(e[1]))
+		}
+		zw.Close()
+		os.WriteFile(filepath.Join(docsDir, "bundle.zip"), buf.Bytes(), 0644)
+	}
+	writeBundle("# Guide\n\nInstall the tool.")
+
+	engine, config := localEngine(t)
+	result, err := engine.IndexDir([]string{docsDir}, nil, indexDir, config)
+	if err != nil {
+		t.Fatalf("IndexDir() error = %v", err)
+	}
+	if result.NewFiles != 2 || result.SkippedBinary != 1 {
+		t.Fatalf("result = %+v, want 2 new entries and 1 binary", result)
+	}
+	index, _, err := rag.LoadIndexFromDir(indexDir)
+	if err != nil {
+		t.Fatalf("LoadIndexFromDir() error = %v", err)
+	}
+	guide := filepath.Join(docsDir, "bundle.zip") + "!/docs/guide.md"
+	if _, ok := index.FileChecksums[guide]; !ok {
+		t.Fatalf("checksums = %v, want one for %s", index.FileChecksums, guide)
+	}
+
+	// Only the entry that changed is re-embedded
+	writeBundle("# Guide\n\nInstall the tool, then run it.")
+	result, err = engine.IndexDir([]string{docsDir}, nil, indexDir, config)
+	if err != nil {
+		t.Fatalf("re-IndexDir() error = %v", err)
+	}
+	if result.UpdatedFiles != 1 || result.SkippedFiles != 1 || result.NewFiles != 0 {
+		t.Fatalf("re-index result = %+v, want 1 updated and 1 skipped", result)
+	}
+	index, _, _ = rag.LoadIndexFromDir(indexDir)
+	for _, meta := range index.Meta {
+		if meta.FilePath == guide && meta.Text != "# Guide\n\nInstall the tool, then run it." {
+			t.Errorf("guide chunk = %q", meta.Text)
+		}
+	}
+
+	report, err := engine.VerifyDir(indexDir, config, rag.VerifyOptions{CheckFiles: true, SampleEmbed: true})
+	if err != nil {
+		t.Fatalf("VerifyDir() error = %v", err)
+	}
+	if len(report.Issues) != 0 {
+		t.Fatalf("verify issues = %+v", report.Issues)
+	}
+}
+
+func TestLocal_IndexesOfficeDocumentsBySection(t *testing.T) {
+	docsDir := t.TempDir()
+	indexDir := t.TempDir()
+
+	const ns = `xmlns:p="http://schemas.openxmlformats.org/presentationml/2006/main" xmlns:a="http://schemas.openxmlformats.org/drawingml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"`
+	slide := func(text string) string {
+		return `<p:sld ` + ns + `><p:cSld><p:spTree><p:sp><p:txBody><a:p><a:r><a:t>` + text + `</a:t></a:r></a:p></p:txBody></p:sp></p:spTree></p:cSld></p:sld>`
+	}
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, content := range map[string]string{
+		"ppt/presentation.xml":            `<p:presentation ` + ns + `><p:sldIdLst><p:sldId id="256" r:id="rId1"/><p:sldId id="257" r:id="rId2"/></p:sldIdLst></p:presentation>`,
+		"ppt/_rels/presentation.xml.rels": `<Relationships><Relationship Id="rId1" Target="slides/slide1.xml"/><Relationship Id="rId2" Target="slides/slide2.xml"/></Relationships>`,
+		"ppt/slides/slide1.xml":           slide("Quarterly revenue grew in every region"),
+		"ppt/slides/slide2.xml":           slide("Hiring plans for the platform team"),
+	} {
+		w, _ := zw.Create(name)
+		w.Write([]byte(content))
+	}
+	zw.Close()
+	os.WriteFile(filepath.Join(docsDir, "review.pptx"), buf.Bytes(), 0644)
+
+	engine, config := localEngine(t)
+	result, err := engine.IndexDir([]string{docsDir}, nil, indexDir, config)
+	if err != nil {
+		t.Fatalf("IndexDir() error = %v", err)
+	}
+	if result.IndexedFiles != 1 || result.TotalChunks != 2 || result.SkippedBinary != 0 {
+		t.Fatalf("result = %+v, want 1 file in 2 chunks", result)
+	}
+
+	results, err := engine.QueryDir("hiring plans for the platform team", indexDir, config)
+	if err != nil {
+		t.Fatalf("QueryDir() error = %v", err)
+	}
+	if len(results) == 0 || results[0].PageLabel != "slide 2" || !strings.Contains(results[0].Text, "Hiring") {
+		t.Fatalf("top result = %+v, want slide 2", results)
+	}
+}
+
+func TestLocal_KeepsIndexedVersionOfCorruptDocument(t *testing.T) {
+	docsDir := t.TempDir()
+	indexDir := t.TempDir()
+
+	docx := func(parts map[string]string) []byte {
+		var buf bytes.Buffer
+		zw := zip.NewWriter(&buf)
+		for name, content := range parts {
+			w, _ := zw.Create(name)
+			w.Write([]byte(content))
+		}
+		zw.Close()
+		return buf.Bytes()
+	}
+	good := docx(map[string]string{
+		"word/document.xml": `<w:document xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main"><w:body><w:p><w:r><w:t>The budget was approved</w:t></w:r></w:p></w:body></w:document>`,
+	})
+	corrupt := docx(map[string]string{"docProps/core.xml": `<cp:coreProperties/>`}) // no word/document.xml
+	reportPath := filepath.Join(docsDir, "report.docx")
+	os.WriteFile(reportPath, good, 0644)
+
+	engine, config := localEngine(t)
+	if _, err := engine.IndexDir([]string{docsDir}, nil, indexDir, config); err != nil {
+		t.Fatalf("IndexDir() error = %v", err)
+	}
+	before, _, err := rag.LoadIndexFromDir(indexDir)
+	if err != nil {
+		t.Fatalf("LoadIndexFromDir() error = %v", err)
+	}
+
+	// Documents that can't be extracted are neither recorded nor allowed to
+	// drop the chunks of the version indexed before
+	os.WriteFile(reportPath, corrupt, 0644)
+	os.WriteFile(filepath.Join(docsDir, "bad.docx"), corrupt, 0644)
+	result, err := engine.IndexDir([]string{docsDir}, nil, indexDir, config)
+	if err != nil {
+		t.Fatalf("IndexDir() error = %v", err)
+	}
+	if result.NewFiles != 0 || result.UpdatedFiles != 0 || result.TotalChunks != len(before.Meta) {
+		t.Fatalf("result = %+v, want the old chunks kept and nothing new", result)
+	}
+	index, _, err := rag.LoadIndexFromDir(indexDir)
+	if err != nil {
+		t.Fatalf("LoadIndexFromDir() error = %v", err)
+	}
+	if _, ok := index.FileChecksums[filepath.Join(docsDir, "bad.docx")]; ok {
+		t.Error("bad.docx was recorded without chunks")
+	}
+	if index.FileChecksums[reportPath] != before.FileChecksums[reportPath] {
+		t.Error("report.docx checksum changed to that of the corrupt version")
+	}
+
+	// Once repaired, the document is indexed again
+	os.WriteFile(reportPath, docx(map[string]string{
+		"word/document.xml": `<w:document xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main"><w:body><w:p><w:r><w:t>The budget was cut</w:t></w:r></w:p></w:body></w:document>`,
+	}), 0644)
+	if result, err = engine.IndexDir([]string{docsDir}, nil, indexDir, config); err != nil {
+		t.Fatalf("IndexDir() error = %v", err)
+	}
+	if result.UpdatedFiles != 1 {
+		t.Fatalf("updated files = %d, want 1", result.UpdatedFiles)
+	}
+}
+
+func TestLocal_TranscodesJapaneseText(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	docsDir := filepath.Join(home, "docs")
+	os.MkdirAll(docsDir, 0755)
+	memo := "来週の会議は東京本社で行います。資料を準備してください。"
+	sjis, _ := japanese.ShiftJIS.NewEncoder().String(memo)
+	os.WriteFile(filepath.Join(docsDir, "memo.txt"), []byte(sjis), 0644)
+	page, _ := japanese.EUCJP.NewEncoder().String(`<html><head><meta charset="euc-jp"></head><body><h1>お知らせ</h1><p>年末年始は休業します。</p></body></html>`)
+	os.WriteFile(filepath.Join(docsDir, "news.html"), []byte(page), 0644)
+
+	engine, config := localEngine(t)
+	if _, err := engine.Index([]string{docsDir}, nil, "ja", config); err != nil {
+		t.Fatalf("Index() error = %v", err)
+	}
+	index, _, err := rag.LoadIndex("ja")
+	if err != nil {
+		t.Fatalf("LoadIndex() error = %v", err)
+	}
+	want := map[string][2]string{
+		"memo.txt":  {memo, "shift_jis"},
+		"news.html": {"# お知らせ\n\n年末年始は休業します。", "euc-jp"},
+	}
+	for _, meta := range index.Meta {
+		w := want[filepath.Base(meta.FilePath)]
+		if meta.Encoding != w[1] || (meta.StartOffset == 0 && meta.Text != w[0]) {
+			t.Errorf("%s chunk = %q (%q), want %q (%q)", meta.FilePath, meta.Text, meta.Encoding, w[0], w[1])
+		}
+	}
+
+	// Unchanged files decode the same way and stay as they are
+	result, err := engine.Index([]string{docsDir}, nil, "ja", config)
+	if err != nil {
+		t.Fatalf("re-Index() error = %v", err)
+	}
+	if result.SkippedFiles != 2 {
+		t.Fatalf("re-Index() skipped %d files, want 2", result.SkippedFiles)
+	}
+
+	// Forcing another encoding re-chunks the files it changes
+	config.Encoding = "euc-jp"
+	result, err = engine.Index([]string{docsDir}, nil, "ja", config)
+	if err != nil {
+		t.Fatalf("Index(euc-jp) error = %v", err)
+	}
+	if result.UpdatedFiles != 1 || result.SkippedFiles != 1 {
+		t.Fatalf("Index(euc-jp) updated %d and skipped %d files, want 1 and 1", result.UpdatedFiles, result.SkippedFiles)
+	}
+
+	config.Encoding = "klingon"
+	if _, err := engine.Index([]string{docsDir}, nil, "ja", config); err == nil {
+		t.Fatal("expected an error for an unknown encoding")
+	}
+}
+
+func TestLocal_IndexesHTMLAsText(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	docsDir := filepath.Join(home, "site")
+	os.MkdirAll(docsDir, 0755)
+	page := `<html><head><script>trackVisitor()</script></head><body>
+<nav>Home | Blog | About</nav>
+<h1>Deployment</h1><p>Deploy with <a href="/cli">the command line tool</a>.</p></body></html>`
+	os.WriteFile(filepath.Join(docsDir, "deploy.html"), []byte(page), 0644)
+
+	engine, config := localEngine(t)
+	config.DropHTMLBoilerplate = true
+	if _, err := engine.Index([]string{docsDir}, nil, "html", config); err != nil {
+		t.Fatalf("Index() error = %v", err)
+	}
+	if err := engine.IndexContent("html", "faq.htm", "<h2>FAQ</h2><p>Ask <em>anything</em>.</p>", config); err != nil {
+		t.Fatalf("IndexContent() error = %v", err)
+	}
+
+	index, _, err := rag.LoadIndex("html")
+	if err != nil {
+		t.Fatalf("LoadIndex() error = %v", err)
+	}
+	texts := make(map[string]string)
+	for _, meta := range index.Meta {
+		texts[filepath.Base(meta.FilePath)] += meta.Text
+	}
+	if got := texts["deploy.html"]; got != "# Deployment\n\nDeploy with the command line tool." {
+		t.Fatalf("deploy.html chunk text = %q", got)
+	}
+	if got := texts["faq.htm"]; got != "## FAQ\n\nAsk anything." {
+		t.Fatalf("faq.htm chunk text = %q", got)
+	}
+}
+
+func TestLocal_ChunksStructuredDataByRecord(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	docsDir := filepath.Join(home, "data")
+	os.MkdirAll(docsDir, 0755)
+	var csv strings.Builder
+	csv.WriteString("region,owner\n")
+	for _, region := range []string{"north", "south", "east", "west", "central"} {
+		csv.WriteString(region + "," + region + "-team\n")
+	}
+	os.WriteFile(filepath.Join(docsDir, "owners.csv"), []byte(csv.String()), 0644)
+	os.WriteFile(filepath.Join(docsDir, "deploy.json"), []byte(`{"service": "api", "replicas": [{"zone": "a"}, {"zone": "b"}]}`), 0644)
+
+	engine, config := localEngine(t)
+	config.CSVRows = 2
+	if _, err := engine.Index([]string{docsDir}, nil, "data", config); err != nil {
+		t.Fatalf("Index() error = %v", err)
+	}
+	if err := engine.IndexContent("data", "limits.yaml", "cpu: 2\nmemory: 4Gi\n", config); err != nil {
+		t.Fatalf("IndexContent() error = %v", err)
+	}
+
+	index, _, err := rag.LoadIndex("data")
+	if err != nil {
+		t.Fatalf("LoadIndex() error = %v", err)
+	}
+	got := make(map[string]string)
+	for _, meta := range index.Meta {
+		got[filepath.Base(meta.FilePath)+" "+meta.PageLabel] = meta.Text
+	}
+	want := map[string]string{
+		"owners.csv rows 2-3":    "region,owner\nnorth,north-team\nsouth,south-team",
+		"owners.csv rows 4-5":    "region,owner\neast,east-team\nwest,west-team",
+		"owners.csv row 6":       "region,owner\ncentral,central-team",
+		"deploy.json $.service":  `"service": "api"`,
+		"deploy.json $.replicas": `"replicas": [{"zone": "a"}, {"zone": "b"}]`,
+		"limits.yaml cpu":        "cpu: 2",
+		"limits.yaml memory":     "memory: 4Gi",
+	}
+	if len(got) != len(want) {
+		t.Fatalf("chunks = %q, want %q", got, want)
+	}
+	for label, text := range want {
+		if got[label] != text {
+			t.Errorf("%s chunk = %q, want %q", label, got[label], text)
+		}
+	}
+}
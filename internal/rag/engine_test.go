@@ -2,15 +2,21 @@ package rag
 
 import (
 	"bytes"
+	"errors"
+	"fmt"
 	"image"
 	"image/color"
 	"image/png"
 	"io"
 	"os"
 	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
 	"testing"
 
 	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu"
 	"github.com/takeshy/ragujuary/internal/embedding"
 )
 
@@ -74,6 +80,36 @@ func makeTestPDF(t *testing.T, pages int) []byte {
 	return pdfBuf.Bytes()
 }
 
+// makeTextPDF builds a PDF with a line of text on every page ("" leaves a
+// page blank)
+func makeTextPDF(t *testing.T, pages []string) []byte {
+	t.Helper()
+	var kids []string
+	objects := []string{"", "<< /Type /Catalog /Pages 2 0 R >>", "", "<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica >>"}
+	for _, text := range pages {
+		content := fmt.Sprintf("BT /F1 12 Tf 72 720 Td (%s) Tj ET", text)
+		objects = append(objects, fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", len(content), content))
+		objects = append(objects, fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 612 792] /Resources << /Font << /F1 3 0 R >> >> /Contents %d 0 R >>", len(objects)-1))
+		kids = append(kids, strconv.Itoa(len(objects)-1)+" 0 R")
+	}
+	objects[2] = fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(pages))
+
+	var buf bytes.Buffer
+	buf.WriteString("%PDF-1.4\n")
+	offsets := make([]int, len(objects))
+	for i := 1; i < len(objects); i++ {
+		offsets[i] = buf.Len()
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", i, objects[i])
+	}
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(objects))
+	for i := 1; i < len(objects); i++ {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", offsets[i])
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects), xref)
+	return buf.Bytes()
+}
+
 func TestIndexPreservesFilesOutsideCurrentScan(t *testing.T) {
 	home := t.TempDir()
 	t.Setenv("HOME", home)
@@ -282,6 +318,237 @@ func TestIndexReindexesOnlyPDFsWhenPDFMaxPagesChanges(t *testing.T) {
 	}
 }
 
+func TestIndexLabelsPDFChunksWithOutlineSections(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	docsDir := filepath.Join(home, "docs")
+	os.MkdirAll(docsDir, 0755)
+
+	var withOutline bytes.Buffer
+	bookmarks := []pdfcpu.Bookmark{
+		{Title: "Introduction", PageFrom: 1},
+		{Title: "Setup", PageFrom: 3, Kids: []pdfcpu.Bookmark{{Title: "Linux", PageFrom: 4}}},
+		{Title: "Usage", PageFrom: 6},
+	}
+	if err := api.AddBookmarks(bytes.NewReader(makeTestPDF(t, 7)), &withOutline, bookmarks, true, nil); err != nil {
+		t.Fatalf("AddBookmarks() error = %v", err)
+	}
+	pdfPath := filepath.Join(docsDir, "manual.pdf")
+	os.WriteFile(pdfPath, withOutline.Bytes(), 0644)
+
+	engine := NewEngine(fakeMultimodalClient{})
+	config := DefaultConfig()
+	config.Dimension = 4
+	config.PDFMaxPages = 4
+	if _, err := engine.Index([]string{docsDir}, nil, "outline-store", config); err != nil {
+		t.Fatalf("Index() error = %v", err)
+	}
+	index, _, err := LoadIndex("outline-store")
+	if err != nil {
+		t.Fatalf("LoadIndex() error = %v", err)
+	}
+	want := map[string][]string{
+		"pages 1-4 of 7": {"Introduction", "Setup", "Setup > Linux"},
+		"pages 5-7 of 7": {"Setup > Linux", "Usage"},
+	}
+	if len(index.Meta) != len(want) {
+		t.Fatalf("got %d chunks, want %d", len(index.Meta), len(want))
+	}
+	for _, meta := range index.Meta {
+		sections := want[meta.PageLabel]
+		if strings.Join(meta.Sections, "|") != strings.Join(sections, "|") {
+			t.Errorf("%s sections = %q, want %q", meta.PageLabel, meta.Sections, sections)
+		}
+		if !strings.Contains(meta.Text, "\nSection: "+sections[len(sections)-1]) {
+			t.Errorf("%s text = %q", meta.PageLabel, meta.Text)
+		}
+	}
+
+	// Chunks can also start at every chapter; switching it on re-splits the
+	// PDFs of an existing store
+	config.PDFChapters = true
+	result, err := engine.Index([]string{docsDir}, nil, "outline-store", config)
+	if err != nil {
+		t.Fatalf("Index(PDFChapters) error = %v", err)
+	}
+	if result.UpdatedFiles != 1 {
+		t.Fatalf("updated files = %d, want 1", result.UpdatedFiles)
+	}
+	index, _, err = LoadIndex("outline-store")
+	if err != nil {
+		t.Fatalf("LoadIndex() error = %v", err)
+	}
+	if !index.PDFChapters {
+		t.Error("PDFChapters was not recorded in the index")
+	}
+	var labels []string
+	for _, meta := range index.Meta {
+		labels = append(labels, meta.PageLabel)
+	}
+	if got := strings.Join(labels, ", "); got != "pages 1-2 of 7, pages 3-5 of 7, pages 6-7 of 7" {
+		t.Fatalf("chapter chunks = %s", got)
+	}
+
+	if result, err = engine.Index([]string{docsDir}, nil, "outline-store", config); err != nil || result.SkippedFiles != 1 {
+		t.Fatalf("Index(PDFChapters) again = %+v, %v, want the PDF skipped", result, err)
+	}
+
+	// Migration re-embeds the same page ranges
+	target := config
+	target.Model = "new-model"
+	migrated, err := engine.Migrate("outline-store", target, MigrateOptions{})
+	if err != nil {
+		t.Fatalf("Migrate() error = %v", err)
+	}
+	if migrated.EmbeddedChunks != 3 || len(migrated.DroppedFiles) != 0 {
+		t.Fatalf("migrated %d chunks, dropped %v", migrated.EmbeddedChunks, migrated.DroppedFiles)
+	}
+	if index, _, err = LoadIndex("outline-store"); err != nil || !index.PDFChapters {
+		t.Fatalf("migrated store lost PDFChapters (err %v)", err)
+	}
+
+	// Uploads that split no PDF keep the settings the stored PDFs were split with
+	upload := target
+	upload.PDFChapters = false
+	upload.PDFMaxPages = 6
+	if err := engine.IndexContent("outline-store", "note.md", "uploaded note", upload); err != nil {
+		t.Fatalf("IndexContent() error = %v", err)
+	}
+	if err := engine.IndexMultimodalContent("outline-store", "photo.png", []byte("png"), "image/png", upload); err != nil {
+		t.Fatalf("IndexMultimodalContent() error = %v", err)
+	}
+	if index, _, err = LoadIndex("outline-store"); err != nil || !index.PDFChapters || index.PDFMaxPages != 4 {
+		t.Fatalf("uploads changed the PDF split settings (err %v)", err)
+	}
+	if result, err = engine.Index([]string{docsDir}, nil, "outline-store", target); err != nil || result.SkippedFiles != 1 {
+		t.Fatalf("Index() after uploads = %+v, %v, want the PDF skipped", result, err)
+	}
+}
+
+func TestIndexLabelsTextPDFChunksWithOutlineSections(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	docsDir := filepath.Join(home, "docs")
+	os.MkdirAll(docsDir, 0755)
+
+	var withOutline bytes.Buffer
+	bookmarks := []pdfcpu.Bookmark{
+		{Title: "Install", PageFrom: 1, Kids: []pdfcpu.Bookmark{{Title: "Agent", PageFrom: 2}}},
+		{Title: "Operate", PageFrom: 3},
+	}
+	pdf := makeTextPDF(t, []string{"Install the agent", "Start the agent", "Rotate the keys"})
+	if err := api.AddBookmarks(bytes.NewReader(pdf), &withOutline, bookmarks, true, nil); err != nil {
+		t.Fatalf("AddBookmarks() error = %v", err)
+	}
+	os.WriteFile(filepath.Join(docsDir, "manual.pdf"), withOutline.Bytes(), 0644)
+
+	config := DefaultConfig()
+	config.Dimension = 4
+	config.PDFMode = PDFModeText
+	if _, err := NewEngine(fakeMultimodalClient{}).Index([]string{docsDir}, nil, "text-outline-store", config); err != nil {
+		t.Fatalf("Index() error = %v", err)
+	}
+	index, _, err := LoadIndex("text-outline-store")
+	if err != nil {
+		t.Fatalf("LoadIndex() error = %v", err)
+	}
+	want := map[string]string{
+		"page 1 of 3": "Install",
+		"page 2 of 3": "Install > Agent",
+		"page 3 of 3": "Operate",
+	}
+	if len(index.Meta) != len(want) {
+		t.Fatalf("got %d chunks, want %d", len(index.Meta), len(want))
+	}
+	for _, meta := range index.Meta {
+		if got := strings.Join(meta.Sections, "|"); got != want[meta.PageLabel] {
+			t.Errorf("%s sections = %q, want %q", meta.PageLabel, got, want[meta.PageLabel])
+		}
+	}
+}
+
+func TestIndexPDFModes(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	docsDir := filepath.Join(home, "docs")
+	os.MkdirAll(docsDir, 0755)
+	os.WriteFile(filepath.Join(docsDir, "manual.pdf"), makeTextPDF(t, []string{"Install the agent", "", "Rotate the keys"}), 0644)
+
+	engine := NewEngine(fakeMultimodalClient{})
+	config := DefaultConfig()
+	config.Dimension = 4
+	// chunks returns the stored chunks as "representation: label"
+	chunks := func() string {
+		index, vectors, err := LoadIndex("modes-store")
+		if err != nil {
+			t.Fatalf("LoadIndex() error = %v", err)
+		}
+		var got []string
+		for _, r := range Search(fakeVector("query", 4), index, vectors, 10, -1) {
+			got = append(got, r.Representation+": "+r.PageLabel)
+		}
+		sort.Strings(got)
+		return strings.Join(got, ", ")
+	}
+
+	config.PDFMode = PDFModeBoth
+	if _, err := engine.Index([]string{docsDir}, nil, "modes-store", config); err != nil {
+		t.Fatalf("Index(both) error = %v", err)
+	}
+	if got := chunks(); got != "text: page 1 of 3, text: page 3 of 3, visual: pages 1-3 of 3" {
+		t.Fatalf("both mode chunks = %s", got)
+	}
+	result, err := engine.Index([]string{docsDir}, nil, "modes-store", config)
+	if err != nil {
+		t.Fatalf("Index(both) again error = %v", err)
+	}
+	if result.SkippedFiles != 1 || result.UpdatedFiles != 0 {
+		t.Fatalf("unchanged PDF: skipped %d, updated %d", result.SkippedFiles, result.UpdatedFiles)
+	}
+
+	// Changing the mode re-embeds the PDF as the representations asked for
+	for _, tt := range []struct{ mode, want string }{
+		{PDFModeText, "text: page 1 of 3, text: page 3 of 3"},
+		{PDFModeAuto, "visual: pages 1-3 of 3"},
+		{PDFModeVisual, "visual: pages 1-3 of 3"},
+	} {
+		config.PDFMode = tt.mode
+		if _, err := engine.Index([]string{docsDir}, nil, "modes-store", config); err != nil {
+			t.Fatalf("Index(%s) error = %v", tt.mode, err)
+		}
+		if got := chunks(); got != tt.want {
+			t.Errorf("%s mode chunks = %s, want %s", tt.mode, got, tt.want)
+		}
+	}
+
+	// Text-only backends embed the text in both mode
+	config.PDFMode = PDFModeBoth
+	if _, err := NewEngine(fakeEmbeddingClient{}).Index([]string{docsDir}, nil, "modes-store", config); err != nil {
+		t.Fatalf("Index(both, text-only backend) error = %v", err)
+	}
+	if got := chunks(); got != "text: page 1 of 3, text: page 3 of 3" {
+		t.Errorf("both mode chunks on a text-only backend = %s", got)
+	}
+
+	// Scanned PDFs without text are embedded as page images in text mode too
+	scansDir := filepath.Join(home, "scans")
+	os.MkdirAll(scansDir, 0755)
+	os.WriteFile(filepath.Join(scansDir, "scan.pdf"), makeTestPDF(t, 2), 0644)
+	config.PDFMode = PDFModeText
+	result, err = engine.Index([]string{scansDir}, nil, "scans-store", config)
+	if err != nil {
+		t.Fatalf("Index(text, scan) error = %v", err)
+	}
+	if result.MultimodalFiles != 1 {
+		t.Errorf("scanned PDF multimodal files = %d, want 1", result.MultimodalFiles)
+	}
+
+	config.PDFMode = "images"
+	if _, err := engine.Index([]string{docsDir}, nil, "modes-store", config); err == nil {
+		t.Error("expected an error for an unknown PDF mode")
+	}
+}
+
 func TestIndexReindexesOnlyTextWhenChunkConfigChanges(t *testing.T) {
 	home := t.TempDir()
 	t.Setenv("HOME", home)
@@ -360,3 +627,366 @@ func TestIndexReindexesOnlyTextWhenChunkConfigChanges(t *testing.T) {
 		t.Fatalf("image chunk count = %d, want 1", imageChunks)
 	}
 }
+
+type countingEmbeddingClient struct {
+	fakeEmbeddingClient
+	calls *int
+}
+
+func (c countingEmbeddingClient) BatchEmbedContents(model string, texts []string, taskType embedding.TaskType, dimension int) ([][]float32, error) {
+	*c.calls += len(texts)
+	return c.fakeEmbeddingClient.BatchEmbedContents(model, texts, taskType, dimension)
+}
+
+func TestIndexDetectsRenamesWithoutReembedding(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	docsDir := filepath.Join(home, "docs")
+	if err := os.MkdirAll(filepath.Join(docsDir, "sub"), 0755); err != nil {
+		t.Fatalf("mkdir docs: %v", err)
+	}
+	oldPath := filepath.Join(docsDir, "old.txt")
+	keptPath := filepath.Join(docsDir, "kept.txt")
+	if err := os.WriteFile(oldPath, []byte("moving document"), 0644); err != nil {
+		t.Fatalf("write old: %v", err)
+	}
+	if err := os.WriteFile(keptPath, []byte("stable document"), 0644); err != nil {
+		t.Fatalf("write kept: %v", err)
+	}
+
+	calls := 0
+	engine := NewEngine(countingEmbeddingClient{calls: &calls})
+	config := DefaultConfig()
+	config.Dimension = 4
+
+	if _, err := engine.Index([]string{docsDir}, nil, "rename-store", config); err != nil {
+		t.Fatalf("first index: %v", err)
+	}
+
+	newPath := filepath.Join(docsDir, "sub", "new.txt")
+	if err := os.Rename(oldPath, newPath); err != nil {
+		t.Fatalf("rename: %v", err)
+	}
+
+	calls = 0
+	result, err := engine.Index([]string{docsDir}, nil, "rename-store", config)
+	if err != nil {
+		t.Fatalf("second index: %v", err)
+	}
+	if calls != 0 {
+		t.Fatalf("embedding calls after rename = %d, want 0", calls)
+	}
+	if result.RenamedFiles != 1 || result.NewFiles != 0 || result.SkippedFiles != 1 {
+		t.Fatalf("renamed=%d new=%d skipped=%d, want 1/0/1", result.RenamedFiles, result.NewFiles, result.SkippedFiles)
+	}
+	if result.Renames[0].From != oldPath || result.Renames[0].To != newPath {
+		t.Fatalf("renames = %+v", result.Renames)
+	}
+	if result.TotalChunks != 2 {
+		t.Fatalf("total chunks = %d, want 2 (no stale duplicates)", result.TotalChunks)
+	}
+
+	index, _, err := LoadIndex("rename-store")
+	if err != nil {
+		t.Fatalf("load index: %v", err)
+	}
+	if _, ok := index.FileChecksums[oldPath]; ok {
+		t.Fatal("old path still present in checksums")
+	}
+	if _, ok := index.FileChecksums[newPath]; !ok {
+		t.Fatal("new path missing from checksums")
+	}
+	for _, m := range index.Meta {
+		if m.FilePath == oldPath {
+			t.Fatal("chunk still points at old path")
+		}
+	}
+}
+
+// poisonedEmbeddingClient reports texts containing "poison" as failed batch items
+type poisonedEmbeddingClient struct {
+	fakeEmbeddingClient
+}
+
+func (c poisonedEmbeddingClient) BatchEmbedContents(model string, texts []string, taskType embedding.TaskType, dimension int) ([][]float32, error) {
+	vecs, _ := c.fakeEmbeddingClient.BatchEmbedContents(model, texts, taskType, dimension)
+	var batchErr embedding.BatchError
+	for i, text := range texts {
+		if strings.Contains(text, "poison") {
+			vecs[i] = nil
+			batchErr.Items = append(batchErr.Items, embedding.ItemError{Index: i, Err: errors.New("input too long")})
+		}
+	}
+	if len(batchErr.Items) > 0 {
+		return vecs, &batchErr
+	}
+	return vecs, nil
+}
+
+func TestIndexSkipsChunksThatFailToEmbed(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	docsDir := filepath.Join(home, "docs")
+	if err := os.MkdirAll(docsDir, 0755); err != nil {
+		t.Fatalf("mkdir docs: %v", err)
+	}
+	goodPath := filepath.Join(docsDir, "good.txt")
+	badPath := filepath.Join(docsDir, "bad.txt")
+	if err := os.WriteFile(goodPath, []byte("healthy text"), 0644); err != nil {
+		t.Fatalf("write good: %v", err)
+	}
+	if err := os.WriteFile(badPath, []byte("aaaa bbbb cccc dddd poison eeee"), 0644); err != nil {
+		t.Fatalf("write bad: %v", err)
+	}
+
+	config := DefaultConfig()
+	config.Dimension = 4
+	result, err := NewEngine(poisonedEmbeddingClient{}).Index([]string{docsDir}, nil, "poison-store", config)
+	if err != nil {
+		t.Fatalf("Index() error = %v", err)
+	}
+	if result.FailedChunks != 1 || result.TotalChunks != 1 {
+		t.Fatalf("failed=%d total=%d, want 1/1", result.FailedChunks, result.TotalChunks)
+	}
+
+	index, vectors, err := LoadIndex("poison-store")
+	if err != nil {
+		t.Fatalf("load index: %v", err)
+	}
+	if len(vectors) != len(index.Meta)*index.Dimension {
+		t.Fatalf("vectors = %d for %d chunks", len(vectors), len(index.Meta))
+	}
+	if _, ok := index.FileChecksums[badPath]; ok {
+		t.Fatal("file with a failed chunk kept its checksum")
+	}
+	if _, ok := index.FileChecksums[goodPath]; !ok {
+		t.Fatal("good file missing from checksums")
+	}
+
+	// The incomplete file is embedded again on the next run
+	second, err := NewEngine(fakeEmbeddingClient{}).Index([]string{docsDir}, nil, "poison-store", config)
+	if err != nil {
+		t.Fatalf("second index: %v", err)
+	}
+	if second.NewFiles != 1 || second.SkippedFiles != 1 || second.FailedChunks != 0 {
+		t.Fatalf("new=%d skipped=%d failed=%d, want 1/1/0", second.NewFiles, second.SkippedFiles, second.FailedChunks)
+	}
+}
+
+// imageOnlyClient embeds images but no other multimodal content, like an
+// image-capable Ollama model
+type imageOnlyClient struct {
+	fakeMultimodalClient
+}
+
+func (c imageOnlyClient) SupportsContent(model, mimeType string) bool {
+	return strings.HasPrefix(mimeType, "image/")
+}
+
+func TestIndexHonoursBackendContentFilter(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	docsDir := filepath.Join(home, "docs")
+	if err := os.MkdirAll(docsDir, 0755); err != nil {
+		t.Fatalf("mkdir docs: %v", err)
+	}
+	imagePath := filepath.Join(docsDir, "photo.png")
+	if err := os.WriteFile(imagePath, []byte{0x89, 'P', 'N', 'G', '\r', '\n', 0x1a, '\n'}, 0644); err != nil {
+		t.Fatalf("write image: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(docsDir, "paper.pdf"), makeTestPDF(t, 2), 0644); err != nil {
+		t.Fatalf("write pdf: %v", err)
+	}
+
+	config := DefaultConfig()
+	config.Dimension = 4
+	result, err := NewEngine(imageOnlyClient{}).Index([]string{docsDir}, nil, "filter-store", config)
+	if err != nil {
+		t.Fatalf("Index() error = %v", err)
+	}
+	if result.MultimodalFiles != 1 {
+		t.Fatalf("multimodal files = %d, want 1", result.MultimodalFiles)
+	}
+
+	index, _, err := LoadIndex("filter-store")
+	if err != nil {
+		t.Fatalf("load index: %v", err)
+	}
+	if len(index.Meta) != 1 || index.Meta[0].FilePath != imagePath || index.Meta[0].ContentType != "image" {
+		t.Fatalf("meta = %+v, want only the image", index.Meta)
+	}
+	// The PDF went through text extraction (it has no text), as with text-only backends
+	if _, ok := index.FileChecksums[filepath.Join(docsDir, "paper.pdf")]; !ok {
+		t.Fatal("PDF was not handled as text")
+	}
+}
+
+// recordingEmbeddingClient records the texts it is asked to embed
+type recordingEmbeddingClient struct {
+	fakeEmbeddingClient
+	texts *[]string
+}
+
+func (c recordingEmbeddingClient) EmbedContent(model, text string, taskType embedding.TaskType, dimension int) ([]float32, error) {
+	*c.texts = append(*c.texts, text)
+	return c.fakeEmbeddingClient.EmbedContent(model, text, taskType, dimension)
+}
+
+func (c recordingEmbeddingClient) BatchEmbedContents(model string, texts []string, taskType embedding.TaskType, dimension int) ([][]float32, error) {
+	*c.texts = append(*c.texts, texts...)
+	return c.fakeEmbeddingClient.BatchEmbedContents(model, texts, taskType, dimension)
+}
+
+func TestIndexAppliesModelPromptTemplate(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	docsDir := filepath.Join(home, "docs")
+	if err := os.MkdirAll(docsDir, 0755); err != nil {
+		t.Fatalf("mkdir docs: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(docsDir, "a.txt"), []byte("alpha"), 0644); err != nil {
+		t.Fatalf("write a.txt: %v", err)
+	}
+
+	var texts []string
+	engine := NewEngine(recordingEmbeddingClient{texts: &texts})
+	config := DefaultConfig()
+	config.Model = "nomic-embed-text"
+	config.Dimension = 4
+	if _, err := engine.Index([]string{docsDir}, nil, "prompt-store", config); err != nil {
+		t.Fatalf("Index() error = %v", err)
+	}
+	if len(texts) != 1 || !strings.HasPrefix(texts[0], "search_document: ") {
+		t.Fatalf("embedded texts = %q, want search_document: prefix", texts)
+	}
+
+	index, _, err := LoadIndex("prompt-store")
+	if err != nil {
+		t.Fatalf("load index: %v", err)
+	}
+	if index.PromptTemplate == nil || index.PromptTemplate.Query != "search_query: " {
+		t.Fatalf("stored template = %+v", index.PromptTemplate)
+	}
+
+	texts = nil
+	if _, err := engine.Query("what is alpha", "prompt-store", config); err != nil {
+		t.Fatalf("Query() error = %v", err)
+	}
+	if len(texts) != 1 || texts[0] != "search_query: what is alpha" {
+		t.Fatalf("query texts = %q", texts)
+	}
+
+	// An explicit template that differs from the store's needs a migration
+	config.PromptTemplate = &embedding.PromptTemplate{Query: "query: ", Document: "passage: "}
+	_, err = engine.Index([]string{docsDir}, nil, "prompt-store", config)
+	if err == nil || !strings.Contains(err.Error(), "embed migrate") {
+		t.Fatalf("Index() with other template error = %v, want migrate hint", err)
+	}
+}
+
+func TestIndexRecordsProvider(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	docsDir := filepath.Join(home, "docs")
+	if err := os.MkdirAll(docsDir, 0755); err != nil {
+		t.Fatalf("mkdir docs: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(docsDir, "a.txt"), []byte("alpha"), 0644); err != nil {
+		t.Fatalf("write a.txt: %v", err)
+	}
+
+	engine := NewEngine(fakeEmbeddingClient{})
+	config := DefaultConfig()
+	config.Provider = "voyage"
+	config.Dimension = 4
+	if _, err := engine.Index([]string{docsDir}, nil, "provider-store", config); err != nil {
+		t.Fatalf("Index() error = %v", err)
+	}
+
+	index, err := LoadIndexMetadata("provider-store")
+	if err != nil {
+		t.Fatalf("LoadIndexMetadata() error = %v", err)
+	}
+	if index.Provider != "voyage" {
+		t.Fatalf("provider = %q, want voyage", index.Provider)
+	}
+
+	// The store refuses chunks embedded by another provider
+	if err := os.WriteFile(filepath.Join(docsDir, "b.txt"), []byte("beta"), 0644); err != nil {
+		t.Fatalf("write b.txt: %v", err)
+	}
+	config.Provider = "cohere"
+	_, err = engine.Index([]string{docsDir}, nil, "provider-store", config)
+	if err == nil || !strings.Contains(err.Error(), "embed migrate") {
+		t.Fatalf("Index() with another provider error = %v, want migrate hint", err)
+	}
+	if err := engine.IndexContent("provider-store", "note.txt", "gamma", config); err == nil {
+		t.Fatal("IndexContent() with another provider succeeded")
+	}
+}
+
+type unavailableEmbeddingClient struct{}
+
+func (unavailableEmbeddingClient) EmbedContent(model, text string, taskType embedding.TaskType, dimension int) ([]float32, error) {
+	return nil, &embedding.StatusError{Op: "embed", StatusCode: 503}
+}
+
+func (unavailableEmbeddingClient) BatchEmbedContents(model string, texts []string, taskType embedding.TaskType, dimension int) ([][]float32, error) {
+	return nil, &embedding.StatusError{Op: "batch embed", StatusCode: 503}
+}
+
+func TestIndexRecordsFallbackBackend(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	docsDir := filepath.Join(home, "docs")
+	if err := os.MkdirAll(docsDir, 0755); err != nil {
+		t.Fatalf("mkdir docs: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(docsDir, "a.txt"), []byte("alpha"), 0644); err != nil {
+		t.Fatalf("write a.txt: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(docsDir, "b.png"), []byte("\x89PNG\r\n\x1a\nimage"), 0644); err != nil {
+		t.Fatalf("write b.png: %v", err)
+	}
+
+	chain := embedding.NewFallbackClient(
+		embedding.Backend{Name: "primary", Client: unavailableEmbeddingClient{}},
+		embedding.Backend{Name: "backup", Client: fakeMultimodalClient{}},
+	)
+	engine := NewEngine(chain)
+	config := DefaultConfig()
+	config.Dimension = 4
+	if _, err := engine.Index([]string{docsDir}, nil, "fallback-store", config); err != nil {
+		t.Fatalf("Index() error = %v", err)
+	}
+
+	index, err := LoadIndexMetadata("fallback-store")
+	if err != nil {
+		t.Fatalf("LoadIndexMetadata() error = %v", err)
+	}
+	if len(index.Meta) != 2 {
+		t.Fatalf("indexed %d chunks, want 2", len(index.Meta))
+	}
+	for _, meta := range index.Meta {
+		if meta.Backend != "backup" {
+			t.Fatalf("chunk of %s recorded backend %q, want backup", meta.FilePath, meta.Backend)
+		}
+	}
+
+	// Chunks embedded by a plain client record no backend
+	if err := NewEngine(fakeEmbeddingClient{}).IndexContent("fallback-store", "note.txt", "gamma", config); err != nil {
+		t.Fatalf("IndexContent() error = %v", err)
+	}
+	index, _ = LoadIndexMetadata("fallback-store")
+	for _, meta := range index.Meta {
+		if meta.FilePath == "note.txt" && meta.Backend != "" {
+			t.Fatalf("plain client recorded backend %q", meta.Backend)
+		}
+	}
+}
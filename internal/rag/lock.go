@@ -0,0 +1,126 @@
+package rag
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Operations that write a store hold a lock file next to it ("<dir>.lock"),
+// so that they don't overwrite each other's changes: indexing and uploads
+// wait for a migration, compaction or import instead of writing to a store
+// that is about to be swapped out. Readers never lock.
+const lockSuffix = ".lock"
+
+var (
+	lockRefresh = 5 * time.Second        // how often the holder touches its lock file
+	lockStale   = 30 * time.Second       // a lock not touched for this long was left by a process that died
+	lockWait    = 30 * time.Second       // how long to wait for a busy store
+	lockPoll    = 100 * time.Millisecond // how often to check a busy store
+)
+
+// staleLocks numbers the names stale lock files are moved aside to
+var staleLocks atomic.Int64
+
+// storeLock is a held store lock
+type storeLock struct {
+	path string
+	stop chan struct{}
+	done chan struct{}
+}
+
+// LockStore locks a store for an operation that writes it (e.g. "embed
+// roots set"), waiting for another holder to finish. The returned function
+// releases the lock.
+func LockStore(storeName, operation string) (func(), error) {
+	dir, err := storeDir(storeName)
+	if err != nil {
+		return nil, err
+	}
+	lock, err := lockStore(dir, operation)
+	if err != nil {
+		return nil, err
+	}
+	return lock.unlock, nil
+}
+
+// lockStore locks the store or index in dir for operation, waiting up to
+// lockWait for another holder to release it
+func lockStore(dir, operation string) (*storeLock, error) {
+	path := filepath.Clean(dir) + lockSuffix
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create store directory: %w", err)
+	}
+	deadline := time.Now().Add(lockWait)
+	for {
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if err == nil {
+			fmt.Fprintf(f, "%s (pid %d)\n", operation, os.Getpid())
+			f.Close()
+			lock := &storeLock{path: path, stop: make(chan struct{}), done: make(chan struct{})}
+			go lock.refresh()
+			return lock, nil
+		}
+		if !os.IsExist(err) {
+			return nil, fmt.Errorf("failed to lock %s: %w", dir, err)
+		}
+		if removeStaleLock(path) {
+			continue
+		}
+		if time.Now().After(deadline) {
+			holder, _ := os.ReadFile(path)
+			return nil, fmt.Errorf("%s is busy (locked by %s); try again when that has finished", dir, strings.TrimSpace(string(holder)))
+		}
+		time.Sleep(lockPoll)
+	}
+}
+
+// refresh touches the lock file until the lock is released, so that waiters
+// can tell it from one left by a process that died
+func (l *storeLock) refresh() {
+	defer close(l.done)
+	ticker := time.NewTicker(lockRefresh)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-l.stop:
+			return
+		case <-ticker.C:
+			now := time.Now()
+			os.Chtimes(l.path, now, now)
+		}
+	}
+}
+
+// unlock releases the lock
+func (l *storeLock) unlock() {
+	close(l.stop)
+	<-l.done
+	os.Remove(l.path)
+}
+
+// removeStaleLock removes the lock file at path if its holder stopped
+// refreshing it, and reports whether the lock is free to take
+func removeStaleLock(path string) bool {
+	info, err := os.Stat(path)
+	if err != nil {
+		return os.IsNotExist(err) // released meanwhile
+	}
+	if time.Since(info.ModTime()) < lockStale {
+		return false
+	}
+	// Moved aside first, so that of several waiters only one takes it over
+	aside := fmt.Sprintf("%s.stale-%d-%d", path, os.Getpid(), staleLocks.Add(1))
+	if err := os.Rename(path, aside); err != nil {
+		return false
+	}
+	if info, err := os.Stat(aside); err == nil && time.Since(info.ModTime()) < lockStale {
+		os.Rename(aside, path) // another waiter took it over and holds it now
+		return false
+	}
+	os.Remove(aside)
+	return true
+}
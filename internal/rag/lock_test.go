@@ -0,0 +1,89 @@
+package rag
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func shortLockWait(t *testing.T) {
+	t.Helper()
+	wait, poll := lockWait, lockPoll
+	lockWait, lockPoll = 200*time.Millisecond, 10*time.Millisecond
+	t.Cleanup(func() { lockWait, lockPoll = wait, poll })
+}
+
+func TestLockStoreBlocksOtherWriters(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	shortLockWait(t)
+
+	unlock, err := LockStore("docs", "embed migrate")
+	if err != nil {
+		t.Fatalf("LockStore: %v", err)
+	}
+	err = CreateEmptyIndex("docs")
+	if err == nil || !strings.Contains(err.Error(), "embed migrate") {
+		t.Fatalf("expected the store to be busy with the migration, got %v", err)
+	}
+
+	unlock()
+	if err := CreateEmptyIndex("docs"); err != nil {
+		t.Fatalf("CreateEmptyIndex after unlock: %v", err)
+	}
+	dir, _ := storeDir("docs")
+	if _, err := os.Stat(dir + lockSuffix); !os.IsNotExist(err) {
+		t.Errorf("lock file left behind: %v", err)
+	}
+}
+
+func TestLockStoreTakesOverStaleLock(t *testing.T) {
+	shortLockWait(t)
+	dir := filepath.Join(t.TempDir(), "store")
+	path := dir + lockSuffix
+	if err := os.WriteFile(path, []byte("embed index (pid 1)\n"), 0644); err != nil {
+		t.Fatalf("write lock: %v", err)
+	}
+	old := time.Now().Add(-2 * lockStale)
+	if err := os.Chtimes(path, old, old); err != nil {
+		t.Fatalf("chtimes: %v", err)
+	}
+
+	lock, err := lockStore(dir, "upload")
+	if err != nil {
+		t.Fatalf("stale lock was not taken over: %v", err)
+	}
+	defer lock.unlock()
+	holder, _ := os.ReadFile(path)
+	if !strings.HasPrefix(string(holder), "upload") {
+		t.Errorf("lock file = %q, want it held by the upload", holder)
+	}
+}
+
+func TestLoadIndexDuringSwap(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "store")
+	index := &RagIndex{
+		Meta:          []ChunkMeta{{FilePath: "a.md", Text: "alpha"}},
+		FileChecksums: map[string]string{"a.md": "x"},
+		Dimension:     2,
+	}
+	if err := saveIndexToDir(dir, index, []float32{1, 0}); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	// The moment between swapStoreDir's two renames
+	if err := os.Rename(dir, dir+swapSuffix); err != nil {
+		t.Fatalf("rename: %v", err)
+	}
+
+	loaded, vectors, err := LoadIndexFromDir(dir)
+	if err != nil {
+		t.Fatalf("LoadIndexFromDir: %v", err)
+	}
+	if loaded == nil || len(loaded.Meta) != 1 || len(vectors) != 2 {
+		t.Fatalf("expected the old store to be read while it is swapped out, got %+v", loaded)
+	}
+	if meta, err := LoadIndexMetadataFromDir(dir); err != nil || meta == nil {
+		t.Errorf("LoadIndexMetadataFromDir = %v, %v", meta, err)
+	}
+}
@@ -0,0 +1,514 @@
+package rag
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/takeshy/ragujuary/internal/embedding"
+	"github.com/takeshy/ragujuary/internal/fileutil"
+	"github.com/takeshy/ragujuary/internal/mediautil"
+	"github.com/takeshy/ragujuary/internal/pdfutil"
+)
+
+const (
+	migratingSuffix    = ".migrating" // shadow index being built next to the store
+	swapSuffix         = ".swap-old"  // previous store while the shadow is moved into place
+	migrationStateFile = "migrate.json"
+)
+
+// migrateCheckpointChunks is how many chunks are embedded between checkpoints
+var migrateCheckpointChunks = 256
+
+// MigrateOptions controls a store migration
+type MigrateOptions struct {
+	Restart  bool                  // discard an interrupted migration instead of resuming it
+	Progress func(done, total int) // called after each checkpoint with chunks processed so far
+}
+
+// MigrateResult holds the result of a store migration
+type MigrateResult struct {
+	FromModel      string
+	FromDimension  int
+	ToModel        string
+	ToDimension    int
+	TotalChunks    int
+	ResumedChunks  int      // chunks carried over from an interrupted run
+	EmbeddedChunks int      // chunks embedded in this run
+	DroppedFiles   []string // files whose chunks could not be re-embedded
+}
+
+// migrationState is persisted in the shadow directory so an interrupted
+// migration can pick up where it stopped
+type migrationState struct {
+	Source       string                    `json:"source"` // sha256 of the source index.json
+	Model        string                    `json:"model"`
+	Provider     string                    `json:"provider,omitempty"`
+	Dimension    int                       `json:"dimension"`
+	Template     *embedding.PromptTemplate `json:"prompt_template,omitempty"`
+	DoneFiles    []string                  `json:"done_files"`
+	DroppedFiles []string                  `json:"dropped_files,omitempty"`
+}
+
+// Migrate re-embeds every chunk of a store with config.Model and
+// config.Dimension. Text chunks are re-embedded from their stored text;
+// multimodal chunks are re-embedded from their source files. The new index is
+// built in a shadow directory, so queries keep using the old index until it
+// is swapped in. An interrupted migration resumes from its last checkpoint.
+func (e *Engine) Migrate(storeName string, config Config, opts MigrateOptions) (*MigrateResult, error) {
+	dir, err := storeDir(storeName)
+	if err != nil {
+		return nil, err
+	}
+	// Held until the swap, so that no upload lands in the store being replaced
+	lock, err := lockStore(dir, "embed migrate")
+	if err != nil {
+		return nil, err
+	}
+	defer lock.unlock()
+
+	source, _, err := LoadIndexFromDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load index: %w", err)
+	}
+	if source == nil {
+		return nil, fmt.Errorf("store '%s' not found", storeName)
+	}
+	// The target template comes from config or the table, never from the source
+	config.PromptTemplate = promptTemplateFor(nil, config)
+	if config.Provider == "" {
+		config.Provider = source.Provider
+	}
+	if sameModel(source.EmbeddingModel, config.Model) && source.Dimension == config.Dimension &&
+		samePromptTemplate(source.PromptTemplate, config.PromptTemplate) &&
+		(source.Provider == "" || source.Provider == config.Provider) {
+		return nil, fmt.Errorf("store '%s' already uses %s with dimension %d", storeName, source.EmbeddingModel, source.Dimension)
+	}
+	fingerprint, err := indexFingerprint(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	shadowDir := filepath.Clean(dir) + migratingSuffix
+	if opts.Restart {
+		if err := os.RemoveAll(shadowDir); err != nil {
+			return nil, fmt.Errorf("failed to remove previous migration: %w", err)
+		}
+	}
+	state, shadow, shadowVectors := loadMigration(shadowDir, fingerprint, config)
+	if state == nil {
+		if err := os.RemoveAll(shadowDir); err != nil {
+			return nil, fmt.Errorf("failed to remove stale migration: %w", err)
+		}
+		state = &migrationState{Source: fingerprint, Model: config.Model, Provider: config.Provider, Dimension: config.Dimension, Template: config.PromptTemplate}
+		shadow = &RagIndex{Meta: []ChunkMeta{}, FileChecksums: make(map[string]string)}
+	}
+
+	result := &MigrateResult{
+		FromModel:     source.EmbeddingModel,
+		FromDimension: source.Dimension,
+		ToModel:       config.Model,
+		TotalChunks:   len(source.Meta),
+		ResumedChunks: len(shadow.Meta),
+	}
+
+	done := make(map[string]bool, len(state.DoneFiles))
+	for _, path := range state.DoneFiles {
+		done[path] = true
+	}
+	for _, path := range state.DroppedFiles {
+		done[path] = true
+	}
+
+	// Group chunks by file, in the order files first appear
+	var files []string
+	chunksByFile := make(map[string][]int)
+	for i, meta := range source.Meta {
+		if _, ok := chunksByFile[meta.FilePath]; !ok {
+			files = append(files, meta.FilePath)
+		}
+		chunksByFile[meta.FilePath] = append(chunksByFile[meta.FilePath], i)
+	}
+
+	var newVecs [][]float32
+	dim := shadow.Dimension
+	for i := 0; dim > 0 && i < len(shadow.Meta); i++ {
+		newVecs = append(newVecs, shadowVectors[i*dim:(i+1)*dim])
+	}
+
+	checkpoint := func() error {
+		dimension, err := uniformDimension(newVecs, config.Dimension)
+		if err != nil {
+			return err
+		}
+		shadow.Dimension = dimension
+		flat := make([]float32, 0, len(newVecs)*dimension)
+		for _, vec := range newVecs {
+			flat = append(flat, vec...)
+		}
+		if err := saveIndexToDir(shadowDir, shadow, flat); err != nil {
+			return fmt.Errorf("failed to save migration checkpoint: %w", err)
+		}
+		data, err := json.Marshal(state)
+		if err != nil {
+			return fmt.Errorf("failed to marshal migration state: %w", err)
+		}
+		if err := os.WriteFile(filepath.Join(shadowDir, migrationStateFile), data, 0644); err != nil {
+			return fmt.Errorf("failed to save migration state: %w", err)
+		}
+		if opts.Progress != nil {
+			processed := 0
+			for path := range done {
+				processed += len(chunksByFile[path])
+			}
+			opts.Progress(processed, len(source.Meta))
+		}
+		return nil
+	}
+
+	sinceCheckpoint := 0
+	for _, path := range files {
+		if done[path] {
+			continue
+		}
+		metas, vecs, err := e.reembedFile(source, chunksByFile[path], config)
+		if err != nil {
+			return nil, err
+		}
+		if vecs == nil {
+			state.DroppedFiles = append(state.DroppedFiles, path)
+		} else {
+			shadow.Meta = append(shadow.Meta, metas...)
+			newVecs = append(newVecs, vecs...)
+			if checksum, ok := source.FileChecksums[path]; ok {
+				shadow.FileChecksums[path] = checksum
+			}
+			state.DoneFiles = append(state.DoneFiles, path)
+			result.EmbeddedChunks += len(vecs)
+			sinceCheckpoint += len(vecs)
+		}
+		done[path] = true
+
+		if sinceCheckpoint >= migrateCheckpointChunks {
+			if err := checkpoint(); err != nil {
+				return nil, err
+			}
+			sinceCheckpoint = 0
+		}
+	}
+	if err := checkpoint(); err != nil {
+		return nil, err
+	}
+	result.DroppedFiles = state.DroppedFiles
+
+	// The store must not have changed while we were embedding
+	current, err := indexFingerprint(dir)
+	if err != nil {
+		return nil, err
+	}
+	if current != fingerprint {
+		return nil, fmt.Errorf("store '%s' was modified during the migration; run migrate again", storeName)
+	}
+
+	// Checksums of files without chunks (e.g. empty files) carry over unchanged;
+	// dropped files lose theirs so the next index run re-embeds them
+	for path, checksum := range source.FileChecksums {
+		if _, ok := chunksByFile[path]; !ok {
+			shadow.FileChecksums[path] = checksum
+		}
+	}
+
+	shadow.EmbeddingModel = config.Model
+	shadow.Provider = config.Provider
+	shadow.PromptTemplate = config.PromptTemplate
+	shadow.ChunkSize = source.ChunkSize
+	shadow.ChunkOverlap = source.ChunkOverlap
+	shadow.PDFMaxPages = source.PDFMaxPages
+	shadow.PDFChapters = source.PDFChapters
+	shadow.Roots = source.Roots
+	shadow.Format = source.Format
+	if err := checkpoint(); err != nil {
+		return nil, err
+	}
+	if err := os.Remove(filepath.Join(shadowDir, migrationStateFile)); err != nil {
+		return nil, fmt.Errorf("failed to remove migration state: %w", err)
+	}
+	if err := swapStoreDir(dir, shadowDir); err != nil {
+		return nil, err
+	}
+
+	result.ToDimension = shadow.Dimension
+	return result, nil
+}
+
+// reembedFile embeds the given chunks of one file with the target model.
+// It returns nil vectors when the file's chunks can't be re-embedded (the
+// source of a multimodal chunk is gone or changed, or the backend is text-only).
+func (e *Engine) reembedFile(source *RagIndex, chunks []int, config Config) ([]ChunkMeta, [][]float32, error) {
+	path := source.Meta[chunks[0]].FilePath
+	metas := make([]ChunkMeta, 0, len(chunks))
+	for _, i := range chunks {
+		meta := source.Meta[i]
+		meta.Backend = ""
+		metas = append(metas, meta)
+	}
+
+	var textChunks []int
+	var mmChunks []int
+	for i, meta := range metas {
+		if meta.ContentType == "" {
+			textChunks = append(textChunks, i)
+		} else {
+			mmChunks = append(mmChunks, i)
+		}
+	}
+
+	vecs := make([][]float32, len(metas))
+
+	if len(textChunks) > 0 {
+		// Decode the source the way its chunks were decoded
+		sourceConfig := config
+		sourceConfig.Encoding = metas[textChunks[0]].Encoding
+		content := unchangedSourceText(source, path, sourceConfig)
+		texts := make([]string, 0, len(textChunks))
+		textMetas := make([]ChunkMeta, 0, len(textChunks))
+		for _, i := range textChunks {
+			texts = append(texts, buildEmbeddingText(path, content, Chunk{Text: metas[i].Text, StartOffset: metas[i].StartOffset}))
+			textMetas = append(textMetas, metas[i])
+		}
+		embeddings, failed, err := e.embedTexts(texts, textMetas, config)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to embed chunks of %s: %w", path, err)
+		}
+		if len(failed) > 0 {
+			fmt.Fprintf(os.Stderr, "Warning: dropping %s (%d chunks could not be embedded: %v); run embed index to re-add it\n", path, len(failed), firstItemError(failed))
+			return nil, nil, nil
+		}
+		for j, vec := range embeddings {
+			vecs[textChunks[j]] = vec
+			metas[textChunks[j]].Backend = textMetas[j].Backend
+		}
+	}
+
+	if len(mmChunks) > 0 {
+		mmVecs, reason := e.reembedMultimodal(source, path, metas, mmChunks, config)
+		if mmVecs == nil {
+			fmt.Fprintf(os.Stderr, "Warning: dropping %s (%s); run embed index to re-add it\n", path, reason)
+			return nil, nil, nil
+		}
+		for j, i := range mmChunks {
+			vecs[i] = mmVecs[j]
+		}
+	}
+
+	return metas, vecs, nil
+}
+
+// reembedMultimodal re-creates the pieces of a multimodal file the way Index
+// split it (PDF page ranges, media segments) and embeds the ones matching
+// the stored chunks. On failure it returns nil and the reason.
+func (e *Engine) reembedMultimodal(source *RagIndex, path string, metas []ChunkMeta, chunks []int, config Config) ([][]float32, string) {
+	if !embedding.SupportsMultimodal(e.embeddingClient, config.Model, metas[chunks[0]].MIMEType) {
+		return nil, "backend does not support multimodal embedding"
+	}
+	recorded := source.FileChecksums[path]
+	if isPseudoChecksum(recorded) {
+		return nil, "uploaded content has no source file"
+	}
+	absPath := source.ResolvePath(path)
+	checksum, err := fileutil.CalculateChecksum(absPath)
+	if err != nil {
+		return nil, fmt.Sprintf("cannot read source file: %v", err)
+	}
+	if checksum != recorded {
+		return nil, "source file changed since it was indexed"
+	}
+	data, err := fileutil.ReadFile(absPath)
+	if err != nil {
+		return nil, fmt.Sprintf("cannot read source file: %v", err)
+	}
+
+	// Pieces of the source keyed by the page label Index gave them
+	pieces := make(map[string][]byte)
+	mimeType := metas[chunks[0]].MIMEType
+	split := false
+	for _, i := range chunks {
+		if metas[i].PageLabel != "" {
+			split = true
+			break
+		}
+	}
+	if split {
+		switch {
+		case mimeType == "application/pdf":
+			// The label has the pages: chunks may also start at chapters
+			for _, i := range chunks {
+				label := metas[i].PageLabel
+				var start, end, total int
+				if _, err := fmt.Sscanf(label, "pages %d-%d of %d", &start, &end, &total); err != nil {
+					continue
+				}
+				if start == 1 && end == total {
+					pieces[label] = data
+					continue
+				}
+				piece, err := pdfutil.ExtractPages(data, start, end)
+				if err != nil {
+					return nil, fmt.Sprintf("failed to split PDF: %v", err)
+				}
+				pieces[label] = piece
+			}
+		case strings.HasPrefix(mimeType, "audio/") || strings.HasPrefix(mimeType, "video/"):
+			if err := mediautil.CheckFFmpeg(); err != nil {
+				return nil, err.Error()
+			}
+			mediaPath, cleanup, err := fileutil.LocalPath(absPath)
+			if err != nil {
+				return nil, fmt.Sprintf("cannot read source file: %v", err)
+			}
+			_, _, _, maxDur, _ := mediautil.NeedsSplit(mediaPath, mimeType)
+			segments, err := mediautil.SplitMedia(mediaPath, mimeType, maxDur)
+			cleanup()
+			if err != nil {
+				return nil, fmt.Sprintf("failed to split media: %v", err)
+			}
+			for _, seg := range segments {
+				pieces[mediautil.FormatTimeLabel(seg.StartSec, seg.EndSec, seg.TotalSec)] = seg.Data
+			}
+		}
+	}
+
+	vecs := make([][]float32, 0, len(chunks))
+	for _, i := range chunks {
+		piece := data
+		if label := metas[i].PageLabel; label != "" {
+			var ok bool
+			if piece, ok = pieces[label]; !ok {
+				return nil, fmt.Sprintf("no part of the source matches %q", label)
+			}
+		}
+		vec, backend, err := e.embedContent(metas[i].MIMEType, piece, config)
+		if err != nil {
+			return nil, fmt.Sprintf("failed to embed: %v", err)
+		}
+		metas[i].Backend = backend
+		vecs = append(vecs, vec)
+	}
+	return vecs, ""
+}
+
+// unchangedSourceText returns the text a file's chunks were cut from, or ""
+// when the file is gone or changed. It only supplies heading context for the
+// embedding text; the chunk text itself always comes from the index.
+func unchangedSourceText(index *RagIndex, path string, config Config) string {
+	recorded := index.FileChecksums[path]
+	if recorded == "" || isPseudoChecksum(recorded) {
+		return ""
+	}
+	absPath := index.ResolvePath(path)
+	checksum, err := fileutil.CalculateChecksum(absPath)
+	if err != nil || checksum != recorded {
+		return ""
+	}
+	data, err := fileutil.ReadFile(absPath)
+	if err != nil {
+		return ""
+	}
+	if strings.EqualFold(filepath.Ext(absPath), ".pdf") {
+		text, err := pdfutil.ExtractAllText(data)
+		if err != nil {
+			return ""
+		}
+		return text
+	}
+	mimeType, _, err := fileutil.DetectFile(absPath, config.MIMETypes)
+	if err != nil {
+		return ""
+	}
+	config.ChunkSize = index.EffectiveChunkSize() // JSON and CSV records are grouped by it
+	doc, _, err := extractText(absPath, data, mimeType, config)
+	if err != nil {
+		return ""
+	}
+	return doc.Text
+}
+
+// loadMigration returns the state and shadow index of an interrupted
+// migration, or nil if there is none or it was for another source or target
+func loadMigration(shadowDir, fingerprint string, config Config) (*migrationState, *RagIndex, []float32) {
+	data, err := os.ReadFile(filepath.Join(shadowDir, migrationStateFile))
+	if err != nil {
+		return nil, nil, nil
+	}
+	var state migrationState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, nil, nil
+	}
+	if state.Source != fingerprint || state.Model != config.Model || state.Provider != config.Provider || state.Dimension != config.Dimension ||
+		!samePromptTemplate(state.Template, config.PromptTemplate) {
+		return nil, nil, nil
+	}
+	shadow, vectors, err := LoadIndexFromDir(shadowDir)
+	if err != nil || shadow == nil {
+		return nil, nil, nil
+	}
+	if shadow.FileChecksums == nil {
+		shadow.FileChecksums = make(map[string]string)
+	}
+	return &state, shadow, vectors
+}
+
+// MigrationPending reports whether a store has an interrupted migration
+func MigrationPending(storeName string) bool {
+	dir, err := storeDir(storeName)
+	if err != nil {
+		return false
+	}
+	_, err = os.Stat(filepath.Join(filepath.Clean(dir)+migratingSuffix, migrationStateFile))
+	return err == nil
+}
+
+// indexFingerprint hashes a store's index.json to detect concurrent changes
+func indexFingerprint(dir string) (string, error) {
+	data, err := os.ReadFile(filepath.Join(dir, indexFileName))
+	if err != nil {
+		return "", fmt.Errorf("failed to read index: %w", err)
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// swapStoreDir moves shadowDir into place as dir, which need not exist yet.
+// Each step is a single rename, and the old store is restored if installing
+// the new one fails; the new one is then left in shadowDir.
+func swapStoreDir(dir, shadowDir string) error {
+	dir = filepath.Clean(dir)
+	oldDir := dir + swapSuffix
+	if err := os.RemoveAll(oldDir); err != nil {
+		return fmt.Errorf("failed to clean up previous swap: %w", err)
+	}
+	replacing := true
+	if err := os.Rename(dir, oldDir); err != nil {
+		if !os.IsNotExist(err) {
+			return fmt.Errorf("failed to move old store aside: %w", err)
+		}
+		replacing = false
+	}
+	if err := os.Rename(shadowDir, dir); err != nil {
+		if !replacing {
+			return fmt.Errorf("failed to install new store: %w (it is left at %s)", err, shadowDir)
+		}
+		if restoreErr := os.Rename(oldDir, dir); restoreErr != nil {
+			return fmt.Errorf("failed to install new store: %w (it is left at %s, the old store at %s: %v)", err, shadowDir, oldDir, restoreErr)
+		}
+		return fmt.Errorf("failed to install new store: %w (it is left at %s)", err, shadowDir)
+	}
+	if err := os.RemoveAll(oldDir); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to remove old store at %s: %v\n", oldDir, err)
+	}
+	return nil
+}
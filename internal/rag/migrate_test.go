@@ -0,0 +1,238 @@
+package rag
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/takeshy/ragujuary/internal/embedding"
+)
+
+// flakyMultimodalClient fails every call once its budget of embeddings is used up
+type flakyMultimodalClient struct {
+	fakeMultimodalClient
+	budget *int
+}
+
+func (c flakyMultimodalClient) BatchEmbedContents(model string, texts []string, taskType embedding.TaskType, dimension int) ([][]float32, error) {
+	if *c.budget < len(texts) {
+		return nil, errors.New("quota exceeded")
+	}
+	*c.budget -= len(texts)
+	return c.fakeMultimodalClient.BatchEmbedContents(model, texts, taskType, dimension)
+}
+
+func writeMigrateFixture(t *testing.T, docsDir string) {
+	t.Helper()
+	if err := os.MkdirAll(docsDir, 0755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	files := map[string][]byte{
+		"a.md":      []byte("# Alpha\n\nfirst document with some text in it"),
+		"b.md":      []byte("# Beta\n\nsecond document with other text"),
+		"c.md":      []byte("# Gamma\n\nthird document"),
+		"photo.png": {0x89, 'P', 'N', 'G', '\r', '\n', 0x1a, '\n'},
+		"paper.pdf": makeTestPDF(t, 7),
+	}
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(docsDir, name), data, 0644); err != nil {
+			t.Fatalf("write %s: %v", name, err)
+		}
+	}
+}
+
+func TestMigrateReembedsStore(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	docsDir := filepath.Join(home, "docs")
+	writeMigrateFixture(t, docsDir)
+
+	config := DefaultConfig()
+	config.Model = "old-model"
+	config.Dimension = 4
+	engine := NewEngine(fakeMultimodalClient{})
+	if _, err := engine.Index([]string{docsDir}, nil, "migrate-store", config); err != nil {
+		t.Fatalf("index: %v", err)
+	}
+	before, _, _ := LoadIndex("migrate-store")
+
+	target := config
+	target.Model = "new-model"
+	target.Dimension = 8
+	result, err := engine.Migrate("migrate-store", target, MigrateOptions{})
+	if err != nil {
+		t.Fatalf("Migrate() error = %v", err)
+	}
+	if result.EmbeddedChunks != len(before.Meta) || len(result.DroppedFiles) != 0 {
+		t.Fatalf("embedded=%d dropped=%v, want %d/none", result.EmbeddedChunks, result.DroppedFiles, len(before.Meta))
+	}
+
+	after, vectors, err := LoadIndex("migrate-store")
+	if err != nil {
+		t.Fatalf("load migrated: %v", err)
+	}
+	if after.EmbeddingModel != "new-model" || after.Dimension != 8 {
+		t.Fatalf("model/dimension = %s/%d, want new-model/8", after.EmbeddingModel, after.Dimension)
+	}
+	if len(after.Meta) != len(before.Meta) || len(vectors) != len(after.Meta)*8 {
+		t.Fatalf("chunks=%d vectors=%d, want %d chunks", len(after.Meta), len(vectors), len(before.Meta))
+	}
+	if len(after.FileChecksums) != len(before.FileChecksums) {
+		t.Fatalf("checksums = %v", after.FileChecksums)
+	}
+	if _, err := os.Stat(filepath.Join(home, ".ragujuary-embed", "migrate-store"+migratingSuffix)); !os.IsNotExist(err) {
+		t.Fatal("shadow directory left behind")
+	}
+
+	// The migrated store keeps indexing incrementally with the new settings
+	second, err := engine.Index([]string{docsDir}, nil, "migrate-store", target)
+	if err != nil {
+		t.Fatalf("reindex: %v", err)
+	}
+	if second.SkippedFiles != 5 {
+		t.Fatalf("skipped = %d, want 5", second.SkippedFiles)
+	}
+}
+
+func TestMigrateResumesAfterInterruption(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	docsDir := filepath.Join(home, "docs")
+	writeMigrateFixture(t, docsDir)
+
+	config := DefaultConfig()
+	config.Model = "old-model"
+	config.Dimension = 4
+	if _, err := NewEngine(fakeMultimodalClient{}).Index([]string{docsDir}, nil, "resume-store", config); err != nil {
+		t.Fatalf("index: %v", err)
+	}
+
+	saved := migrateCheckpointChunks
+	migrateCheckpointChunks = 1
+	defer func() { migrateCheckpointChunks = saved }()
+
+	target := config
+	target.Dimension = 8
+	budget := 2
+	_, err := NewEngine(flakyMultimodalClient{budget: &budget}).Migrate("resume-store", target, MigrateOptions{})
+	if err == nil || !strings.Contains(err.Error(), "quota exceeded") {
+		t.Fatalf("Migrate() error = %v, want quota error", err)
+	}
+	if !MigrationPending("resume-store") {
+		t.Fatal("MigrationPending() = false after interruption")
+	}
+
+	// Queries still see the old index
+	index, _, _ := LoadIndex("resume-store")
+	if index.Dimension != 4 {
+		t.Fatalf("old store dimension = %d, want 4", index.Dimension)
+	}
+
+	calls := 0
+	result, err := NewEngine(countingMultimodalClient{calls: &calls}).Migrate("resume-store", target, MigrateOptions{})
+	if err != nil {
+		t.Fatalf("resume: %v", err)
+	}
+	if result.ResumedChunks != 2 {
+		t.Fatalf("resumed = %d, want 2", result.ResumedChunks)
+	}
+	if calls != 1 {
+		t.Fatalf("text embeddings on resume = %d, want 1", calls)
+	}
+	if MigrationPending("resume-store") {
+		t.Fatal("MigrationPending() = true after completion")
+	}
+	index, _, _ = LoadIndex("resume-store")
+	if index.Dimension != 8 || len(index.Meta) != result.TotalChunks {
+		t.Fatalf("dimension=%d chunks=%d", index.Dimension, len(index.Meta))
+	}
+}
+
+func TestIndexRefusesModelAndDimensionChanges(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	docsDir := filepath.Join(home, "docs")
+	if err := os.MkdirAll(docsDir, 0755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(docsDir, "a.md"), []byte("alpha"), 0644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	engine := NewEngine(fakeEmbeddingClient{})
+	config := DefaultConfig()
+	config.Dimension = 4
+	if _, err := engine.Index([]string{docsDir}, nil, "guard-store", config); err != nil {
+		t.Fatalf("index: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(docsDir, "b.md"), []byte("beta"), 0644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	other := config
+	other.Model = "other-model"
+	if _, err := engine.Index([]string{docsDir}, nil, "guard-store", other); err == nil || !strings.Contains(err.Error(), "embed migrate") {
+		t.Fatalf("Index() with another model error = %v", err)
+	}
+
+	resized := config
+	resized.Dimension = 8
+	if _, err := engine.Index([]string{docsDir}, nil, "guard-store", resized); err == nil || !strings.Contains(err.Error(), "embed migrate") {
+		t.Fatalf("Index() with another dimension error = %v", err)
+	}
+
+	index, vectors, err := LoadIndex("guard-store")
+	if err != nil || len(index.Meta) != 1 || len(vectors) != 4 {
+		t.Fatalf("store changed after refused index: %v", err)
+	}
+}
+
+type countingMultimodalClient struct {
+	fakeMultimodalClient
+	calls *int
+}
+
+func (c countingMultimodalClient) BatchEmbedContents(model string, texts []string, taskType embedding.TaskType, dimension int) ([][]float32, error) {
+	*c.calls += len(texts)
+	return c.fakeMultimodalClient.BatchEmbedContents(model, texts, taskType, dimension)
+}
+
+func TestSwapStoreDir(t *testing.T) {
+	base := t.TempDir()
+	dir := filepath.Join(base, "store")
+	staged := func(name string) string {
+		t.Helper()
+		shadow := filepath.Join(base, name)
+		if err := os.MkdirAll(shadow, 0755); err != nil {
+			t.Fatalf("mkdir: %v", err)
+		}
+		os.WriteFile(filepath.Join(shadow, indexFileName), []byte(name), 0644)
+		return shadow
+	}
+	content := func() string {
+		data, _ := os.ReadFile(filepath.Join(dir, indexFileName))
+		return string(data)
+	}
+
+	// Installs a store where there was none, then replaces it
+	if err := swapStoreDir(dir, staged("first")); err != nil || content() != "first" {
+		t.Fatalf("swap into a new store: %v, index %q", err, content())
+	}
+	if err := swapStoreDir(dir, staged("second")); err != nil || content() != "second" {
+		t.Fatalf("swap over a store: %v, index %q", err, content())
+	}
+	if _, err := os.Stat(dir + swapSuffix); !os.IsNotExist(err) {
+		t.Errorf("old store left behind: %v", err)
+	}
+
+	// A failed install restores the old store
+	err := swapStoreDir(dir, filepath.Join(base, "missing"))
+	if err == nil || !strings.Contains(err.Error(), "missing") {
+		t.Fatalf("swap from a missing directory error = %v, want it to name the new store's location", err)
+	}
+	if content() != "second" {
+		t.Fatalf("old store not restored: index %q", content())
+	}
+}
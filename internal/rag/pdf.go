@@ -0,0 +1,94 @@
+package rag
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/takeshy/ragujuary/internal/docutil"
+	"github.com/takeshy/ragujuary/internal/pdfutil"
+)
+
+// splitPDF splits a PDF into page chunks of at most config.PDFMaxPages
+// pages, starting a chunk at every chapter with config.PDFChapters, and
+// returns them with the PDF's title and outline (nil if they can't be read)
+func splitPDF(name string, data []byte, config Config) ([]pdfutil.PDFChunk, *pdfutil.Metadata, error) {
+	meta, metaErr := pdfutil.ReadMetadata(data)
+	var breaks []int
+	if config.PDFChapters {
+		breaks = meta.ChapterStarts()
+	}
+	chunks, err := pdfutil.SplitPagesAt(data, config.PDFMaxPages, breaks)
+	if err != nil {
+		return nil, nil, err
+	}
+	if metaErr != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to read the outline of %s: %v\n", name, metaErr)
+	}
+	return chunks, meta, nil
+}
+
+// pdfChunkText is the text stored with a PDF page chunk: a header naming
+// the file, its pages, the document's title and the outline sections of the
+// pages, then the text extracted from the pages
+func pdfChunkText(ct, name, pageLabel string, chunk pdfutil.PDFChunk, pageTexts []string, title string, sections []string) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "[%s: %s (%s)]", ct, name, pageLabel)
+	if title != "" {
+		sb.WriteString("\nDocument: " + title)
+	}
+	for _, s := range sections {
+		sb.WriteString("\nSection: " + s)
+	}
+
+	var parts []string
+	for p := chunk.StartPage; p <= chunk.EndPage && p <= len(pageTexts); p++ {
+		if t := pageTexts[p-1]; t != "" {
+			parts = append(parts, t)
+		}
+	}
+	if len(parts) > 0 {
+		sb.WriteString("\n\n" + strings.Join(parts, "\n\n"))
+	}
+	return sb.String()
+}
+
+// pageSections returns the outline sections of the page a text chunk of a
+// PDF comes from (labelled "page N of M" by pdfTextDocument)
+func pageSections(meta *pdfutil.Metadata, label string) []string {
+	var page, total int
+	if meta == nil || label == "" {
+		return nil
+	}
+	if _, err := fmt.Sscanf(label, "page %d of %d", &page, &total); err != nil {
+		return nil
+	}
+	return meta.Sections(page, page)
+}
+
+// pdfTextDocument returns the text of a PDF's pages, separated by blank
+// lines (as pdfutil.ExtractAllText joins them), with a section labelled
+// "page 3 of 24" for every page that has text
+func pdfTextDocument(data []byte) (*docutil.Document, error) {
+	pages, err := pdfutil.ExtractText(data)
+	if err != nil {
+		return nil, err
+	}
+	var sb strings.Builder
+	var sections []docutil.Section
+	for i, text := range pages {
+		if i > 0 {
+			sb.WriteString("\n\n")
+		}
+		start := sb.Len()
+		sb.WriteString(text)
+		if text != "" {
+			sections = append(sections, docutil.Section{
+				Label: fmt.Sprintf("page %d of %d", i+1, len(pages)),
+				Start: start,
+				End:   sb.Len(),
+			})
+		}
+	}
+	return &docutil.Document{Text: sb.String(), Sections: sections}, nil
+}
@@ -0,0 +1,134 @@
+package rag
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// rootNamePattern restricts root names so that "name:rel/path" can't be
+// confused with a Windows drive letter ("C:\...") or a URL scheme we'd never record.
+var rootNamePattern = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_.-]+$`)
+
+// ParseRoot parses a "name=dir" root specification, resolving dir to an absolute path
+func ParseRoot(spec string) (string, string, error) {
+	name, dir, ok := strings.Cut(spec, "=")
+	if !ok || name == "" || dir == "" {
+		return "", "", fmt.Errorf("invalid root %q (expected NAME=DIR)", spec)
+	}
+	if !rootNamePattern.MatchString(name) {
+		return "", "", fmt.Errorf("invalid root name %q (letters, digits, '.', '_' and '-', at least 2 characters)", name)
+	}
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to get absolute path for %q: %w", dir, err)
+	}
+	return name, absDir, nil
+}
+
+// splitRootPath splits a stored "name:rel/path" into its root name and relative part.
+// ok is false if p is not anchored to one of the index's roots.
+func (r *RagIndex) splitRootPath(p string) (name, rel string, ok bool) {
+	if r == nil || len(r.Roots) == 0 {
+		return "", "", false
+	}
+	name, rel, found := strings.Cut(p, ":")
+	if !found {
+		return "", "", false
+	}
+	if _, known := r.Roots[name]; !known {
+		return "", "", false
+	}
+	return name, rel, true
+}
+
+// ResolvePath returns the on-disk path for a stored FilePath.
+// Root-anchored paths ("name:rel/path") are joined onto the root's directory;
+// anything else is returned unchanged.
+func (r *RagIndex) ResolvePath(p string) string {
+	name, rel, ok := r.splitRootPath(p)
+	if !ok {
+		return p
+	}
+	return filepath.Join(r.Roots[name], filepath.FromSlash(rel))
+}
+
+// RelativePath returns the stored form of an absolute path: "name:rel/path"
+// for the most specific root containing it, or the path unchanged.
+func (r *RagIndex) RelativePath(absPath string) string {
+	if r == nil || len(r.Roots) == 0 || !filepath.IsAbs(absPath) {
+		return absPath
+	}
+
+	bestName, bestRel, bestLen := "", "", -1
+	for name, dir := range r.Roots {
+		rel, err := filepath.Rel(dir, absPath)
+		if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			continue
+		}
+		if len(dir) > bestLen {
+			bestName, bestRel, bestLen = name, rel, len(dir)
+		}
+	}
+	if bestLen < 0 {
+		return absPath
+	}
+	return bestName + ":" + filepath.ToSlash(bestRel)
+}
+
+// SetRoot records (or moves) a named root and converts every stored absolute
+// path under it to root-relative form. Paths already anchored to the root keep
+// their relative part, so moving a root is just a matter of calling SetRoot again.
+// Returns the number of chunk and checksum entries that were rewritten.
+func (r *RagIndex) SetRoot(name, absDir string) int {
+	if r.Roots == nil {
+		r.Roots = make(map[string]string)
+	}
+	r.Roots[name] = absDir
+	return r.relativizePaths()
+}
+
+// relativizePaths rewrites absolute FilePath/FileChecksums entries that fall under a root
+func (r *RagIndex) relativizePaths() int {
+	rewritten := 0
+	for i, m := range r.Meta {
+		if rel := r.RelativePath(m.FilePath); rel != m.FilePath {
+			r.Meta[i].FilePath = rel
+			rewritten++
+		}
+	}
+	checksums := make(map[string]string, len(r.FileChecksums))
+	for p, sum := range r.FileChecksums {
+		rel := r.RelativePath(p)
+		if rel != p {
+			rewritten++
+		}
+		checksums[rel] = sum
+	}
+	r.FileChecksums = checksums
+	return rewritten
+}
+
+// RemoveRoot drops a named root, converting its root-relative paths back to absolute ones
+func (r *RagIndex) RemoveRoot(name string) error {
+	if _, ok := r.Roots[name]; !ok {
+		return fmt.Errorf("root '%s' not found", name)
+	}
+	absolutize := func(p string) string {
+		if n, _, ok := r.splitRootPath(p); ok && n == name {
+			return r.ResolvePath(p)
+		}
+		return p
+	}
+	for i, m := range r.Meta {
+		r.Meta[i].FilePath = absolutize(m.FilePath)
+	}
+	checksums := make(map[string]string, len(r.FileChecksums))
+	for p, sum := range r.FileChecksums {
+		checksums[absolutize(p)] = sum
+	}
+	r.FileChecksums = checksums
+	delete(r.Roots, name)
+	return nil
+}
@@ -0,0 +1,192 @@
+package rag
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+)
+
+func TestRelativeAndResolvePath(t *testing.T) {
+	index := &RagIndex{Roots: map[string]string{
+		"repo": filepath.FromSlash("/src/repo"),
+		"docs": filepath.FromSlash("/src/repo/docs"),
+	}}
+
+	tests := []struct {
+		abs  string
+		want string
+	}{
+		{abs: "/src/repo/main.go", want: "repo:main.go"},
+		{abs: "/src/repo/docs/guide/intro.md", want: "docs:guide/intro.md"}, // most specific root wins
+		{abs: "/src/repository/x.go", want: "/src/repository/x.go"},
+		{abs: "/elsewhere/y.md", want: "/elsewhere/y.md"},
+	}
+	for _, tt := range tests {
+		abs := filepath.FromSlash(tt.abs)
+		want := tt.want
+		if want[0] == '/' {
+			want = filepath.FromSlash(want)
+		}
+		got := index.RelativePath(abs)
+		if got != want {
+			t.Fatalf("RelativePath(%q) = %q, want %q", abs, got, want)
+		}
+		if resolved := index.ResolvePath(got); resolved != abs {
+			t.Fatalf("ResolvePath(%q) = %q, want %q", got, resolved, abs)
+		}
+	}
+
+	if got := index.ResolvePath("unknown:a.md"); got != "unknown:a.md" {
+		t.Fatalf("ResolvePath() with unknown root = %q", got)
+	}
+}
+
+func TestParseRoot(t *testing.T) {
+	if _, _, err := ParseRoot("C=/tmp"); err == nil {
+		t.Fatal("ParseRoot() accepted single-letter root name")
+	}
+	if _, _, err := ParseRoot("docs"); err == nil {
+		t.Fatal("ParseRoot() accepted spec without directory")
+	}
+	name, dir, err := ParseRoot("docs=.")
+	if err != nil {
+		t.Fatalf("ParseRoot() error = %v", err)
+	}
+	if name != "docs" || !filepath.IsAbs(dir) {
+		t.Fatalf("ParseRoot() = %q, %q", name, dir)
+	}
+}
+
+func TestIndexWithRootSurvivesMove(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	oldDir := filepath.Join(home, "checkout-a")
+	if err := os.MkdirAll(filepath.Join(oldDir, "guide"), 0755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(oldDir, "guide", "intro.md"), []byte("intro text"), 0644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	engine := NewEngine(fakeEmbeddingClient{})
+	config := DefaultConfig()
+	config.Dimension = 4
+	config.Roots = map[string]string{"docs": oldDir}
+
+	if _, err := engine.Index([]string{oldDir}, nil, "roots-store", config); err != nil {
+		t.Fatalf("first index: %v", err)
+	}
+	index, _, err := LoadIndex("roots-store")
+	if err != nil {
+		t.Fatalf("load index: %v", err)
+	}
+	if _, ok := index.FileChecksums["docs:guide/intro.md"]; !ok {
+		t.Fatalf("checksums = %v, want docs:guide/intro.md", index.FileChecksums)
+	}
+	if index.Meta[0].FilePath != "docs:guide/intro.md" {
+		t.Fatalf("meta path = %q", index.Meta[0].FilePath)
+	}
+
+	newDir := filepath.Join(home, "checkout-b")
+	if err := os.Rename(oldDir, newDir); err != nil {
+		t.Fatalf("rename: %v", err)
+	}
+
+	config.Roots = map[string]string{"docs": newDir}
+	second, err := engine.Index([]string{newDir}, nil, "roots-store", config)
+	if err != nil {
+		t.Fatalf("second index: %v", err)
+	}
+	if second.SkippedFiles != 1 || second.NewFiles != 0 {
+		t.Fatalf("after move: skipped=%d new=%d, want 1/0", second.SkippedFiles, second.NewFiles)
+	}
+
+	results, err := engine.Query("intro", "roots-store", config)
+	if err != nil {
+		t.Fatalf("query: %v", err)
+	}
+	if len(results) == 0 || results[0].ResolvedPath != filepath.Join(newDir, "guide", "intro.md") {
+		t.Fatalf("results = %+v", results)
+	}
+}
+
+func TestDeleteFilesFromStoreWithRoots(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	docsDir := filepath.Join(home, "checkout")
+	if err := os.MkdirAll(filepath.Join(docsDir, "guide"), 0755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	for _, name := range []string{"intro.md", "setup.md"} {
+		if err := os.WriteFile(filepath.Join(docsDir, "guide", name), []byte(name+" text"), 0644); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+	}
+
+	engine := NewEngine(fakeEmbeddingClient{})
+	config := DefaultConfig()
+	config.Dimension = 4
+	config.Roots = map[string]string{"docs": docsDir}
+	if _, err := engine.Index([]string{docsDir}, nil, "roots-delete-store", config); err != nil {
+		t.Fatalf("index: %v", err)
+	}
+
+	// Patterns match the absolute path as well as the stored "docs:..." key
+	deleted, err := engine.DeleteFiles("roots-delete-store", regexp.QuoteMeta(filepath.Join(docsDir, "guide", "intro.md")))
+	if err != nil || deleted != 1 {
+		t.Fatalf("delete by absolute path = %d, %v, want 1", deleted, err)
+	}
+	deleted, err = engine.DeleteFiles("roots-delete-store", "^docs:guide/setup")
+	if err != nil || deleted != 1 {
+		t.Fatalf("delete by stored path = %d, %v, want 1", deleted, err)
+	}
+	index, _, err := LoadIndex("roots-delete-store")
+	if err != nil {
+		t.Fatalf("load index: %v", err)
+	}
+	if len(index.FileChecksums) != 0 || len(index.Meta) != 0 {
+		t.Fatalf("left checksums %v and %d chunks", index.FileChecksums, len(index.Meta))
+	}
+}
+
+func TestSetRootMigratesAbsoluteStore(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	dir := filepath.Join(home, "repo")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	path := filepath.Join(dir, "a.txt")
+	if err := os.WriteFile(path, []byte("alpha"), 0644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	engine := NewEngine(fakeEmbeddingClient{})
+	config := DefaultConfig()
+	config.Dimension = 4
+	if _, err := engine.Index([]string{dir}, nil, "abs-store", config); err != nil {
+		t.Fatalf("first index: %v", err)
+	}
+
+	// Re-indexing with a root converts the existing absolute entries instead of re-embedding
+	config.Roots = map[string]string{"repo": dir}
+	second, err := engine.Index([]string{dir}, nil, "abs-store", config)
+	if err != nil {
+		t.Fatalf("second index: %v", err)
+	}
+	if second.SkippedFiles != 1 || second.TotalChunks != 1 {
+		t.Fatalf("skipped=%d chunks=%d, want 1/1", second.SkippedFiles, second.TotalChunks)
+	}
+
+	index, _, _ := LoadIndex("abs-store")
+	if err := index.RemoveRoot("repo"); err != nil {
+		t.Fatalf("RemoveRoot() error = %v", err)
+	}
+	if _, ok := index.FileChecksums[path]; !ok || index.Meta[0].FilePath != path {
+		t.Fatalf("RemoveRoot() did not restore absolute paths: %v", index.FileChecksums)
+	}
+}
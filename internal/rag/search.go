@@ -2,16 +2,24 @@ package rag
 
 import (
 	"math"
+	"path/filepath"
 	"sort"
+	"strings"
 )
 
 // SearchResult represents a single search result
 type SearchResult struct {
-	Text        string  `json:"text"`
-	FilePath    string  `json:"file_path"`
-	Score       float64 `json:"score"`
-	ContentType string  `json:"content_type,omitempty"`
-	PageLabel   string  `json:"page_label,omitempty"`
+	Text        string   `json:"text"`
+	FilePath    string   `json:"file_path"`
+	Score       float64  `json:"score"`
+	ContentType string   `json:"content_type,omitempty"`
+	PageLabel   string   `json:"page_label,omitempty"`
+	Sections    []string `json:"sections,omitempty"`
+	// Representation is which representation of a PDF matched:
+	// RepresentationText or RepresentationVisual ("" for other files)
+	Representation string `json:"representation,omitempty"`
+	// ResolvedPath is the on-disk path when FilePath is root-anchored ("name:rel/path")
+	ResolvedPath string `json:"resolved_path,omitempty"`
 }
 
 // Search finds the most similar chunks to the query vector
@@ -22,11 +30,6 @@ func Search(queryVec []float32, index *RagIndex, vectors []float32, topK int, mi
 
 	dim := index.Dimension
 
-	type scored struct {
-		index int
-		score float64
-	}
-
 	scores := make([]scored, 0, len(index.Meta))
 	for i := range index.Meta {
 		chunkVec := vectors[i*dim : (i+1)*dim]
@@ -36,6 +39,62 @@ func Search(queryVec []float32, index *RagIndex, vectors []float32, topK int, mi
 		}
 	}
 
+	return topResults(index, scores, topK)
+}
+
+// Candidates kept from the coarse pass of SearchTruncated, per requested result
+const (
+	coarseCandidatesPerResult = 10
+	minCoarseCandidates       = 100
+)
+
+// SearchTruncated finds the most similar chunks in two passes: every chunk is
+// scored on the first searchDim components of its vector (Matryoshka
+// truncation; cosine similarity renormalizes the prefix), then the best
+// candidates are rescored with the full vectors.
+func SearchTruncated(queryVec []float32, index *RagIndex, vectors []float32, topK int, minScore float64, searchDim int) []SearchResult {
+	if index == nil || len(index.Meta) == 0 || len(vectors) == 0 {
+		return nil
+	}
+	dim := index.Dimension
+	if topK <= 0 || searchDim <= 0 || searchDim >= dim {
+		return Search(queryVec, index, vectors, topK, minScore)
+	}
+
+	coarse := make([]scored, len(index.Meta))
+	queryPrefix := queryVec[:searchDim]
+	for i := range index.Meta {
+		coarse[i] = scored{index: i, score: cosineSimilarity(queryPrefix, vectors[i*dim:i*dim+searchDim])}
+	}
+	sort.Slice(coarse, func(a, b int) bool {
+		return coarse[a].score > coarse[b].score
+	})
+
+	candidates := topK * coarseCandidatesPerResult
+	if candidates < minCoarseCandidates {
+		candidates = minCoarseCandidates
+	}
+	if candidates > len(coarse) {
+		candidates = len(coarse)
+	}
+
+	scores := make([]scored, 0, candidates)
+	for _, c := range coarse[:candidates] {
+		score := cosineSimilarity(queryVec, vectors[c.index*dim:(c.index+1)*dim])
+		if score >= minScore {
+			scores = append(scores, scored{index: c.index, score: score})
+		}
+	}
+	return topResults(index, scores, topK)
+}
+
+type scored struct {
+	index int
+	score float64
+}
+
+// topResults sorts scored chunks by descending score and converts the best topK
+func topResults(index *RagIndex, scores []scored, topK int) []SearchResult {
 	sort.Slice(scores, func(a, b int) bool {
 		return scores[a].score > scores[b].score
 	})
@@ -52,12 +111,29 @@ func Search(queryVec []float32, index *RagIndex, vectors []float32, topK int, mi
 			Score:       s.score,
 			ContentType: index.Meta[s.index].ContentType,
 			PageLabel:   index.Meta[s.index].PageLabel,
+			Sections:    index.Meta[s.index].Sections,
+		}
+		results[i].Representation = pdfRepresentation(index.Meta[s.index])
+		if resolved := index.ResolvePath(results[i].FilePath); resolved != results[i].FilePath {
+			results[i].ResolvedPath = resolved
 		}
 	}
 
 	return results
 }
 
+// pdfRepresentation returns which representation of a PDF a chunk is, or ""
+// for chunks of other files
+func pdfRepresentation(meta ChunkMeta) string {
+	switch {
+	case meta.ContentType == "pdf":
+		return RepresentationVisual
+	case meta.ContentType == "" && strings.EqualFold(filepath.Ext(meta.FilePath), ".pdf"):
+		return RepresentationText
+	}
+	return ""
+}
+
 // cosineSimilarity computes the cosine similarity between two vectors
 func cosineSimilarity(a, b []float32) float64 {
 	var dot, normA, normB float64
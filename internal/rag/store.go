@@ -7,6 +7,9 @@ import (
 	"math"
 	"os"
 	"path/filepath"
+	"strings"
+
+	"github.com/takeshy/ragujuary/internal/embedding"
 )
 
 const (
@@ -15,26 +18,59 @@ const (
 	formatVersion   = 2
 )
 
+// IndexFormat identifies the JSON layout of index.json
+type IndexFormat string
+
+const (
+	// FormatNative is ragujuary's snake_case layout
+	FormatNative IndexFormat = "native"
+	// FormatExternal is the camelCase layout used by other RAG tools
+	FormatExternal IndexFormat = "external"
+)
+
+// ParseIndexFormat parses a user-supplied index format name
+func ParseIndexFormat(s string) (IndexFormat, error) {
+	switch s {
+	case "", "auto":
+		return "", nil
+	case "native", "ragujuary", "snake":
+		return FormatNative, nil
+	case "external", "camel":
+		return FormatExternal, nil
+	default:
+		return "", fmt.Errorf("unknown index format %q (must be auto, native or external)", s)
+	}
+}
+
 // ChunkMeta holds metadata for a single chunk
 type ChunkMeta struct {
-	FilePath    string `json:"file_path"`
-	StartOffset int    `json:"start_offset"`
-	Text        string `json:"text"`
-	ContentType string `json:"content_type,omitempty"` // "image", "pdf", "video", "audio" (empty = text)
-	MIMEType    string `json:"mime_type,omitempty"`
-	PageLabel   string `json:"page_label,omitempty"` // e.g. "pages 1-6 of 24"
+	FilePath    string   `json:"file_path"`
+	StartOffset int      `json:"start_offset"`
+	Text        string   `json:"text"`
+	ContentType string   `json:"content_type,omitempty"` // "image", "pdf", "video", "audio" (empty = text)
+	MIMEType    string   `json:"mime_type,omitempty"`
+	PageLabel   string   `json:"page_label,omitempty"` // e.g. "pages 1-6 of 24"
+	Sections    []string `json:"sections,omitempty"`   // PDF outline sections of the pages, e.g. "2 Setup > 2.1 Linux"
+	Backend     string   `json:"backend,omitempty"`    // fallback-chain backend that embedded the chunk (empty = the store's provider)
+	Encoding    string   `json:"encoding,omitempty"`   // encoding the source text was decoded from (empty = UTF-8)
 }
 
 // RagIndex holds the complete index metadata
 type RagIndex struct {
-	Meta           []ChunkMeta       `json:"meta"`
-	Dimension      int               `json:"dimension"`
-	FileChecksums  map[string]string `json:"file_checksums"`
-	EmbeddingModel string            `json:"embedding_model"`
-	ChunkSize      int               `json:"chunk_size,omitempty"`
-	ChunkOverlap   int               `json:"chunk_overlap,omitempty"`
-	PDFMaxPages    int               `json:"pdf_max_pages,omitempty"`
-	FormatVersion  int               `json:"format_version"`
+	Meta              []ChunkMeta               `json:"meta"`
+	Dimension         int                       `json:"dimension"` // effective dimension of the stored vectors
+	FileChecksums     map[string]string         `json:"file_checksums"`
+	EmbeddingModel    string                    `json:"embedding_model"`
+	Provider          string                    `json:"provider,omitempty"` // embedding provider the store was built with (empty = recorded before providers were)
+	ChunkSize         int                       `json:"chunk_size,omitempty"`
+	ChunkOverlap      int                       `json:"chunk_overlap,omitempty"`
+	PDFMaxPages       int                       `json:"pdf_max_pages,omitempty"`
+	PDFChapters       bool                      `json:"pdf_chapters,omitempty"`       // PDF chunks also start at every chapter of the outline
+	Roots             map[string]string         `json:"roots,omitempty"`              // root name -> absolute directory; FilePath may be "name:rel/path"
+	EmbeddedDimension int                       `json:"embedded_dimension,omitempty"` // dimension embedded at before compaction to Dimension (0 = not compacted)
+	PromptTemplate    *embedding.PromptTemplate `json:"prompt_template,omitempty"`    // query/document prefixes the chunks were embedded with
+	FormatVersion     int                       `json:"format_version"`
+	Format            IndexFormat               `json:"-"` // layout the index was read from / is written in (empty = native)
 }
 
 func (r *RagIndex) EffectiveChunkSize() int {
@@ -72,6 +108,9 @@ func storeBaseDir() (string, error) {
 
 // storeDir returns the directory for a specific store
 func storeDir(storeName string) (string, error) {
+	if err := checkStoreName(storeName); err != nil {
+		return "", err
+	}
 	base, err := storeBaseDir()
 	if err != nil {
 		return "", err
@@ -79,20 +118,41 @@ func storeDir(storeName string) (string, error) {
 	return filepath.Join(base, storeName), nil
 }
 
+// checkStoreName rejects store names that aren't a single directory name
+// under the store base directory (names are joined to it and may come from
+// archives)
+func checkStoreName(storeName string) error {
+	if storeName == "" || storeName == "." || storeName == ".." ||
+		strings.ContainsAny(storeName, `/\`) || filepath.IsAbs(storeName) || filepath.VolumeName(storeName) != "" {
+		return fmt.Errorf("invalid store name %q", storeName)
+	}
+	return nil
+}
+
 // SaveIndex saves the RAG index and vectors to disk
 func SaveIndex(storeName string, index *RagIndex, vectors []float32) error {
 	dir, err := storeDir(storeName)
 	if err != nil {
 		return err
 	}
+	return saveIndexToDir(dir, index, vectors)
+}
 
+// saveIndexToDir writes index.json and vectors.bin into dir
+func saveIndexToDir(dir string, index *RagIndex, vectors []float32) error {
 	if err := os.MkdirAll(dir, 0755); err != nil {
 		return fmt.Errorf("failed to create store directory: %w", err)
 	}
 
 	// Save metadata as JSON
-	index.FormatVersion = formatVersion
-	data, err := json.MarshalIndent(index, "", "  ")
+	var data []byte
+	var err error
+	if index.Format == FormatExternal {
+		data, err = json.MarshalIndent(convertToExternalIndex(index), "", "  ")
+	} else {
+		index.FormatVersion = formatVersion
+		data, err = json.MarshalIndent(index, "", "  ")
+	}
 	if err != nil {
 		return fmt.Errorf("failed to marshal index: %w", err)
 	}
@@ -104,52 +164,148 @@ func SaveIndex(storeName string, index *RagIndex, vectors []float32) error {
 
 	// Save vectors as binary (little-endian float32)
 	vectorsPath := filepath.Join(dir, vectorsFileName)
+	if err := os.WriteFile(vectorsPath, encodeVectors(vectors), 0644); err != nil {
+		return fmt.Errorf("failed to write vectors: %w", err)
+	}
+
+	return nil
+}
+
+// encodeVectors serializes vectors as little-endian float32
+func encodeVectors(vectors []float32) []byte {
 	buf := make([]byte, len(vectors)*4)
 	for i, v := range vectors {
 		binary.LittleEndian.PutUint32(buf[i*4:], math.Float32bits(v))
 	}
+	return buf
+}
 
-	if err := os.WriteFile(vectorsPath, buf, 0644); err != nil {
-		return fmt.Errorf("failed to write vectors: %w", err)
+// decodeVectors parses little-endian float32 data produced by encodeVectors
+func decodeVectors(buf []byte) ([]float32, error) {
+	if len(buf)%4 != 0 {
+		return nil, fmt.Errorf("vectors file is corrupted: size %d is not a multiple of 4", len(buf))
 	}
 
-	return nil
+	vectors := make([]float32, len(buf)/4)
+	for i := range vectors {
+		vectors[i] = math.Float32frombits(binary.LittleEndian.Uint32(buf[i*4:]))
+	}
+	return vectors, nil
 }
 
 // externalChunkMeta handles camelCase JSON field names from external RAG tools
 type externalChunkMeta struct {
-	FilePath    string `json:"filePath"`
-	ChunkIndex  int    `json:"chunkIndex"`
-	Text        string `json:"text"`
-	ContentType string `json:"contentType,omitempty"`
-	PageLabel   string `json:"pageLabel,omitempty"`
+	FilePath   string `json:"filePath"`
+	ChunkIndex int    `json:"chunkIndex"`
+	// StartOffset is the chunk's byte offset in its file, written when it
+	// differs from ChunkIndex (indexes of other tools have only ChunkIndex)
+	StartOffset *int     `json:"startOffset,omitempty"`
+	Text        string   `json:"text"`
+	ContentType string   `json:"contentType,omitempty"`
+	MIMEType    string   `json:"mimeType,omitempty"`
+	PageLabel   string   `json:"pageLabel,omitempty"`
+	Sections    []string `json:"sections,omitempty"`
+	Backend     string   `json:"backend,omitempty"`
+	Encoding    string   `json:"encoding,omitempty"`
 }
 
-// externalRagIndex handles camelCase JSON field names from external RAG tools
+// externalRagIndex handles camelCase JSON field names from external RAG tools.
+// Fields beyond meta/dimension/fileChecksums/embeddingModel are ragujuary
+// extensions, written only when set so other tools can ignore them.
 type externalRagIndex struct {
-	Meta           []externalChunkMeta `json:"meta"`
-	Dimension      int                 `json:"dimension"`
-	FileChecksums  map[string]string   `json:"fileChecksums"`
-	EmbeddingModel string              `json:"embeddingModel"`
+	Meta              []externalChunkMeta       `json:"meta"`
+	Dimension         int                       `json:"dimension"`
+	FileChecksums     map[string]string         `json:"fileChecksums"`
+	EmbeddingModel    string                    `json:"embeddingModel"`
+	Provider          string                    `json:"provider,omitempty"`
+	ChunkSize         int                       `json:"chunkSize,omitempty"`
+	ChunkOverlap      int                       `json:"chunkOverlap,omitempty"`
+	PDFMaxPages       int                       `json:"pdfMaxPages,omitempty"`
+	PDFChapters       bool                      `json:"pdfChapters,omitempty"`
+	Roots             map[string]string         `json:"roots,omitempty"`
+	EmbeddedDimension int                       `json:"embeddedDimension,omitempty"`
+	PromptTemplate    *embedding.PromptTemplate `json:"promptTemplate,omitempty"`
 }
 
 // convertExternalIndex converts an external format index to ragujuary format
 func convertExternalIndex(ext *externalRagIndex) *RagIndex {
 	meta := make([]ChunkMeta, len(ext.Meta))
 	for i, m := range ext.Meta {
+		offset := m.ChunkIndex
+		if m.StartOffset != nil {
+			offset = *m.StartOffset
+		}
 		meta[i] = ChunkMeta{
 			FilePath:    m.FilePath,
-			StartOffset: m.ChunkIndex,
+			StartOffset: offset,
 			Text:        m.Text,
 			ContentType: m.ContentType,
+			MIMEType:    m.MIMEType,
 			PageLabel:   m.PageLabel,
+			Sections:    m.Sections,
+			Backend:     m.Backend,
+			Encoding:    m.Encoding,
 		}
 	}
 	return &RagIndex{
-		Meta:           meta,
-		Dimension:      ext.Dimension,
-		FileChecksums:  ext.FileChecksums,
-		EmbeddingModel: ext.EmbeddingModel,
+		Meta:              meta,
+		Dimension:         ext.Dimension,
+		FileChecksums:     ext.FileChecksums,
+		EmbeddingModel:    ext.EmbeddingModel,
+		Provider:          ext.Provider,
+		ChunkSize:         ext.ChunkSize,
+		ChunkOverlap:      ext.ChunkOverlap,
+		PDFMaxPages:       ext.PDFMaxPages,
+		PDFChapters:       ext.PDFChapters,
+		Roots:             ext.Roots,
+		Format:            FormatExternal,
+		EmbeddedDimension: ext.EmbeddedDimension,
+		PromptTemplate:    ext.PromptTemplate,
+	}
+}
+
+// convertToExternalIndex converts a ragujuary index to the external camelCase format.
+// External tools number chunks per file, so chunkIndex is the chunk's ordinal within its file;
+// the byte offset goes in startOffset.
+func convertToExternalIndex(index *RagIndex) *externalRagIndex {
+	meta := make([]externalChunkMeta, len(index.Meta))
+	ordinals := make(map[string]int)
+	for i, m := range index.Meta {
+		var offset *int
+		if m.StartOffset != ordinals[m.FilePath] {
+			offset = &index.Meta[i].StartOffset
+		}
+		meta[i] = externalChunkMeta{
+			FilePath:    m.FilePath,
+			ChunkIndex:  ordinals[m.FilePath],
+			StartOffset: offset,
+			Text:        m.Text,
+			ContentType: m.ContentType,
+			MIMEType:    m.MIMEType,
+			PageLabel:   m.PageLabel,
+			Sections:    m.Sections,
+			Backend:     m.Backend,
+			Encoding:    m.Encoding,
+		}
+		ordinals[m.FilePath]++
+	}
+	checksums := index.FileChecksums
+	if checksums == nil {
+		checksums = make(map[string]string)
+	}
+	return &externalRagIndex{
+		Meta:              meta,
+		Dimension:         index.Dimension,
+		FileChecksums:     checksums,
+		EmbeddingModel:    index.EmbeddingModel,
+		Provider:          index.Provider,
+		ChunkSize:         index.ChunkSize,
+		ChunkOverlap:      index.ChunkOverlap,
+		PDFMaxPages:       index.PDFMaxPages,
+		PDFChapters:       index.PDFChapters,
+		Roots:             index.Roots,
+		EmbeddedDimension: index.EmbeddedDimension,
+		PromptTemplate:    index.PromptTemplate,
 	}
 }
 
@@ -221,50 +377,107 @@ func LoadIndex(storeName string) (*RagIndex, []float32, error) {
 
 // LoadIndexFromDir loads the RAG index and vectors from an arbitrary directory
 func LoadIndexFromDir(dir string) (*RagIndex, []float32, error) {
-	// Load metadata
-	indexPath := filepath.Join(dir, indexFileName)
-	data, err := os.ReadFile(indexPath)
-	if err != nil {
-		if os.IsNotExist(err) {
-			return nil, nil, nil
-		}
-		return nil, nil, fmt.Errorf("failed to read index: %w", err)
+	index, vectors, err := loadIndexUnchecked(dir)
+	if err != nil || index == nil {
+		return nil, nil, err
 	}
 
-	index, err := unmarshalIndex(data)
+	expected := len(index.Meta) * index.Dimension
+	if len(vectors) != expected {
+		return nil, nil, fmt.Errorf("vectors/index mismatch: got %d floats, expected %d (%d chunks × %d dimensions)",
+			len(vectors), expected, len(index.Meta), index.Dimension)
+	}
+
+	return index, vectors, nil
+}
+
+// LoadIndexMetadata loads the index metadata of a store without its vectors,
+// e.g. to find out which model and provider it was built with. It returns nil
+// if the store has no index.
+func LoadIndexMetadata(storeName string) (*RagIndex, error) {
+	dir, err := storeDir(storeName)
 	if err != nil {
-		return nil, nil, err
+		return nil, err
 	}
+	return LoadIndexMetadataFromDir(dir)
+}
 
-	if index.FormatVersion > formatVersion {
-		return nil, nil, fmt.Errorf("incompatible index format version %d (max supported: %d)", index.FormatVersion, formatVersion)
+// LoadIndexMetadataFromDir loads the index metadata of a directory without its vectors
+func LoadIndexMetadataFromDir(dir string) (*RagIndex, error) {
+	data, _, err := readIndexFiles(dir, false)
+	if err != nil || data == nil {
+		return nil, err
 	}
+	return parseIndex(data)
+}
 
-	// Load vectors
-	vectorsPath := filepath.Join(dir, vectorsFileName)
-	buf, err := os.ReadFile(vectorsPath)
+// parseIndex decodes index.json and checks its format version
+func parseIndex(data []byte) (*RagIndex, error) {
+	index, err := unmarshalIndex(data)
 	if err != nil {
-		return nil, nil, fmt.Errorf("failed to read vectors: %w", err)
+		return nil, err
 	}
 
-	if len(buf)%4 != 0 {
-		return nil, nil, fmt.Errorf("vectors file is corrupted: size %d is not a multiple of 4", len(buf))
+	if index.FormatVersion > formatVersion {
+		return nil, fmt.Errorf("incompatible index format version %d (max supported: %d)", index.FormatVersion, formatVersion)
 	}
+	return index, nil
+}
 
-	vectors := make([]float32, len(buf)/4)
-	for i := range vectors {
-		vectors[i] = math.Float32frombits(binary.LittleEndian.Uint32(buf[i*4:]))
+// loadIndexUnchecked loads index and vectors without checking that they agree,
+// for diagnostics that need to inspect a damaged store
+func loadIndexUnchecked(dir string) (*RagIndex, []float32, error) {
+	data, buf, err := readIndexFiles(dir, true)
+	if err != nil || data == nil {
+		return nil, nil, err
 	}
 
-	expected := len(index.Meta) * index.Dimension
-	if len(vectors) != expected {
-		return nil, nil, fmt.Errorf("vectors/index mismatch: got %d floats, expected %d (%d chunks × %d dimensions)",
-			len(vectors), expected, len(index.Meta), index.Dimension)
+	index, err := parseIndex(data)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	vectors, err := decodeVectors(buf)
+	if err != nil {
+		return nil, nil, err
 	}
 
 	return index, vectors, nil
 }
 
+// readIndexFiles reads index.json (and vectors.bin if withVectors) from the
+// same directory, returning nil data if dir has no index. While swapStoreDir
+// replaces a store its directory is missing for a moment, so the old one is
+// read from beside it then, or the new one once that is gone too.
+func readIndexFiles(dir string, withVectors bool) ([]byte, []byte, error) {
+	var missingVectors error
+	for _, d := range []string{dir, filepath.Clean(dir) + swapSuffix, dir} {
+		data, err := os.ReadFile(filepath.Join(d, indexFileName))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read index: %w", err)
+		}
+		if !withVectors {
+			return data, nil, nil
+		}
+		buf, err := os.ReadFile(filepath.Join(d, vectorsFileName))
+		if os.IsNotExist(err) {
+			missingVectors = err // or d was swapped out meanwhile
+			continue
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read vectors: %w", err)
+		}
+		return data, buf, nil
+	}
+	if missingVectors != nil {
+		return nil, nil, fmt.Errorf("failed to read vectors: %w", missingVectors)
+	}
+	return nil, nil, nil
+}
+
 // CreateEmptyIndex creates a new empty embedding store
 func CreateEmptyIndex(storeName string) error {
 	index := &RagIndex{
@@ -274,6 +487,11 @@ func CreateEmptyIndex(storeName string) error {
 		ChunkOverlap:  200,
 		PDFMaxPages:   6,
 	}
+	unlock, err := LockStore(storeName, "create")
+	if err != nil {
+		return err
+	}
+	defer unlock()
 	return SaveIndex(storeName, index, nil)
 }
 
@@ -290,10 +508,19 @@ func DeleteIndex(storeName string) error {
 		}
 		return fmt.Errorf("failed to access store: %w", err)
 	}
+	lock, err := lockStore(dir, "delete")
+	if err != nil {
+		return err
+	}
+	defer lock.unlock()
 
 	if err := os.RemoveAll(dir); err != nil {
 		return fmt.Errorf("failed to delete store: %w", err)
 	}
+	// Discard an unfinished migration along with the store
+	if err := os.RemoveAll(filepath.Clean(dir) + migratingSuffix); err != nil {
+		return fmt.Errorf("failed to delete pending migration: %w", err)
+	}
 
 	return nil
 }
@@ -315,7 +542,7 @@ func ListStores() ([]string, error) {
 
 	var stores []string
 	for _, entry := range entries {
-		if entry.IsDir() {
+		if entry.IsDir() && !isStagingDir(entry.Name()) {
 			// Check if it has an index.json
 			indexPath := filepath.Join(base, entry.Name(), indexFileName)
 			if _, err := os.Stat(indexPath); err == nil {
@@ -326,3 +553,14 @@ func ListStores() ([]string, error) {
 
 	return stores, nil
 }
+
+// isStagingDir reports whether a directory under the store base holds a
+// store being imported or migrated rather than a store of its own
+func isStagingDir(name string) bool {
+	for _, suffix := range []string{".importing", migratingSuffix, compactingSuffix, swapSuffix} {
+		if strings.HasSuffix(name, suffix) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,171 @@
+package rag
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const externalFixtureDir = "testdata/external"
+
+func copyFixture(t *testing.T, src string) string {
+	t.Helper()
+	dst := t.TempDir()
+	for _, name := range []string{indexFileName, vectorsFileName} {
+		data, err := os.ReadFile(filepath.Join(src, name))
+		if err != nil {
+			t.Fatalf("read fixture %s: %v", name, err)
+		}
+		if err := os.WriteFile(filepath.Join(dst, name), data, 0644); err != nil {
+			t.Fatalf("write fixture %s: %v", name, err)
+		}
+	}
+	return dst
+}
+
+func readJSONMap(t *testing.T, path string) map[string]interface{} {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read %s: %v", path, err)
+	}
+	var m map[string]interface{}
+	if err := json.Unmarshal(data, &m); err != nil {
+		t.Fatalf("parse %s: %v", path, err)
+	}
+	return m
+}
+
+func TestExternalIndexRoundTrip(t *testing.T) {
+	index, vectors, err := LoadIndexFromDir(externalFixtureDir)
+	if err != nil {
+		t.Fatalf("LoadIndexFromDir() error = %v", err)
+	}
+	if index.Format != FormatExternal {
+		t.Fatalf("format = %q, want %q", index.Format, FormatExternal)
+	}
+
+	out := t.TempDir()
+	if err := saveIndexToDir(out, index, vectors); err != nil {
+		t.Fatalf("saveIndexToDir() error = %v", err)
+	}
+
+	want := readJSONMap(t, filepath.Join(externalFixtureDir, indexFileName))
+	got := readJSONMap(t, filepath.Join(out, indexFileName))
+	if !reflect.DeepEqual(got, want) {
+		gotJSON, _ := json.MarshalIndent(got, "", "  ")
+		t.Fatalf("round-tripped index differs from fixture:\n%s", gotJSON)
+	}
+
+	wantVecs, _ := os.ReadFile(filepath.Join(externalFixtureDir, vectorsFileName))
+	gotVecs, _ := os.ReadFile(filepath.Join(out, vectorsFileName))
+	if !bytes.Equal(gotVecs, wantVecs) {
+		t.Fatal("round-tripped vectors differ from fixture")
+	}
+
+	// Byte offsets of ragujuary's chunks survive next to the per-file chunkIndex
+	index.Meta = []ChunkMeta{
+		{FilePath: "notes/setup.md", StartOffset: 0, Text: "first"},
+		{FilePath: "notes/setup.md", StartOffset: 950, Text: "second"},
+		{FilePath: "notes/setup.md", StartOffset: 2, Text: "third"},
+		{FilePath: "manual.pdf", StartOffset: 0, Text: "[pdf]"},
+	}
+	if err := saveIndexToDir(out, index, make([]float32, len(index.Meta)*index.Dimension)); err != nil {
+		t.Fatalf("saveIndexToDir() error = %v", err)
+	}
+	reloaded, _, err := LoadIndexFromDir(out)
+	if err != nil {
+		t.Fatalf("LoadIndexFromDir() error = %v", err)
+	}
+	for i, meta := range reloaded.Meta {
+		if meta.StartOffset != index.Meta[i].StartOffset {
+			t.Errorf("chunk %d offset = %d, want %d", i, meta.StartOffset, index.Meta[i].StartOffset)
+		}
+	}
+}
+
+func TestIndexDirUpdatesExternalIndexInPlace(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	indexDir := copyFixture(t, externalFixtureDir)
+	docsDir := filepath.Join(home, "docs")
+	if err := os.MkdirAll(docsDir, 0755); err != nil {
+		t.Fatalf("mkdir docs: %v", err)
+	}
+	newFile := filepath.Join(docsDir, "faq.md")
+	if err := os.WriteFile(newFile, []byte("Frequently asked questions"), 0644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	engine := NewEngine(fakeEmbeddingClient{})
+	config := DefaultConfig()
+	config.Model = "gemini-embedding-001"
+	config.Dimension = 2
+
+	result, err := engine.IndexDir([]string{docsDir}, nil, indexDir, config)
+	if err != nil {
+		t.Fatalf("IndexDir() error = %v", err)
+	}
+	if result.NewFiles != 1 || result.TotalChunks != 4 {
+		t.Fatalf("new=%d chunks=%d, want 1/4", result.NewFiles, result.TotalChunks)
+	}
+
+	raw, err := os.ReadFile(filepath.Join(indexDir, indexFileName))
+	if err != nil {
+		t.Fatalf("read index: %v", err)
+	}
+	for _, key := range []string{`"filePath"`, `"chunkIndex"`, `"pageLabel"`, `"fileChecksums"`} {
+		if !strings.Contains(string(raw), key) {
+			t.Fatalf("updated index lost camelCase key %s", key)
+		}
+	}
+	if strings.Contains(string(raw), `"file_path"`) {
+		t.Fatal("updated index contains snake_case keys")
+	}
+
+	index, _, err := LoadIndexFromDir(indexDir)
+	if err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	if index.EmbeddingModel != "models/gemini-embedding-001" {
+		t.Fatalf("embedding model = %q, want original spelling", index.EmbeddingModel)
+	}
+	if _, ok := index.FileChecksums["manual.pdf"]; !ok {
+		t.Fatal("existing external entries were dropped")
+	}
+}
+
+func TestIndexDirCreatesExternalIndex(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	docsDir := filepath.Join(home, "docs")
+	if err := os.MkdirAll(docsDir, 0755); err != nil {
+		t.Fatalf("mkdir docs: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(docsDir, "a.md"), []byte("alpha"), 0644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	indexDir := filepath.Join(home, "shared-index")
+	engine := NewEngine(fakeEmbeddingClient{})
+	config := DefaultConfig()
+	config.Dimension = 4
+	config.IndexFormat = FormatExternal
+
+	if _, err := engine.IndexDir([]string{docsDir}, nil, indexDir, config); err != nil {
+		t.Fatalf("IndexDir() error = %v", err)
+	}
+	m := readJSONMap(t, filepath.Join(indexDir, indexFileName))
+	if _, ok := m["embeddingModel"]; !ok {
+		t.Fatalf("new index is not camelCase: %v", m)
+	}
+	if _, ok := m["format_version"]; ok {
+		t.Fatal("external index should not carry format_version")
+	}
+}
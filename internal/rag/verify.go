@@ -0,0 +1,389 @@
+package rag
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/takeshy/ragujuary/internal/embedding"
+	"github.com/takeshy/ragujuary/internal/fileutil"
+)
+
+// Issue kinds reported by Verify
+const (
+	IssueVectorCount    = "vector_count"     // vectors.bin size doesn't match meta × dimension
+	IssueNaNVector      = "nan_vector"       // vector contains NaN or Inf
+	IssueZeroVector     = "zero_vector"      // vector is all zeros
+	IssueUnnormalized   = "unnormalized"     // vector norm is not 1
+	IssueDuplicateChunk = "duplicate_chunk"  // same file, offset, label and text stored twice
+	IssueOrphanChecksum = "orphan_checksum"  // FileChecksums entry with no chunks
+	IssueNoChecksum     = "missing_checksum" // chunks whose file has no FileChecksums entry
+	IssueMissingFile    = "missing_file"     // file recorded in FileChecksums no longer exists
+	IssueStaleFile      = "stale_file"       // file on disk differs from its recorded checksum
+	IssueModelMismatch  = "model_mismatch"   // configured model differs from the store's model
+	IssueModelDrift     = "model_drift"      // re-embedding a sample chunk disagrees with the stored vector
+	IssueDimensionDrift = "dimension_drift"  // the model now returns a different dimension
+	IssueSampleFailed   = "sample_failed"    // the sample chunk could not be embedded
+)
+
+// warningKinds are reported but don't make a store fail verification.
+// Gemini only normalizes 3072-dimensional output, so truncated vectors are
+// legitimately non-unit; cosine similarity doesn't depend on the norm.
+var warningKinds = map[string]bool{
+	IssueUnnormalized: true,
+}
+
+// normTolerance is how far a vector's L2 norm may deviate from 1
+const normTolerance = 1e-3
+
+// VerifyIssue describes a single problem found in a store
+type VerifyIssue struct {
+	Kind     string `json:"kind"`
+	FilePath string `json:"file_path,omitempty"`
+	Chunk    int    `json:"chunk"` // index into Meta, -1 if not chunk-specific
+	Detail   string `json:"detail"`
+	Fixable  bool   `json:"fixable"`
+	Fixed    bool   `json:"fixed,omitempty"`
+	Warning  bool   `json:"warning,omitempty"`
+}
+
+// VerifyOptions controls which checks Verify performs
+type VerifyOptions struct {
+	CheckFiles  bool // compare FileChecksums against files on disk
+	SampleEmbed bool // re-embed a sample chunk to detect model/dimension drift
+	Fix         bool // repair problems that can be fixed without re-embedding
+}
+
+// VerifyReport is the result of a store verification
+type VerifyReport struct {
+	Chunks    int           `json:"chunks"`
+	Files     int           `json:"files"`
+	Dimension int           `json:"dimension"`
+	Model     string        `json:"model"`
+	Issues    []VerifyIssue `json:"issues"`
+	Fixed     int           `json:"fixed"`
+}
+
+// Unresolved returns the number of non-warning issues that were not fixed
+func (r *VerifyReport) Unresolved() int {
+	n := 0
+	for _, issue := range r.Issues {
+		if !issue.Fixed && !issue.Warning {
+			n++
+		}
+	}
+	return n
+}
+
+func (r *VerifyReport) add(kind, filePath string, chunk int, fixable bool, format string, args ...interface{}) {
+	r.Issues = append(r.Issues, VerifyIssue{
+		Kind:     kind,
+		FilePath: filePath,
+		Chunk:    chunk,
+		Detail:   fmt.Sprintf(format, args...),
+		Fixable:  fixable,
+		Warning:  warningKinds[kind],
+	})
+}
+
+// Verify checks the integrity of an embedding store
+func (e *Engine) Verify(storeName string, config Config, opts VerifyOptions) (*VerifyReport, error) {
+	dir, err := storeDir(storeName)
+	if err != nil {
+		return nil, err
+	}
+	return e.VerifyDir(dir, config, opts)
+}
+
+// VerifyDir checks the integrity of a RAG index in an arbitrary directory.
+// With opts.Fix, safe repairs are written back: bad vectors and duplicate
+// chunks are dropped (clearing the file's checksum so the next index run
+// re-embeds it), non-normalized vectors are renormalized, and checksum
+// entries without chunks are removed.
+func (e *Engine) VerifyDir(dir string, config Config, opts VerifyOptions) (*VerifyReport, error) {
+	if opts.Fix {
+		lock, err := lockStore(dir, "embed verify --fix")
+		if err != nil {
+			return nil, err
+		}
+		defer lock.unlock()
+	}
+	index, vectors, err := loadIndexUnchecked(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load index: %w", err)
+	}
+	if index == nil {
+		return nil, fmt.Errorf("no index found in '%s'", dir)
+	}
+
+	report := &VerifyReport{
+		Chunks:    len(index.Meta),
+		Files:     len(index.FileChecksums),
+		Dimension: index.Dimension,
+		Model:     index.EmbeddingModel,
+	}
+	dim := index.Dimension
+
+	// Vector count: nothing else about the vectors can be trusted if this is off
+	vectorsOK := len(vectors) == len(index.Meta)*dim
+	if !vectorsOK {
+		report.add(IssueVectorCount, "", -1, false, "vectors.bin holds %d floats, expected %d (%d chunks × %d dimensions)",
+			len(vectors), len(index.Meta)*dim, len(index.Meta), dim)
+	}
+
+	drop := make(map[int]bool)
+	renormalize := make(map[int]bool)
+
+	if vectorsOK && dim > 0 {
+		for i, meta := range index.Meta {
+			vec := vectors[i*dim : (i+1)*dim]
+			var sumSq float64
+			bad := false
+			for _, v := range vec {
+				f := float64(v)
+				if math.IsNaN(f) || math.IsInf(f, 0) {
+					bad = true
+					break
+				}
+				sumSq += f * f
+			}
+			switch {
+			case bad:
+				report.add(IssueNaNVector, meta.FilePath, i, true, "vector contains NaN or Inf")
+				drop[i] = true
+			case sumSq == 0:
+				report.add(IssueZeroVector, meta.FilePath, i, true, "vector is all zeros")
+				drop[i] = true
+			case math.Abs(math.Sqrt(sumSq)-1) > normTolerance:
+				report.add(IssueUnnormalized, meta.FilePath, i, true, "vector norm is %.4f", math.Sqrt(sumSq))
+				renormalize[i] = true
+			}
+		}
+	}
+
+	// Duplicate chunks
+	type chunkKey struct {
+		path, label, text string
+		offset            int
+	}
+	seen := make(map[chunkKey]int)
+	for i, meta := range index.Meta {
+		key := chunkKey{meta.FilePath, meta.PageLabel, meta.Text, meta.StartOffset}
+		if first, ok := seen[key]; ok {
+			report.add(IssueDuplicateChunk, meta.FilePath, i, vectorsOK, "duplicate of chunk %d", first)
+			if vectorsOK {
+				drop[i] = true
+			}
+			continue
+		}
+		seen[key] = i
+	}
+
+	// Checksums vs chunks
+	chunkFiles := make(map[string]int)
+	for _, meta := range index.Meta {
+		chunkFiles[meta.FilePath]++
+	}
+	for _, path := range sortedKeys(index.FileChecksums) {
+		if chunkFiles[path] == 0 {
+			report.add(IssueOrphanChecksum, path, -1, true, "checksum recorded but no chunks indexed")
+		}
+	}
+	for _, path := range sortedKeys(chunkFiles) {
+		if _, ok := index.FileChecksums[path]; !ok {
+			report.add(IssueNoChecksum, path, -1, false, "%d chunk(s) have no checksum entry and will never be refreshed", chunkFiles[path])
+		}
+	}
+
+	// Checksums vs files on disk
+	if opts.CheckFiles {
+		for _, path := range sortedKeys(index.FileChecksums) {
+			recorded := index.FileChecksums[path]
+			if isPseudoChecksum(recorded) {
+				continue
+			}
+			current, err := fileutil.CalculateChecksum(index.ResolvePath(path))
+			if err != nil {
+				if _, statErr := fileutil.Stat(index.ResolvePath(path)); os.IsNotExist(statErr) {
+					report.add(IssueMissingFile, path, -1, false, "file no longer exists on disk")
+				} else {
+					report.add(IssueMissingFile, path, -1, false, "cannot read file: %v", err)
+				}
+				continue
+			}
+			if current != recorded {
+				report.add(IssueStaleFile, path, -1, false, "file changed since it was indexed (run embed index to refresh)")
+			}
+		}
+	}
+
+	// Model / dimension drift
+	if config.Model != "" && index.EmbeddingModel != "" && !sameModel(index.EmbeddingModel, config.Model) {
+		report.add(IssueModelMismatch, "", -1, false, "store was built with %s but %s is configured; indexing refuses to add to it (use 'embed migrate' to re-embed it with the configured model)",
+			index.EmbeddingModel, config.Model)
+	}
+	if opts.SampleEmbed && vectorsOK && len(index.Meta) > 0 {
+		e.verifySample(index, vectors, drop, report)
+	}
+
+	if opts.Fix {
+		if err := applyVerifyFixes(dir, index, vectors, drop, renormalize, report); err != nil {
+			return report, err
+		}
+	}
+
+	return report, nil
+}
+
+// verifySample re-embeds one text chunk with the store's model and compares
+// it to the stored vector. When the source file is unchanged on disk the exact
+// embedding text is reconstructed; otherwise a close approximation is used.
+func (e *Engine) verifySample(index *RagIndex, vectors []float32, drop map[int]bool, report *VerifyReport) {
+	model := strings.TrimPrefix(index.EmbeddingModel, "models/")
+	dim := index.Dimension
+
+	sample, exact := -1, false
+	var sampleText string
+	for i, meta := range index.Meta {
+		if meta.ContentType != "" || drop[i] {
+			continue
+		}
+		if sample < 0 {
+			sample = i
+			sampleText = buildEmbeddingText(meta.FilePath, "", Chunk{Text: meta.Text, StartOffset: meta.StartOffset})
+		}
+		content := unchangedSourceText(index, meta.FilePath, Config{Encoding: meta.Encoding})
+		if content == "" {
+			continue
+		}
+		sample, exact = i, true
+		sampleText = buildEmbeddingText(meta.FilePath, content, Chunk{Text: meta.Text, StartOffset: meta.StartOffset})
+		break
+	}
+	if sample < 0 {
+		return
+	}
+
+	meta := index.Meta[sample]
+	sampleText = index.PromptTemplate.Apply(embedding.TaskRetrievalDocument, sampleText)
+	vec, err := e.embeddingClient.EmbedContent(model, sampleText, embedding.TaskRetrievalDocument, dim)
+	if err != nil {
+		report.add(IssueSampleFailed, meta.FilePath, sample, false, "failed to embed sample chunk with %s: %v", model, err)
+		return
+	}
+	if len(vec) != dim {
+		report.add(IssueDimensionDrift, meta.FilePath, sample, false, "%s returned %d dimensions, store has %d", model, len(vec), dim)
+		return
+	}
+
+	threshold := 0.98
+	if !exact {
+		threshold = 0.85
+	}
+	if sim := cosineSimilarity(vec, vectors[sample*dim:(sample+1)*dim]); sim < threshold {
+		report.add(IssueModelDrift, meta.FilePath, sample, false,
+			"re-embedded sample has similarity %.4f to the stored vector (threshold %.2f); the model behind %s may have changed",
+			sim, threshold, model)
+	}
+}
+
+// applyVerifyFixes rewrites the index with safe repairs and marks issues as fixed
+func applyVerifyFixes(dir string, index *RagIndex, vectors []float32, drop, renormalize map[int]bool, report *VerifyReport) error {
+	dim := index.Dimension
+	changed := false
+
+	// Files losing chunks must be re-embedded on the next run
+	staleFiles := make(map[string]bool)
+	for i := range drop {
+		staleFiles[index.Meta[i].FilePath] = true
+	}
+
+	if len(drop) > 0 || len(renormalize) > 0 {
+		var newMeta []ChunkMeta
+		var newVectors []float32
+		for i, meta := range index.Meta {
+			if drop[i] {
+				continue
+			}
+			vec := vectors[i*dim : (i+1)*dim]
+			if renormalize[i] {
+				vec = normalized(vec)
+			}
+			newMeta = append(newMeta, meta)
+			newVectors = append(newVectors, vec...)
+		}
+		index.Meta = newMeta
+		vectors = newVectors
+		changed = true
+	}
+
+	remaining := make(map[string]bool)
+	for _, meta := range index.Meta {
+		remaining[meta.FilePath] = true
+	}
+	for path := range index.FileChecksums {
+		// Duplicates only lose the extra copy, so their checksum stays valid
+		if (staleFiles[path] && hasDroppedBadVector(report, path)) || !remaining[path] {
+			delete(index.FileChecksums, path)
+			changed = true
+		}
+	}
+
+	for i := range report.Issues {
+		if report.Issues[i].Fixable {
+			report.Issues[i].Fixed = true
+			report.Fixed++
+		}
+	}
+
+	if !changed {
+		return nil
+	}
+	if index.Meta == nil {
+		index.Meta = []ChunkMeta{}
+	}
+	return saveIndexToDir(dir, index, vectors)
+}
+
+func hasDroppedBadVector(report *VerifyReport, path string) bool {
+	for _, issue := range report.Issues {
+		if issue.FilePath == path && (issue.Kind == IssueNaNVector || issue.Kind == IssueZeroVector) {
+			return true
+		}
+	}
+	return false
+}
+
+// normalized returns a unit-length copy of vec
+func normalized(vec []float32) []float32 {
+	var sumSq float64
+	for _, v := range vec {
+		sumSq += float64(v) * float64(v)
+	}
+	out := make([]float32, len(vec))
+	norm := math.Sqrt(sumSq)
+	if norm == 0 {
+		return out
+	}
+	for i, v := range vec {
+		out[i] = float32(float64(v) / norm)
+	}
+	return out
+}
+
+// isPseudoChecksum reports whether a checksum was recorded for content
+// uploaded through MCP rather than computed from a file on disk
+func isPseudoChecksum(checksum string) bool {
+	return strings.HasPrefix(checksum, "content:") || strings.HasPrefix(checksum, "multimodal:")
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
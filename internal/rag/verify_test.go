@@ -0,0 +1,189 @@
+package rag
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/takeshy/ragujuary/internal/embedding"
+)
+
+func issueKinds(report *VerifyReport) map[string]int {
+	kinds := make(map[string]int)
+	for _, issue := range report.Issues {
+		kinds[issue.Kind]++
+	}
+	return kinds
+}
+
+func TestVerifyDetectsAndFixesProblems(t *testing.T) {
+	dir := t.TempDir()
+	docPath := filepath.Join(dir, "doc.md")
+	if err := os.WriteFile(docPath, []byte("hello world"), 0644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	nan := float32(math.NaN())
+	index := &RagIndex{
+		Meta: []ChunkMeta{
+			{FilePath: docPath, Text: "hello", StartOffset: 0},
+			{FilePath: docPath, Text: "world", StartOffset: 6},
+			{FilePath: docPath, Text: "world", StartOffset: 6}, // duplicate
+			{FilePath: "/gone/bad.md", Text: "nan", StartOffset: 0},
+			{FilePath: "/gone/zero.md", Text: "zero", StartOffset: 0},
+		},
+		Dimension:      2,
+		EmbeddingModel: "test-model",
+		FileChecksums: map[string]string{
+			docPath:         "not-the-real-checksum",
+			"/gone/bad.md":  "x",
+			"/gone/zero.md": "y",
+			"/gone/orphan":  "z",
+		},
+	}
+	vectors := []float32{
+		1, 0,
+		3, 4, // norm 5
+		0, 1,
+		nan, 1,
+		0, 0,
+	}
+	storeDir := filepath.Join(dir, "store")
+	if err := saveIndexToDir(storeDir, index, vectors); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+
+	engine := NewEngine(nil)
+	report, err := engine.VerifyDir(storeDir, Config{}, VerifyOptions{CheckFiles: true})
+	if err != nil {
+		t.Fatalf("VerifyDir() error = %v", err)
+	}
+	kinds := issueKinds(report)
+	want := map[string]int{
+		IssueNaNVector:      1,
+		IssueZeroVector:     1,
+		IssueUnnormalized:   1,
+		IssueDuplicateChunk: 1,
+		IssueOrphanChecksum: 1,
+		IssueStaleFile:      1,
+		IssueMissingFile:    3,
+	}
+	for kind, n := range want {
+		if kinds[kind] != n {
+			t.Fatalf("%s issues = %d, want %d (all: %v)", kind, kinds[kind], n, kinds)
+		}
+	}
+	if report.Unresolved() == 0 {
+		t.Fatal("Unresolved() = 0 for a broken store")
+	}
+
+	report, err = engine.VerifyDir(storeDir, Config{}, VerifyOptions{Fix: true})
+	if err != nil {
+		t.Fatalf("VerifyDir(fix) error = %v", err)
+	}
+	if report.Unresolved() != 0 {
+		t.Fatalf("Unresolved() after fix = %d: %+v", report.Unresolved(), report.Issues)
+	}
+
+	fixed, fixedVectors, err := LoadIndexFromDir(storeDir)
+	if err != nil {
+		t.Fatalf("load fixed index: %v", err)
+	}
+	if len(fixed.Meta) != 2 || len(fixedVectors) != 4 {
+		t.Fatalf("fixed index has %d chunks / %d floats, want 2/4", len(fixed.Meta), len(fixedVectors))
+	}
+	if fixedVectors[2] != 0.6 || fixedVectors[3] != 0.8 {
+		t.Fatalf("vector not renormalized: %v", fixedVectors[2:])
+	}
+	if _, ok := fixed.FileChecksums[docPath]; !ok {
+		t.Fatal("duplicate removal should keep the file's checksum")
+	}
+	for _, path := range []string{"/gone/bad.md", "/gone/zero.md", "/gone/orphan"} {
+		if _, ok := fixed.FileChecksums[path]; ok {
+			t.Fatalf("checksum for %s should have been removed", path)
+		}
+	}
+
+	report, err = engine.VerifyDir(storeDir, Config{}, VerifyOptions{})
+	if err != nil {
+		t.Fatalf("re-verify: %v", err)
+	}
+	if len(report.Issues) != 0 {
+		t.Fatalf("issues after fix: %+v", report.Issues)
+	}
+}
+
+func TestVerifyVectorCountMismatch(t *testing.T) {
+	dir := t.TempDir()
+	index := &RagIndex{
+		Meta:          []ChunkMeta{{FilePath: "a.md", Text: "a"}, {FilePath: "a.md", Text: "b", StartOffset: 1}},
+		Dimension:     2,
+		FileChecksums: map[string]string{"a.md": "content:a.md"},
+	}
+	if err := saveIndexToDir(dir, index, []float32{1, 0}); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	if _, _, err := LoadIndexFromDir(dir); err == nil {
+		t.Fatal("LoadIndexFromDir() accepted mismatched vectors")
+	}
+
+	report, err := NewEngine(nil).VerifyDir(dir, Config{}, VerifyOptions{CheckFiles: true, Fix: true})
+	if err != nil {
+		t.Fatalf("VerifyDir() error = %v", err)
+	}
+	kinds := issueKinds(report)
+	if kinds[IssueVectorCount] != 1 || report.Unresolved() != 1 {
+		t.Fatalf("issues = %+v, want one unresolved vector_count", report.Issues)
+	}
+}
+
+func TestVerifySampleDetectsModelDrift(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	docsDir := filepath.Join(home, "docs")
+	if err := os.MkdirAll(docsDir, 0755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(docsDir, "a.md"), []byte("alpha beta gamma"), 0644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	config := DefaultConfig()
+	config.Dimension = 4
+	if _, err := NewEngine(fakeEmbeddingClient{}).Index([]string{docsDir}, nil, "verify-store", config); err != nil {
+		t.Fatalf("index: %v", err)
+	}
+
+	report, err := NewEngine(fakeEmbeddingClient{}).Verify("verify-store", config, VerifyOptions{CheckFiles: true, SampleEmbed: true})
+	if err != nil {
+		t.Fatalf("Verify() error = %v", err)
+	}
+	if report.Unresolved() != 0 {
+		t.Fatalf("unexpected issues with the same model: %+v", report.Issues)
+	}
+
+	report, err = NewEngine(driftingEmbeddingClient{}).Verify("verify-store", config, VerifyOptions{SampleEmbed: true})
+	if err != nil {
+		t.Fatalf("Verify() error = %v", err)
+	}
+	if issueKinds(report)[IssueModelDrift] != 1 {
+		t.Fatalf("issues = %+v, want model_drift", report.Issues)
+	}
+}
+
+// driftingEmbeddingClient returns vectors pointing away from fakeEmbeddingClient's
+type driftingEmbeddingClient struct {
+	fakeEmbeddingClient
+}
+
+func (d driftingEmbeddingClient) EmbedContent(model, text string, taskType embedding.TaskType, dimension int) ([]float32, error) {
+	vec := fakeVector(text, dimension)
+	for i := range vec {
+		if i%2 == 0 {
+			vec[i] = -vec[i]
+		}
+	}
+	return vec, nil
+}